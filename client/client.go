@@ -0,0 +1,119 @@
+// Package client 是 Runner Manager REST API 的 Go 客户端。
+// 类型与方法由 /api/openapi.json 对应的文档生成（generated.go），接口变化后运行 go generate ./client 重新生成。
+//
+//	c := client.New("http://localhost:8080")
+//	c.Token = "rfm_..."
+//	list, err := c.ListRunners(ctx, &client.ListRunnersParams{Status: "installed"})
+package client
+
+//go:generate go run ../cmd/openapi-gen -o generated.go
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Client 调用 Manager API；Token 与 Username/Password 均为空时不带认证
+type Client struct {
+	BaseURL    string // 如 http://localhost:8080，不含 /api
+	HTTPClient *http.Client
+	Token      string // API Token（rfm_...），以 Authorization: Bearer 发送
+	Username   string // Basic Auth，Token 为空时使用
+	Password   string
+}
+
+// New 创建客户端，使用 http.DefaultClient
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/"), HTTPClient: http.DefaultClient}
+}
+
+// Error 非 2xx 响应，Message 为响应中的 message
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("runner manager: %d %s", e.StatusCode, e.Message)
+}
+
+// do 发送请求：bodyMedia 为空时 body 编码为 JSON，否则 body 为该媒体类型的 []byte；
+// out 为 *[]byte 时写入原始响应，否则按 JSON 解码，为 nil 时丢弃响应
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, header http.Header, body any, bodyMedia string, out any) error {
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var r io.Reader
+	if body != nil {
+		if bodyMedia != "" {
+			r = bytes.NewReader(body.([]byte))
+		} else {
+			b, err := json.Marshal(body)
+			if err != nil {
+				return err
+			}
+			r = bytes.NewReader(b)
+			bodyMedia = "application/json"
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, r)
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if r != nil {
+		req.Header.Set("Content-Type", bodyMedia)
+	}
+	req.Header.Set("Accept", "application/json")
+	switch {
+	case c.Token != "":
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	case c.Username != "" || c.Password != "":
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		e := &Error{StatusCode: resp.StatusCode}
+		var msg struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &msg) == nil && msg.Message != "" {
+			e.Message = msg.Message
+		} else {
+			e.Message = strings.TrimSpace(string(data))
+		}
+		return e
+	}
+	switch v := out.(type) {
+	case nil:
+		return nil
+	case *[]byte:
+		*v = data
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("解析 %s %s 的响应失败: %w", method, path, err)
+	}
+	return nil
+}
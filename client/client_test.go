@@ -0,0 +1,104 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/lab-dev/github-actions-runner-manager/internal/handler"
+	"github.com/lab-dev/github-actions-runner-manager/internal/openapi"
+	"github.com/labstack/echo/v4"
+)
+
+func TestGeneratedUpToDate(t *testing.T) {
+	want, err := openapi.GenerateClient(handler.OpenAPIDocument(), "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile("generated.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("generated.go 与接口定义不一致，请运行 go generate ./client")
+	}
+}
+
+func TestClient(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	cfg := &config.Config{Runners: config.RunnersConfig{BasePath: dir, Items: []config.RunnerItem{
+		{Name: "r1", TargetType: "org", Target: "o1", Labels: []string{"linux"}},
+	}}}
+	if err := cfg.Save(cfgPath); err != nil {
+		t.Fatal(err)
+	}
+	prev := handler.ConfigPath
+	handler.ConfigPath = cfgPath
+	defer func() { handler.ConfigPath = prev }()
+
+	e := echo.New()
+	e.Use(handler.Auth("admin", "pw"))
+	handler.RegisterRoutes(e)
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+	ctx := context.Background()
+
+	var apiErr *Error
+	if _, err := New(srv.URL).ListRunners(ctx, nil); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("without credentials: %v", err)
+	}
+
+	c := New(srv.URL + "/")
+	c.Username, c.Password = "admin", "pw"
+	added, err := c.AddRunner(ctx, AddRunnerRequest{Name: "web", TargetType: "org", Target: "o1", Labels: []string{"gpu"}})
+	if err != nil || added.Name != "web" || added.InstallDir == "" {
+		t.Fatalf("AddRunner = %+v, %v", added, err)
+	}
+	probe := false
+	list, err := c.ListRunners(ctx, &ListRunnersParams{Label: "gpu", Probe: &probe})
+	if err != nil || list.TotalCount != 1 || list.Runners[0].Name != "web" || list.Probed {
+		t.Fatalf("ListRunners = %+v, %v", list, err)
+	}
+	info, err := c.GetRunner(ctx, "web")
+	if err != nil || info.ETag == "" {
+		t.Fatalf("GetRunner = %+v, %v", info, err)
+	}
+	if _, err := c.GetRunner(ctx, "nope"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Message == "" {
+		t.Fatalf("GetRunner(nope): %v", err)
+	}
+	upd := UpdateRunnerRequest{TargetType: "org", Target: "o1", Labels: []string{"gpu", "big"}}
+	if _, err := c.UpdateRunner(ctx, "web", upd, &UpdateRunnerParams{IfMatch: `"stale"`}); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("UpdateRunner with stale ETag: %v", err)
+	}
+	updated, err := c.UpdateRunner(ctx, "web", upd, &UpdateRunnerParams{IfMatch: info.ETag})
+	if err != nil || updated.Runner == nil || len(updated.Runner.Labels) != 2 {
+		t.Fatalf("UpdateRunner = %+v, %v", updated, err)
+	}
+
+	plan, err := c.PlanConfig(ctx, []byte("runners:\n  base_path: "+dir+"\n  items: []\n"))
+	if err != nil || !plan.Valid || len(plan.Actions) != 2 {
+		t.Fatalf("PlanConfig = %+v, %v", plan, err)
+	}
+	history, err := c.ListConfigHistory(ctx)
+	if err != nil || len(history.Revisions) == 0 {
+		t.Fatalf("ListConfigHistory = %+v, %v", history, err)
+	}
+	content, err := c.GetConfigRevision(ctx, "1")
+	if err != nil || !strings.Contains(string(content), "r1") {
+		t.Fatalf("GetConfigRevision = %q, %v", content, err)
+	}
+	if diff, err := c.DiffConfig(ctx, 1, nil); err != nil || !strings.Contains(diff.Diff, "web") {
+		t.Fatalf("DiffConfig = %+v, %v", diff, err)
+	}
+	if _, err := c.RemoveRunner(ctx, "web"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,966 @@
+// Code generated by openapi-gen; DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// APIToken 对应 components.schemas.APIToken
+type APIToken struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	Hash      string    `json:"hash,omitempty"`
+	Hint      string    `json:"hint"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AddRunnerRequest 对应 components.schemas.AddRunnerRequest
+type AddRunnerRequest struct {
+	Name              string   `json:"name,omitempty"`
+	Path              string   `json:"path,omitempty"`
+	TargetType        string   `json:"target_type,omitempty"`
+	Target            string   `json:"target,omitempty"`
+	Labels            []string `json:"labels"`
+	RegistrationToken string   `json:"registration_token,omitempty"`
+	RunnerVersion     string   `json:"runner_version,omitempty"`
+	Arch              string   `json:"arch,omitempty"`
+	Count             int      `json:"count,omitempty"`
+}
+
+// AddRunnerResponse 对应 components.schemas.AddRunnerResponse
+type AddRunnerResponse struct {
+	Message     string          `json:"message"`
+	Name        string          `json:"name,omitempty"`
+	InstallDir  string          `json:"install_dir,omitempty"`
+	Queued      bool            `json:"queued,omitempty"`
+	JobID       string          `json:"job_id,omitempty"`
+	Names       []string        `json:"names"`
+	Runners     []ReplicaResult `json:"runners"`
+	QueuedCount int             `json:"queued_count,omitempty"`
+}
+
+// ApplyGitOpsRequest 对应 components.schemas.ApplyGitOpsRequest
+type ApplyGitOpsRequest struct {
+	Commit string `json:"commit,omitempty"`
+}
+
+// AuditChange 对应 components.schemas.AuditChange
+type AuditChange struct {
+	Field  string `json:"field"`
+	Before any    `json:"before,omitempty"`
+	After  any    `json:"after,omitempty"`
+}
+
+// AuditEntry 对应 components.schemas.AuditEntry
+type AuditEntry struct {
+	Time      time.Time     `json:"time"`
+	Actor     string        `json:"actor"`
+	ActorKind string        `json:"actor_kind"`
+	SourceIP  string        `json:"source_ip"`
+	Action    string        `json:"action"`
+	Method    string        `json:"method"`
+	Path      string        `json:"path"`
+	Runner    string        `json:"runner,omitempty"`
+	Before    any           `json:"before,omitempty"`
+	After     any           `json:"after,omitempty"`
+	Changes   []AuditChange `json:"changes"`
+	Result    string        `json:"result"`
+	Status    int           `json:"status"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// AuditList 对应 components.schemas.AuditList
+type AuditList struct {
+	Entries []AuditEntry `json:"entries"`
+}
+
+// BulkRequest 对应 components.schemas.BulkRequest
+type BulkRequest struct {
+	Action              string       `json:"action,omitempty"`
+	Selector            BulkSelector `json:"selector,omitempty"`
+	Labels              []string     `json:"labels"`
+	AddLabels           []string     `json:"add_labels"`
+	RemoveLabels        []string     `json:"remove_labels"`
+	Concurrency         int          `json:"concurrency,omitempty"`
+	DrainTimeoutSeconds int          `json:"drain_timeout_seconds,omitempty"`
+}
+
+// BulkResponse 对应 components.schemas.BulkResponse
+type BulkResponse struct {
+	Action    string       `json:"action"`
+	Results   []BulkResult `json:"results"`
+	Succeeded int          `json:"succeeded"`
+	Skipped   int          `json:"skipped"`
+	Failed    int          `json:"failed"`
+}
+
+// BulkResult 对应 components.schemas.BulkResult
+type BulkResult struct {
+	Runner  string `json:"runner"`
+	Result  string `json:"result"`
+	Message string `json:"message,omitempty"`
+}
+
+// BulkSelector 对应 components.schemas.BulkSelector
+type BulkSelector struct {
+	Names  []string `json:"names"`
+	Label  string   `json:"label,omitempty"`
+	Target string   `json:"target,omitempty"`
+	Status string   `json:"status,omitempty"`
+}
+
+// CleanupPolicy 对应 components.schemas.CleanupPolicy
+type CleanupPolicy struct {
+	AfterJob            bool   `json:"after_job,omitempty"`
+	MaxWorkSizeMB       int64  `json:"max_work_size_mb,omitempty"`
+	MaxDiskUsagePercent int    `json:"max_disk_usage_percent,omitempty"`
+	Schedule            string `json:"schedule,omitempty"`
+}
+
+// CleanupResult 对应 components.schemas.CleanupResult
+type CleanupResult struct {
+	LastAt          string `json:"last_at"`
+	LastReason      string `json:"last_reason"`
+	LastBytesFreed  int64  `json:"last_bytes_freed"`
+	TotalBytesFreed int64  `json:"total_bytes_freed"`
+	Runs            int    `json:"runs"`
+	LastError       string `json:"last_error,omitempty"`
+}
+
+// ConfigDiff 对应 components.schemas.ConfigDiff
+type ConfigDiff struct {
+	From int    `json:"from"`
+	To   int    `json:"to"`
+	Diff string `json:"diff"`
+}
+
+// ConfigHistory 对应 components.schemas.ConfigHistory
+type ConfigHistory struct {
+	Revisions []ConfigRevision `json:"revisions"`
+}
+
+// ConfigPlan 对应 components.schemas.ConfigPlan
+type ConfigPlan struct {
+	Valid           bool           `json:"valid"`
+	Errors          []string       `json:"errors"`
+	Actions         []RunnerAction `json:"actions"`
+	Warnings        []string       `json:"warnings"`
+	Recreate        []string       `json:"recreate"`
+	Start           []string       `json:"start"`
+	Stop            []string       `json:"stop"`
+	Register        []string       `json:"register"`
+	StillRegistered []string       `json:"still_registered"`
+	KeptDirs        []string       `json:"kept_dirs"`
+}
+
+// ConfigRevision 对应 components.schemas.ConfigRevision
+type ConfigRevision struct {
+	Rev    int       `json:"rev"`
+	Time   time.Time `json:"time"`
+	Author string    `json:"author"`
+	Note   string    `json:"note,omitempty"`
+	SHA256 string    `json:"sha256"`
+}
+
+// CreateTokenRequest 对应 components.schemas.CreateTokenRequest
+type CreateTokenRequest struct {
+	Name   string   `json:"name,omitempty"`
+	Scopes []string `json:"scopes"`
+}
+
+// CreateTokenResponse 对应 components.schemas.CreateTokenResponse
+type CreateTokenResponse struct {
+	Token string    `json:"token"`
+	Info  *APIToken `json:"info"`
+}
+
+// ErrorResponse 对应 components.schemas.ErrorResponse
+type ErrorResponse struct {
+	Message string `json:"message"`
+}
+
+// FleetUpgradeRequest 对应 components.schemas.FleetUpgradeRequest
+type FleetUpgradeRequest struct {
+	Version              string   `json:"version,omitempty"`
+	Runners              []string `json:"runners"`
+	FailureBudget        int      `json:"failure_budget,omitempty"`
+	DrainTimeoutSeconds  int      `json:"drain_timeout_seconds,omitempty"`
+	OnlineTimeoutSeconds int      `json:"online_timeout_seconds,omitempty"`
+}
+
+// FleetUpgradeStatus 对应 components.schemas.FleetUpgradeStatus
+type FleetUpgradeStatus struct {
+	Upgrade *Rollout       `json:"upgrade"`
+	Counts  map[string]int `json:"counts"`
+}
+
+// GitOpsApplyResponse 对应 components.schemas.GitOpsApplyResponse
+type GitOpsApplyResponse struct {
+	Plan   *GitOpsPlan     `json:"plan"`
+	Result ReconcileResult `json:"result"`
+}
+
+// GitOpsChange 对应 components.schemas.GitOpsChange
+type GitOpsChange struct {
+	Action string      `json:"action"`
+	Runner string      `json:"runner"`
+	Fields []string    `json:"fields"`
+	Before *RunnerItem `json:"before,omitempty"`
+	After  *RunnerItem `json:"after,omitempty"`
+}
+
+// GitOpsPlan 对应 components.schemas.GitOpsPlan
+type GitOpsPlan struct {
+	Repo          string         `json:"repo"`
+	Branch        string         `json:"branch"`
+	File          string         `json:"file"`
+	Commit        string         `json:"commit,omitempty"`
+	State         string         `json:"state"`
+	Changes       []GitOpsChange `json:"changes"`
+	Desired       []RunnerItem   `json:"desired"`
+	Error         string         `json:"error,omitempty"`
+	CheckedAt     time.Time      `json:"checked_at"`
+	AppliedCommit string         `json:"applied_commit,omitempty"`
+	AppliedAt     *time.Time     `json:"applied_at,omitempty"`
+}
+
+// GitOpsStatus 对应 components.schemas.GitOpsStatus
+type GitOpsStatus struct {
+	Enabled bool        `json:"enabled"`
+	Plan    *GitOpsPlan `json:"plan"`
+}
+
+// GitOpsSyncResponse 对应 components.schemas.GitOpsSyncResponse
+type GitOpsSyncResponse struct {
+	Plan *GitOpsPlan `json:"plan"`
+}
+
+// HealthResponse 对应 components.schemas.HealthResponse
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+// JobRecord 对应 components.schemas.JobRecord
+type JobRecord struct {
+	ID              string    `json:"id"`
+	Runner          string    `json:"runner"`
+	Repository      string    `json:"repository"`
+	Workflow        string    `json:"workflow"`
+	Job             string    `json:"job"`
+	Conclusion      string    `json:"conclusion"`
+	StartedAt       time.Time `json:"started_at,omitzero"`
+	CompletedAt     time.Time `json:"completed_at,omitzero"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Source          string    `json:"source"`
+	DiagID          string    `json:"diag_id,omitempty"`
+}
+
+// MessageResponse 对应 components.schemas.MessageResponse
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// Principal 对应 components.schemas.Principal
+type Principal struct {
+	Name   string   `json:"name"`
+	Kind   string   `json:"kind"`
+	Role   string   `json:"role"`
+	Groups []string `json:"groups"`
+}
+
+// ProbeInfo 对应 components.schemas.ProbeInfo
+type ProbeInfo struct {
+	Error        string `json:"error"`
+	Type         string `json:"type"`
+	Suggestion   string `json:"suggestion"`
+	CheckCommand string `json:"check_command"`
+	FixCommand   string `json:"fix_command"`
+}
+
+// QueueFullResponse 对应 components.schemas.QueueFullResponse
+type QueueFullResponse struct {
+	Message string `json:"message"`
+	Name    string `json:"name"`
+}
+
+// ReconcileResult 对应 components.schemas.ReconcileResult
+type ReconcileResult struct {
+	Stopped   []string          `json:"stopped"`
+	Started   []string          `json:"started"`
+	Recreated []string          `json:"recreated"`
+	Updated   []string          `json:"updated"`
+	Errors    map[string]string `json:"errors,omitempty"`
+}
+
+// RegistrationJob 对应 components.schemas.RegistrationJob
+type RegistrationJob struct {
+	ID            string    `json:"id"`
+	Runner        string    `json:"runner"`
+	InstallDir    string    `json:"install_dir"`
+	URL           string    `json:"url"`
+	Labels        []string  `json:"labels"`
+	RunnerVersion string    `json:"runner_version,omitempty"`
+	State         string    `json:"state"`
+	Error         string    `json:"error,omitempty"`
+	InstallOutput string    `json:"install_output,omitempty"`
+	ConfigOutput  string    `json:"config_output,omitempty"`
+	RetryOf       string    `json:"retry_of,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	StartedAt     time.Time `json:"started_at,omitzero"`
+	FinishedAt    time.Time `json:"finished_at,omitzero"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// RegistrationJobList 对应 components.schemas.RegistrationJobList
+type RegistrationJobList struct {
+	Jobs       []*RegistrationJob `json:"jobs"`
+	TotalCount int                `json:"total_count"`
+}
+
+// RegistrationJobResponse 对应 components.schemas.RegistrationJobResponse
+type RegistrationJobResponse struct {
+	Message string           `json:"message"`
+	Job     *RegistrationJob `json:"job"`
+}
+
+// ReplicaResult 对应 components.schemas.ReplicaResult
+type ReplicaResult struct {
+	Name       string `json:"name"`
+	InstallDir string `json:"install_dir"`
+	Queued     bool   `json:"queued"`
+	JobID      string `json:"job_id,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// ResourceUsage 对应 components.schemas.ResourceUsage
+type ResourceUsage struct {
+	InstallDirBytes int64    `json:"install_dir_bytes"`
+	WorkDirBytes    int64    `json:"work_dir_bytes"`
+	CPUPercent      float64  `json:"cpu_percent"`
+	MemoryRSSBytes  int64    `json:"memory_rss_bytes"`
+	Warnings        []string `json:"warnings"`
+}
+
+// RetryRegistrationRequest 对应 components.schemas.RetryRegistrationRequest
+type RetryRegistrationRequest struct {
+	RegistrationToken string `json:"registration_token,omitempty"`
+}
+
+// RollbackResult 对应 components.schemas.RollbackResult
+type RollbackResult struct {
+	Revision  int               `json:"revision"`
+	Stopped   []string          `json:"stopped"`
+	Started   []string          `json:"started"`
+	Recreated []string          `json:"recreated"`
+	Updated   []string          `json:"updated"`
+	Errors    map[string]string `json:"errors,omitempty"`
+}
+
+// Rollout 对应 components.schemas.Rollout
+type Rollout struct {
+	ID                   string        `json:"id"`
+	Version              string        `json:"version"`
+	State                string        `json:"state"`
+	FailureBudget        int           `json:"failure_budget"`
+	Failures             int           `json:"failures"`
+	DrainTimeoutSeconds  int           `json:"drain_timeout_seconds"`
+	OnlineTimeoutSeconds int           `json:"online_timeout_seconds"`
+	Error                string        `json:"error,omitempty"`
+	Steps                []RolloutStep `json:"steps"`
+	CreatedAt            time.Time     `json:"created_at"`
+	UpdatedAt            time.Time     `json:"updated_at"`
+	FinishedAt           time.Time     `json:"finished_at,omitzero"`
+}
+
+// RolloutStep 对应 components.schemas.RolloutStep
+type RolloutStep struct {
+	Runner         string    `json:"runner"`
+	State          string    `json:"state"`
+	FromVersion    string    `json:"from_version,omitempty"`
+	Arch           string    `json:"arch,omitempty"`
+	Message        string    `json:"message,omitempty"`
+	RolledBack     bool      `json:"rolled_back,omitempty"`
+	JobInterrupted bool      `json:"job_interrupted,omitempty"`
+	StartedAt      time.Time `json:"started_at,omitzero"`
+	FinishedAt     time.Time `json:"finished_at,omitzero"`
+}
+
+// RunnerAction 对应 components.schemas.RunnerAction
+type RunnerAction struct {
+	Runner         string   `json:"runner"`
+	Action         string   `json:"action"`
+	Fields         []string `json:"fields"`
+	Runtime        []string `json:"runtime"`
+	InstallDir     string   `json:"install_dir"`
+	PrevInstallDir string   `json:"prev_install_dir,omitempty"`
+	Registered     bool     `json:"registered"`
+	Running        bool     `json:"running"`
+	Note           string   `json:"note,omitempty"`
+}
+
+// RunnerActionResponse 对应 components.schemas.RunnerActionResponse
+type RunnerActionResponse struct {
+	Message string     `json:"message"`
+	Probe   *ProbeInfo `json:"probe,omitempty"`
+}
+
+// RunnerInfo 对应 components.schemas.RunnerInfo
+type RunnerInfo struct {
+	Name                  string         `json:"name"`
+	Path                  string         `json:"path"`
+	TargetType            string         `json:"target_type"`
+	Target                string         `json:"target"`
+	Labels                []string       `json:"labels"`
+	Status                string         `json:"status"`
+	InstallDir            string         `json:"install_dir"`
+	Running               bool           `json:"running"`
+	Probe                 *ProbeInfo     `json:"probe,omitempty"`
+	JobDockerBackend      string         `json:"job_docker_backend"`
+	RegistrationSuccess   *bool          `json:"registration_success"`
+	RegistrationMessage   string         `json:"registration_message"`
+	RegistrationCheckedAt string         `json:"registration_checked_at"`
+	RegisteredOnGitHub    *bool          `json:"registered_on_github"`
+	GitHubOnline          *bool          `json:"github_online"`
+	GitHubBusy            *bool          `json:"github_busy"`
+	GitHubCheckAt         string         `json:"github_check_at"`
+	Cleanup               *CleanupResult `json:"cleanup,omitempty"`
+	Usage                 *ResourceUsage `json:"usage,omitempty"`
+	RunnerVersion         string         `json:"runner_version,omitempty"`
+	Arch                  string         `json:"arch,omitempty"`
+	ETag                  string         `json:"etag"`
+}
+
+// RunnerItem 对应 components.schemas.RunnerItem
+type RunnerItem struct {
+	Name          string         `json:"name"`
+	Path          string         `json:"path"`
+	TargetType    string         `json:"target_type"`
+	Target        string         `json:"target"`
+	Labels        []string       `json:"labels"`
+	RunnerVersion string         `json:"runner_version,omitempty"`
+	Arch          string         `json:"arch,omitempty"`
+	Cleanup       *CleanupPolicy `json:"cleanup,omitempty"`
+}
+
+// RunnerJobList 对应 components.schemas.RunnerJobList
+type RunnerJobList struct {
+	Jobs       []JobRecord `json:"jobs"`
+	TotalCount int         `json:"total_count"`
+	Page       int         `json:"page"`
+	PerPage    int         `json:"per_page"`
+}
+
+// RunnerList 对应 components.schemas.RunnerList
+type RunnerList struct {
+	Probed     bool         `json:"probed"`
+	CachedAt   time.Time    `json:"cached_at,omitzero"`
+	Runners    []RunnerInfo `json:"runners"`
+	TotalCount int          `json:"total_count"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
+// TokenList 对应 components.schemas.TokenList
+type TokenList struct {
+	Tokens []APIToken `json:"tokens"`
+}
+
+// UpdateRunnerRequest 对应 components.schemas.UpdateRunnerRequest
+type UpdateRunnerRequest struct {
+	Name          string   `json:"name,omitempty"`
+	Path          string   `json:"path,omitempty"`
+	TargetType    string   `json:"target_type,omitempty"`
+	Target        string   `json:"target,omitempty"`
+	Labels        []string `json:"labels"`
+	RunnerVersion *string  `json:"runner_version,omitempty"`
+	Arch          *string  `json:"arch,omitempty"`
+}
+
+// UpdateRunnerResponse 对应 components.schemas.UpdateRunnerResponse
+type UpdateRunnerResponse struct {
+	Message string      `json:"message"`
+	Runner  *RunnerInfo `json:"runner"`
+	Started bool        `json:"started"`
+}
+
+// VersionResponse 对应 components.schemas.VersionResponse
+type VersionResponse struct {
+	Version string `json:"version"`
+}
+
+// WebhookResponse 对应 components.schemas.WebhookResponse
+type WebhookResponse struct {
+	Message string `json:"message"`
+	ID      string `json:"id,omitempty"`
+}
+
+// Health 调用 GET /health：健康检查
+func (c *Client) Health(ctx context.Context) (*HealthResponse, error) {
+	query, header := url.Values{}, http.Header{}
+	var out HealthResponse
+	if err := c.do(ctx, "GET", "/health", query, header, nil, "", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Version 调用 GET /version：版本号
+func (c *Client) Version(ctx context.Context) (*VersionResponse, error) {
+	query, header := url.Values{}, http.Header{}
+	var out VersionResponse
+	if err := c.do(ctx, "GET", "/version", query, header, nil, "", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Metrics 调用 GET /metrics：Prometheus 指标
+func (c *Client) Metrics(ctx context.Context) ([]byte, error) {
+	query, header := url.Values{}, http.Header{}
+	var out []byte
+	err := c.do(ctx, "GET", "/metrics", query, header, nil, "", &out)
+	return out, err
+}
+
+// Me 调用 GET /api/me：当前调用者
+func (c *Client) Me(ctx context.Context) (*Principal, error) {
+	query, header := url.Values{}, http.Header{}
+	var out Principal
+	if err := c.do(ctx, "GET", "/api/me", query, header, nil, "", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListRunnersParams ListRunners 的可选参数，零值表示不传
+type ListRunnersParams struct {
+	// 逗号分隔的状态，任一匹配
+	Status string
+	// 是否在运行
+	Running *bool
+	// target 通配，如 myorg/*
+	Target string
+	// 逗号分隔的 label，须全部包含
+	Label string
+	// true、false 或 unknown（尚未检查）
+	RegisteredOnGitHub string
+	// 逗号分隔的探测失败类型，any 为任意失败，none 为无失败
+	ProbeError string
+	// name、status 或 target，前缀 - 倒序；默认配置顺序
+	Sort string
+	// 每页条数，1-500；省略时不分页
+	Limit int
+	// 上一页返回的 next_cursor，须与 sort 一致
+	Cursor string
+	// false 时不实时探测容器，返回最近一次的缓存状态；默认 true
+	Probe *bool
+}
+
+// ListRunners 调用 GET /api/runners：列出 runner
+func (c *Client) ListRunners(ctx context.Context, params *ListRunnersParams) (*RunnerList, error) {
+	query, header := url.Values{}, http.Header{}
+	if params != nil {
+		if params.Status != "" {
+			query.Set("status", params.Status)
+		}
+		if params.Running != nil {
+			query.Set("running", strconv.FormatBool(*params.Running))
+		}
+		if params.Target != "" {
+			query.Set("target", params.Target)
+		}
+		if params.Label != "" {
+			query.Set("label", params.Label)
+		}
+		if params.RegisteredOnGitHub != "" {
+			query.Set("registered_on_github", params.RegisteredOnGitHub)
+		}
+		if params.ProbeError != "" {
+			query.Set("probe_error", params.ProbeError)
+		}
+		if params.Sort != "" {
+			query.Set("sort", params.Sort)
+		}
+		if params.Limit != 0 {
+			query.Set("limit", strconv.Itoa(params.Limit))
+		}
+		if params.Cursor != "" {
+			query.Set("cursor", params.Cursor)
+		}
+		if params.Probe != nil {
+			query.Set("probe", strconv.FormatBool(*params.Probe))
+		}
+	}
+	var out RunnerList
+	if err := c.do(ctx, "GET", "/api/runners", query, header, nil, "", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AddRunner 调用 POST /api/runners：添加 runner
+func (c *Client) AddRunner(ctx context.Context, body AddRunnerRequest) (*AddRunnerResponse, error) {
+	query, header := url.Values{}, http.Header{}
+	var out AddRunnerResponse
+	if err := c.do(ctx, "POST", "/api/runners", query, header, body, "", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// BulkRunners 调用 POST /api/runners/bulk：批量操作 runner
+func (c *Client) BulkRunners(ctx context.Context, body BulkRequest) (*BulkResponse, error) {
+	query, header := url.Values{}, http.Header{}
+	var out BulkResponse
+	if err := c.do(ctx, "POST", "/api/runners/bulk", query, header, body, "", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetRunner 调用 GET /api/runners/{name}：查看 runner
+func (c *Client) GetRunner(ctx context.Context, name string) (*RunnerInfo, error) {
+	query, header := url.Values{}, http.Header{}
+	var out RunnerInfo
+	if err := c.do(ctx, "GET", "/api/runners/"+url.PathEscape(name), query, header, nil, "", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateRunnerParams UpdateRunner 的可选参数，零值表示不传
+type UpdateRunnerParams struct {
+	// GET 返回的 ETag，不一致时返回 412
+	IfMatch string
+}
+
+// UpdateRunner 调用 PUT /api/runners/{name}：更新 runner 配置
+func (c *Client) UpdateRunner(ctx context.Context, name string, body UpdateRunnerRequest, params *UpdateRunnerParams) (*UpdateRunnerResponse, error) {
+	query, header := url.Values{}, http.Header{}
+	if params != nil {
+		if params.IfMatch != "" {
+			header.Set("If-Match", params.IfMatch)
+		}
+	}
+	var out UpdateRunnerResponse
+	if err := c.do(ctx, "PUT", "/api/runners/"+url.PathEscape(name), query, header, body, "", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RemoveRunner 调用 DELETE /api/runners/{name}：移除 runner
+func (c *Client) RemoveRunner(ctx context.Context, name string) (*MessageResponse, error) {
+	query, header := url.Values{}, http.Header{}
+	var out MessageResponse
+	if err := c.do(ctx, "DELETE", "/api/runners/"+url.PathEscape(name), query, header, nil, "", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// StartRunner 调用 POST /api/runners/{name}/start：启动 runner
+func (c *Client) StartRunner(ctx context.Context, name string) (*RunnerActionResponse, error) {
+	query, header := url.Values{}, http.Header{}
+	var out RunnerActionResponse
+	if err := c.do(ctx, "POST", "/api/runners/"+url.PathEscape(name)+"/start", query, header, nil, "", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// StopRunner 调用 POST /api/runners/{name}/stop：停止 runner
+func (c *Client) StopRunner(ctx context.Context, name string) (*RunnerActionResponse, error) {
+	query, header := url.Values{}, http.Header{}
+	var out RunnerActionResponse
+	if err := c.do(ctx, "POST", "/api/runners/"+url.PathEscape(name)+"/stop", query, header, nil, "", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListRunnerJobsParams ListRunnerJobs 的可选参数，零值表示不传
+type ListRunnerJobsParams struct {
+	// 页码，默认 1
+	Page int
+	// 每页条数，1-100，默认 30
+	PerPage int
+}
+
+// ListRunnerJobs 调用 GET /api/runners/{name}/jobs：runner 的 Job 历史
+func (c *Client) ListRunnerJobs(ctx context.Context, name string, params *ListRunnerJobsParams) (*RunnerJobList, error) {
+	query, header := url.Values{}, http.Header{}
+	if params != nil {
+		if params.Page != 0 {
+			query.Set("page", strconv.Itoa(params.Page))
+		}
+		if params.PerPage != 0 {
+			query.Set("per_page", strconv.Itoa(params.PerPage))
+		}
+	}
+	var out RunnerJobList
+	if err := c.do(ctx, "GET", "/api/runners/"+url.PathEscape(name)+"/jobs", query, header, nil, "", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListRegistrationJobsParams ListRegistrationJobs 的可选参数，零值表示不传
+type ListRegistrationJobsParams struct {
+	// runner 名称
+	Runner string
+	// 任务状态
+	State string
+}
+
+// ListRegistrationJobs 调用 GET /api/jobs：列出注册任务
+func (c *Client) ListRegistrationJobs(ctx context.Context, params *ListRegistrationJobsParams) (*RegistrationJobList, error) {
+	query, header := url.Values{}, http.Header{}
+	if params != nil {
+		if params.Runner != "" {
+			query.Set("runner", params.Runner)
+		}
+		if params.State != "" {
+			query.Set("state", params.State)
+		}
+	}
+	var out RegistrationJobList
+	if err := c.do(ctx, "GET", "/api/jobs", query, header, nil, "", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetRegistrationJob 调用 GET /api/jobs/{id}：查看注册任务
+func (c *Client) GetRegistrationJob(ctx context.Context, id string) (*RegistrationJob, error) {
+	query, header := url.Values{}, http.Header{}
+	var out RegistrationJob
+	if err := c.do(ctx, "GET", "/api/jobs/"+url.PathEscape(id), query, header, nil, "", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CancelRegistrationJob 调用 POST /api/jobs/{id}/cancel：取消注册任务
+func (c *Client) CancelRegistrationJob(ctx context.Context, id string) (*RegistrationJobResponse, error) {
+	query, header := url.Values{}, http.Header{}
+	var out RegistrationJobResponse
+	if err := c.do(ctx, "POST", "/api/jobs/"+url.PathEscape(id)+"/cancel", query, header, nil, "", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RetryRegistrationJob 调用 POST /api/jobs/{id}/retry：重试注册任务
+func (c *Client) RetryRegistrationJob(ctx context.Context, id string, body RetryRegistrationRequest) (*RegistrationJobResponse, error) {
+	query, header := url.Values{}, http.Header{}
+	var out RegistrationJobResponse
+	if err := c.do(ctx, "POST", "/api/jobs/"+url.PathEscape(id)+"/retry", query, header, body, "", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListAuditParams ListAudit 的可选参数，零值表示不传
+type ListAuditParams struct {
+	Actor string
+	// 如 runner.add、token.create
+	Action string
+	Runner string
+	// success 或 failure
+	Result string
+	// RFC 3339 时间
+	Since string
+	// RFC 3339 时间
+	Until string
+	// 1-1000，默认 100
+	Limit int
+}
+
+// ListAudit 调用 GET /api/audit：查询审计日志
+func (c *Client) ListAudit(ctx context.Context, params *ListAuditParams) (*AuditList, error) {
+	query, header := url.Values{}, http.Header{}
+	if params != nil {
+		if params.Actor != "" {
+			query.Set("actor", params.Actor)
+		}
+		if params.Action != "" {
+			query.Set("action", params.Action)
+		}
+		if params.Runner != "" {
+			query.Set("runner", params.Runner)
+		}
+		if params.Result != "" {
+			query.Set("result", params.Result)
+		}
+		if params.Since != "" {
+			query.Set("since", params.Since)
+		}
+		if params.Until != "" {
+			query.Set("until", params.Until)
+		}
+		if params.Limit != 0 {
+			query.Set("limit", strconv.Itoa(params.Limit))
+		}
+	}
+	var out AuditList
+	if err := c.do(ctx, "GET", "/api/audit", query, header, nil, "", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListTokens 调用 GET /api/tokens：列出 API Token
+func (c *Client) ListTokens(ctx context.Context) (*TokenList, error) {
+	query, header := url.Values{}, http.Header{}
+	var out TokenList
+	if err := c.do(ctx, "GET", "/api/tokens", query, header, nil, "", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateToken 调用 POST /api/tokens：签发 API Token
+func (c *Client) CreateToken(ctx context.Context, body CreateTokenRequest) (*CreateTokenResponse, error) {
+	query, header := url.Values{}, http.Header{}
+	var out CreateTokenResponse
+	if err := c.do(ctx, "POST", "/api/tokens", query, header, body, "", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteToken 调用 DELETE /api/tokens/{id}：吊销 API Token
+func (c *Client) DeleteToken(ctx context.Context, id string) (*MessageResponse, error) {
+	query, header := url.Values{}, http.Header{}
+	var out MessageResponse
+	if err := c.do(ctx, "DELETE", "/api/tokens/"+url.PathEscape(id), query, header, nil, "", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListConfigHistory 调用 GET /api/config/history：配置历史版本
+func (c *Client) ListConfigHistory(ctx context.Context) (*ConfigHistory, error) {
+	query, header := url.Values{}, http.Header{}
+	var out ConfigHistory
+	if err := c.do(ctx, "GET", "/api/config/history", query, header, nil, "", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetConfigRevision 调用 GET /api/config/history/{rev}：历史版本的配置内容
+func (c *Client) GetConfigRevision(ctx context.Context, rev string) ([]byte, error) {
+	query, header := url.Values{}, http.Header{}
+	var out []byte
+	err := c.do(ctx, "GET", "/api/config/history/"+url.PathEscape(rev), query, header, nil, "", &out)
+	return out, err
+}
+
+// DiffConfigParams DiffConfig 的可选参数，零值表示不传
+type DiffConfigParams struct {
+	// 目标版本，省略时为当前配置文件
+	To int
+}
+
+// DiffConfig 调用 GET /api/config/diff：比较两个配置版本
+func (c *Client) DiffConfig(ctx context.Context, from int, params *DiffConfigParams) (*ConfigDiff, error) {
+	query, header := url.Values{}, http.Header{}
+	query.Set("from", strconv.Itoa(from))
+	if params != nil {
+		if params.To != 0 {
+			query.Set("to", strconv.Itoa(params.To))
+		}
+	}
+	var out ConfigDiff
+	if err := c.do(ctx, "GET", "/api/config/diff", query, header, nil, "", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RollbackConfig 调用 POST /api/config/rollback/{rev}：回滚配置
+func (c *Client) RollbackConfig(ctx context.Context, rev string) (*RollbackResult, error) {
+	query, header := url.Values{}, http.Header{}
+	var out RollbackResult
+	if err := c.do(ctx, "POST", "/api/config/rollback/"+url.PathEscape(rev), query, header, nil, "", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PlanConfig 调用 POST /api/config/plan：预览配置变更
+func (c *Client) PlanConfig(ctx context.Context, body []byte) (*ConfigPlan, error) {
+	query, header := url.Values{}, http.Header{}
+	var out ConfigPlan
+	if err := c.do(ctx, "POST", "/api/config/plan", query, header, body, "application/yaml", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetGitOps 调用 GET /api/gitops：GitOps 同步状态
+func (c *Client) GetGitOps(ctx context.Context) (*GitOpsStatus, error) {
+	query, header := url.Values{}, http.Header{}
+	var out GitOpsStatus
+	if err := c.do(ctx, "GET", "/api/gitops", query, header, nil, "", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SyncGitOps 调用 POST /api/gitops/sync：立即同步仓库
+func (c *Client) SyncGitOps(ctx context.Context) (*GitOpsSyncResponse, error) {
+	query, header := url.Values{}, http.Header{}
+	var out GitOpsSyncResponse
+	if err := c.do(ctx, "POST", "/api/gitops/sync", query, header, nil, "", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ApplyGitOps 调用 POST /api/gitops/apply：应用待应用的计划
+func (c *Client) ApplyGitOps(ctx context.Context, body ApplyGitOpsRequest) (*GitOpsApplyResponse, error) {
+	query, header := url.Values{}, http.Header{}
+	var out GitOpsApplyResponse
+	if err := c.do(ctx, "POST", "/api/gitops/apply", query, header, body, "", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetFleetUpgrade 调用 GET /api/fleet/upgrade：滚动升级进度
+func (c *Client) GetFleetUpgrade(ctx context.Context) (*FleetUpgradeStatus, error) {
+	query, header := url.Values{}, http.Header{}
+	var out FleetUpgradeStatus
+	if err := c.do(ctx, "GET", "/api/fleet/upgrade", query, header, nil, "", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// StartFleetUpgrade 调用 POST /api/fleet/upgrade：开始滚动升级
+func (c *Client) StartFleetUpgrade(ctx context.Context, body FleetUpgradeRequest) (*Rollout, error) {
+	query, header := url.Values{}, http.Header{}
+	var out Rollout
+	if err := c.do(ctx, "POST", "/api/fleet/upgrade", query, header, body, "", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CancelFleetUpgrade 调用 POST /api/fleet/upgrade/cancel：取消滚动升级
+func (c *Client) CancelFleetUpgrade(ctx context.Context) (*MessageResponse, error) {
+	query, header := url.Values{}, http.Header{}
+	var out MessageResponse
+	if err := c.do(ctx, "POST", "/api/fleet/upgrade/cancel", query, header, nil, "", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,38 @@
+// openapi-gen：由 handler.OpenAPIDocument 生成 client 包的类型与方法，可同时导出 OpenAPI 文档。
+// 用法：go generate ./client（见 client/client.go），或 go run ./cmd/openapi-gen -o client/generated.go -spec openapi.json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/handler"
+	"github.com/lab-dev/github-actions-runner-manager/internal/openapi"
+)
+
+func main() {
+	out := flag.String("o", "generated.go", "生成的 Go 文件路径")
+	pkg := flag.String("pkg", "client", "生成代码的包名")
+	spec := flag.String("spec", "", "可选，同时写入 OpenAPI 文档（JSON）的路径")
+	flag.Parse()
+
+	doc := handler.OpenAPIDocument()
+	src, err := openapi.GenerateClient(doc, *pkg)
+	if err != nil {
+		log.Fatalf("生成客户端失败: %v", err)
+	}
+	if err := os.WriteFile(*out, src, 0644); err != nil {
+		log.Fatalf("写入 %s 失败: %v", *out, err)
+	}
+	if *spec != "" {
+		b, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			log.Fatalf("编码 OpenAPI 文档失败: %v", err)
+		}
+		if err := os.WriteFile(*spec, append(b, '\n'), 0644); err != nil {
+			log.Fatalf("写入 %s 失败: %v", *spec, err)
+		}
+	}
+}
@@ -1,4 +1,5 @@
-// Runner Agent：运行在 Runner 容器内，职责仅为 Runner 进程控制（启动/停止）与健康/状态上报（/status、/health），供 Manager 通过 HTTP 调用。
+// Runner Agent：运行在 Runner 容器内，职责仅为 Runner 进程控制（启动/停止）与健康/状态上报（/status、/health、/metrics），供 Manager 通过 HTTP 调用。
+// 另按 Manager 写入的 .cleanup_policy.json 在 Runner 空闲时清理 _work。
 // 环境变量：RUNNER_INSTALL_DIR（默认 /runner）、AGENT_PORT（默认 8081）
 package main
 
@@ -14,6 +15,10 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/procstat"
+	"github.com/lab-dev/github-actions-runner-manager/internal/workspace"
 )
 
 const defaultInstallDir = "/runner"
@@ -116,10 +121,18 @@ func stopRunner(installDir string) error {
 }
 
 type statusResponse struct {
-	Status  string `json:"status"`
-	Running bool   `json:"running"`
+	Status          string  `json:"status"`
+	Running         bool    `json:"running"`
+	InstallDirBytes int64   `json:"install_dir_bytes"`
+	WorkDirBytes    int64   `json:"work_dir_bytes"`
+	CPUPercent      float64 `json:"cpu_percent"`
+	MemoryRSSBytes  int64   `json:"memory_rss_bytes"`
 }
 
+// statusSampler 对 Runner 进程树做 CPU 采样，两次 /status 之间的差值即为使用率；/metrics 使用独立的 metricsSampler，
+// 两者各按自己的请求间隔计算，Manager 轮询不会缩短 Prometheus 抓取看到的采样窗口
+var statusSampler = procstat.NewSampler()
+
 func handleStatus(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -127,7 +140,15 @@ func handleStatus(w http.ResponseWriter, r *http.Request) {
 	}
 	dir := installDir()
 	status, running := getStatus(dir)
-	_ = json.NewEncoder(w).Encode(statusResponse{Status: status, Running: running})
+	resp := statusResponse{Status: status, Running: running}
+	resp.InstallDirBytes, _ = workspace.CachedDirSize(dir)
+	resp.WorkDirBytes, _ = workspace.CachedDirSize(filepath.Join(dir, workspace.WorkDirName))
+	if running {
+		if pid, err := readRunnerPid(dir); err == nil {
+			resp.CPUPercent, resp.MemoryRSSBytes, _ = statusSampler.Sample(pid)
+		}
+	}
+	_ = json.NewEncoder(w).Encode(resp)
 }
 
 func handleStart(w http.ResponseWriter, r *http.Request) {
@@ -163,6 +184,20 @@ func handleStop(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write([]byte(`{"message":"stop signal sent"}`))
 }
 
+// runCleanupLoop 每分钟读取 Manager 下发的清理策略并在空闲时清理 _work，结果写入 .cleanup_result.json 供 Manager 展示
+func runCleanupLoop(installDir string) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		res, err := workspace.Tick(installDir, workspace.ReadPolicy(installDir), time.Now())
+		if err != nil {
+			log.Printf("清理 _work 失败: %v", err)
+		} else if res != nil {
+			log.Printf("已清理 _work（%s），释放 %d 字节", res.LastReason, res.LastBytesFreed)
+		}
+	}
+}
+
 func main() {
 	port := os.Getenv("AGENT_PORT")
 	if port == "" {
@@ -171,10 +206,13 @@ func main() {
 	http.HandleFunc("/status", handleStatus)
 	http.HandleFunc("/start", handleStart)
 	http.HandleFunc("/stop", handleStop)
+	http.HandleFunc("/metrics", handleMetrics)
 	http.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("ok"))
 	})
+	go runCleanupLoop(installDir())
+	go runListenerWatch(installDir())
 	log.Printf("Runner Agent 监听 :%s，RUNNER_INSTALL_DIR=%s", port, installDir())
 	if err := http.ListenAndServe(":"+port, nil); err != nil {
 		log.Fatal(err)
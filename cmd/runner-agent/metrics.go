@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/jobhistory"
+	"github.com/lab-dev/github-actions-runner-manager/internal/metrics"
+	"github.com/lab-dev/github-actions-runner-manager/internal/procstat"
+	"github.com/lab-dev/github-actions-runner-manager/internal/workspace"
+)
+
+// agentPrefix Agent 侧指标名前缀，与 Manager 的 runner_fleet_ 区分
+const agentPrefix = "runner_agent_"
+
+var (
+	agentRegistry = metrics.NewRegistry()
+	// listenerRestarts 观察到 Runner 进程 pid 变化的次数（run.sh 自更新重启或经 /start 重新拉起）
+	listenerRestarts = agentRegistry.NewCounterVec(agentPrefix+"listener_restarts_total", "Times the runner listener was observed with a new pid since the agent started.")
+	// jobsCompleted 按 Worker 日志观察到的 Job 完成次数；Runner 清理旧日志不影响已计入的值
+	jobsCompleted  = agentRegistry.NewCounterVec(agentPrefix+"jobs_completed_total", "Jobs observed finishing since the agent started, by conclusion.", "conclusion")
+	agentStartedAt = time.Now()
+)
+
+// listenerWatchInterval 检查 Runner pid 变化的间隔
+const listenerWatchInterval = 15 * time.Second
+
+var (
+	listenerMu      sync.Mutex
+	lastListenerPid int
+)
+
+// observeListener 记录当前 Runner pid，pid 与上次观察到的不同则计一次重启
+func observeListener(installDir string) {
+	pid, err := readRunnerPid(installDir)
+	if err != nil || !processExists(pid) {
+		return
+	}
+	listenerMu.Lock()
+	defer listenerMu.Unlock()
+	if lastListenerPid != 0 && pid != lastListenerPid {
+		listenerRestarts.Inc()
+	}
+	lastListenerPid = pid
+}
+
+var (
+	jobsMu sync.Mutex
+	// countedLogs 已计入或 Agent 启动前已完成的 Worker 日志（文件名）；nil 表示尚未做首次观察
+	countedLogs map[string]bool
+)
+
+// observeJobs 检查 _diag 中新出现结论的 Worker 日志并计入 jobsCompleted。
+// 首次观察时已完成的日志只登记不计数，计数从 Agent 启动开始
+func observeJobs(installDir string) {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	first := countedLogs == nil
+	seen := make(map[string]bool, len(countedLogs))
+	for _, p := range jobhistory.WorkerLogs(installDir) {
+		name := filepath.Base(p)
+		if countedLogs[name] {
+			seen[name] = true
+			continue
+		}
+		rec, err := jobhistory.ParseWorkerLog("", p)
+		if err != nil || rec == nil || !rec.Completed() {
+			continue
+		}
+		seen[name] = true
+		if !first {
+			jobsCompleted.Inc(rec.Conclusion)
+		}
+	}
+	// 只保留仍存在的日志，Runner 清理的日志名不会再出现
+	countedLogs = seen
+}
+
+// runListenerWatch 定时观察 Runner pid 与 Worker 日志，用于统计重启次数与完成的 Job
+func runListenerWatch(installDir string) {
+	listenerRestarts.Add(0) // 尚未重启时也输出 0 样本
+	observeListener(installDir)
+	observeJobs(installDir)
+	ticker := time.NewTicker(listenerWatchInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		observeListener(installDir)
+		observeJobs(installDir)
+	}
+}
+
+// runnerAgentName 从 .runner 读取注册到 GitHub 的名称，作为指标的 runner 标签；未注册时为空
+func runnerAgentName(installDir string) string {
+	b, err := os.ReadFile(filepath.Join(installDir, ".runner"))
+	if err != nil {
+		return ""
+	}
+	var v struct {
+		AgentName string `json:"agentName"`
+	}
+	// .runner 由 config.sh 写入，可能带 UTF-8 BOM
+	if json.Unmarshal(bytes.TrimPrefix(b, []byte("\xef\xbb\xbf")), &v) != nil {
+		return ""
+	}
+	return strings.TrimSpace(v.AgentName)
+}
+
+// metricsSampler /metrics 的 CPU 采样器，使用率为两次抓取之间的平均值
+var metricsSampler = procstat.NewSampler()
+
+// handleMetrics 以 Prometheus 文本格式输出 Runner 容器内的指标（GET /metrics），供 Prometheus 在 runner-net 内直接抓取
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	dir := installDir()
+	observeListener(dir)
+	observeJobs(dir)
+	labels := metrics.Labels{"runner": runnerAgentName(dir)}
+	var buf bytes.Buffer
+	mw := metrics.NewWriter(&buf)
+	gauge := func(name, help string, v float64) {
+		mw.Header(agentPrefix+name, help, "gauge")
+		mw.Sample(agentPrefix+name, labels, v)
+	}
+
+	status, running := getStatus(dir)
+	gauge("agent_uptime_seconds", "Seconds since the agent started.", time.Since(agentStartedAt).Seconds())
+	gauge("runner_installed", "Whether the runner is configured (.runner exists).", metrics.Bool(status == "installed"))
+	gauge("listener_running", "Whether the runner listener process is alive.", metrics.Bool(running))
+	var uptime, cpu float64
+	var rss int64
+	if running {
+		if pid, err := readRunnerPid(dir); err == nil {
+			if u, err := procstat.Tree(pid); err == nil {
+				uptime = u.ElapsedSeconds
+			}
+			cpu, rss, _ = metricsSampler.Sample(pid)
+		}
+	}
+	gauge("listener_uptime_seconds", "Seconds since the runner listener process started; 0 when not running.", uptime)
+	gauge("process_cpu_percent", "CPU usage of the runner process tree since the previous scrape (100 = one core).", cpu)
+	gauge("process_resident_memory_bytes", "Resident memory of the runner process tree.", float64(rss))
+
+	var jobSeconds float64
+	busy := false
+	if cur := jobhistory.Current(dir); cur != nil {
+		busy = true
+		if !cur.StartedAt.IsZero() {
+			jobSeconds = time.Since(cur.StartedAt).Seconds()
+		}
+	}
+	gauge("job_running", "Whether a job is currently running.", metrics.Bool(busy))
+	gauge("current_job_duration_seconds", "Seconds since the current job started; 0 when idle.", jobSeconds)
+
+	installBytes, _ := workspace.CachedDirSize(dir)
+	workBytes, _ := workspace.CachedDirSize(filepath.Join(dir, workspace.WorkDirName))
+	gauge("install_dir_bytes", "Size of the runner install dir (cached for one minute).", float64(installBytes))
+	gauge("workspace_bytes", "Size of _work (cached for one minute).", float64(workBytes))
+	if res := workspace.ReadResult(dir); res != nil {
+		gauge("workspace_cleanup_freed_bytes", "Bytes freed by _work cleanup, accumulated across runs (.cleanup_result.json).", float64(res.TotalBytesFreed))
+	}
+
+	agentRegistry.Write(mw)
+	if err := mw.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", metrics.ContentType)
+	_, _ = w.Write(buf.Bytes())
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/jobhistory"
+)
+
+const (
+	runningLog  = "[2026-03-03 10:00:00Z INFO Terminal] WRITE LINE: Running job: build\n"
+	finishedLog = runningLog + "[2026-03-03 10:01:00Z INFO JobRunner] Job result after all job steps finish: Succeeded\n"
+)
+
+func writeFile(t *testing.T, p, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func resetJobs() {
+	jobsMu.Lock()
+	countedLogs = nil
+	jobsMu.Unlock()
+}
+
+func TestObserveListener_CountsPidChange(t *testing.T) {
+	dir := t.TempDir()
+	pidFile := filepath.Join(dir, "Runner.Listener.pid")
+	listenerMu.Lock()
+	lastListenerPid = 0
+	listenerMu.Unlock()
+	before := listenerRestarts.Value()
+
+	writeFile(t, pidFile, strconv.Itoa(os.Getpid()))
+	observeListener(dir)
+	observeListener(dir)
+	if got := listenerRestarts.Value() - before; got != 0 {
+		t.Fatalf("restarts = %v before any pid change", got)
+	}
+	// 父进程必然存在，模拟 Runner 以新 pid 重启
+	writeFile(t, pidFile, strconv.Itoa(os.Getppid()))
+	observeListener(dir)
+	if got := listenerRestarts.Value() - before; got != 1 {
+		t.Errorf("restarts = %v, want 1", got)
+	}
+	// pid 文件指向已退出的进程时不计数，也不更新记录的 pid
+	writeFile(t, pidFile, "999999999")
+	observeListener(dir)
+	writeFile(t, pidFile, strconv.Itoa(os.Getppid()))
+	observeListener(dir)
+	if got := listenerRestarts.Value() - before; got != 1 {
+		t.Errorf("restarts = %v after dead pid, want 1", got)
+	}
+}
+
+func TestObserveJobs_CountsCompletionsOnce(t *testing.T) {
+	dir := t.TempDir()
+	diag := filepath.Join(dir, jobhistory.DiagDirName)
+	resetJobs()
+	defer resetJobs()
+	before := jobsCompleted.Value("success")
+
+	// Agent 启动前已完成的 Job 不计入
+	writeFile(t, filepath.Join(diag, "Worker_20260303-090000-utc.log"), finishedLog)
+	writeFile(t, filepath.Join(diag, "Worker_20260303-100000-utc.log"), runningLog)
+	observeJobs(dir)
+	if got := jobsCompleted.Value("success") - before; got != 0 {
+		t.Fatalf("jobs completed = %v after first observation", got)
+	}
+	writeFile(t, filepath.Join(diag, "Worker_20260303-100000-utc.log"), finishedLog)
+	observeJobs(dir)
+	observeJobs(dir)
+	if got := jobsCompleted.Value("success") - before; got != 1 {
+		t.Fatalf("jobs completed = %v, want 1", got)
+	}
+	// Runner 清理旧日志后计数不回退
+	if err := os.RemoveAll(diag); err != nil {
+		t.Fatal(err)
+	}
+	observeJobs(dir)
+	if got := jobsCompleted.Value("success") - before; got != 1 {
+		t.Errorf("jobs completed = %v after pruning, want 1", got)
+	}
+}
+
+func TestHandleMetrics(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("RUNNER_INSTALL_DIR", dir)
+	resetJobs()
+	defer resetJobs()
+	writeFile(t, filepath.Join(dir, ".runner"), "\xef\xbb\xbf{\"agentName\":\"gpu-1\"}")
+	writeFile(t, filepath.Join(dir, jobhistory.DiagDirName, "Worker_20260303-100000-utc.log"), runningLog)
+	writeFile(t, filepath.Join(dir, "_work", "a.bin"), "12345")
+
+	rec := httptest.NewRecorder()
+	handleMetrics(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("content type = %q", ct)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE runner_agent_runner_installed gauge",
+		`runner_agent_runner_installed{runner="gpu-1"} 1`,
+		`runner_agent_listener_running{runner="gpu-1"} 0`,
+		`runner_agent_job_running{runner="gpu-1"} 1`,
+		`runner_agent_workspace_bytes{runner="gpu-1"} 5`,
+		"# TYPE runner_agent_listener_restarts_total counter",
+		"# TYPE runner_agent_jobs_completed_total counter",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "runner_agent_jobs{") {
+		t.Errorf("jobs gauge should be gone\n%s", body)
+	}
+
+	rec = httptest.NewRecorder()
+	handleMetrics(rec, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want 405", rec.Code)
+	}
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"crypto/subtle"
 	"embed"
 	"encoding/json"
 	"flag"
@@ -17,10 +16,13 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/lab-dev/github-actions-runner-manager/internal/audit"
 	"github.com/lab-dev/github-actions-runner-manager/internal/config"
 	"github.com/lab-dev/github-actions-runner-manager/internal/githubcheck"
 	"github.com/lab-dev/github-actions-runner-manager/internal/handler"
+	"github.com/lab-dev/github-actions-runner-manager/internal/jobhistory"
 	"github.com/lab-dev/github-actions-runner-manager/internal/runner"
+	"github.com/lab-dev/github-actions-runner-manager/internal/workspace"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 )
@@ -65,11 +67,15 @@ func main() {
 
 	handler.ConfigPath = *configPath
 	handler.Version = Version
-	handler.StartRegistrationWorker()
+	handler.WebhookSecret = strings.TrimSpace(os.Getenv("GITHUB_WEBHOOK_SECRET"))
+	handler.MetricsToken = strings.TrimSpace(os.Getenv("METRICS_TOKEN"))
+	handler.GitHubAdminToken = strings.TrimSpace(os.Getenv("GITHUB_ADMIN_TOKEN"))
 	cfg, err := config.Load(*configPath)
 	if err != nil {
 		log.Fatalf("加载配置失败: %v", err)
 	}
+	handler.StartRegistrationWorker(cfg)
+	handler.RecoverFleetUpgrade(cfg)
 	if cfg.Runners.ContainerMode && runner.ManagerDockerHostIsDind() {
 		log.Printf("警告: 容器模式已开启，但 DOCKER_HOST 指向 TCP（DinD）。Manager 必须使用宿主机 Docker（socket）才能创建/启停 Runner 容器。请在 .env 中移除或注释 DOCKER_HOST=tcp://runner-dind:2375")
 	}
@@ -89,23 +95,32 @@ func main() {
 		_ = c.JSON(code, map[string]string{"message": msg})
 	}
 
-	if pw := os.Getenv("BASIC_AUTH_PASSWORD"); pw != "" {
-		expectedUser := strings.TrimSpace(os.Getenv("BASIC_AUTH_USER"))
-		if expectedUser == "" {
-			expectedUser = "admin"
+	// Basic Auth（视为 admin）、带权限范围的 API Token（Authorization: Bearer rfm_...）与 OIDC 登录会话并存，见 handler.Auth
+	if handler.OIDC = handler.NewOIDCProvider(cfg, strings.TrimSpace(os.Getenv("OIDC_CLIENT_SECRET"))); handler.OIDC != nil {
+		log.Printf("OIDC 登录已启用（issuer: %s）", handler.OIDC.Issuer)
+	}
+	basicUser, basicPassword := "", os.Getenv("BASIC_AUTH_PASSWORD")
+	if basicPassword != "" {
+		basicUser = strings.TrimSpace(os.Getenv("BASIC_AUTH_USER"))
+		if basicUser == "" {
+			basicUser = "admin"
+		}
+		log.Printf("Basic Auth 已启用（用户: %s）", basicUser)
+	}
+	// AuditLog 在 Auth 之前，被拒绝的请求同样记入审计日志
+	e.Use(handler.AuditLog(), handler.Auth(basicUser, basicPassword))
+	if cfg.Audit != nil && cfg.Audit.Syslog != nil {
+		sl := cfg.Audit.Syslog
+		tag := sl.Tag
+		if tag == "" {
+			tag = "runner-fleet"
+		}
+		if w, err := audit.DialSyslog(sl.Network, sl.Address, tag); err != nil {
+			log.Printf("警告: 连接 syslog 失败，审计日志仅写入本地: %v", err)
+		} else {
+			audit.SetForwarder(w)
+			log.Printf("审计日志将转发到 syslog %s %s", sl.Network, sl.Address)
 		}
-		expectedPassword := pw
-		e.Use(middleware.BasicAuthWithConfig(middleware.BasicAuthConfig{
-			Skipper: func(c echo.Context) bool {
-				return c.Path() == "/health"
-			},
-			Validator: func(username, password string, c echo.Context) (bool, error) {
-				userOk := subtle.ConstantTimeCompare([]byte(username), []byte(expectedUser)) == 1
-				passOk := subtle.ConstantTimeCompare([]byte(password), []byte(expectedPassword)) == 1
-				return userOk && passOk, nil
-			},
-		}))
-		log.Printf("Basic Auth 已启用（用户: %s）", expectedUser)
 	}
 
 	e.Renderer = newTemplateRenderer()
@@ -120,16 +135,7 @@ func main() {
 		}
 		return t, nil
 	}
-	e.GET("/health", handler.Health)
-	e.GET("/version", handler.VersionInfo)
-	e.GET("/", handler.Index)
-	e.GET("/api/runners", handler.ListRunners)
-	e.GET("/api/runners/:name", handler.GetRunner)
-	e.POST("/api/runners", handler.AddRunner)
-	e.PUT("/api/runners/:name", handler.UpdateRunner)
-	e.DELETE("/api/runners/:name", handler.RemoveRunnerByName)
-	e.POST("/api/runners/:name/start", handler.StartRunner)
-	e.POST("/api/runners/:name/stop", handler.StopRunner)
+	handler.RegisterRoutes(e)
 
 	addr := ":8080"
 	if cfg.Server.Port > 0 {
@@ -142,6 +148,11 @@ func main() {
 	srv := &http.Server{Addr: addr, Handler: e}
 	go runAutoStartRunners(*configPath)
 	go runRegistrationCheck(*configPath)
+	go runJobHistorySync(*configPath)
+	go runWorkspaceCleanup(*configPath)
+	go handler.RunStatusWatcher(handler.StatusWatchInterval)
+	go handler.RunConfigWatcher(handler.ConfigWatchInterval)
+	go handler.RunGitOpsSync()
 	go func() {
 		log.Printf("监听 %s", addr)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -213,3 +224,49 @@ func runRegistrationCheck(configPath string) {
 		<-ticker.C
 	}
 }
+
+// runJobHistorySync 每分钟解析各 runner 目录下 _diag 的 Worker 日志，写入 Job 历史供 /api/runners/:name/jobs 查询
+func runJobHistorySync(configPath string) {
+	const interval = time.Minute
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if cfg, err := config.Load(configPath); err == nil {
+			jobhistory.Sync(cfg)
+		}
+		<-ticker.C
+	}
+}
+
+// runWorkspaceCleanup 每分钟处理各 runner 的 _work 清理策略：容器模式下写入 .cleanup_policy.json 由容器内 Agent 执行，
+// 进程模式下由 Manager 在 runner 空闲时直接清理
+func runWorkspaceCleanup(configPath string) {
+	const interval = time.Minute
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		cfg, err := config.Load(configPath)
+		if err == nil {
+			for _, item := range cfg.Runners.Items {
+				installDir := item.InstallPath(cfg.Runners.BasePath)
+				if _, statErr := os.Stat(installDir); statErr != nil {
+					continue
+				}
+				policy := cfg.Runners.EffectiveCleanup(item)
+				if cfg.Runners.ContainerMode {
+					if err := workspace.WritePolicy(installDir, policy); err != nil {
+						log.Printf("写入 runner %s 清理策略失败: %v", item.Name, err)
+					}
+					continue
+				}
+				res, err := workspace.Tick(installDir, policy, time.Now())
+				if err != nil {
+					log.Printf("清理 runner %s 的 _work 失败: %v", item.Name, err)
+				} else if res != nil {
+					log.Printf("已清理 runner %s 的 _work（%s），释放 %d 字节", item.Name, res.LastReason, res.LastBytesFreed)
+				}
+			}
+		}
+		<-ticker.C
+	}
+}
@@ -0,0 +1,215 @@
+// Package apitoken 管理带权限范围的 API Token：明文仅在创建时返回一次，磁盘上只保存 SHA-256，
+// 存放于 base_path/.fleet/tokens.json（0600）。
+package apitoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 权限范围，admin 包含 operate，operate 包含 read
+const (
+	ScopeRead    = "read"    // 查看 runner、任务、事件与指标
+	ScopeOperate = "operate" // 启停 runner、取消/重试任务
+	ScopeAdmin   = "admin"   // 增删改 runner、升级、配置与 Token 管理
+)
+
+// Prefix Token 明文前缀，便于在日志与密钥扫描中识别
+const Prefix = "rfm_"
+
+var scopeRank = map[string]int{ScopeRead: 1, ScopeOperate: 2, ScopeAdmin: 3}
+
+// Includes 权限范围 have 是否包含 need
+func Includes(have, need string) bool {
+	return scopeRank[have] > 0 && scopeRank[have] >= scopeRank[need]
+}
+
+// Highest 返回一组权限范围中最高的一个
+func Highest(scopes []string) string {
+	best := ""
+	for _, s := range scopes {
+		if scopeRank[s] > scopeRank[best] {
+			best = s
+		}
+	}
+	return best
+}
+
+// ValidScope 是否为支持的权限范围
+func ValidScope(s string) bool {
+	_, ok := scopeRank[s]
+	return ok
+}
+
+// ErrNotFound Token 不存在
+var ErrNotFound = errors.New("token 不存在")
+
+var mu sync.Mutex
+
+// Token 已签发的 Token（不含明文）
+type Token struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	Hash      string    `json:"hash,omitempty"` // 明文的 SHA-256（hex），API 输出前清空
+	Hint      string    `json:"hint"`           // 明文末 4 位，便于辨认
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Allows Token 是否具备 scope 所需权限
+func (t *Token) Allows(scope string) bool {
+	return Includes(Highest(t.Scopes), scope)
+}
+
+func file(stateDir string) string {
+	return filepath.Join(stateDir, "tokens.json")
+}
+
+func hash(secret string) string {
+	s := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(s[:])
+}
+
+func load(stateDir string) ([]Token, error) {
+	b, err := os.ReadFile(file(stateDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var list []Token
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func save(stateDir string, list []Token) error {
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	p := file(stateDir)
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+// Create 签发新 Token，返回明文（仅此一次）与记录
+func Create(stateDir, name string, scopes []string) (string, *Token, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil, errors.New("name 必填")
+	}
+	if len(scopes) == 0 {
+		return "", nil, errors.New("scopes 不能为空")
+	}
+	var norm []string
+	for _, s := range scopes {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if !ValidScope(s) {
+			return "", nil, fmt.Errorf("不支持的 scope: %q（可选 read、operate、admin）", s)
+		}
+		if !slices.Contains(norm, s) {
+			norm = append(norm, s)
+		}
+	}
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, err
+	}
+	secret := Prefix + hex.EncodeToString(raw)
+	id := make([]byte, 6)
+	if _, err := rand.Read(id); err != nil {
+		return "", nil, err
+	}
+	t := Token{
+		ID:        "tok-" + hex.EncodeToString(id),
+		Name:      name,
+		Scopes:    norm,
+		Hash:      hash(secret),
+		Hint:      secret[len(secret)-4:],
+		CreatedAt: time.Now().UTC(),
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	list, err := load(stateDir)
+	if err != nil {
+		return "", nil, err
+	}
+	if slices.ContainsFunc(list, func(x Token) bool { return x.Name == name }) {
+		return "", nil, fmt.Errorf("已存在名为 %q 的 token", name)
+	}
+	if err := save(stateDir, append(list, t)); err != nil {
+		return "", nil, err
+	}
+	t.Hash = ""
+	return secret, &t, nil
+}
+
+// List 列出全部 Token（不含哈希），按创建时间排序
+func List(stateDir string) ([]Token, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	list, err := load(stateDir)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		list[i].Hash = ""
+	}
+	return list, nil
+}
+
+// Delete 吊销 Token，不存在返回 ErrNotFound
+func Delete(stateDir, id string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	list, err := load(stateDir)
+	if err != nil {
+		return err
+	}
+	idx := slices.IndexFunc(list, func(t Token) bool { return t.ID == id })
+	if idx < 0 {
+		return ErrNotFound
+	}
+	return save(stateDir, slices.Delete(list, idx, idx+1))
+}
+
+// Lookup 按明文查找 Token，未找到或格式不符时返回 false
+func Lookup(stateDir, secret string) (*Token, bool) {
+	if !strings.HasPrefix(secret, Prefix) {
+		return nil, false
+	}
+	h := hash(secret)
+	mu.Lock()
+	list, err := load(stateDir)
+	mu.Unlock()
+	if err != nil {
+		return nil, false
+	}
+	for _, t := range list {
+		if subtle.ConstantTimeCompare([]byte(t.Hash), []byte(h)) == 1 {
+			t.Hash = ""
+			return &t, true
+		}
+	}
+	return nil, false
+}
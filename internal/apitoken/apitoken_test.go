@@ -0,0 +1,52 @@
+package apitoken
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCreateLookupDelete(t *testing.T) {
+	dir := t.TempDir()
+	secret, tok, err := Create(dir, "ci", []string{"operate", "OPERATE"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(secret, Prefix) || tok.Hash != "" || len(tok.Scopes) != 1 {
+		t.Fatalf("unexpected token %q %+v", secret, tok)
+	}
+	b, _ := os.ReadFile(filepath.Join(dir, "tokens.json"))
+	if strings.Contains(string(b), secret) {
+		t.Error("plaintext token stored on disk")
+	}
+	if st, _ := os.Stat(filepath.Join(dir, "tokens.json")); st.Mode().Perm() != 0600 {
+		t.Errorf("tokens.json mode = %v", st.Mode().Perm())
+	}
+	got, ok := Lookup(dir, secret)
+	if !ok || got.ID != tok.ID {
+		t.Fatalf("lookup failed: %v %v", got, ok)
+	}
+	if !got.Allows(ScopeRead) || !got.Allows(ScopeOperate) || got.Allows(ScopeAdmin) {
+		t.Errorf("operate scope allows = read %v operate %v admin %v", got.Allows(ScopeRead), got.Allows(ScopeOperate), got.Allows(ScopeAdmin))
+	}
+	if _, ok := Lookup(dir, secret+"x"); ok {
+		t.Error("lookup with wrong secret succeeded")
+	}
+	if _, _, err := Create(dir, "ci", []string{"read"}); err == nil {
+		t.Error("expected duplicate name error")
+	}
+	if _, _, err := Create(dir, "bad", []string{"root"}); err == nil {
+		t.Error("expected invalid scope error")
+	}
+	if err := Delete(dir, tok.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Lookup(dir, secret); ok {
+		t.Error("deleted token still valid")
+	}
+	if err := Delete(dir, tok.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,232 @@
+// Package audit 记录所有修改操作的审计日志：操作者、来源 IP、动作、runner、配置变更前后与结果，
+// 以 JSON Lines 追加写入 base_path/.fleet/audit.jsonl（只追加，不改写），可选同时转发到 syslog。
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 操作结果
+const (
+	ResultOK    = "ok"
+	ResultError = "error"
+)
+
+// FileName 审计日志文件名，位于状态目录下
+const FileName = "audit.jsonl"
+
+// maxLineBytes 单条记录的最大长度，读取时超长行跳过
+const maxLineBytes = 1 << 20
+
+var (
+	mu        sync.Mutex
+	forwarder io.Writer
+)
+
+// Change 单个字段的变更
+type Change struct {
+	Field  string `json:"field"`
+	Before any    `json:"before,omitempty"`
+	After  any    `json:"after,omitempty"`
+}
+
+// Entry 一条审计记录
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Actor     string    `json:"actor"`
+	ActorKind string    `json:"actor_kind"` // basic、token、session、anonymous；认证失败时 actor 与 actor_kind 为空
+	SourceIP  string    `json:"source_ip"`
+	Action    string    `json:"action"` // 如 runner.add、runner.stop、token.create
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Runner    string    `json:"runner,omitempty"`
+	Before    any       `json:"before,omitempty"` // 变更前的 runner 配置
+	After     any       `json:"after,omitempty"`  // 变更后的 runner 配置
+	Changes   []Change  `json:"changes,omitempty"`
+	Result    string    `json:"result"`
+	Status    int       `json:"status"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// SetForwarder 设置转发目标（如 syslog），每条记录以一行 JSON 写入；nil 表示不转发
+func SetForwarder(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	forwarder = w
+}
+
+// Append 追加一条记录；转发失败只记日志，不影响本地写入。时间在持锁后取得，文件中的记录按时间递增
+func Append(stateDir string, e *Entry) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(stateDir, FileName), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return err
+	}
+	_, werr := f.Write(append(b, '\n'))
+	if cerr := f.Close(); werr == nil {
+		werr = cerr
+	}
+	if forwarder != nil {
+		if _, err := forwarder.Write(b); err != nil {
+			log.Printf("[audit] 转发失败: %v", err)
+		}
+	}
+	return werr
+}
+
+// Filter 查询条件，空字段不过滤
+type Filter struct {
+	Actor  string
+	Action string
+	Runner string
+	Result string
+	Since  time.Time
+	Until  time.Time
+	Limit  int // 最多返回条数，<=0 时为 100
+}
+
+func (f Filter) match(e *Entry) bool {
+	return (f.Actor == "" || e.Actor == f.Actor) &&
+		(f.Action == "" || e.Action == f.Action) &&
+		(f.Runner == "" || e.Runner == f.Runner) &&
+		(f.Result == "" || e.Result == f.Result) &&
+		(f.Since.IsZero() || !e.Time.Before(f.Since)) &&
+		(f.Until.IsZero() || e.Time.Before(f.Until))
+}
+
+// Query 按条件返回记录，最新的在前。从文件末尾向前读取，凑满 Limit 条或读到早于 Since 的记录即停止；
+// 只在确定读取范围时持锁，不阻塞其间的写入
+func Query(stateDir string, f Filter) ([]Entry, error) {
+	if f.Limit <= 0 {
+		f.Limit = 100
+	}
+	mu.Lock()
+	file, err := os.Open(filepath.Join(stateDir, FileName))
+	var size int64
+	if err == nil {
+		var st os.FileInfo
+		if st, err = file.Stat(); err == nil {
+			size = st.Size()
+		} else {
+			file.Close()
+		}
+	}
+	mu.Unlock()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Entry{}, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+	matched := []Entry{}
+	err = scanLinesBackward(file, size, func(line []byte) bool {
+		var e Entry
+		if json.Unmarshal(line, &e) != nil {
+			return true
+		}
+		if !f.Since.IsZero() && e.Time.Before(f.Since) {
+			return false
+		}
+		if f.match(&e) {
+			matched = append(matched, e)
+		}
+		return len(matched) < f.Limit
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matched, nil
+}
+
+// scanLinesBackward 从 size 处向前逐行读取 r，对每个非空行调用 fn（line 仅在调用期间有效），fn 返回 false 时停止；
+// 超过 maxLineBytes 的行跳过
+func scanLinesBackward(r io.ReaderAt, size int64, fn func(line []byte) bool) error {
+	const chunkSize = 64 * 1024
+	var pending []byte // 已读部分开头尚未遇到换行的行尾
+	skipping := false  // pending 所在的行过长，丢弃到上一个换行为止
+	for off := size; off > 0; {
+		n := min(int64(chunkSize), off)
+		off -= n
+		chunk := make([]byte, n, n+int64(len(pending)))
+		if _, err := r.ReadAt(chunk, off); err != nil && err != io.EOF {
+			return err
+		}
+		pending = append(chunk, pending...)
+		for {
+			i := bytes.LastIndexByte(pending, '\n')
+			if i < 0 {
+				break
+			}
+			line := pending[i+1:]
+			pending = pending[:i]
+			if skipping {
+				skipping = false
+				continue
+			}
+			if len(line) > 0 && !fn(line) {
+				return nil
+			}
+		}
+		if len(pending) > maxLineBytes {
+			pending, skipping = nil, true
+		}
+	}
+	if len(pending) > 0 && !skipping {
+		fn(pending)
+	}
+	return nil
+}
+
+// Diff 比较两个可 JSON 序列化的值，按顶层字段返回变更；任一为 nil 时列出另一方的全部字段
+func Diff(before, after any) []Change {
+	b, a := toMap(before), toMap(after)
+	keys := map[string]bool{}
+	for k := range b {
+		keys[k] = true
+	}
+	for k := range a {
+		keys[k] = true
+	}
+	var out []Change
+	for k := range keys {
+		if !reflect.DeepEqual(b[k], a[k]) {
+			out = append(out, Change{Field: k, Before: b[k], After: a[k]})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Field < out[j].Field })
+	return out
+}
+
+func toMap(v any) map[string]any {
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Pointer && reflect.ValueOf(v).IsNil()) {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var m map[string]any
+	_ = json.Unmarshal(b, &m)
+	return m
+}
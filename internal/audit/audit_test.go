@@ -0,0 +1,101 @@
+package audit
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppendQuery(t *testing.T) {
+	dir := t.TempDir()
+	var fwd bytes.Buffer
+	SetForwarder(&fwd)
+	defer SetForwarder(nil)
+	base := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i, e := range []Entry{
+		{Actor: "alice", Action: "runner.add", Runner: "r1", Result: ResultOK},
+		{Actor: "bob", Action: "runner.stop", Runner: "r1", Result: ResultError},
+		{Actor: "alice", Action: "runner.remove", Runner: "r2", Result: ResultOK},
+	} {
+		e.Time = base.Add(time.Duration(i) * time.Minute)
+		if err := Append(dir, &e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := bytes.Count(fwd.Bytes(), []byte(`"actor"`)); n != 3 {
+		t.Errorf("forwarded %d entries, want 3", n)
+	}
+
+	all, err := Query(dir, Filter{})
+	if err != nil || len(all) != 3 || all[0].Action != "runner.remove" {
+		t.Fatalf("query all = %+v, %v", all, err)
+	}
+	if got, _ := Query(dir, Filter{Actor: "alice"}); len(got) != 2 {
+		t.Errorf("actor filter: %d entries", len(got))
+	}
+	if got, _ := Query(dir, Filter{Runner: "r1", Result: ResultError}); len(got) != 1 || got[0].Actor != "bob" {
+		t.Errorf("runner+result filter: %+v", got)
+	}
+	if got, _ := Query(dir, Filter{Since: base.Add(time.Minute), Until: base.Add(2 * time.Minute)}); len(got) != 1 || got[0].Action != "runner.stop" {
+		t.Errorf("time filter: %+v", got)
+	}
+	if got, _ := Query(dir, Filter{Limit: 1}); len(got) != 1 {
+		t.Errorf("limit: %d entries", len(got))
+	}
+	if got, err := Query(t.TempDir(), Filter{}); err != nil || len(got) != 0 {
+		t.Errorf("missing file: %v, %v", got, err)
+	}
+}
+
+func TestQuery_ReadsBackward(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	appendN := func(from, n int) {
+		for i := from; i < from+n; i++ {
+			e := Entry{Time: base.Add(time.Duration(i) * time.Second), Actor: "u" + strconv.Itoa(i), Action: "runner.stop"}
+			if err := Append(dir, &e); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	appendN(0, 1000)
+	// 超长行跨越多个读取块，应整行跳过
+	f, err := os.OpenFile(filepath.Join(dir, FileName), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"actor":"` + strings.Repeat("x", maxLineBytes+10) + "\"}\n")
+	f.Close()
+	appendN(1000, 1000)
+
+	got, err := Query(dir, Filter{Limit: 3})
+	if err != nil || len(got) != 3 || got[0].Actor != "u1999" || got[2].Actor != "u1997" {
+		t.Fatalf("latest = %+v, %v", got, err)
+	}
+	if got, _ := Query(dir, Filter{Actor: "u0"}); len(got) != 1 {
+		t.Errorf("oldest entry across long line: %+v", got)
+	}
+	if got, _ := Query(dir, Filter{Since: base.Add(1995 * time.Second), Limit: 100}); len(got) != 5 {
+		t.Errorf("since: %d entries", len(got))
+	}
+}
+
+func TestDiff(t *testing.T) {
+	type item struct {
+		Name   string   `json:"name"`
+		Target string   `json:"target"`
+		Labels []string `json:"labels,omitempty"`
+	}
+	changes := Diff(&item{Name: "r1", Target: "o1"}, &item{Name: "r1", Target: "o2", Labels: []string{"gpu"}})
+	if len(changes) != 2 || changes[0].Field != "labels" || changes[1].Field != "target" || changes[1].After != "o2" {
+		t.Errorf("changes = %+v", changes)
+	}
+	var none *item
+	if got := Diff(none, &item{Name: "r1"}); len(got) != 2 {
+		t.Errorf("add diff = %+v", got)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package audit
+
+import (
+	"io"
+	"log/syslog"
+)
+
+// DialSyslog 连接 syslog：network 与 addr 为空时使用本机 syslog，否则如 udp、10.0.0.5:514
+func DialSyslog(network, addr, tag string) (io.Writer, error) {
+	return syslog.Dial(network, addr, syslog.LOG_INFO|syslog.LOG_AUTH, tag)
+}
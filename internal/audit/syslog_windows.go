@@ -0,0 +1,13 @@
+//go:build windows
+
+package audit
+
+import (
+	"errors"
+	"io"
+)
+
+// DialSyslog Windows 上不支持 syslog 转发
+func DialSyslog(network, addr, tag string) (io.Writer, error) {
+	return nil, errors.New("windows 不支持 syslog 转发")
+}
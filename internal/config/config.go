@@ -1,15 +1,26 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"reflect"
 	"regexp"
+	"runtime"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/lab-dev/github-actions-runner-manager/internal/cron"
 	"gopkg.in/yaml.v3"
 )
 
@@ -17,6 +28,67 @@ import (
 var mu sync.Mutex
 var runnerContainerNameSanitizeRe = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
 
+// StateDirName Manager 自身状态（任务历史等）所在目录名，位于 base_path 下，不可用作 runner 名称或路径
+const StateDirName = ".fleet"
+
+// CacheDirName runner 安装包共享下载缓存目录名，位于 base_path 下，同样为保留目录名
+const CacheDirName = ".cache"
+
+// DefaultRunnerVersion 未配置 runner_version 时安装的 actions runner 版本
+const DefaultRunnerVersion = "2.331.0"
+
+var runnerVersionRe = regexp.MustCompile(`^[0-9]+\.[0-9]+\.[0-9]+$`)
+
+// ValidRunnerVersion 判断是否为 x.y.z 形式的 actions runner 版本号
+func ValidRunnerVersion(v string) bool {
+	return runnerVersionRe.MatchString(v)
+}
+
+// runner 架构，取值与 actions/runner 安装包名一致（actions-runner-linux-<arch>-<version>.tar.gz）
+const (
+	ArchX64   = "x64"
+	ArchARM64 = "arm64"
+	ArchARM   = "arm"
+)
+
+// NormalizeArch 将架构名规范为 x64/arm64/arm，兼容 Go 与 uname 的写法（amd64、x86_64、aarch64、armv7l 等）；不支持时返回 false
+func NormalizeArch(s string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "x64", "amd64", "x86_64":
+		return ArchX64, true
+	case "arm64", "aarch64":
+		return ArchARM64, true
+	case "arm", "armv7", "armv7l", "armhf":
+		return ArchARM, true
+	}
+	return "", false
+}
+
+// HostArch 返回 Manager 所在主机的 runner 架构，未识别时为 x64
+func HostArch() string {
+	if a, ok := NormalizeArch(runtime.GOARCH); ok {
+		return a
+	}
+	return ArchX64
+}
+
+// DockerPlatform 返回架构对应的 docker --platform 值，如 linux/arm64
+func DockerPlatform(arch string) string {
+	switch arch {
+	case ArchARM64:
+		return "linux/arm64"
+	case ArchARM:
+		return "linux/arm/v7"
+	}
+	return "linux/amd64"
+}
+
+// 后台安装+注册 worker 默认值
+const (
+	DefaultRegistrationWorkers   = 4
+	DefaultRegistrationPerTarget = 2
+)
+
 // DefaultRunnerImageRepo 默认 Runner 镜像仓库名，与 Manager 同仓库
 const DefaultRunnerImageRepo = "ghcr.io/soulteary/runner-fleet"
 
@@ -102,6 +174,175 @@ func applyEnvOverrides(c *Config) {
 type Config struct {
 	Server  ServerConfig  `yaml:"server"`
 	Runners RunnersConfig `yaml:"runners"`
+	Auth    *AuthConfig   `yaml:"auth,omitempty"`
+	Audit   *AuditConfig  `yaml:"audit,omitempty"`
+	GitOps  *GitOpsConfig `yaml:"gitops,omitempty"`
+}
+
+// GitOpsConfig 从 git 仓库同步 runner 列表；配置后 runners.items 由仓库中的定义文件管理，不能再通过界面或 API 修改
+type GitOpsConfig struct {
+	Repo            string `yaml:"repo"`                       // 仓库地址：https、ssh 或本地路径，凭据使用宿主机的 git 配置（SSH key、credential helper）
+	Branch          string `yaml:"branch,omitempty"`           // 默认 main
+	File            string `yaml:"file,omitempty"`             // 仓库内定义文件的相对路径，默认 fleet.yaml
+	IntervalSeconds int    `yaml:"interval_seconds,omitempty"` // 拉取间隔，默认 300，最小 30
+	AutoApply       bool   `yaml:"auto_apply,omitempty"`       // 为 true 时校验通过即应用，否则等待 POST /api/gitops/apply 批准
+}
+
+// 仓库同步默认值
+const (
+	DefaultGitOpsBranch   = "main"
+	DefaultGitOpsFile     = "fleet.yaml"
+	DefaultGitOpsInterval = 300
+)
+
+// GitManaged runner 列表是否由 git 仓库管理
+func (c *Config) GitManaged() bool {
+	return c.GitOps != nil && strings.TrimSpace(c.GitOps.Repo) != ""
+}
+
+// EffectiveBranch 返回要同步的分支
+func (g *GitOpsConfig) EffectiveBranch() string {
+	if b := strings.TrimSpace(g.Branch); b != "" {
+		return b
+	}
+	return DefaultGitOpsBranch
+}
+
+// EffectiveFile 返回仓库内定义文件的相对路径
+func (g *GitOpsConfig) EffectiveFile() string {
+	if f := strings.TrimSpace(g.File); f != "" {
+		return f
+	}
+	return DefaultGitOpsFile
+}
+
+// Interval 返回拉取间隔
+func (g *GitOpsConfig) Interval() time.Duration {
+	if g.IntervalSeconds > 0 {
+		return time.Duration(g.IntervalSeconds) * time.Second
+	}
+	return DefaultGitOpsInterval * time.Second
+}
+
+// AuditConfig 审计日志配置；日志始终写入 base_path/.fleet/audit.jsonl，此处仅配置转发
+type AuditConfig struct {
+	Syslog *SyslogConfig `yaml:"syslog,omitempty"` // 同时转发到 syslog
+}
+
+// SyslogConfig syslog 转发目标
+type SyslogConfig struct {
+	Network string `yaml:"network,omitempty"` // udp、tcp；与 address 同时为空时使用本机 syslog
+	Address string `yaml:"address,omitempty"` // 如 10.0.0.5:514
+	Tag     string `yaml:"tag,omitempty"`     // 默认 runner-fleet
+}
+
+// AuthConfig 登录相关配置；Basic Auth 与 API Token 不在此配置（分别见环境变量与 /api/tokens）
+type AuthConfig struct {
+	OIDC   *OIDCConfig  `yaml:"oidc,omitempty"`   // Web 界面 OIDC 单点登录，client secret 取环境变量 OIDC_CLIENT_SECRET
+	Access []AccessRule `yaml:"access,omitempty"` // 按 target 限制可管理的 runner；为空时不限制
+}
+
+// AccessRule 授权规则：principals 中的主体可管理 target 匹配 targets 中任一模式的 runner。
+// 配置了任意规则后，SSO 用户与 API Token 仅能管理匹配规则授予的 target；Basic Auth 不受限
+type AccessRule struct {
+	Principals []string `yaml:"principals"` // group:<组名>、user:<用户名>、token:<Token 名称>
+	Targets    []string `yaml:"targets"`    // 模式按 path.Match 匹配 target，如 acme/payments-*；* 不跨越 /，单独的 * 表示全部
+}
+
+// 授权规则中的主体前缀
+const (
+	AccessPrincipalGroup = "group:"
+	AccessPrincipalUser  = "user:"
+	AccessPrincipalToken = "token:"
+)
+
+// AccessPatterns 返回主体可管理的 target 模式；未配置任何规则时 restricted 为 false（不限制）。
+// principals 为调用者自身的标识，如 user:alice、group:payments
+func (a *AuthConfig) AccessPatterns(principals []string) (patterns []string, restricted bool) {
+	if a == nil || len(a.Access) == 0 {
+		return nil, false
+	}
+	for _, rule := range a.Access {
+		if slices.ContainsFunc(rule.Principals, func(p string) bool { return slices.Contains(principals, p) }) {
+			patterns = append(patterns, rule.Targets...)
+		}
+	}
+	return patterns, true
+}
+
+// TargetAllowed target 是否匹配任一模式；单独的 * 匹配全部
+func TargetAllowed(patterns []string, target string) bool {
+	for _, p := range patterns {
+		if p == "*" {
+			return true
+		}
+		if ok, _ := path.Match(p, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// 登录用户的角色，与 API Token 的权限范围一致
+const (
+	RoleRead    = "read"
+	RoleOperate = "operate"
+	RoleAdmin   = "admin"
+	RoleNone    = "none" // 仅用于 default_role，表示未映射到角色的用户不可登录
+)
+
+var roleRank = map[string]int{RoleRead: 1, RoleOperate: 2, RoleAdmin: 3}
+
+// OIDCConfig OIDC 授权码流程配置
+type OIDCConfig struct {
+	Issuer            string            `yaml:"issuer"`                        // 如 https://login.example.com/realms/acme，须与 discovery 中的 issuer 一致
+	ClientID          string            `yaml:"client_id"`                     // IdP 中登记的客户端 ID
+	RedirectURL       string            `yaml:"redirect_url"`                  // 回调地址，形如 https://fleet.example.com/auth/callback，须在 IdP 中登记
+	Scopes            []string          `yaml:"scopes,omitempty"`              // 默认 openid profile email；组信息需额外 scope 时在此添加（如 groups）
+	GroupsClaim       string            `yaml:"groups_claim,omitempty"`        // ID Token 中组列表所在声明，默认 groups
+	AllowedGroups     []string          `yaml:"allowed_groups,omitempty"`      // 非空时仅这些组的成员可登录
+	GroupRoles        map[string]string `yaml:"group_roles,omitempty"`         // 组 -> read/operate/admin，属于多个组时取最高
+	DefaultRole       string            `yaml:"default_role,omitempty"`        // 未匹配 group_roles 时的角色，默认 read；none 表示拒绝登录
+	SessionTTLMinutes int               `yaml:"session_ttl_minutes,omitempty"` // 会话有效期，默认 720（12 小时）
+}
+
+// EffectiveGroupsClaim 返回组列表声明名，默认 groups
+func (o *OIDCConfig) EffectiveGroupsClaim() string {
+	if o.GroupsClaim != "" {
+		return o.GroupsClaim
+	}
+	return "groups"
+}
+
+// SessionTTL 返回会话有效期，默认 12 小时
+func (o *OIDCConfig) SessionTTL() time.Duration {
+	if o.SessionTTLMinutes > 0 {
+		return time.Duration(o.SessionTTLMinutes) * time.Minute
+	}
+	return 12 * time.Hour
+}
+
+// RoleFor 按用户所在组返回角色；不在 allowed_groups 中或未映射且 default_role 为 none 时返回 false
+func (o *OIDCConfig) RoleFor(groups []string) (string, bool) {
+	if len(o.AllowedGroups) > 0 && !slices.ContainsFunc(groups, func(g string) bool { return slices.Contains(o.AllowedGroups, g) }) {
+		return "", false
+	}
+	role := ""
+	for _, g := range groups {
+		if r := o.GroupRoles[g]; roleRank[r] > roleRank[role] {
+			role = r
+		}
+	}
+	if role != "" {
+		return role, true
+	}
+	switch o.DefaultRole {
+	case "":
+		return RoleRead, true
+	case RoleNone:
+		return "", false
+	}
+	return o.DefaultRole, true
 }
 
 // ServerConfig HTTP 服务配置
@@ -124,15 +365,81 @@ type RunnersConfig struct {
 	JobDockerBackend string `yaml:"job_docker_backend"` // dind | host-socket | none，默认 dind
 	DindHost         string `yaml:"dind_host"`          // 仅 job_docker_backend=dind 时有效，DinD 主机名，默认 runner-dind
 	VolumeHostPath   string `yaml:"volume_host_path"`   // 容器模式下宿主机上 runners 根路径，供 docker create -v 使用；Manager 自身在容器内时必填（如 /data/runners）
+
+	Cleanup *CleanupPolicy `yaml:"cleanup,omitempty"` // 全局 _work 清理策略，可被 items[].cleanup 覆盖
+
+	UsageThresholds *UsageThresholds `yaml:"usage_thresholds,omitempty"` // 资源占用告警阈值，超出时在 API 与界面中标记
+
+	Registration *RegistrationConfig `yaml:"registration,omitempty"` // 后台安装+注册任务的并发设置
+
+	RunnerVersion  string `yaml:"runner_version,omitempty"`  // 新安装 runner 使用的 actions runner 版本，默认 DefaultRunnerVersion；items[].runner_version 可覆盖
+	RunnerManifest string `yaml:"runner_manifest,omitempty"` // 安装包校验值 manifest（YAML：版本 -> 平台 -> sha256），补充内置条目
+	// RunnerChecksumFromReleaseNotes manifest 未收录的版本或平台改用 actions/runner 发布说明中公布的校验值；默认关闭，未收录时拒绝安装
+	RunnerChecksumFromReleaseNotes bool `yaml:"runner_checksum_from_release_notes,omitempty"`
+
+	Arch            string            `yaml:"arch,omitempty"`             // runner 架构 x64/arm64/arm，默认与 Manager 主机一致；items[].arch 可覆盖
+	ContainerImages map[string]string `yaml:"container_images,omitempty"` // 容器模式下按架构指定 Runner 镜像，未列出的架构使用 container_image
+}
+
+// RegistrationConfig 后台安装+注册 worker 池设置，0 或省略时使用默认值
+type RegistrationConfig struct {
+	Workers   int `yaml:"workers,omitempty"`    // 同时执行的任务数，默认 4
+	PerTarget int `yaml:"per_target,omitempty"` // 同一 org/repo 同时执行的任务数上限，默认 2
+}
+
+// RegistrationWorkers 返回 worker 数，未配置时为 DefaultRegistrationWorkers
+func (r RunnersConfig) RegistrationWorkers() int {
+	if r.Registration != nil && r.Registration.Workers > 0 {
+		return r.Registration.Workers
+	}
+	return DefaultRegistrationWorkers
+}
+
+// RegistrationPerTarget 返回单个 org/repo 的并发上限，未配置时为 DefaultRegistrationPerTarget（不超过 worker 数）
+func (r RunnersConfig) RegistrationPerTarget() int {
+	n := DefaultRegistrationPerTarget
+	if r.Registration != nil && r.Registration.PerTarget > 0 {
+		n = r.Registration.PerTarget
+	}
+	return min(n, r.RegistrationWorkers())
+}
+
+// UsageThresholds runner 资源占用告警阈值，0 表示不检查该项
+type UsageThresholds struct {
+	InstallDirMB int64   `yaml:"install_dir_mb,omitempty"` // 安装目录总大小（MB）
+	WorkDirMB    int64   `yaml:"work_dir_mb,omitempty"`    // _work 目录大小（MB）
+	CPUPercent   float64 `yaml:"cpu_percent,omitempty"`    // 进程树/容器 CPU 使用率（100 为一个核）
+	MemoryMB     int64   `yaml:"memory_mb,omitempty"`      // 进程树 RSS / 容器内存（MB）
+}
+
+// CleanupPolicy runner _work 目录清理策略；仅在 runner 空闲（无 Job 执行）时清理，任一条件满足即触发
+type CleanupPolicy struct {
+	AfterJob            bool   `yaml:"after_job,omitempty" json:"after_job,omitempty"`                           // 每次 Job 结束后清理
+	MaxWorkSizeMB       int64  `yaml:"max_work_size_mb,omitempty" json:"max_work_size_mb,omitempty"`             // _work 超过该大小（MB）时清理，0 为不限
+	MaxDiskUsagePercent int    `yaml:"max_disk_usage_percent,omitempty" json:"max_disk_usage_percent,omitempty"` // runner 目录所在磁盘使用率超过该百分比时清理（距上次清理至少 30 分钟），0 为不限
+	Schedule            string `yaml:"schedule,omitempty" json:"schedule,omitempty"`                             // 标准 5 段 cron 表达式（分 时 日 月 周），按 Manager/Agent 本地时区
+}
+
+// Enabled 是否配置了任一清理条件
+func (p *CleanupPolicy) Enabled() bool {
+	if p == nil {
+		return false
+	}
+	return p.AfterJob || p.MaxWorkSizeMB > 0 || p.MaxDiskUsagePercent > 0 || strings.TrimSpace(p.Schedule) != ""
 }
 
 // RunnerItem 单个 Runner 配置
 type RunnerItem struct {
-	Name       string   `yaml:"name"`        // 显示名称，也用作目录名
-	Path       string   `yaml:"path"`        // 相对 base_path 的目录，空则用 name
-	TargetType string   `yaml:"target_type"` // org | repo
-	Target     string   `yaml:"target"`      // org 名或 owner/repo
-	Labels     []string `yaml:"labels"`      // 自定义标签
+	Name       string   `yaml:"name" json:"name"`               // 显示名称，也用作目录名
+	Path       string   `yaml:"path" json:"path"`               // 相对 base_path 的目录，空则用 name
+	TargetType string   `yaml:"target_type" json:"target_type"` // org | repo
+	Target     string   `yaml:"target" json:"target"`           // org 名或 owner/repo
+	Labels     []string `yaml:"labels" json:"labels"`           // 自定义标签
+
+	RunnerVersion string `yaml:"runner_version,omitempty" json:"runner_version,omitempty"` // 该 runner 的 actions runner 版本，覆盖 runners.runner_version
+	Arch          string `yaml:"arch,omitempty" json:"arch,omitempty"`                     // 该 runner 的架构，覆盖 runners.arch
+
+	Cleanup *CleanupPolicy `yaml:"cleanup,omitempty" json:"cleanup,omitempty"` // 该 runner 的 _work 清理策略，设置后整体覆盖 runners.cleanup
 }
 
 // InstallPath 返回该 runner 的完整安装路径
@@ -144,6 +451,56 @@ func (r RunnerItem) InstallPath(basePath string) string {
 	return filepath.Join(basePath, filepath.Clean(dir))
 }
 
+// EffectiveCleanup 返回 item 实际生效的清理策略：item 自身配置优先，否则使用全局配置；均未配置返回 nil
+func (r RunnersConfig) EffectiveCleanup(item RunnerItem) *CleanupPolicy {
+	if item.Cleanup != nil {
+		return item.Cleanup
+	}
+	return r.Cleanup
+}
+
+// EffectiveRunnerVersion 返回 item 应安装的 actions runner 版本：item 自身配置优先，其次全局配置，否则 DefaultRunnerVersion
+func (r RunnersConfig) EffectiveRunnerVersion(item RunnerItem) string {
+	if v := strings.TrimSpace(item.RunnerVersion); v != "" {
+		return v
+	}
+	if v := strings.TrimSpace(r.RunnerVersion); v != "" {
+		return v
+	}
+	return DefaultRunnerVersion
+}
+
+// EffectiveArch 返回 item 的 runner 架构：item 自身配置优先，其次全局配置，否则为 Manager 主机架构
+func (r RunnersConfig) EffectiveArch(item RunnerItem) string {
+	for _, v := range []string{item.Arch, r.Arch} {
+		if a, ok := NormalizeArch(v); ok {
+			return a
+		}
+	}
+	return HostArch()
+}
+
+// ContainerImageFor 返回指定架构的 Runner 容器镜像：container_images 中的条目优先，否则为 container_image（多架构镜像）
+func (r RunnersConfig) ContainerImageFor(arch string) string {
+	if img := strings.TrimSpace(r.ContainerImages[arch]); img != "" {
+		return img
+	}
+	if r.ContainerImage != "" {
+		return r.ContainerImage
+	}
+	return DefaultRunnerContainerImage()
+}
+
+// StateDir 返回 Manager 状态目录（base_path/.fleet）
+func (r RunnersConfig) StateDir() string {
+	return filepath.Join(r.BasePath, StateDirName)
+}
+
+// CacheDir 返回 runner 安装包共享下载缓存目录（base_path/.cache）
+func (r RunnersConfig) CacheDir() string {
+	return filepath.Join(r.BasePath, CacheDirName)
+}
+
 // defaultConfig 返回与 Load 中默认值一致的配置（不读文件、不应用环境变量），用于文件不存在时从 env 生成配置。
 func defaultConfig() *Config {
 	return &Config{
@@ -185,6 +542,23 @@ func Load(path string) (*Config, error) {
 		}
 		return nil, err
 	}
+	return Parse(data)
+}
+
+// Parse 解析配置内容并补全默认值、应用环境变量与校验，与 Load 读取文件后的处理一致
+func Parse(data []byte) (*Config, error) {
+	c, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := Validate(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Decode 解析配置内容并补全默认值、应用环境变量，不做校验
+func Decode(data []byte) (*Config, error) {
 	var c Config
 	if err := yaml.Unmarshal(data, &c); err != nil {
 		return nil, err
@@ -220,14 +594,83 @@ func Load(path string) (*Config, error) {
 		c.Runners.DindHost = "runner-dind"
 	}
 	applyEnvOverrides(&c)
-	if err := Validate(&c); err != nil {
-		return nil, err
-	}
 	return &c, nil
 }
 
+func validateOIDC(o *OIDCConfig) error {
+	if strings.TrimSpace(o.Issuer) == "" || strings.TrimSpace(o.ClientID) == "" || strings.TrimSpace(o.RedirectURL) == "" {
+		return fmt.Errorf("auth.oidc 需填写 issuer、client_id、redirect_url")
+	}
+	for _, v := range []string{o.Issuer, o.RedirectURL} {
+		if u, err := url.Parse(v); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("auth.oidc 中的地址 %q 不是有效的 http(s) URL", v)
+		}
+	}
+	for g, r := range o.GroupRoles {
+		if _, ok := roleRank[r]; !ok {
+			return fmt.Errorf("auth.oidc.group_roles[%q] 仅支持 read/operate/admin，当前为 %q", g, r)
+		}
+	}
+	if _, ok := roleRank[o.DefaultRole]; !ok && o.DefaultRole != "" && o.DefaultRole != RoleNone {
+		return fmt.Errorf("auth.oidc.default_role 仅支持 read/operate/admin/none，当前为 %q", o.DefaultRole)
+	}
+	if o.SessionTTLMinutes < 0 {
+		return fmt.Errorf("auth.oidc.session_ttl_minutes 不能为负数")
+	}
+	return nil
+}
+
 // Validate 校验配置：同名 Runner 冲突等
 func Validate(c *Config) error {
+	if c.Auth != nil && c.Auth.OIDC != nil {
+		if err := validateOIDC(c.Auth.OIDC); err != nil {
+			return err
+		}
+	}
+	if c.Audit != nil && c.Audit.Syslog != nil {
+		if n := c.Audit.Syslog.Network; n != "" && n != "udp" && n != "tcp" {
+			return fmt.Errorf("audit.syslog.network 仅支持 udp/tcp，当前为 %q", n)
+		}
+		if (c.Audit.Syslog.Network == "") != (c.Audit.Syslog.Address == "") {
+			return fmt.Errorf("audit.syslog 的 network 与 address 需同时填写（都不填时使用本机 syslog）")
+		}
+	}
+	if g := c.GitOps; g != nil {
+		if strings.TrimSpace(g.Repo) == "" {
+			return fmt.Errorf("gitops.repo 必填")
+		}
+		// 以 - 开头的值会被 git 当作命令行选项
+		if strings.HasPrefix(strings.TrimSpace(g.Repo), "-") {
+			return fmt.Errorf("gitops.repo 不能以 - 开头，当前为 %q", g.Repo)
+		}
+		if strings.HasPrefix(g.EffectiveBranch(), "-") {
+			return fmt.Errorf("gitops.branch 不能以 - 开头，当前为 %q", g.Branch)
+		}
+		if g.IntervalSeconds != 0 && g.IntervalSeconds < 30 {
+			return fmt.Errorf("gitops.interval_seconds 不能小于 30，当前为 %d", g.IntervalSeconds)
+		}
+		f := g.EffectiveFile()
+		if filepath.IsAbs(f) || f == ".." || strings.HasPrefix(filepath.Clean(f), ".."+string(filepath.Separator)) {
+			return fmt.Errorf("gitops.file 须为仓库内的相对路径，当前为 %q", g.File)
+		}
+	}
+	if c.Auth != nil {
+		for i, rule := range c.Auth.Access {
+			if len(rule.Principals) == 0 || len(rule.Targets) == 0 {
+				return fmt.Errorf("auth.access[%d] 需填写 principals 与 targets", i)
+			}
+			for _, p := range rule.Principals {
+				if !strings.HasPrefix(p, AccessPrincipalGroup) && !strings.HasPrefix(p, AccessPrincipalUser) && !strings.HasPrefix(p, AccessPrincipalToken) {
+					return fmt.Errorf("auth.access[%d].principals 须以 group:、user: 或 token: 开头，当前为 %q", i, p)
+				}
+			}
+			for _, t := range rule.Targets {
+				if _, err := path.Match(t, ""); err != nil {
+					return fmt.Errorf("auth.access[%d].targets 模式 %q 无效: %v", i, t, err)
+				}
+			}
+		}
+	}
 	seen := make(map[string]bool)
 	seenContainerNames := make(map[string]string)
 	seenInstallPaths := make(map[string]string)
@@ -261,6 +704,28 @@ func Validate(c *Config) error {
 			return fmt.Errorf("container_mode=true 且 base_path=%s 时必须设置 runners.volume_host_path（宿主机 runners 根目录绝对路径）", c.Runners.BasePath)
 		}
 	}
+	if err := validateCleanupPolicy(c.Runners.Cleanup); err != nil {
+		return fmt.Errorf("runners.cleanup: %w", err)
+	}
+	if t := c.Runners.UsageThresholds; t != nil && (t.InstallDirMB < 0 || t.WorkDirMB < 0 || t.CPUPercent < 0 || t.MemoryMB < 0) {
+		return fmt.Errorf("runners.usage_thresholds 各阈值不能为负数")
+	}
+	if v := strings.TrimSpace(c.Runners.RunnerVersion); v != "" && !runnerVersionRe.MatchString(v) {
+		return fmt.Errorf("runners.runner_version 格式应为 x.y.z，当前为 %q", v)
+	}
+	if v := strings.TrimSpace(c.Runners.Arch); v != "" {
+		if _, ok := NormalizeArch(v); !ok {
+			return fmt.Errorf("runners.arch 仅支持 x64/arm64/arm，当前为 %q", v)
+		}
+	}
+	for arch := range c.Runners.ContainerImages {
+		if a, ok := NormalizeArch(arch); !ok || a != arch {
+			return fmt.Errorf("runners.container_images 的键须为 x64/arm64/arm，当前为 %q", arch)
+		}
+	}
+	if r := c.Runners.Registration; r != nil && (r.Workers < 0 || r.PerTarget < 0) {
+		return fmt.Errorf("runners.registration.workers/per_target 不能为负数")
+	}
 	for i, item := range c.Runners.Items {
 		name := strings.TrimSpace(item.Name)
 		path := strings.TrimSpace(item.Path)
@@ -275,9 +740,23 @@ func Validate(c *Config) error {
 		if path != "" && !IsSafeRunnerNameOrPath(path) {
 			return fmt.Errorf("runners.items[%d].path 包含非法字符（不允许 .. / \\\\）: %s", i, path)
 		}
+		if isReservedDirName(name) || isReservedDirName(path) {
+			return fmt.Errorf("runners.items[%d] 的 name/path 不可使用保留目录名: %s、%s", i, StateDirName, CacheDirName)
+		}
 		if err := ValidateTarget(targetType, target); err != nil {
 			return fmt.Errorf("runners.items[%d]: %w", i, err)
 		}
+		if err := validateCleanupPolicy(item.Cleanup); err != nil {
+			return fmt.Errorf("runners.items[%d].cleanup: %w", i, err)
+		}
+		if v := strings.TrimSpace(item.RunnerVersion); v != "" && !runnerVersionRe.MatchString(v) {
+			return fmt.Errorf("runners.items[%d].runner_version 格式应为 x.y.z，当前为 %q", i, v)
+		}
+		if v := strings.TrimSpace(item.Arch); v != "" {
+			if _, ok := NormalizeArch(v); !ok {
+				return fmt.Errorf("runners.items[%d].arch 仅支持 x64/arm64/arm，当前为 %q", i, v)
+			}
+		}
 		if seen[name] {
 			return fmt.Errorf("runners.items 中存在同名 Runner: %s", name)
 		}
@@ -309,6 +788,32 @@ func NormalizedContainerName(name string) string {
 	return "github-runner-" + safe
 }
 
+// ValidationErrors 尽量多地列出配置的校验错误（Validate 遇到第一个错误即返回）：
+// 先校验全局设置，再逐个校验 runner，单项都通过后再校验同名、容器名与安装目录冲突。无错误时返回空切片
+func ValidationErrors(c *Config) []string {
+	errs := []string{}
+	global := *c
+	global.Runners.Items = nil
+	if err := Validate(&global); err != nil {
+		// 全局设置无效时逐项校验的结果也都带着同一个错误
+		return append(errs, err.Error())
+	}
+	single := global
+	for i, item := range c.Runners.Items {
+		single.Runners.Items = []RunnerItem{item}
+		if err := Validate(&single); err != nil {
+			errs = append(errs, strings.Replace(err.Error(), "runners.items[0]", fmt.Sprintf("runners.items[%d]", i), 1))
+		}
+	}
+	if len(errs) == 0 {
+		full := *c
+		if err := Validate(&full); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	return errs
+}
+
 // IsSafeRunnerNameOrPath 校验 name/path 不含路径穿越或非法字符（禁止 .. / \）
 func IsSafeRunnerNameOrPath(s string) bool {
 	if s == "" {
@@ -317,6 +822,30 @@ func IsSafeRunnerNameOrPath(s string) bool {
 	return !strings.Contains(s, "..") && !strings.Contains(s, "/") && !strings.Contains(s, "\\")
 }
 
+// validateCleanupPolicy 校验清理策略的阈值范围与 cron 表达式
+func validateCleanupPolicy(p *CleanupPolicy) error {
+	if p == nil {
+		return nil
+	}
+	if p.MaxWorkSizeMB < 0 {
+		return fmt.Errorf("max_work_size_mb 不能为负数")
+	}
+	if p.MaxDiskUsagePercent < 0 || p.MaxDiskUsagePercent > 100 {
+		return fmt.Errorf("max_disk_usage_percent 必须在 0-100 之间")
+	}
+	if s := strings.TrimSpace(p.Schedule); s != "" {
+		if _, err := cron.Parse(s); err != nil {
+			return fmt.Errorf("schedule: %w", err)
+		}
+	}
+	return nil
+}
+
+// isReservedDirName 判断 name/path 是否与 base_path 下的保留目录冲突
+func isReservedDirName(s string) bool {
+	return s == StateDirName || s == CacheDirName
+}
+
 // ValidateTarget 校验 target 格式：org 为组织名（不含 /），repo 为 owner/repo（恰好一个斜杠且两端非空）
 func ValidateTarget(targetType, target string) error {
 	t := strings.TrimSpace(target)
@@ -344,8 +873,14 @@ func ValidateTarget(targetType, target string) error {
 	}
 }
 
-// Save 将配置写回文件（调用方需自行加锁，写操作请使用 LoadAndSave）
+// Save 将配置写回文件（调用方需自行加锁，写操作请使用 LoadAndSave）。先写同目录临时文件并 fsync 再改名，
+// 写入中途崩溃不会留下截断的 config.yaml
 func (c *Config) Save(path string) error {
+	return c.save(path, nil)
+}
+
+// save 写回文件并记录本次写入；loaded 为写入者修改前读取到的文件内容，未知时为 nil
+func (c *Config) save(path string, loaded []byte) error {
 	if err := Validate(c); err != nil {
 		return err
 	}
@@ -353,22 +888,155 @@ func (c *Config) Save(path string) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, data, 0644)
+	if err := writeFileAtomic(path, data, 0644); err != nil {
+		return err
+	}
+	recordWrite(path, loaded, data)
+	return nil
+}
+
+// Write 本进程对配置文件的一次写入：Loaded 为写入者修改前读取到的内容（直接调用 Save 时为 nil），Saved 为写入的内容
+type Write struct {
+	Loaded []byte
+	Saved  []byte
+}
+
+// maxRecordedWrites 每个文件保留的写入记录上限，无人取走时丢弃最早的
+const maxRecordedWrites = 64
+
+var (
+	writesMu sync.Mutex
+	writes   = map[string][]Write{}
+)
+
+func recordWrite(path string, loaded, saved []byte) {
+	writesMu.Lock()
+	defer writesMu.Unlock()
+	path = filepath.Clean(path)
+	list := append(writes[path], Write{Loaded: loaded, Saved: saved})
+	if len(list) > maxRecordedWrites {
+		list = list[len(list)-maxRecordedWrites:]
+	}
+	writes[path] = list
+}
+
+// TakeWrites 返回并清空本进程自上次调用以来对 path 的写入记录（按写入顺序），供热加载区分外部修改与自身写入
+func TakeWrites(path string) []Write {
+	writesMu.Lock()
+	defer writesMu.Unlock()
+	path = filepath.Clean(path)
+	list := writes[path]
+	delete(writes, path)
+	return list
 }
 
-// LoadAndSave 在持锁下加载配置、执行 fn、写回；用于所有修改配置的写操作，避免并发覆盖
+// writeFileAtomic 原子替换 path：写临时文件、fsync、rename，再 fsync 所在目录使改名落盘
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp) // 改名成功后为空操作
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	// 部分平台不支持对目录 fsync，忽略错误
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// ErrLocked 配置文件被其他进程长时间锁定
+var ErrLocked = errors.New("配置文件正被其他进程修改，请稍后重试")
+
+// lockTimeout 等待其他进程释放配置文件锁的最长时间
+const lockTimeout = 10 * time.Second
+
+// LockPath 返回配置文件的锁文件路径（与配置文件同目录的 .<文件名>.lock）
+func LockPath(path string) string {
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".lock")
+}
+
+// ETag 返回 runner 配置的实体标签（带引号），配置任一字段变化都会改变；用于 If-Match 乐观并发控制
+func (r RunnerItem) ETag() string {
+	data, _ := yaml.Marshal(r)
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// ChangedFields 返回两个 runner 配置中取值不同的字段名（配置文件中的字段名，按字母排序）；nil 与空切片视为相同
+func ChangedFields(a, b RunnerItem) []string {
+	var fields []string
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	t := va.Type()
+	for i := 0; i < t.NumField(); i++ {
+		fa, fb := va.Field(i), vb.Field(i)
+		if fa.Kind() == reflect.Slice && fa.Len() == 0 && fb.Len() == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(fa.Interface(), fb.Interface()) {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+			fields = append(fields, name)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// LoadAndSave 在持锁下加载配置、执行 fn、写回；用于所有修改配置的写操作，避免并发覆盖。
+// 由系统发起（无具体操作者）的修改使用此函数，操作者可知时使用 LoadAndSaveBy
 func LoadAndSave(path string, fn func(*Config) error) error {
+	return LoadAndSaveBy(path, "", "", fn)
+}
+
+// LoadAndSaveBy 同 LoadAndSave，写回后在变更历史中记录一个版本；author 为操作者，note 为变更说明。
+// 进程内以 mu、跨进程以文件锁串行化，CLI 或另一个 Manager 同时写同一文件也不会互相覆盖
+func LoadAndSaveBy(path, author, note string, fn func(*Config) error) error {
 	mu.Lock()
 	defer mu.Unlock()
+	unlock, err := lockFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	loaded, _ := os.ReadFile(path) // 文件不存在时由 Load 写入默认配置
 	cfg, err := Load(path)
 	if err != nil {
 		return err
 	}
+	// 先记下当前文件：首次写入时作为初始版本，被手动改过时作为一个手动版本，保证都能回滚
+	recordRevision(path, AuthorManual, "")
 	if err := fn(cfg); err != nil {
 		return err
 	}
 	if err := Validate(cfg); err != nil {
 		return err
 	}
-	return cfg.Save(path)
+	if err := cfg.save(path, loaded); err != nil {
+		return err
+	}
+	if author == "" {
+		author = AuthorSystem
+	}
+	recordRevision(path, author, note)
+	return nil
 }
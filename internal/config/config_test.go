@@ -883,3 +883,225 @@ runners:
 		t.Fatalf("expected container_image %q (last colon separates tag), got %q", want, cfg.Runners.ContainerImage)
 	}
 }
+
+func TestValidate_ReservedStateDirName(t *testing.T) {
+	for _, item := range []RunnerItem{
+		{Name: StateDirName, TargetType: "org", Target: "o1"},
+		{Name: "r1", Path: StateDirName, TargetType: "org", Target: "o1"},
+		{Name: CacheDirName, TargetType: "org", Target: "o1"},
+	} {
+		cfg := &Config{Runners: RunnersConfig{BasePath: "./runners", Items: []RunnerItem{item}}}
+		if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "保留目录名") {
+			t.Errorf("expected reserved dir error for %+v, got %v", item, err)
+		}
+	}
+}
+
+func TestRegistrationConcurrency(t *testing.T) {
+	r := RunnersConfig{}
+	if r.RegistrationWorkers() != DefaultRegistrationWorkers || r.RegistrationPerTarget() != DefaultRegistrationPerTarget {
+		t.Errorf("defaults: workers=%d per_target=%d", r.RegistrationWorkers(), r.RegistrationPerTarget())
+	}
+	r.Registration = &RegistrationConfig{Workers: 1, PerTarget: 3}
+	if r.RegistrationWorkers() != 1 || r.RegistrationPerTarget() != 1 {
+		t.Errorf("per_target should not exceed workers: workers=%d per_target=%d", r.RegistrationWorkers(), r.RegistrationPerTarget())
+	}
+	cfg := &Config{Runners: RunnersConfig{BasePath: "./runners", Registration: &RegistrationConfig{Workers: -1}}}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "runners.registration") {
+		t.Errorf("expected runners.registration error, got %v", err)
+	}
+}
+
+func TestEffectiveRunnerVersion(t *testing.T) {
+	r := RunnersConfig{}
+	item := RunnerItem{Name: "r1"}
+	if v := r.EffectiveRunnerVersion(item); v != DefaultRunnerVersion {
+		t.Errorf("default = %q", v)
+	}
+	r.RunnerVersion = "2.330.0"
+	if v := r.EffectiveRunnerVersion(item); v != "2.330.0" {
+		t.Errorf("fleet = %q", v)
+	}
+	item.RunnerVersion = "2.332.0"
+	if v := r.EffectiveRunnerVersion(item); v != "2.332.0" {
+		t.Errorf("item = %q", v)
+	}
+	cfg := &Config{Runners: RunnersConfig{BasePath: "./runners", Items: []RunnerItem{{Name: "r1", TargetType: "org", Target: "o1", RunnerVersion: "latest"}}}}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "runner_version") {
+		t.Errorf("expected runner_version error, got %v", err)
+	}
+}
+
+func TestOIDCConfig_RoleFor(t *testing.T) {
+	o := &OIDCConfig{
+		AllowedGroups: []string{"platform", "dev"},
+		GroupRoles:    map[string]string{"platform": RoleAdmin, "dev": RoleOperate},
+	}
+	if r, ok := o.RoleFor([]string{"dev", "platform"}); !ok || r != RoleAdmin {
+		t.Errorf("highest role = %q %v", r, ok)
+	}
+	if _, ok := o.RoleFor([]string{"sales"}); ok {
+		t.Error("group outside allowed_groups should be rejected")
+	}
+	o.AllowedGroups = nil
+	if r, ok := o.RoleFor([]string{"sales"}); !ok || r != RoleRead {
+		t.Errorf("default role = %q %v", r, ok)
+	}
+	o.DefaultRole = RoleNone
+	if _, ok := o.RoleFor(nil); ok {
+		t.Error("default_role none should reject unmapped users")
+	}
+	cfg := &Config{Runners: RunnersConfig{BasePath: "./runners"}, Auth: &AuthConfig{OIDC: &OIDCConfig{Issuer: "https://idp", ClientID: "c", RedirectURL: "https://fleet/auth/callback", GroupRoles: map[string]string{"x": "root"}}}}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "group_roles") {
+		t.Errorf("expected group_roles error, got %v", err)
+	}
+	cfg.Auth.OIDC.GroupRoles = nil
+	cfg.Auth.OIDC.RedirectURL = ""
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "redirect_url") {
+		t.Errorf("expected missing redirect_url error, got %v", err)
+	}
+}
+
+func TestAccessPatterns(t *testing.T) {
+	var a *AuthConfig
+	if _, restricted := a.AccessPatterns([]string{"user:alice"}); restricted {
+		t.Error("no rules should not restrict")
+	}
+	a = &AuthConfig{Access: []AccessRule{
+		{Principals: []string{"group:payments", "token:ci-payments"}, Targets: []string{"acme/payments-*"}},
+		{Principals: []string{"user:root"}, Targets: []string{"*"}},
+	}}
+	patterns, restricted := a.AccessPatterns([]string{"user:alice", "group:payments"})
+	if !restricted || !TargetAllowed(patterns, "acme/payments-api") || TargetAllowed(patterns, "acme/billing") || TargetAllowed(patterns, "acme") {
+		t.Errorf("payments patterns = %v", patterns)
+	}
+	if patterns, _ := a.AccessPatterns([]string{"user:bob"}); TargetAllowed(patterns, "acme/payments-api") {
+		t.Error("unmatched principal should see nothing")
+	}
+	if patterns, _ := a.AccessPatterns([]string{"user:root"}); !TargetAllowed(patterns, "other-org") {
+		t.Error("* should match every target")
+	}
+	cfg := &Config{Runners: RunnersConfig{BasePath: "./runners"}, Auth: &AuthConfig{Access: []AccessRule{{Principals: []string{"payments"}, Targets: []string{"acme/*"}}}}}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "principals") {
+		t.Errorf("expected principal prefix error, got %v", err)
+	}
+	cfg.Auth.Access[0] = AccessRule{Principals: []string{"group:payments"}, Targets: []string{"acme/[pay"}}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "targets") {
+		t.Errorf("expected bad pattern error, got %v", err)
+	}
+}
+
+func TestEffectiveArch(t *testing.T) {
+	r := RunnersConfig{}
+	item := RunnerItem{Name: "r1"}
+	if a := r.EffectiveArch(item); a != HostArch() {
+		t.Errorf("default = %q, want host %q", a, HostArch())
+	}
+	r.Arch = "amd64"
+	if a := r.EffectiveArch(item); a != ArchX64 {
+		t.Errorf("fleet = %q", a)
+	}
+	item.Arch = "aarch64"
+	if a := r.EffectiveArch(item); a != ArchARM64 {
+		t.Errorf("item = %q", a)
+	}
+	r.ContainerImage = "img:v1-runner"
+	r.ContainerImages = map[string]string{ArchARM64: "img:v1-runner-arm64"}
+	if img := r.ContainerImageFor(ArchARM64); img != "img:v1-runner-arm64" {
+		t.Errorf("arm64 image = %q", img)
+	}
+	if img := r.ContainerImageFor(ArchX64); img != "img:v1-runner" {
+		t.Errorf("x64 image = %q", img)
+	}
+	cfg := &Config{Runners: RunnersConfig{BasePath: "./runners", Items: []RunnerItem{{Name: "r1", TargetType: "org", Target: "o1", Arch: "sparc"}}}}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "items[0].arch") {
+		t.Errorf("expected arch error, got %v", err)
+	}
+	cfg = &Config{Runners: RunnersConfig{BasePath: "./runners", ContainerImages: map[string]string{"aarch64": "x"}}}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "container_images") {
+		t.Errorf("expected container_images error, got %v", err)
+	}
+}
+
+func TestValidate_CleanupPolicy(t *testing.T) {
+	base := func() *Config {
+		return &Config{Runners: RunnersConfig{BasePath: "./runners", Items: []RunnerItem{{Name: "r1", TargetType: "org", Target: "o1"}}}}
+	}
+	cfg := base()
+	cfg.Runners.Cleanup = &CleanupPolicy{MaxDiskUsagePercent: 101}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "runners.cleanup") {
+		t.Errorf("expected runners.cleanup error, got %v", err)
+	}
+	cfg = base()
+	cfg.Runners.Items[0].Cleanup = &CleanupPolicy{Schedule: "every day"}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "items[0].cleanup") {
+		t.Errorf("expected items[0].cleanup error, got %v", err)
+	}
+	cfg = base()
+	cfg.Runners.Cleanup = &CleanupPolicy{AfterJob: true}
+	cfg.Runners.Items[0].Cleanup = &CleanupPolicy{Schedule: "0 3 * * *"}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := cfg.Runners.EffectiveCleanup(cfg.Runners.Items[0]); got.AfterJob || got.Schedule != "0 3 * * *" {
+		t.Errorf("item policy should override fleet policy, got %+v", got)
+	}
+	if got := cfg.Runners.EffectiveCleanup(RunnerItem{Name: "r2"}); got == nil || !got.AfterJob {
+		t.Errorf("expected fleet policy fallback, got %+v", got)
+	}
+}
+
+func TestValidate_UsageThresholds(t *testing.T) {
+	cfg := &Config{Runners: RunnersConfig{BasePath: "./runners", UsageThresholds: &UsageThresholds{MemoryMB: -1}}}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "usage_thresholds") {
+		t.Errorf("expected usage_thresholds error, got %v", err)
+	}
+	cfg.Runners.UsageThresholds = &UsageThresholds{WorkDirMB: 1024, CPUPercent: 150}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestValidate_GitOps(t *testing.T) {
+	for _, tc := range []struct {
+		gitops *GitOpsConfig
+		want   string
+	}{
+		{&GitOpsConfig{Repo: "https://git.example.com/fleet.git"}, ""},
+		{&GitOpsConfig{Repo: " "}, "gitops.repo"},
+		{&GitOpsConfig{Repo: "/srv/fleet.git", IntervalSeconds: 10}, "interval_seconds"},
+		{&GitOpsConfig{Repo: "/srv/fleet.git", File: "../fleet.yaml"}, "gitops.file"},
+		{&GitOpsConfig{Repo: "/srv/fleet.git", File: "/etc/fleet.yaml"}, "gitops.file"},
+		{&GitOpsConfig{Repo: "--upload-pack=touch /tmp/x"}, "gitops.repo"},
+		{&GitOpsConfig{Repo: "/srv/fleet.git", Branch: "--upload-pack=id"}, "gitops.branch"},
+	} {
+		cfg := &Config{Runners: RunnersConfig{BasePath: "./runners"}, GitOps: tc.gitops}
+		err := Validate(cfg)
+		if tc.want == "" && err != nil || tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)) {
+			t.Errorf("Validate(%+v) = %v, want %q", tc.gitops, err, tc.want)
+		}
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	cfg := &Config{Runners: RunnersConfig{BasePath: "./runners", JobDockerBackend: "bad"}}
+	if errs := ValidationErrors(cfg); len(errs) != 1 || !strings.Contains(errs[0], "job_docker_backend") {
+		t.Errorf("global errs = %v", errs)
+	}
+	cfg = &Config{Runners: RunnersConfig{BasePath: "./runners", Items: []RunnerItem{
+		{Name: "a", TargetType: "org", Target: "o"},
+		{Name: "", TargetType: "org", Target: "o"},
+		{Name: "c", TargetType: "repo", Target: "o"},
+	}}}
+	errs := ValidationErrors(cfg)
+	if len(errs) != 2 || !strings.HasPrefix(errs[0], "runners.items[1].name") || !strings.Contains(errs[1], "owner/repo") {
+		t.Errorf("item errs = %v", errs)
+	}
+	cfg.Runners.Items = []RunnerItem{{Name: "a", TargetType: "org", Target: "o"}, {Name: "a", TargetType: "org", Target: "o"}}
+	if errs := ValidationErrors(cfg); len(errs) != 1 {
+		t.Errorf("duplicate errs = %v", errs)
+	}
+	if errs := ValidationErrors(&Config{Runners: RunnersConfig{BasePath: "./runners"}}); errs == nil || len(errs) != 0 {
+		t.Errorf("valid errs = %#v", errs)
+	}
+}
@@ -0,0 +1,304 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// 变更历史中的特殊操作者
+const (
+	AuthorSystem = "system" // Manager 自身发起的修改（如滚动升级记录版本）
+	AuthorManual = "manual" // 在 Manager 之外直接编辑 config.yaml
+)
+
+// HistoryLimit 保留的历史版本数，超出后删除最旧的
+const HistoryLimit = 100
+
+// ErrRevisionNotFound 历史版本不存在（或已被清理）
+var ErrRevisionNotFound = errors.New("配置版本不存在")
+
+// Revision 配置文件的一个历史版本
+type Revision struct {
+	Rev    int       `json:"rev"`
+	Time   time.Time `json:"time"`
+	Author string    `json:"author"`
+	Note   string    `json:"note,omitempty"`
+	SHA256 string    `json:"sha256"`
+}
+
+// HistoryDir 返回配置文件的历史版本目录：与配置文件同目录的 .<文件名>.history。
+// 不放在 base_path 下，因为 base_path 本身也可能随配置回滚而变化
+func HistoryDir(path string) string {
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".history")
+}
+
+func historyIndex(path string) string {
+	return filepath.Join(HistoryDir(path), "index.json")
+}
+
+func revisionFile(path string, rev int) string {
+	return filepath.Join(HistoryDir(path), fmt.Sprintf("%06d.yaml", rev))
+}
+
+func loadHistory(path string) ([]Revision, error) {
+	b, err := os.ReadFile(historyIndex(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var list []Revision
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func saveHistory(path string, list []Revision) error {
+	b, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(historyIndex(path), b, 0600)
+}
+
+// recordRevision 将当前配置文件记为新版本（调用方需持有 mu）；内容与最新版本相同时不记录。
+// 历史只是辅助，失败时仅记日志，不影响配置写入
+func recordRevision(path, author, note string) {
+	if err := appendRevision(path, author, note); err != nil {
+		log.Printf("警告: 记录配置历史失败: %v", err)
+	}
+}
+
+func appendRevision(path, author, note string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	list, err := loadHistory(path)
+	if err != nil {
+		return err
+	}
+	if len(list) > 0 && list[len(list)-1].SHA256 == hash {
+		return nil
+	}
+	if err := os.MkdirAll(HistoryDir(path), 0700); err != nil {
+		return err
+	}
+	rev := 1
+	if len(list) > 0 {
+		rev = list[len(list)-1].Rev + 1
+	}
+	if author == AuthorManual && note == "" {
+		note = "在 Manager 之外修改"
+		if len(list) == 0 {
+			note = "初始版本"
+		}
+	}
+	if err := writeFileAtomic(revisionFile(path, rev), data, 0600); err != nil {
+		return err
+	}
+	list = append(list, Revision{Rev: rev, Time: time.Now().UTC(), Author: author, Note: note, SHA256: hash})
+	for len(list) > HistoryLimit {
+		_ = os.Remove(revisionFile(path, list[0].Rev))
+		list = list[1:]
+	}
+	return saveHistory(path, list)
+}
+
+// History 返回配置的历史版本，最新的在前
+func History(path string) ([]Revision, error) {
+	mu.Lock()
+	list, err := loadHistory(path)
+	mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	slices.Reverse(list)
+	if list == nil {
+		list = []Revision{}
+	}
+	return list, nil
+}
+
+// RevisionContent 返回某个历史版本的配置文件内容
+func RevisionContent(path string, rev int) ([]byte, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	list, err := loadHistory(path)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.ContainsFunc(list, func(r Revision) bool { return r.Rev == rev }) {
+		return nil, ErrRevisionNotFound
+	}
+	return os.ReadFile(revisionFile(path, rev))
+}
+
+// Rollback 将配置恢复为历史版本 rev 的内容（经 Validate 校验），并记为 author 的一个新版本。
+// 返回回滚前与回滚后的配置，供调用方对齐 runner 状态
+func Rollback(path string, rev int, author string) (prev, restored *Config, err error) {
+	data, err := RevisionContent(path, rev)
+	if err != nil {
+		return nil, nil, err
+	}
+	restored, err = Parse(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("版本 %d 无法通过校验: %w", rev, err)
+	}
+	err = LoadAndSaveBy(path, author, fmt.Sprintf("回滚到版本 %d", rev), func(c *Config) error {
+		cur := *c
+		prev = &cur
+		*c = *restored
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return prev, restored, nil
+}
+
+// DiffRevisions 返回两个版本之间的 unified diff；to 为 0 时与当前配置文件比较
+func DiffRevisions(path string, from, to int) (string, error) {
+	a, err := RevisionContent(path, from)
+	if err != nil {
+		return "", err
+	}
+	toName := fmt.Sprintf("rev %d", to)
+	var b []byte
+	if to == 0 {
+		toName = "current"
+		mu.Lock()
+		b, err = os.ReadFile(path)
+		mu.Unlock()
+	} else {
+		b, err = RevisionContent(path, to)
+	}
+	if err != nil {
+		return "", err
+	}
+	return UnifiedDiff(fmt.Sprintf("rev %d", from), toName, string(a), string(b)), nil
+}
+
+// UnifiedDiff 按行比较 a、b，返回带 3 行上下文的 unified diff；内容相同时返回空串
+func UnifiedDiff(nameA, nameB, a, b string) string {
+	x, y := splitLines(a), splitLines(b)
+	// 最长公共子序列，配置文件只有数百行，O(n*m) 足够
+	n, m := len(x), len(y)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	type op struct {
+		kind byte // ' '、'-'、'+'
+		text string
+		ai   int // 该行在 a 中的行号（从 0 开始），'+' 为插入位置
+		bi   int
+	}
+	var ops []op
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && x[i] == y[j]:
+			ops = append(ops, op{' ', x[i], i, j})
+			i++
+			j++
+		case i < n && (j == m || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, op{'-', x[i], i, j})
+			i++
+		default:
+			ops = append(ops, op{'+', y[j], i, j})
+			j++
+		}
+	}
+
+	const ctxLines = 3
+	var sb strings.Builder
+	for k := 0; k < len(ops); {
+		if ops[k].kind == ' ' {
+			k++
+			continue
+		}
+		start := max(k-ctxLines, 0)
+		end := k
+		// 向后扩展，直到连续超过 2*ctxLines 行未变
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*ctxLines {
+				end = min(end+ctxLines, len(ops))
+				break
+			}
+			end = run
+		}
+		if sb.Len() == 0 {
+			fmt.Fprintf(&sb, "--- %s\n+++ %s\n", nameA, nameB)
+		}
+		var aLen, bLen int
+		for _, o := range ops[start:end] {
+			if o.kind != '+' {
+				aLen++
+			}
+			if o.kind != '-' {
+				bLen++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(ops[start].ai, aLen), hunkRange(ops[start].bi, bLen))
+		for _, o := range ops[start:end] {
+			sb.WriteByte(o.kind)
+			sb.WriteString(o.text)
+			sb.WriteByte('\n')
+		}
+		k = end
+	}
+	return sb.String()
+}
+
+// hunkRange 按 unified diff 约定格式化起始行（从 1 开始）与行数；行数为 0 时起始行为前一行
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+func splitLines(s string) []string {
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
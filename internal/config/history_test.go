@@ -0,0 +1,103 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHistoryAndRollback(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	base := &Config{Runners: RunnersConfig{BasePath: dir, Items: []RunnerItem{{Name: "r1", TargetType: "org", Target: "o1"}}}}
+	if err := base.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadAndSaveBy(path, "alice", "runner.update r1", func(c *Config) error {
+		c.Runners.Items[0].Target = "o2"
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// 在 Manager 之外编辑后再次写入，应先记下手动版本
+	data, _ := os.ReadFile(path)
+	_ = os.WriteFile(path, []byte(strings.Replace(string(data), "target: o2", "target: o3", 1)), 0644)
+	if err := LoadAndSave(path, func(c *Config) error {
+		c.Runners.Items = append(c.Runners.Items, RunnerItem{Name: "r2", TargetType: "org", Target: "o1"})
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// 无变化的写入不产生版本
+	_ = LoadAndSave(path, func(*Config) error { return nil })
+
+	list, err := History(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range list {
+		got = append(got, r.Author)
+	}
+	if want := "system,manual,alice,manual"; strings.Join(got, ",") != want {
+		t.Fatalf("authors = %s, want %s", strings.Join(got, ","), want)
+	}
+	if list[3].Note != "初始版本" || list[0].Rev != 4 {
+		t.Errorf("history = %+v", list)
+	}
+
+	diff, err := DiffRevisions(path, 1, 2)
+	if err != nil || !strings.Contains(diff, "-          target: o1\n+          target: o2\n") {
+		t.Errorf("diff 1..2 = %q, %v", diff, err)
+	}
+	if diff, _ := DiffRevisions(path, 4, 0); diff != "" {
+		t.Errorf("latest vs current should be empty, got %q", diff)
+	}
+	if _, err := DiffRevisions(path, 9, 0); err != ErrRevisionNotFound {
+		t.Errorf("missing rev: %v", err)
+	}
+
+	prev, restored, err := Rollback(path, 1, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prev.Runners.Items) != 2 || len(restored.Runners.Items) != 1 || restored.Runners.Items[0].Target != "o1" {
+		t.Errorf("rollback prev=%+v restored=%+v", prev.Runners.Items, restored.Runners.Items)
+	}
+	cur, _ := Load(path)
+	if len(cur.Runners.Items) != 1 || cur.Runners.Items[0].Target != "o1" {
+		t.Errorf("config after rollback = %+v", cur.Runners.Items)
+	}
+	list, _ = History(path)
+	if list[0].Rev != 5 || list[0].Author != "bob" || list[0].Note != "回滚到版本 1" {
+		t.Errorf("rollback revision = %+v", list[0])
+	}
+}
+
+func TestHistoryRollbackValidates(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	_ = (&Config{Runners: RunnersConfig{BasePath: dir}}).Save(path)
+	_ = LoadAndSave(path, func(c *Config) error { c.Server.Port = 9090; return nil })
+	// 篡改历史版本为非法配置，回滚应被拒绝且不改动当前配置
+	_ = os.WriteFile(filepath.Join(HistoryDir(path), "000001.yaml"), []byte("runners:\n  items:\n    - name: ../x\n"), 0600)
+	if _, _, err := Rollback(path, 1, "bob"); err == nil {
+		t.Fatal("expected validation error")
+	}
+	if cur, _ := Load(path); cur.Server.Port != 9090 {
+		t.Errorf("config changed by failed rollback: port %d", cur.Server.Port)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	b := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\n"
+	want := "--- x\n+++ y\n@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n@@ -8,3 +8,4 @@\n h\n i\n j\n+k\n"
+	if got := UnifiedDiff("x", "y", a, b); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if got := UnifiedDiff("x", "y", a, a); got != "" {
+		t.Errorf("identical: %q", got)
+	}
+}
@@ -0,0 +1,54 @@
+//go:build !windows
+
+package config
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSaveIsAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	c := &Config{Runners: RunnersConfig{BasePath: dir}}
+	if err := c.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if strings.Contains(e.Name(), ".tmp-") {
+			t.Errorf("temp file left behind: %s", e.Name())
+		}
+	}
+	if fi, _ := os.Stat(path); fi.Mode().Perm() != 0644 {
+		t.Errorf("mode = %v", fi.Mode().Perm())
+	}
+}
+
+// TestLoadAndSaveWaitsForOtherProcess 另一个进程持有文件锁时，写入等待其释放
+func TestLoadAndSaveWaitsForOtherProcess(t *testing.T) {
+	if _, err := exec.LookPath("flock"); err != nil {
+		t.Skip("flock(1) not available")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	_ = (&Config{Runners: RunnersConfig{BasePath: dir}}).Save(path)
+	_ = os.WriteFile(LockPath(path), nil, 0644)
+	cmd := exec.Command("flock", LockPath(path), "sleep", "0.5")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Wait()
+	time.Sleep(100 * time.Millisecond)
+	start := time.Now()
+	if err := LoadAndSave(path, func(c *Config) error { c.Server.Port = 9090; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited < 200*time.Millisecond {
+		t.Errorf("write did not wait for lock holder (%s)", waited)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build windows
+
+package config
+
+// lockFile Windows 上暂不支持跨进程文件锁，仅依赖进程内互斥锁
+func lockFile(path string) (unlock func(), err error) {
+	return func() {}, nil
+}
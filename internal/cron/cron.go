@@ -0,0 +1,114 @@
+// Package cron 解析标准 5 段 cron 表达式（分 时 日 月 周），供清理策略等定时任务按分钟匹配。
+// 支持 *、数字、a-b 范围、/n 步长与逗号列表；周取值 0-7（0 与 7 均为周日）。
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule 解析后的 cron 表达式，每段为允许取值的位图
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+}
+
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = []field{
+	{"分钟", 0, 59},
+	{"小时", 0, 23},
+	{"日", 1, 31},
+	{"月", 1, 12},
+	{"周", 0, 7},
+}
+
+// Parse 解析 5 段 cron 表达式
+func Parse(expr string) (*Schedule, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("cron 表达式需为 5 段（分 时 日 月 周），当前为 %q", expr)
+	}
+	var bits [5]uint64
+	for i, p := range parts {
+		b, err := parseField(p, fields[i])
+		if err != nil {
+			return nil, err
+		}
+		bits[i] = b
+	}
+	// 周日可写作 0 或 7，统一到 0
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+	return &Schedule{
+		minute:  bits[0],
+		hour:    bits[1],
+		dom:     bits[2],
+		month:   bits[3],
+		dow:     bits[4],
+		domStar: strings.HasPrefix(parts[2], "*"),
+		dowStar: strings.HasPrefix(parts[4], "*"),
+	}, nil
+}
+
+func parseField(s string, f field) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(s, ",") {
+		rangePart, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			rangePart = item[:i]
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("%s字段步长非法: %q", f.name, item)
+			}
+			step = n
+		}
+		lo, hi := f.min, f.max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			a, b, _ := strings.Cut(rangePart, "-")
+			var err1, err2 error
+			lo, err1 = strconv.Atoi(a)
+			hi, err2 = strconv.Atoi(b)
+			if err1 != nil || err2 != nil || lo > hi {
+				return 0, fmt.Errorf("%s字段范围非法: %q", f.name, item)
+			}
+		default:
+			n, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, fmt.Errorf("%s字段非法: %q", f.name, item)
+			}
+			lo = n
+			if step == 1 {
+				hi = n
+			}
+		}
+		if lo < f.min || hi > f.max {
+			return 0, fmt.Errorf("%s字段超出范围 %d-%d: %q", f.name, f.min, f.max, item)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Match 判断 t 所在分钟是否命中；日与周均被限定时满足其一即可，以 * 开头的字段（如 */2）不算限定（与标准 cron 一致）
+func (s *Schedule) Match(t time.Time) bool {
+	if s.minute&(1<<uint(t.Minute())) == 0 || s.hour&(1<<uint(t.Hour())) == 0 || s.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+	domOK := s.dom&(1<<uint(t.Day())) != 0
+	dowOK := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domOK && dowOK
+	}
+	return domOK || dowOK
+}
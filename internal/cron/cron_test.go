@@ -0,0 +1,53 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse_Invalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) expected error", expr)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	// 2026-03-01 为周日
+	sun := time.Date(2026, 3, 1, 3, 30, 0, 0, time.UTC)
+	tests := []struct {
+		expr string
+		t    time.Time
+		want bool
+	}{
+		{"* * * * *", sun, true},
+		{"30 3 * * *", sun, true},
+		{"31 3 * * *", sun, false},
+		{"*/15 * * * *", sun, true},
+		{"0-10,30 3 * * *", sun, true},
+		{"30 3 * * 7", sun, true},
+		{"30 3 * * 0", sun, true},
+		{"30 3 * * 1-5", sun, false},
+		// 日与周同时限定时满足其一即可
+		{"30 3 15 * 0", sun, true},
+		{"30 3 1 * 1", sun, true},
+		{"30 3 2 * 1", sun, false},
+		{"30 3 * 4 *", sun, false},
+		// 以 * 开头的字段（如 */2）视为未限定：须同时满足隔日与周一
+		{"30 3 */2 * 1", sun.AddDate(0, 0, 8), true},  // 03-09 周一，单数日
+		{"30 3 */2 * 1", sun.AddDate(0, 0, 1), false}, // 03-02 周一，双数日
+		{"30 3 */2 * 1", sun.AddDate(0, 0, 2), false}, // 03-03 单数日，周二
+		{"30 3 1 * */2", sun, true},
+		{"30 3 1 * */2", sun.AddDate(0, 0, 1), false},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.expr, err)
+		}
+		if got := s.Match(tt.t); got != tt.want {
+			t.Errorf("Match(%q, %s) = %v, want %v", tt.expr, tt.t, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// Package events 是 Manager 进程内的事件总线：handler、注册 worker、GitHub 检查与状态监视在状态变化时发布类型化事件，
+// GET /api/events 以 Server-Sent Events 推送给浏览器。最近的事件保留在环形缓冲中，断线重连时按 Last-Event-ID 补发。
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// 事件类型，即 SSE 的 event 字段
+const (
+	TypeRunnerAdded           = "runner.added"
+	TypeRunnerRemoved         = "runner.removed"
+	TypeRunnerUpdated         = "runner.updated"
+	TypeRunnerStatus          = "runner.status" // status/running 变化
+	TypeProbeFailed           = "runner.probe_failed"
+	TypeRegistrationQueued    = "registration.queued"
+	TypeRegistrationStarted   = "registration.started"
+	TypeRegistrationSucceeded = "registration.succeeded"
+	TypeRegistrationFailed    = "registration.failed"
+	TypeGitHubChecked         = "github.checked"
+	TypeUpgradeProgress       = "upgrade.progress"     // 滚动升级中某个 runner 的步骤变化
+	TypeConfigReloaded        = "config.reloaded"      // 外部修改的 config.yaml 已加载并对齐 runner
+	TypeConfigReloadFailed    = "config.reload_failed" // 外部修改的 config.yaml 无法加载，继续使用上一份配置
+)
+
+// Event 单个事件；Data 为类型相关的负载，序列化为 JSON
+type Event struct {
+	ID     int64     `json:"id"`
+	Type   string    `json:"type"`
+	Runner string    `json:"runner,omitempty"`
+	Time   time.Time `json:"time"`
+	Data   any       `json:"data,omitempty"`
+}
+
+// subscriberBuffer 每个订阅者的缓冲；缓冲写满时关闭该订阅者的通道，客户端重连后按 Last-Event-ID 从环形缓冲补发
+const subscriberBuffer = 64
+
+// Bus 发布/订阅总线
+type Bus struct {
+	mu      sync.Mutex
+	nextID  int64
+	history []Event // 环形缓冲，按 ID 升序
+	size    int
+	subs    map[chan Event]struct{}
+}
+
+// NewBus 创建总线，historySize 为保留用于补发的事件数
+func NewBus(historySize int) *Bus {
+	return &Bus{size: historySize, subs: make(map[chan Event]struct{})}
+}
+
+// Publish 发布事件并返回其 ID；缓冲已满的订阅者被移除并关闭通道，不会静默漏掉事件
+func (b *Bus) Publish(typ, runner string, data any) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextID++
+	ev := Event{ID: b.nextID, Type: typ, Runner: runner, Time: time.Now().UTC(), Data: data}
+	b.history = append(b.history, ev)
+	if len(b.history) > b.size {
+		b.history = b.history[len(b.history)-b.size:]
+	}
+	for ch := range b.subs {
+		select {
+		case ch <- ev:
+		default:
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+	return ev.ID
+}
+
+// Subscribe 订阅后续事件，并返回 ID 大于 afterID 的已缓存事件（afterID<=0 时不补发）；使用完毕须调用 cancel。
+// 通道被关闭表示消费过慢已被移除，应以最后收到的事件 ID 重新订阅
+func (b *Bus) Subscribe(afterID int64) (ch <-chan Event, backlog []Event, cancel func()) {
+	c := make(chan Event, subscriberBuffer)
+	b.mu.Lock()
+	b.subs[c] = struct{}{}
+	if afterID > 0 {
+		for _, ev := range b.history {
+			if ev.ID > afterID {
+				backlog = append(backlog, ev)
+			}
+		}
+	}
+	b.mu.Unlock()
+	var once sync.Once
+	return c, backlog, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, c)
+			b.mu.Unlock()
+		})
+	}
+}
+
+// Subscribers 当前订阅数
+func (b *Bus) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+// Default Manager 进程使用的总线
+var Default = NewBus(256)
+
+// Publish 发布到 Default
+func Publish(typ, runner string, data any) int64 { return Default.Publish(typ, runner, data) }
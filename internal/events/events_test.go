@@ -0,0 +1,72 @@
+package events
+
+import "testing"
+
+func TestBus_PublishSubscribe(t *testing.T) {
+	b := NewBus(2)
+	b.Publish(TypeRunnerAdded, "r1", nil)
+	ch, backlog, cancel := b.Subscribe(0)
+	defer cancel()
+	if len(backlog) != 0 {
+		t.Fatalf("afterID=0 should not replay, got %d", len(backlog))
+	}
+	id := b.Publish(TypeRunnerStatus, "r1", map[string]any{"status": "installed"})
+	ev := <-ch
+	if ev.ID != id || ev.Type != TypeRunnerStatus || ev.Runner != "r1" {
+		t.Errorf("unexpected event %+v", ev)
+	}
+	if b.Subscribers() != 1 {
+		t.Errorf("subscribers = %d, want 1", b.Subscribers())
+	}
+	cancel()
+	cancel()
+	if b.Subscribers() != 0 {
+		t.Errorf("subscribers after cancel = %d, want 0", b.Subscribers())
+	}
+}
+
+func TestBus_ReplayAfterID(t *testing.T) {
+	b := NewBus(2)
+	b.Publish(TypeRunnerAdded, "r1", nil)   // 1，超出缓存后丢弃
+	b.Publish(TypeRunnerUpdated, "r1", nil) // 2
+	b.Publish(TypeRunnerRemoved, "r1", nil) // 3
+	_, backlog, cancel := b.Subscribe(1)
+	defer cancel()
+	if len(backlog) != 2 || backlog[0].ID != 2 || backlog[1].ID != 3 {
+		t.Errorf("unexpected backlog %+v", backlog)
+	}
+}
+
+func TestBus_SlowSubscriberDoesNotBlock(t *testing.T) {
+	b := NewBus(8)
+	_, _, cancel := b.Subscribe(0)
+	defer cancel()
+	for i := 0; i < subscriberBuffer*2; i++ {
+		b.Publish(TypeGitHubChecked, "r1", nil)
+	}
+}
+
+func TestBus_OverflowClosesSubscriber(t *testing.T) {
+	b := NewBus(subscriberBuffer * 2)
+	ch, _, cancel := b.Subscribe(0)
+	defer cancel()
+	for i := 0; i <= subscriberBuffer; i++ {
+		b.Publish(TypeGitHubChecked, "r1", nil)
+	}
+	if b.Subscribers() != 0 {
+		t.Errorf("overflowed subscriber still registered")
+	}
+	var last int64
+	for ev := range ch {
+		last = ev.ID
+	}
+	if last != subscriberBuffer {
+		t.Errorf("last delivered ID = %d, want %d", last, subscriberBuffer)
+	}
+	// 以最后收到的 ID 重新订阅，补发溢出的事件
+	_, backlog, cancel2 := b.Subscribe(last)
+	defer cancel2()
+	if len(backlog) != 1 || backlog[0].ID != subscriberBuffer+1 {
+		t.Errorf("replay after overflow = %+v", backlog)
+	}
+}
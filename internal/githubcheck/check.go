@@ -10,11 +10,15 @@ import (
 	"time"
 
 	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/lab-dev/github-actions-runner-manager/internal/events"
+	"github.com/lab-dev/github-actions-runner-manager/internal/metrics"
 	"github.com/lab-dev/github-actions-runner-manager/internal/runner"
 )
 
+// APIBase GitHub REST API 地址；测试中替换为本地服务
+var APIBase = "https://api.github.com"
+
 const (
-	apiBase         = "https://api.github.com"
 	apiTimeout      = 30 * time.Second
 	apiPerPage      = 100                   // 单页数量，减少漏判（GitHub 默认 30）
 	runnerTokenFile = ".github_check_token" // 各 runner 目录下可选文件，内容为用于 List runners API 的 PAT
@@ -27,7 +31,8 @@ type githubRunnersResponse struct {
 		ID     int64  `json:"id"`
 		Name   string `json:"name"`
 		OS     string `json:"os"`
-		Status string `json:"status"`
+		Status string `json:"status"` // online / offline
+		Busy   bool   `json:"busy"`
 	} `json:"runners"`
 }
 
@@ -39,14 +44,25 @@ func Run(cfg *config.Config) {
 	}
 	client := &http.Client{Timeout: apiTimeout}
 	for _, item := range cfg.Runners.Items {
-		installDir := item.InstallPath(cfg.Runners.BasePath)
-		token := tokenForRunner(installDir)
-		if token == "" {
-			continue
-		}
-		registered := checkOne(client, token, item.TargetType, item.Target, item.Name)
-		_ = runner.WriteGitHubStatus(installDir, registered)
+		check(client, cfg, item)
+	}
+}
+
+// Check 立即检查单个 runner 并写入 .github_status.json；未配置 .github_check_token 时返回 ok=false
+func Check(cfg *config.Config, item config.RunnerItem) (st runner.GitHubStatus, ok bool) {
+	return check(&http.Client{Timeout: apiTimeout}, cfg, item)
+}
+
+func check(client *http.Client, cfg *config.Config, item config.RunnerItem) (runner.GitHubStatus, bool) {
+	installDir := item.InstallPath(cfg.Runners.BasePath)
+	token := tokenForRunner(installDir)
+	if token == "" {
+		return runner.GitHubStatus{}, false
 	}
+	st := checkOne(client, token, item.TargetType, item.Target, item.Name)
+	_ = runner.WriteGitHubStatus(installDir, st)
+	events.Publish(events.TypeGitHubChecked, item.Name, st)
+	return st, true
 }
 
 // tokenForRunner 返回该 runner 用于 GitHub 检查的 token：从 installDir 下的 .github_check_token 读取，不存在或为空则返回空
@@ -64,11 +80,12 @@ func isValidTargetFormat(targetType, target string) bool {
 	return config.ValidateTarget(tt, target) == nil
 }
 
-func checkOne(client *http.Client, token, targetType, target, runnerName string) bool {
+func checkOne(client *http.Client, token, targetType, target, runnerName string) runner.GitHubStatus {
+	var st runner.GitHubStatus
 	raw := strings.TrimSpace(target)
 	tt := strings.ToLower(strings.TrimSpace(targetType))
 	if !isValidTargetFormat(tt, target) {
-		return false
+		return st
 	}
 	var path string
 	if tt == "org" {
@@ -77,30 +94,49 @@ func checkOne(client *http.Client, token, targetType, target, runnerName string)
 		// repo
 		path = "/repos/" + raw + "/actions/runners"
 	}
-	url := apiBase + path + "?per_page=" + strconv.Itoa(apiPerPage)
+	url := APIBase + path + "?per_page=" + strconv.Itoa(apiPerPage)
 	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
-		return false
+		return st
 	}
 	req.Header.Set("Accept", "application/vnd.github+json")
 	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
-	resp, err := client.Do(req)
+	resp, err := doInstrumented(client, req)
 	if err != nil || resp.StatusCode != http.StatusOK {
 		if resp != nil {
 			_ = resp.Body.Close()
 		}
-		return false
+		return st
 	}
 	defer func() { _ = resp.Body.Close() }()
 	var data githubRunnersResponse
 	if json.NewDecoder(resp.Body).Decode(&data) != nil {
-		return false
+		return st
 	}
 	for _, r := range data.Runners {
 		if r.Name == runnerName {
-			return true
+			st.Registered = true
+			st.Online = r.Status == "online"
+			st.Busy = r.Busy
+			return st
 		}
 	}
-	return false
+	return st
+}
+
+// doInstrumented 发送请求并记录 GitHub API 耗时、状态码与错误数
+func doInstrumented(client *http.Client, req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := client.Do(req)
+	metrics.GitHubAPILatency.Observe(time.Since(start).Seconds())
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	metrics.GitHubAPIRequests.Inc(code)
+	if err != nil || resp.StatusCode < 200 || resp.StatusCode > 299 {
+		metrics.GitHubAPIErrors.Inc()
+	}
+	return resp, err
 }
@@ -0,0 +1,53 @@
+package githubcheck
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+)
+
+// RegistrationToken 用具备管理权限的 PAT（组织需 admin:org，仓库需 repo）向 GitHub 申请 runner 注册 Token，约 1 小时有效
+func RegistrationToken(ctx context.Context, pat, targetType, target string) (string, time.Time, error) {
+	tt := strings.ToLower(strings.TrimSpace(targetType))
+	raw := strings.TrimSpace(target)
+	if err := config.ValidateTarget(tt, raw); err != nil {
+		return "", time.Time{}, err
+	}
+	path := "/repos/" + raw + "/actions/runners/registration-token"
+	if tt == "org" {
+		path = "/orgs/" + raw + "/actions/runners/registration-token"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, APIBase+path, nil)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+pat)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	resp, err := doInstrumented(&http.Client{Timeout: apiTimeout}, req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", time.Time{}, fmt.Errorf("GitHub 返回 %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var data struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return "", time.Time{}, fmt.Errorf("解析 GitHub 响应失败: %w", err)
+	}
+	if data.Token == "" {
+		return "", time.Time{}, fmt.Errorf("GitHub 未返回注册 Token")
+	}
+	return data.Token, data.ExpiresAt, nil
+}
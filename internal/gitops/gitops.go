@@ -0,0 +1,272 @@
+// Package gitops 从 git 仓库同步 runner 列表：定时拉取仓库中的定义文件，与当前配置比较生成计划（新增、移除、变更），
+// 由 handler 自动应用或等待批准后应用。拉取使用本机 git 命令，计划保存在 base_path/.fleet/gitops/plan.json。
+package gitops
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// 计划状态
+const (
+	StateInSync  = "in_sync" // 配置与仓库一致
+	StatePending = "pending" // 有变更等待批准（auto_apply 关闭时）
+	StateError   = "error"   // 拉取、解析或校验失败，配置保持不变
+)
+
+// 变更动作
+const (
+	ActionAdd    = "add"
+	ActionRemove = "remove"
+	ActionChange = "change"
+)
+
+// Definition 仓库中的 fleet 定义文件：items 与 config.yaml 中 runners.items 的格式相同，
+// pools 为按数量展开的同配置 runner 组，见 Runners
+type Definition struct {
+	Items []config.RunnerItem `yaml:"items"`
+	Pools []Pool              `yaml:"pools"`
+}
+
+// MaxPoolCount 单个 pool 的 runner 数上限，与 POST /api/runners 的 count 一致
+const MaxPoolCount = 50
+
+// Pool 一组配置相同的 runner，展开为 name-1..name-count（与批量添加的命名相同）；
+// 其余字段与 runners.items 相同，path 不可设置，各 runner 的目录与名称相同
+type Pool struct {
+	config.RunnerItem `yaml:",inline"`
+	Count             int `yaml:"count"`
+}
+
+// Runners 返回 items 与展开后的 pools；调整 count 即增减末尾的 runner
+func (d *Definition) Runners() ([]config.RunnerItem, error) {
+	out := append([]config.RunnerItem{}, d.Items...)
+	for _, p := range d.Pools {
+		if p.Name == "" {
+			return nil, fmt.Errorf("pool 缺少 name")
+		}
+		if p.Count < 1 || p.Count > MaxPoolCount {
+			return nil, fmt.Errorf("pool %s 的 count 须在 1 到 %d 之间", p.Name, MaxPoolCount)
+		}
+		if p.Path != "" {
+			return nil, fmt.Errorf("pool %s 不可设置 path，各 runner 的目录与名称相同", p.Name)
+		}
+		for i := 1; i <= p.Count; i++ {
+			item := p.RunnerItem
+			item.Name = fmt.Sprintf("%s-%d", p.Name, i)
+			item.Labels = append([]string(nil), p.Labels...)
+			out = append(out, item)
+		}
+	}
+	return out, nil
+}
+
+// Change 单个 runner 的变更
+type Change struct {
+	Action string             `json:"action"` // add、remove、change
+	Runner string             `json:"runner"`
+	Fields []string           `json:"fields,omitempty"` // change 时变化的字段（配置文件中的字段名）
+	Before *config.RunnerItem `json:"before,omitempty"`
+	After  *config.RunnerItem `json:"after,omitempty"`
+}
+
+// Plan 最近一次同步的结果
+type Plan struct {
+	Repo          string              `json:"repo"` // 已去掉凭据
+	Branch        string              `json:"branch"`
+	File          string              `json:"file"`
+	Commit        string              `json:"commit,omitempty"`
+	State         string              `json:"state"`
+	Changes       []Change            `json:"changes"`
+	Desired       []config.RunnerItem `json:"desired,omitempty"` // 应用时写入 runners.items 的内容
+	Error         string              `json:"error,omitempty"`
+	CheckedAt     time.Time           `json:"checked_at"`
+	AppliedCommit string              `json:"applied_commit,omitempty"`
+	AppliedAt     *time.Time          `json:"applied_at,omitempty"`
+}
+
+// Redact 去掉仓库地址中的用户名与密码，用于日志与 API 输出
+func Redact(repo string) string {
+	u, err := url.Parse(repo)
+	if err != nil || u.User == nil {
+		return repo
+	}
+	u.User = nil
+	return u.String()
+}
+
+func git(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	// 不交互询问凭据，缺少凭据时直接失败
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// Checkout 将仓库 branch 分支的最新提交检出到 dir（首次克隆，之后 fetch 并重置），返回提交 ID。
+// 仓库地址前加 -- 结束选项解析，以 - 开头的地址不会被 git 当作选项（config.Validate 也会拒绝）
+func Checkout(ctx context.Context, dir, repo, branch string) (string, error) {
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+		_ = os.RemoveAll(dir)
+		if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+			return "", err
+		}
+		if _, err := git(ctx, "", "clone", "--quiet", "--depth", "1", "--single-branch", "--branch", branch, "--", repo, dir); err != nil {
+			return "", redactErr(err, repo)
+		}
+	} else {
+		if _, err := git(ctx, dir, "remote", "set-url", "--", "origin", repo); err != nil {
+			return "", redactErr(err, repo)
+		}
+		if _, err := git(ctx, dir, "fetch", "--quiet", "--depth", "1", "--", "origin", branch); err != nil {
+			return "", redactErr(err, repo)
+		}
+		if _, err := git(ctx, dir, "reset", "--quiet", "--hard", "FETCH_HEAD"); err != nil {
+			return "", err
+		}
+	}
+	out, err := git(ctx, dir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// redactErr 去掉 git 错误信息中带凭据的仓库地址
+func redactErr(err error, repo string) error {
+	if r := Redact(repo); r != repo {
+		return fmt.Errorf("%s", strings.ReplaceAll(err.Error(), repo, r))
+	}
+	return err
+}
+
+// LoadDefinition 读取检出目录中的定义文件；未知字段视为错误，避免拼写错误被静默忽略
+func LoadDefinition(dir, file string) (*Definition, error) {
+	data, err := os.ReadFile(filepath.Join(dir, filepath.Clean(file)))
+	if err != nil {
+		return nil, err
+	}
+	var d Definition
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&d); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %w", file, err)
+	}
+	if d.Items == nil {
+		d.Items = []config.RunnerItem{}
+	}
+	return &d, nil
+}
+
+// Merge 返回应用定义后的 runners.items：定义中未设置 runner_version 时沿用当前值（由滚动升级记录），避免每次同步都回退版本
+func Merge(current, desired []config.RunnerItem) []config.RunnerItem {
+	cur := map[string]config.RunnerItem{}
+	for _, item := range current {
+		cur[item.Name] = item
+	}
+	out := make([]config.RunnerItem, len(desired))
+	for i, item := range desired {
+		if c, ok := cur[item.Name]; ok && item.RunnerVersion == "" {
+			item.RunnerVersion = c.RunnerVersion
+		}
+		if item.Labels == nil {
+			item.Labels = []string{}
+		}
+		out[i] = item
+	}
+	return out
+}
+
+// Diff 比较当前与期望的 runner 列表，按期望顺序列出新增与变更，再列出移除
+func Diff(current, desired []config.RunnerItem) []Change {
+	cur := map[string]config.RunnerItem{}
+	for _, item := range current {
+		cur[item.Name] = item
+	}
+	changes := []Change{}
+	seen := map[string]bool{}
+	for _, item := range desired {
+		seen[item.Name] = true
+		after := item
+		before, ok := cur[item.Name]
+		if !ok {
+			changes = append(changes, Change{Action: ActionAdd, Runner: item.Name, After: &after})
+			continue
+		}
+		if fields := config.ChangedFields(before, item); len(fields) > 0 {
+			changes = append(changes, Change{Action: ActionChange, Runner: item.Name, Fields: fields, Before: &before, After: &after})
+		}
+	}
+	for _, item := range current {
+		if !seen[item.Name] {
+			before := item
+			changes = append(changes, Change{Action: ActionRemove, Runner: item.Name, Before: &before})
+		}
+	}
+	return changes
+}
+
+var mu sync.Mutex
+
+func planFile(stateDir string) string {
+	return filepath.Join(stateDir, "gitops", "plan.json")
+}
+
+// CheckoutDir 返回仓库检出目录
+func CheckoutDir(stateDir string) string {
+	return filepath.Join(stateDir, "gitops", "checkout")
+}
+
+// LoadPlan 读取最近一次的计划，从未同步时返回 nil
+func LoadPlan(stateDir string) (*Plan, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	b, err := os.ReadFile(planFile(stateDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var p Plan
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// SavePlan 保存计划
+func SavePlan(stateDir string, p *Plan) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(planFile(stateDir)), 0755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := planFile(stateDir) + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, planFile(stateDir))
+}
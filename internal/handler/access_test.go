@@ -0,0 +1,255 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/apitoken"
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/lab-dev/github-actions-runner-manager/internal/events"
+	"github.com/lab-dev/github-actions-runner-manager/internal/metrics"
+	"github.com/lab-dev/github-actions-runner-manager/internal/regjob"
+	"github.com/labstack/echo/v4"
+)
+
+func TestTargetAccessRules(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	cfg := &config.Config{
+		Runners: config.RunnersConfig{BasePath: dir, Items: []config.RunnerItem{
+			{Name: "r-pay", TargetType: "repo", Target: "acme/payments-api"},
+			{Name: "r-bill", TargetType: "repo", Target: "acme/billing"},
+		}},
+		Auth: &config.AuthConfig{Access: []config.AccessRule{
+			{Principals: []string{"token:ci-payments"}, Targets: []string{"acme/payments-*"}},
+		}},
+	}
+	_ = cfg.Save(cfgPath)
+	ConfigPath = cfgPath
+	defer func() { ConfigPath = filepath.Join(os.TempDir(), "handler-test-config.yaml") }()
+	payments, _, err := apitoken.Create(cfg.Runners.StateDir(), "ci-payments", []string{"admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, _ := apitoken.Create(cfg.Runners.StateDir(), "ci-other", []string{"admin"})
+
+	e := echo.New()
+	e.Use(Auth("admin", "secret"))
+	e.GET("/api/runners", ListRunners)
+	e.GET("/api/runners/:name", GetRunner)
+	e.PUT("/api/runners/:name", UpdateRunner)
+	e.POST("/api/runners/:name/stop", StopRunner)
+	e.DELETE("/api/runners/:name", RemoveRunnerByName)
+	do := func(method, path, body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if token != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		} else {
+			req.SetBasicAuth("admin", "secret")
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	names := func(rec *httptest.ResponseRecorder) []string {
+		var body struct {
+			Runners []struct {
+				Name string `json:"name"`
+			} `json:"runners"`
+		}
+		_ = json.Unmarshal(rec.Body.Bytes(), &body)
+		var out []string
+		for _, r := range body.Runners {
+			out = append(out, r.Name)
+		}
+		return out
+	}
+
+	if got := names(do(http.MethodGet, "/api/runners", "", payments)); len(got) != 1 || got[0] != "r-pay" {
+		t.Errorf("payments token sees %v", got)
+	}
+	if got := names(do(http.MethodGet, "/api/runners", "", other)); len(got) != 0 {
+		t.Errorf("token without rule sees %v", got)
+	}
+	if got := names(do(http.MethodGet, "/api/runners", "", "")); len(got) != 2 {
+		t.Errorf("basic auth sees %v", got)
+	}
+	for _, tc := range []struct{ method, path, body string }{
+		{http.MethodGet, "/api/runners/r-bill", ""},
+		{http.MethodPost, "/api/runners/r-bill/stop", ""},
+		{http.MethodPut, "/api/runners/r-bill", `{"target_type":"repo","target":"acme/payments-x"}`},
+		{http.MethodDelete, "/api/runners/r-bill", ""},
+	} {
+		if rec := do(tc.method, tc.path, tc.body, payments); rec.Code != http.StatusNotFound {
+			t.Errorf("%s %s: expected 404, got %d", tc.method, tc.path, rec.Code)
+		}
+	}
+	if rec := do(http.MethodGet, "/api/runners/r-pay", "", payments); rec.Code != http.StatusOK {
+		t.Errorf("own runner: expected 200, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPut, "/api/runners/r-pay", `{"target_type":"repo","target":"acme/billing"}`, payments); rec.Code != http.StatusForbidden {
+		t.Errorf("moving runner to foreign target: expected 403, got %d", rec.Code)
+	}
+	latest, _ := config.Load(cfgPath)
+	if len(latest.Runners.Items) != 2 || latest.Runners.Items[0].Target != "acme/payments-api" {
+		t.Errorf("config changed by forbidden requests: %+v", latest.Runners.Items)
+	}
+}
+
+func TestTargetAccess_JobsEventsAndUpgrade(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	cfg := &config.Config{
+		Runners: config.RunnersConfig{BasePath: dir, Items: []config.RunnerItem{
+			{Name: "r-pay", TargetType: "repo", Target: "acme/payments-api"},
+			{Name: "r-bill", TargetType: "repo", Target: "acme/billing"},
+		}},
+		Auth: &config.AuthConfig{Access: []config.AccessRule{
+			{Principals: []string{"token:ci-payments"}, Targets: []string{"acme/payments-*"}},
+		}},
+	}
+	_ = cfg.Save(cfgPath)
+	ConfigPath = cfgPath
+	defer func() { ConfigPath = filepath.Join(os.TempDir(), "handler-test-config.yaml") }()
+	sd := cfg.Runners.StateDir()
+	payments, _, err := apitoken.Create(sd, "ci-payments", []string{"admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payJob := regjob.New("r-pay", filepath.Join(dir, "r-pay"), "https://github.com/acme/payments-api", nil)
+	billJob := regjob.New("r-bill", filepath.Join(dir, "r-bill"), "https://github.com/acme/billing", nil)
+	billJob.State = regjob.StateFailed
+	for _, j := range []*regjob.Job{payJob, billJob} {
+		if err := regjob.Save(sd, j); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e := echo.New()
+	e.Use(Auth("admin", "secret"))
+	RegisterRoutes(e)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+payments)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	var list struct {
+		Jobs []regjob.Job `json:"jobs"`
+	}
+	_ = json.Unmarshal(do(http.MethodGet, "/api/jobs", "").Body.Bytes(), &list)
+	if len(list.Jobs) != 1 || list.Jobs[0].ID != payJob.ID {
+		t.Errorf("payments token sees jobs %+v", list.Jobs)
+	}
+	for _, tc := range []struct{ method, path string }{
+		{http.MethodGet, "/api/jobs/" + billJob.ID},
+		{http.MethodPost, "/api/jobs/" + billJob.ID + "/cancel"},
+		{http.MethodPost, "/api/jobs/" + billJob.ID + "/retry"},
+	} {
+		if rec := do(tc.method, tc.path, `{"registration_token":"AAA111"}`); rec.Code != http.StatusNotFound {
+			t.Errorf("%s %s: expected 404, got %d", tc.method, tc.path, rec.Code)
+		}
+	}
+	if rec := do(http.MethodPost, "/api/jobs/"+payJob.ID+"/cancel", ""); rec.Code != http.StatusOK {
+		t.Errorf("cancel own job: expected 200, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := do(http.MethodPost, "/api/fleet/upgrade", `{"version":"2.331.0"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("upgrade whole fleet: expected 403, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/fleet/upgrade", `{"version":"2.331.0","runners":["r-pay","r-bill"]}`); rec.Code != http.StatusNotFound {
+		t.Errorf("upgrade foreign runner: expected 404, got %d", rec.Code)
+	}
+
+	// 事件流只包含可管理 runner 的事件，配置重载等全局事件不推送
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+	first := events.Publish(events.TypeRunnerUpdated, "r-bill", nil)
+	events.Publish(events.TypeConfigReloaded, "", nil)
+	events.Publish(events.TypeRunnerUpdated, "r-pay", nil)
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/events", nil)
+	req.Header.Set("Last-Event-ID", strconv.FormatInt(first-1, 10))
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+payments)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	sc := bufio.NewScanner(resp.Body)
+	var data []string
+	for sc.Scan() {
+		if line, ok := strings.CutPrefix(sc.Text(), "data: "); ok {
+			data = append(data, line)
+			break
+		}
+	}
+	if len(data) != 1 || !strings.Contains(data[0], `"runner":"r-pay"`) {
+		t.Errorf("payments token received %v", data)
+	}
+}
+
+func TestTargetAccess_Metrics(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	cfg := &config.Config{
+		Runners: config.RunnersConfig{BasePath: dir, Items: []config.RunnerItem{
+			{Name: "r-pay", TargetType: "repo", Target: "acme/payments-api"},
+			{Name: "r-bill", TargetType: "repo", Target: "acme/billing"},
+		}},
+		Auth: &config.AuthConfig{Access: []config.AccessRule{
+			{Principals: []string{"token:ci-payments"}, Targets: []string{"acme/payments-*"}},
+		}},
+	}
+	_ = cfg.Save(cfgPath)
+	ConfigPath = cfgPath
+	defer func() { ConfigPath = filepath.Join(os.TempDir(), "handler-test-config.yaml") }()
+	payments, _, err := apitoken.Create(cfg.Runners.StateDir(), "ci-payments", []string{"read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	MetricsToken = "scrape"
+	defer func() { MetricsToken = "" }()
+	metrics.RunnerStartAttempts.Inc("r-bill")
+
+	e := echo.New()
+	e.Use(Auth("admin", "secret"))
+	e.GET("/metrics", Metrics)
+	scrape := func(token string) string {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d body=%s", rec.Code, rec.Body.String())
+		}
+		return rec.Body.String()
+	}
+
+	body := scrape(payments)
+	if !strings.Contains(body, `runner_fleet_runner_running{runner="r-pay"}`) {
+		t.Errorf("payments token should see r-pay:\n%s", body)
+	}
+	if strings.Contains(body, `runner="r-bill"`) {
+		t.Errorf("payments token should not see r-bill:\n%s", body)
+	}
+	if !strings.Contains(body, "runner_fleet_runners 1\n") {
+		t.Error("runner count should only include visible runners")
+	}
+
+	body = scrape("scrape")
+	if !strings.Contains(body, `runner_fleet_runner_running{runner="r-bill"}`) ||
+		!strings.Contains(body, `runner_fleet_runner_start_attempts_total{runner="r-bill"}`) {
+		t.Errorf("METRICS_TOKEN scrape should see every runner:\n%s", body)
+	}
+}
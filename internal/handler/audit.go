@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/audit"
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/labstack/echo/v4"
+	"gopkg.in/yaml.v3"
+)
+
+// auditActions 路由对应的审计动作名，未列出的写操作记为 "<METHOD> <path>"
+var auditActions = map[string]string{
+	http.MethodPost + " /api/runners":              "runner.add",
+	http.MethodPut + " /api/runners/:name":         "runner.update",
+	http.MethodDelete + " /api/runners/:name":      "runner.remove",
+	http.MethodPost + " /api/runners/:name/start":  "runner.start",
+	http.MethodPost + " /api/runners/:name/stop":   "runner.stop",
+	http.MethodPost + " /api/runners/bulk":         "runner.bulk",
+	http.MethodPost + " /api/jobs/:id/cancel":      "job.cancel",
+	http.MethodPost + " /api/jobs/:id/retry":       "job.retry",
+	http.MethodPost + " /api/fleet/upgrade":        "fleet.upgrade",
+	http.MethodPost + " /api/fleet/upgrade/cancel": "fleet.upgrade_cancel",
+	http.MethodPost + " /api/tokens":               "token.create",
+	http.MethodDelete + " /api/tokens/:id":         "token.delete",
+	http.MethodPost + " /api/config/rollback/:rev": "config.rollback",
+	http.MethodPost + " /api/gitops/sync":          "gitops.sync",
+	http.MethodPost + " /api/gitops/apply":         "gitops.apply",
+	http.MethodPost + " /auth/logout":              "auth.logout",
+}
+
+// auditRunnerKey 处理函数可通过 c.Set 指定审计记录中的 runner（如添加时自动加了后缀的名称）
+const auditRunnerKey = "audit_runner"
+
+// findItem 返回配置中名为 name 的 runner，以配置文件中的字段名表示，便于与 config.yaml 对照；不存在时为 nil
+func findItem(name string) map[string]any {
+	if name == "" {
+		return nil
+	}
+	cfg, err := config.Load(ConfigPath)
+	if err != nil {
+		return nil
+	}
+	idx := slices.IndexFunc(cfg.Runners.Items, func(i config.RunnerItem) bool { return i.Name == name })
+	if idx < 0 {
+		return nil
+	}
+	b, err := yaml.Marshal(cfg.Runners.Items[idx])
+	if err != nil {
+		return nil
+	}
+	var m map[string]any
+	if yaml.Unmarshal(b, &m) != nil {
+		return nil
+	}
+	return m
+}
+
+// AuditLog 审计中间件：记录所有写操作的调用者、来源 IP、动作、runner 配置变更与结果。须注册在 Auth 之前，
+// 认证失败与权限不足的请求同样记录；
+// GitHub webhook 由签名校验来源且不改配置、配置计划只做预览，不记录
+func AuditLog() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			path := c.Path()
+			if safeMethod(req.Method) || path == "/api/webhooks/github" || path == "/api/config/plan" {
+				return next(c)
+			}
+			name := c.Param("name")
+			before := findItem(name)
+			err := next(c)
+
+			p := CurrentPrincipal(c)
+			action, ok := auditActions[req.Method+" "+path]
+			if !ok {
+				action = req.Method + " " + path
+			}
+			if v, ok := c.Get(auditRunnerKey).(string); ok && v != "" {
+				name = v
+			}
+			e := &audit.Entry{
+				Actor:     p.Name,
+				ActorKind: p.Kind,
+				SourceIP:  c.RealIP(),
+				Action:    action,
+				Method:    req.Method,
+				Path:      req.URL.Path,
+				Runner:    name,
+				Result:    audit.ResultOK,
+				Status:    c.Response().Status,
+			}
+			if err != nil {
+				e.Result, e.Status, e.Error = audit.ResultError, http.StatusInternalServerError, err.Error()
+				var he *echo.HTTPError
+				if errors.As(err, &he) {
+					e.Status = he.Code
+					if m, ok := he.Message.(string); ok {
+						e.Error = m
+					}
+				}
+			} else if e.Status >= http.StatusBadRequest {
+				e.Result = audit.ResultError
+			}
+			if after := findItem(name); before != nil || after != nil {
+				if before != nil {
+					e.Before = before
+				}
+				if after != nil {
+					e.After = after
+				}
+				e.Changes = audit.Diff(before, after)
+			}
+			if cfg, cerr := config.Load(ConfigPath); cerr == nil {
+				if aerr := audit.Append(cfg.Runners.StateDir(), e); aerr != nil {
+					log.Printf("[audit] 写入审计日志失败: %v", aerr)
+				}
+			}
+			return err
+		}
+	}
+}
+
+// ListAudit 查询审计日志（GET /api/audit?actor=&action=&runner=&result=&since=&until=&limit=），最新的在前；
+// since/until 为 RFC 3339 时间，limit 默认 100、最大 1000
+func ListAudit(c echo.Context) error {
+	cfg, err := getConfig(c)
+	if err != nil {
+		return err
+	}
+	f := audit.Filter{
+		Actor:  c.QueryParam("actor"),
+		Action: c.QueryParam("action"),
+		Runner: c.QueryParam("runner"),
+		Result: c.QueryParam("result"),
+	}
+	for param, dst := range map[string]*time.Time{"since": &f.Since, "until": &f.Until} {
+		if v := c.QueryParam(param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, param+" 须为 RFC 3339 时间，如 2026-01-02T15:04:05Z")
+			}
+			*dst = t
+		}
+	}
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			return echo.NewHTTPError(http.StatusBadRequest, "limit 必须为 1-1000 的整数")
+		}
+		f.Limit = n
+	}
+	entries, err := audit.Query(cfg.Runners.StateDir(), f)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "读取审计日志失败: "+err.Error())
+	}
+	return c.JSON(http.StatusOK, map[string]any{"entries": entries})
+}
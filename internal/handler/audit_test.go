@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/apitoken"
+	"github.com/lab-dev/github-actions-runner-manager/internal/audit"
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/labstack/echo/v4"
+)
+
+func TestAuditLog(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	cfg := &config.Config{Runners: config.RunnersConfig{BasePath: dir, Items: []config.RunnerItem{
+		{Name: "r1", TargetType: "org", Target: "o1"},
+	}}}
+	_ = cfg.Save(cfgPath)
+	ConfigPath = cfgPath
+	defer func() { ConfigPath = filepath.Join(os.TempDir(), "handler-test-config.yaml") }()
+
+	e := echo.New()
+	e.Use(AuditLog(), Auth("admin", "secret"))
+	e.PUT("/api/runners/:name", UpdateRunner)
+	e.GET("/api/audit", ListAudit)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderXRealIP, "10.0.0.7")
+		req.SetBasicAuth("admin", "secret")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := do(http.MethodPut, "/api/runners/r1", `{"target_type":"org","target":"o2","labels":["gpu"]}`); rec.Code != http.StatusOK {
+		t.Fatalf("update: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := do(http.MethodPut, "/api/runners/missing", `{"target_type":"org","target":"o2"}`); rec.Code != http.StatusNotFound {
+		t.Fatalf("update missing: %d", rec.Code)
+	}
+	// 认证失败与权限不足的请求同样记录
+	secret, _, err := apitoken.Create(cfg.Runners.StateDir(), "ci-read", []string{"read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, auth := range []func(*http.Request){
+		func(r *http.Request) { r.SetBasicAuth("admin", "wrong") },
+		func(r *http.Request) { r.Header.Set(echo.HeaderAuthorization, "Bearer "+secret) },
+	} {
+		req := httptest.NewRequest(http.MethodPut, "/api/runners/r1", strings.NewReader(`{"target_type":"org","target":"o3"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		auth(req)
+		e.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	denied, _ := audit.Query(cfg.Runners.StateDir(), audit.Filter{Result: audit.ResultError, Limit: 2})
+	if len(denied) != 2 || denied[0].Status != http.StatusForbidden || denied[0].Actor != "ci-read" ||
+		denied[1].Status != http.StatusUnauthorized || denied[1].Actor != "" {
+		t.Errorf("denied entries = %+v", denied)
+	}
+
+	if rec := do(http.MethodGet, "/api/audit?since=yesterday", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid since: expected 400, got %d", rec.Code)
+	}
+
+	rec := do(http.MethodGet, "/api/audit?runner=r1&result=ok", "")
+	var out struct {
+		Entries []audit.Entry `json:"entries"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil || len(out.Entries) != 1 {
+		t.Fatalf("audit query: %s", rec.Body.String())
+	}
+	got := out.Entries[0]
+	if got.Actor != "admin" || got.ActorKind != PrincipalBasic || got.SourceIP != "10.0.0.7" ||
+		got.Action != "runner.update" || got.Result != audit.ResultOK {
+		t.Errorf("entry = %+v", got)
+	}
+	fields := []string{}
+	for _, ch := range got.Changes {
+		fields = append(fields, ch.Field)
+	}
+	if strings.Join(fields, ",") != "labels,target" {
+		t.Errorf("changes = %+v", got.Changes)
+	}
+
+	rec = do(http.MethodGet, "/api/audit?result=error&runner=missing", "")
+	out.Entries = nil
+	_ = json.Unmarshal(rec.Body.Bytes(), &out)
+	if len(out.Entries) != 1 || out.Entries[0].Runner != "missing" || out.Entries[0].Status != http.StatusNotFound {
+		t.Errorf("error entry = %+v", out.Entries)
+	}
+}
@@ -0,0 +1,248 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/apitoken"
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/lab-dev/github-actions-runner-manager/internal/runner"
+	"github.com/labstack/echo/v4"
+)
+
+// operateRoutes 需要 operate 权限的路由；其余 GET 为 read，其他写操作为 admin
+var operateRoutes = map[string]bool{
+	http.MethodPost + " /api/runners/:name/start": true,
+	http.MethodPost + " /api/runners/:name/stop":  true,
+	http.MethodPost + " /api/runners/bulk":        true, // remove 与 update-labels 在处理函数中另需 admin
+	http.MethodPost + " /api/jobs/:id/cancel":     true,
+	http.MethodPost + " /api/jobs/:id/retry":      true,
+}
+
+// RouteScope 返回访问路由所需的 Token 权限范围
+func RouteScope(method, path string) string {
+	if strings.HasPrefix(path, "/api/tokens") || strings.HasPrefix(path, "/api/config") || strings.HasPrefix(path, "/api/gitops") || path == "/api/audit" {
+		return apitoken.ScopeAdmin
+	}
+	if path == "/auth/logout" {
+		return apitoken.ScopeRead
+	}
+	if operateRoutes[method+" "+path] {
+		return apitoken.ScopeOperate
+	}
+	if method == http.MethodGet || method == http.MethodHead {
+		return apitoken.ScopeRead
+	}
+	return apitoken.ScopeAdmin
+}
+
+// 调用者类型
+const (
+	PrincipalBasic     = "basic"
+	PrincipalToken     = "token"
+	PrincipalSession   = "session"
+	PrincipalAnonymous = "anonymous" // 未启用任何认证时
+)
+
+// Principal 当前请求的调用者
+type Principal struct {
+	Name   string   `json:"name"`
+	Kind   string   `json:"kind"`
+	Role   string   `json:"role"` // read、operate、admin
+	Groups []string `json:"groups,omitempty"`
+}
+
+// Allows 调用者角色是否具备 scope 所需权限
+func (p *Principal) Allows(scope string) bool {
+	return apitoken.Includes(p.Role, scope)
+}
+
+// accessIDs 调用者在 auth.access 规则中的标识
+func (p *Principal) accessIDs() []string {
+	switch p.Kind {
+	case PrincipalToken:
+		return []string{config.AccessPrincipalToken + p.Name}
+	case PrincipalSession:
+		ids := []string{config.AccessPrincipalUser + p.Name}
+		for _, g := range p.Groups {
+			ids = append(ids, config.AccessPrincipalGroup+g)
+		}
+		return ids
+	}
+	return nil
+}
+
+// accessPatterns 返回调用者可管理的 target 模式，restricted 为 false 表示不受限。未配置 auth.access 时不限制；
+// Basic Auth 与未启用认证时的匿名调用者同样不受限，未识别出调用者时受限且没有任何可管理的 target
+func accessPatterns(c echo.Context, cfg *config.Config) (patterns []string, restricted bool) {
+	p := CurrentPrincipal(c)
+	if p.Role == "" {
+		return nil, true
+	}
+	if p.Kind == PrincipalBasic || p.Kind == PrincipalAnonymous {
+		return nil, false
+	}
+	return cfg.Auth.AccessPatterns(p.accessIDs())
+}
+
+// fullAccess 调用者可管理全部 target
+func fullAccess(c echo.Context, cfg *config.Config) bool {
+	_, restricted := accessPatterns(c, cfg)
+	return !restricted
+}
+
+// targetAccess 返回判断调用者能否管理某 target 的函数，规则见 accessPatterns
+func targetAccess(c echo.Context, cfg *config.Config) func(target string) bool {
+	patterns, restricted := accessPatterns(c, cfg)
+	if !restricted {
+		return func(string) bool { return true }
+	}
+	return func(target string) bool { return config.TargetAllowed(patterns, target) }
+}
+
+// runnerAccessible 名为 name 的 runner 存在且调用者可管理
+func runnerAccessible(c echo.Context, cfg *config.Config, name string) bool {
+	idx := slices.IndexFunc(cfg.Runners.Items, func(i config.RunnerItem) bool { return i.Name == name })
+	return idx >= 0 && targetAccess(c, cfg)(cfg.Runners.Items[idx].Target)
+}
+
+// filterByAccess 只保留调用者可管理的 runner
+func filterByAccess(c echo.Context, cfg *config.Config, list []runner.RunnerInfo) []runner.RunnerInfo {
+	allowed := targetAccess(c, cfg)
+	out := make([]runner.RunnerInfo, 0, len(list))
+	for _, info := range list {
+		if allowed(info.Target) {
+			out = append(out, info)
+		}
+	}
+	return out
+}
+
+const principalKey = "principal"
+
+// CurrentPrincipal 返回 Auth 中间件记录的调用者；未经过中间件时返回没有任何权限的空调用者
+func CurrentPrincipal(c echo.Context) *Principal {
+	if p, ok := c.Get(principalKey).(*Principal); ok {
+		return p
+	}
+	return &Principal{}
+}
+
+// safeMethod 不修改状态的方法，无需 CSRF 校验
+func safeMethod(m string) bool {
+	return m == http.MethodGet || m == http.MethodHead || m == http.MethodOptions
+}
+
+// Auth 认证中间件，依次识别：Authorization: Bearer 携带的 API Token、OIDC 登录会话 Cookie、Basic Auth（视为 admin）。
+// 识别出的调用者按路由校验权限范围；会话发起的写操作还须带与会话一致的 X-CSRF-Token。
+// 未设置 basicPassword 且未启用 OIDC 时不要求认证。/health、webhook 与登录回调不校验，/metrics 可用 METRICS_TOKEN 代替
+func Auth(basicUser, basicPassword string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			path := c.Path()
+			if path == "/health" || path == "/api/webhooks/github" || path == "/auth/login" || path == "/auth/callback" ||
+				(path == "/metrics" && MetricsTokenValid(c)) {
+				return next(c)
+			}
+			req := c.Request()
+			var p *Principal
+			if secret, ok := strings.CutPrefix(req.Header.Get(echo.HeaderAuthorization), "Bearer "); ok {
+				cfg, err := config.Load(ConfigPath)
+				if err != nil {
+					return echo.NewHTTPError(http.StatusInternalServerError, "加载配置失败: "+err.Error())
+				}
+				tok, ok := apitoken.Lookup(cfg.Runners.StateDir(), strings.TrimSpace(secret))
+				if !ok {
+					return echo.NewHTTPError(http.StatusUnauthorized, "无效的 API Token")
+				}
+				p = &Principal{Name: tok.Name, Kind: PrincipalToken, Role: apitoken.Highest(tok.Scopes)}
+			} else if s, ok := lookupSession(c); ok {
+				if !safeMethod(req.Method) && subtle.ConstantTimeCompare([]byte(req.Header.Get(CSRFHeader)), []byte(s.csrf)) != 1 {
+					return echo.NewHTTPError(http.StatusForbidden, "缺少或无效的 "+CSRFHeader)
+				}
+				principal := s.principal
+				p = &principal
+			} else if user, pass, ok := req.BasicAuth(); ok && basicPassword != "" &&
+				subtle.ConstantTimeCompare([]byte(user), []byte(basicUser)) == 1 &&
+				subtle.ConstantTimeCompare([]byte(pass), []byte(basicPassword)) == 1 {
+				p = &Principal{Name: user, Kind: PrincipalBasic, Role: apitoken.ScopeAdmin}
+			} else if basicPassword == "" && OIDC == nil {
+				p = &Principal{Name: PrincipalAnonymous, Kind: PrincipalAnonymous, Role: apitoken.ScopeAdmin}
+			}
+			if p == nil {
+				// 浏览器访问页面时跳转到 IdP 登录，API 请求返回 401
+				if OIDC != nil && req.Method == http.MethodGet && strings.Contains(req.Header.Get(echo.HeaderAccept), echo.MIMETextHTML) {
+					return c.Redirect(http.StatusFound, "/auth/login?return="+url.QueryEscape(req.URL.RequestURI()))
+				}
+				if basicPassword != "" {
+					c.Response().Header().Set(echo.HeaderWWWAuthenticate, `basic realm="Restricted"`)
+				}
+				return echo.ErrUnauthorized
+			}
+			// 先记录调用者，权限不足被拒绝时审计日志也能记下是谁
+			c.Set(principalKey, p)
+			if scope := RouteScope(req.Method, path); !p.Allows(scope) {
+				return echo.NewHTTPError(http.StatusForbidden, p.Name+" 缺少 "+scope+" 权限")
+			}
+			return next(c)
+		}
+	}
+}
+
+// CreateTokenRequest 签发 Token 请求
+type CreateTokenRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"` // read、operate、admin
+}
+
+// ListTokens 列出已签发的 Token（GET /api/tokens），不含明文与哈希
+func ListTokens(c echo.Context) error {
+	cfg, err := getConfig(c)
+	if err != nil {
+		return err
+	}
+	list, err := apitoken.List(cfg.Runners.StateDir())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "读取 Token 失败: "+err.Error())
+	}
+	if list == nil {
+		list = []apitoken.Token{}
+	}
+	return c.JSON(http.StatusOK, map[string]any{"tokens": list})
+}
+
+// CreateToken 签发 Token（POST /api/tokens），明文仅在响应中返回一次
+func CreateToken(c echo.Context) error {
+	cfg, err := getConfig(c)
+	if err != nil {
+		return err
+	}
+	var req CreateTokenRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "参数错误: "+err.Error())
+	}
+	secret, tok, err := apitoken.Create(cfg.Runners.StateDir(), req.Name, req.Scopes)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusCreated, map[string]any{"token": secret, "info": tok})
+}
+
+// DeleteToken 吊销 Token（DELETE /api/tokens/:id）
+func DeleteToken(c echo.Context) error {
+	cfg, err := getConfig(c)
+	if err != nil {
+		return err
+	}
+	if err := apitoken.Delete(cfg.Runners.StateDir(), c.Param("id")); err != nil {
+		if errors.Is(err, apitoken.ErrNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "删除 Token 失败: "+err.Error())
+	}
+	return c.JSON(http.StatusOK, map[string]any{"message": "已吊销"})
+}
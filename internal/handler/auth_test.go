@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/apitoken"
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/labstack/echo/v4"
+)
+
+func TestAuth_TokenScopes(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	_ = (&config.Config{Runners: config.RunnersConfig{BasePath: dir}}).Save(cfgPath)
+	ConfigPath = cfgPath
+	defer func() { ConfigPath = filepath.Join(os.TempDir(), "handler-test-config.yaml") }()
+
+	ok := func(c echo.Context) error { return c.String(http.StatusOK, "ok") }
+	e := echo.New()
+	e.Use(Auth("admin", "secret"))
+	e.GET("/health", ok)
+	e.GET("/api/runners", ok)
+	e.POST("/api/runners", ok)
+	e.POST("/api/runners/:name/start", ok)
+	e.GET("/api/tokens", ListTokens)
+	e.POST("/api/tokens", CreateToken)
+	e.DELETE("/api/tokens/:id", DeleteToken)
+	do := func(method, path, body string, auth func(*http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if auth != nil {
+			auth(req)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	basic := func(r *http.Request) { r.SetBasicAuth("admin", "secret") }
+	bearer := func(tok string) func(*http.Request) {
+		return func(r *http.Request) { r.Header.Set(echo.HeaderAuthorization, "Bearer "+tok) }
+	}
+
+	if rec := do(http.MethodGet, "/health", "", nil); rec.Code != http.StatusOK {
+		t.Errorf("/health without auth: %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/api/runners", "", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("no auth: expected 401, got %d", rec.Code)
+	}
+	rec := do(http.MethodPost, "/api/tokens", `{"name":"ci","scopes":["operate"]}`, basic)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create token: %d %s", rec.Code, rec.Body.String())
+	}
+	var created struct {
+		Token string `json:"token"`
+		Info  struct {
+			ID string `json:"id"`
+		} `json:"info"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+
+	for _, tc := range []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodGet, "/api/runners", http.StatusOK},
+		{http.MethodPost, "/api/runners/r1/start", http.StatusOK},
+		{http.MethodPost, "/api/runners", http.StatusForbidden},
+		{http.MethodGet, "/api/tokens", http.StatusForbidden},
+	} {
+		if rec := do(tc.method, tc.path, "", bearer(created.Token)); rec.Code != tc.want {
+			t.Errorf("operate token %s %s: expected %d, got %d", tc.method, tc.path, tc.want, rec.Code)
+		}
+	}
+	if rec := do(http.MethodGet, "/api/runners", "", bearer("rfm_invalid")); rec.Code != http.StatusUnauthorized {
+		t.Errorf("invalid token: expected 401, got %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/api/tokens", "", basic); rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), created.Token) || strings.Contains(rec.Body.String(), `"hash"`) {
+		t.Errorf("list tokens: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := do(http.MethodDelete, "/api/tokens/"+created.Info.ID, "", basic); rec.Code != http.StatusOK {
+		t.Errorf("delete token: %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/api/runners", "", bearer(created.Token)); rec.Code != http.StatusUnauthorized {
+		t.Errorf("revoked token: expected 401, got %d", rec.Code)
+	}
+}
+
+func TestCurrentPrincipal_DefaultsToNoAccess(t *testing.T) {
+	e := echo.New()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/api/runners", nil), httptest.NewRecorder())
+	p := CurrentPrincipal(c)
+	if p.Allows(apitoken.ScopeRead) {
+		t.Errorf("principal without Auth middleware should have no access: %+v", p)
+	}
+	cfg := &config.Config{}
+	if targetAccess(c, cfg)("o1") {
+		t.Error("targetAccess without principal should deny every target")
+	}
+}
@@ -0,0 +1,453 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/apitoken"
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/lab-dev/github-actions-runner-manager/internal/events"
+	"github.com/lab-dev/github-actions-runner-manager/internal/metrics"
+	"github.com/lab-dev/github-actions-runner-manager/internal/runner"
+	"github.com/labstack/echo/v4"
+)
+
+// 批量操作
+const (
+	BulkStart        = "start"
+	BulkStop         = "stop"
+	BulkRestart      = "restart"
+	BulkDrain        = "drain"  // 等待当前 Job 结束后停止
+	BulkRemove       = "remove" // 停止、删除目录并从配置移除，同 DELETE /api/runners/:name
+	BulkUpdateLabels = "update-labels"
+)
+
+// 单个 runner 的批量操作结果
+const (
+	BulkOK      = "ok"
+	BulkSkipped = "skipped" // 已处于目标状态，未做操作
+	BulkError   = "error"
+)
+
+const (
+	defaultBulkConcurrency  = 4
+	maxBulkConcurrency      = 16
+	defaultBulkDrainTimeout = 10 * time.Minute
+	maxBulkDrainTimeout     = time.Hour
+)
+
+// BulkSelector 选择 runner，各条件同时满足；至少填写一项
+type BulkSelector struct {
+	Names  []string `json:"names"`  // runner 名称；不存在或无权管理的逐个报错
+	Label  string   `json:"label"`  // 含该 label
+	Target string   `json:"target"` // target，支持 path.Match 通配符，如 acme/*
+	Status string   `json:"status"` // installed、new、missing，或 running、stopped（已注册但未运行）
+}
+
+func (s BulkSelector) empty() bool {
+	return len(s.Names) == 0 && s.Label == "" && s.Target == "" && s.Status == ""
+}
+
+// BulkRequest 批量操作请求
+type BulkRequest struct {
+	Action   string       `json:"action"`
+	Selector BulkSelector `json:"selector"`
+	// update-labels：Labels 非 null 时整体替换（[] 表示清空），再加上 AddLabels、去掉 RemoveLabels
+	Labels       []string `json:"labels"`
+	AddLabels    []string `json:"add_labels"`
+	RemoveLabels []string `json:"remove_labels"`
+	// Concurrency 同时操作的 runner 数，默认 4，最大 16
+	Concurrency int `json:"concurrency"`
+	// DrainTimeoutSeconds drain 时等待当前 Job 结束的上限，默认 600，最大 3600；超时的 runner 保持运行并报错
+	DrainTimeoutSeconds int `json:"drain_timeout_seconds"`
+}
+
+// BulkResult 单个 runner 的操作结果
+type BulkResult struct {
+	Runner  string `json:"runner"`
+	Result  string `json:"result"` // ok、skipped、error
+	Message string `json:"message,omitempty"`
+}
+
+// BulkResponse 批量操作结果，按选中 runner 的配置顺序排列
+type BulkResponse struct {
+	Action    string       `json:"action"`
+	Results   []BulkResult `json:"results"`
+	Succeeded int          `json:"succeeded"`
+	Skipped   int          `json:"skipped"`
+	Failed    int          `json:"failed"`
+}
+
+// selectRunners 按选择条件返回配置中匹配且调用者可管理的 runner；names 中找不到的单独返回
+func selectRunners(ctx context.Context, c echo.Context, cfg *config.Config, sel BulkSelector) ([]config.RunnerItem, []string) {
+	allowed := targetAccess(c, cfg)
+	wanted := map[string]bool{}
+	for _, n := range sel.Names {
+		wanted[strings.TrimSpace(n)] = true
+	}
+	var picked []config.RunnerItem
+	for _, item := range cfg.Runners.Items {
+		if !allowed(item.Target) {
+			continue
+		}
+		if len(sel.Names) > 0 {
+			if _, ok := wanted[item.Name]; !ok {
+				continue
+			}
+			wanted[item.Name] = false // 已找到
+		}
+		if sel.Label != "" && !slices.Contains(item.Labels, sel.Label) {
+			continue
+		}
+		if sel.Target != "" {
+			if ok, _ := path.Match(sel.Target, item.Target); !ok {
+				continue
+			}
+		}
+		if sel.Status != "" && !statusMatches(ctx, cfg, item, sel.Status) {
+			continue
+		}
+		picked = append(picked, item)
+	}
+	var missing []string
+	for _, n := range sel.Names {
+		if n = strings.TrimSpace(n); wanted[n] {
+			missing = append(missing, n)
+			delete(wanted, n)
+		}
+	}
+	return picked, missing
+}
+
+func statusMatches(ctx context.Context, cfg *config.Config, item config.RunnerItem, status string) bool {
+	info := runner.GetByName(cfg, item.Name)
+	if info == nil {
+		return false
+	}
+	switch status {
+	case "running":
+		return fleetOps.Running(ctx, cfg, item)
+	case "stopped":
+		return info.Status == runner.StatusInstalled && !fleetOps.Running(ctx, cfg, item)
+	}
+	return string(info.Status) == status
+}
+
+// forEachBounded 以最多 n 个并发对每个 runner 执行 fn，结果按输入顺序返回
+func forEachBounded(n int, items []config.RunnerItem, fn func(config.RunnerItem) BulkResult) []BulkResult {
+	results := make([]BulkResult, len(items))
+	sem := make(chan struct{}, n)
+	var wg sync.WaitGroup
+	for i, item := range items {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = fn(item)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func bulkOK(name, msg string) BulkResult {
+	return BulkResult{Runner: name, Result: BulkOK, Message: msg}
+}
+func bulkSkipped(name, msg string) BulkResult {
+	return BulkResult{Runner: name, Result: BulkSkipped, Message: msg}
+}
+func bulkError(name, msg string) BulkResult {
+	return BulkResult{Runner: name, Result: BulkError, Message: msg}
+}
+
+func bulkRegistered(cfg *config.Config, item config.RunnerItem) error {
+	info := runner.GetByName(cfg, item.Name)
+	if info == nil || info.Status != runner.StatusInstalled {
+		status := runner.StatusMissing
+		if info != nil {
+			status = info.Status
+		}
+		return fmt.Errorf("仅已注册的 runner 可启动，当前状态: %s", status)
+	}
+	return nil
+}
+
+// runBulk 对单个 runner 执行 start、stop、restart、drain 或 remove 的运行时部分
+func runBulk(ctx context.Context, cfg *config.Config, req *BulkRequest, drainTimeout time.Duration, item config.RunnerItem) BulkResult {
+	name := item.Name
+	switch req.Action {
+	case BulkStart:
+		if err := bulkRegistered(cfg, item); err != nil {
+			return bulkError(name, err.Error())
+		}
+		if fleetOps.Running(ctx, cfg, item) {
+			return bulkSkipped(name, "Runner 已在运行中")
+		}
+		if err := fleetOps.Start(ctx, cfg, item); err != nil {
+			return bulkError(name, "启动失败: "+err.Error())
+		}
+		return bulkOK(name, "已发起启动")
+	case BulkStop:
+		if !fleetOps.Running(ctx, cfg, item) {
+			return bulkSkipped(name, "Runner 未在运行")
+		}
+		metrics.RunnerStopAttempts.Inc(name)
+		if err := fleetOps.Stop(ctx, cfg, item); err != nil {
+			metrics.RunnerStopFailures.Inc(name)
+			return bulkError(name, "停止失败: "+err.Error())
+		}
+		return bulkOK(name, "已停止")
+	case BulkRestart:
+		if err := bulkRegistered(cfg, item); err != nil {
+			return bulkError(name, err.Error())
+		}
+		if fleetOps.Running(ctx, cfg, item) {
+			metrics.RunnerStopAttempts.Inc(name)
+			if err := stopAndWait(ctx, cfg, item); err != nil {
+				metrics.RunnerStopFailures.Inc(name)
+				return bulkError(name, err.Error())
+			}
+		}
+		if err := fleetOps.Start(ctx, cfg, item); err != nil {
+			return bulkError(name, "启动失败: "+err.Error())
+		}
+		return bulkOK(name, "已重启")
+	case BulkDrain:
+		if !fleetOps.Running(ctx, cfg, item) {
+			return bulkSkipped(name, "Runner 未在运行")
+		}
+		deadline := time.Now().Add(drainTimeout)
+		for fleetOps.Busy(cfg, item) {
+			if time.Now().After(deadline) {
+				return bulkError(name, fmt.Sprintf("等待当前 Job 结束超时（%d 秒），runner 保持运行", int(drainTimeout.Seconds())))
+			}
+			if sleepCtx(ctx, upgradePollInterval) != nil {
+				return bulkError(name, "已取消，runner 保持运行")
+			}
+		}
+		metrics.RunnerStopAttempts.Inc(name)
+		if err := stopAndWait(ctx, cfg, item); err != nil {
+			metrics.RunnerStopFailures.Inc(name)
+			return bulkError(name, err.Error())
+		}
+		if fleetOps.Busy(cfg, item) {
+			return bulkError(name, "已停止，但"+jobInterruptedMsg)
+		}
+		return bulkOK(name, "当前 Job 结束后已停止")
+	case BulkRemove:
+		if err := fleetOps.Remove(ctx, cfg, item); err != nil {
+			return bulkError(name, "停止失败，未移除: "+err.Error())
+		}
+		// 仅当安装目录在 base_path 下时才删除，防止误删系统路径
+		if dir := item.InstallPath(cfg.Runners.BasePath); isUnderBasePath(cfg.Runners.BasePath, dir) {
+			if err := os.RemoveAll(dir); err != nil {
+				return bulkError(name, "删除目录失败: "+err.Error())
+			}
+		}
+		return bulkOK(name, "已从配置中移除")
+	}
+	return bulkError(name, "不支持的操作")
+}
+
+// updateLabels 计算 update-labels 后的 labels，保持原有顺序
+func updateLabels(cur []string, req *BulkRequest) []string {
+	out := cur
+	if req.Labels != nil {
+		out = req.Labels
+	}
+	out = slices.Clone(out)
+	for _, l := range req.AddLabels {
+		if !slices.Contains(out, l) {
+			out = append(out, l)
+		}
+	}
+	out = slices.DeleteFunc(out, func(l string) bool { return slices.Contains(req.RemoveLabels, l) })
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// BulkRunners 对按条件选中的多个 runner 执行同一操作（POST /api/runners/bulk），返回逐个 runner 的结果。
+// start、stop、restart、drain 需 operate 权限，remove 与 update-labels 需 admin 权限
+func BulkRunners(c echo.Context) error {
+	var req BulkRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "参数错误: "+err.Error())
+	}
+	req.Action = strings.TrimSpace(req.Action)
+	if req.Labels != nil {
+		// 保留 [] 与 null 的区别：[] 表示清空
+		req.Labels = append([]string{}, normalizeLabels(req.Labels)...)
+	}
+	req.AddLabels = normalizeLabels(req.AddLabels)
+	req.RemoveLabels = normalizeLabels(req.RemoveLabels)
+	switch req.Action {
+	case BulkStart, BulkStop, BulkRestart, BulkDrain:
+	case BulkRemove, BulkUpdateLabels:
+		if !CurrentPrincipal(c).Allows(apitoken.ScopeAdmin) {
+			return echo.NewHTTPError(http.StatusForbidden, req.Action+" 需要 admin 权限")
+		}
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "action 仅支持 start/stop/restart/drain/remove/update-labels")
+	}
+	if req.Selector.empty() {
+		return echo.NewHTTPError(http.StatusBadRequest, "请在 selector 中指定 names、label、target 或 status")
+	}
+	switch req.Selector.Status {
+	case "", string(runner.StatusInstalled), string(runner.StatusNew), string(runner.StatusMissing), "running", "stopped":
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "selector.status 仅支持 installed/new/missing/running/stopped")
+	}
+	if _, err := path.Match(req.Selector.Target, ""); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "selector.target 模式无效: "+err.Error())
+	}
+	if req.Action == BulkUpdateLabels && req.Labels == nil && len(req.AddLabels) == 0 && len(req.RemoveLabels) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "update-labels 需提供 labels、add_labels 或 remove_labels")
+	}
+	if req.Concurrency < 0 || req.DrainTimeoutSeconds < 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "concurrency、drain_timeout_seconds 不能为负数")
+	}
+	concurrency := min(req.Concurrency, maxBulkConcurrency)
+	if concurrency == 0 {
+		concurrency = defaultBulkConcurrency
+	}
+	drainTimeout := defaultBulkDrainTimeout
+	if req.DrainTimeoutSeconds > 0 {
+		drainTimeout = min(time.Duration(req.DrainTimeoutSeconds)*time.Second, maxBulkDrainTimeout)
+	}
+
+	cfg, err := getConfig(c)
+	if err != nil {
+		return err
+	}
+	if req.Action == BulkRemove || req.Action == BulkUpdateLabels {
+		if err := rejectIfGitManaged(cfg); err != nil {
+			return err
+		}
+	}
+	ctx := c.Request().Context()
+	items, missing := selectRunners(ctx, c, cfg, req.Selector)
+	names := make([]string, len(items))
+	for i, item := range items {
+		names[i] = item.Name
+	}
+	c.Set(auditRunnerKey, strings.Join(names, ","))
+
+	var results []BulkResult
+	if req.Action == BulkUpdateLabels {
+		results, err = bulkUpdateLabels(c, &req, names)
+	} else {
+		// 已开始的操作不随请求断开而中断，drain 的等待除外
+		opCtx := context.WithoutCancel(ctx)
+		results = forEachBounded(concurrency, items, func(item config.RunnerItem) BulkResult {
+			if req.Action == BulkDrain {
+				return runBulk(ctx, cfg, &req, drainTimeout, item)
+			}
+			return runBulk(opCtx, cfg, &req, drainTimeout, item)
+		})
+		if req.Action == BulkRemove {
+			err = bulkRemoveFromConfig(c, results)
+		}
+	}
+	if err != nil {
+		if he, ok := err.(*echo.HTTPError); ok {
+			return he
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "保存配置失败: "+err.Error())
+	}
+	for _, n := range missing {
+		results = append(results, bulkError(n, "未找到该 runner"))
+	}
+	resp := BulkResponse{Action: req.Action, Results: results}
+	if resp.Results == nil {
+		resp.Results = []BulkResult{}
+	}
+	for _, r := range resp.Results {
+		switch r.Result {
+		case BulkOK:
+			resp.Succeeded++
+		case BulkSkipped:
+			resp.Skipped++
+		default:
+			resp.Failed++
+		}
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// bulkRemoveFromConfig 将已停止并删除目录的 runner 一次性从配置移除，只记录一个配置版本
+func bulkRemoveFromConfig(c echo.Context, results []BulkResult) error {
+	var removed []string
+	for _, r := range results {
+		if r.Result == BulkOK {
+			removed = append(removed, r.Runner)
+		}
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+	if err := config.LoadAndSaveBy(ConfigPath, CurrentPrincipal(c).Name, "runner.bulk remove "+strings.Join(removed, ","), func(cfg *config.Config) error {
+		cfg.Runners.Items = slices.DeleteFunc(cfg.Runners.Items, func(item config.RunnerItem) bool { return slices.Contains(removed, item.Name) })
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, name := range removed {
+		forgetObserved(name)
+		events.Publish(events.TypeRunnerRemoved, name, nil)
+	}
+	return nil
+}
+
+// bulkUpdateLabels 在一次配置写入中修改选中 runner 的 labels；新 labels 在下次注册时生效
+func bulkUpdateLabels(c echo.Context, req *BulkRequest, names []string) ([]BulkResult, error) {
+	results := make([]BulkResult, 0, len(names))
+	var changed []string
+	if len(names) == 0 {
+		return results, nil
+	}
+	err := config.LoadAndSaveBy(ConfigPath, CurrentPrincipal(c).Name, "runner.bulk update-labels "+strings.Join(names, ","), func(cfg *config.Config) error {
+		if err := rejectIfGitManaged(cfg); err != nil {
+			return err
+		}
+		results, changed = results[:0], nil
+		for _, name := range names {
+			idx := slices.IndexFunc(cfg.Runners.Items, func(item config.RunnerItem) bool { return item.Name == name })
+			if idx < 0 {
+				results = append(results, bulkError(name, "未找到该 runner"))
+				continue
+			}
+			item := &cfg.Runners.Items[idx]
+			labels := updateLabels(item.Labels, req)
+			if slices.Equal(labels, item.Labels) {
+				results = append(results, bulkSkipped(name, "labels 未变化"))
+				continue
+			}
+			item.Labels = labels
+			changed = append(changed, name)
+			results = append(results, bulkOK(name, "已更新 labels，重新注册后生效"))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(changed) > 0 {
+		cfg, err := config.Load(ConfigPath)
+		if err == nil {
+			for _, name := range changed {
+				events.Publish(events.TypeRunnerUpdated, name, runner.GetByName(cfg, name))
+			}
+		}
+	}
+	return results, nil
+}
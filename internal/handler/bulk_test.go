@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/apitoken"
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/labstack/echo/v4"
+)
+
+func TestBulkRunners(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	cfg := &config.Config{Runners: config.RunnersConfig{BasePath: dir, Items: []config.RunnerItem{
+		{Name: "r1", TargetType: "org", Target: "o1", Labels: []string{"gpu"}},
+		{Name: "r2", TargetType: "org", Target: "o1", Labels: []string{"gpu"}},
+		{Name: "r3", TargetType: "repo", Target: "o2/app", Labels: []string{"cpu"}},
+	}}}
+	if err := cfg.Save(cfgPath); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"r1", "r2"} {
+		_ = os.MkdirAll(filepath.Join(dir, name), 0755)
+		_ = os.WriteFile(filepath.Join(dir, name, ".runner"), []byte("{}"), 0644)
+	}
+	ConfigPath = cfgPath
+	defer func() { ConfigPath = filepath.Join(os.TempDir(), "handler-test-config.yaml") }()
+	fake := &fakeOps{running: map[string]bool{"r1": true}}
+	savedOps, savedPoll := fleetOps, upgradePollInterval
+	fleetOps, upgradePollInterval = fake, time.Millisecond
+	defer func() { fleetOps, upgradePollInterval = savedOps, savedPoll }()
+	operate, _, _ := apitoken.Create(cfg.Runners.StateDir(), "ops", []string{"operate"})
+
+	e := echo.New()
+	e.Use(Auth("admin", "secret"))
+	e.POST("/api/runners/bulk", BulkRunners)
+	bulk := func(body, token string) (int, BulkResponse) {
+		req := httptest.NewRequest(http.MethodPost, "/api/runners/bulk", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if token != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		} else {
+			req.SetBasicAuth("admin", "secret")
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		var resp BulkResponse
+		_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp
+	}
+	summary := func(resp BulkResponse) string {
+		var out []string
+		for _, r := range resp.Results {
+			out = append(out, r.Runner+":"+r.Result)
+		}
+		return strings.Join(out, ",")
+	}
+
+	if code, resp := bulk(`{"action":"start","selector":{"label":"gpu"}}`, operate); code != http.StatusOK || summary(resp) != "r1:skipped,r2:ok" {
+		t.Errorf("start = %d %s", code, summary(resp))
+	}
+	if code, resp := bulk(`{"action":"stop","selector":{"target":"o*","status":"running"}}`, ""); code != http.StatusOK || summary(resp) != "r1:ok,r2:ok" {
+		t.Errorf("stop = %d %s", code, summary(resp))
+	}
+	fake.ops = nil
+	code, resp := bulk(`{"action":"restart","selector":{"names":["r1","r3","nope"]},"concurrency":2}`, "")
+	if code != http.StatusOK || summary(resp) != "r1:ok,r3:error,nope:error" || resp.Succeeded != 1 || resp.Failed != 2 {
+		t.Errorf("restart = %d %s %+v", code, summary(resp), resp)
+	}
+	if got := strings.Join(fake.ops, ","); got != "start r1" {
+		t.Errorf("restart ops = %s", got)
+	}
+	if code, resp := bulk(`{"action":"drain","selector":{"names":["r1","r2"]}}`, ""); code != http.StatusOK || summary(resp) != "r1:ok,r2:skipped" {
+		t.Errorf("drain = %d %s", code, summary(resp))
+	}
+
+	// remove 与 update-labels 需 admin
+	if code, _ := bulk(`{"action":"update-labels","selector":{"label":"gpu"},"add_labels":["fast"]}`, operate); code != http.StatusForbidden {
+		t.Errorf("update-labels with operate token = %d, want 403", code)
+	}
+	if code, resp := bulk(`{"action":"update-labels","selector":{"label":"gpu"},"add_labels":["fast"],"remove_labels":["gpu"]}`, ""); code != http.StatusOK || summary(resp) != "r1:ok,r2:ok" {
+		t.Errorf("update-labels = %d %s", code, summary(resp))
+	}
+	cur, _ := config.Load(cfgPath)
+	if !slices.Equal(cur.Runners.Items[0].Labels, []string{"fast"}) || !slices.Equal(cur.Runners.Items[2].Labels, []string{"cpu"}) {
+		t.Errorf("labels = %+v", cur.Runners.Items)
+	}
+	if code, resp := bulk(`{"action":"remove","selector":{"label":"fast","names":["r2"]}}`, ""); code != http.StatusOK || summary(resp) != "r2:ok" {
+		t.Errorf("remove = %d %s", code, summary(resp))
+	}
+	if cur, _ := config.Load(cfgPath); len(cur.Runners.Items) != 2 || cur.Runners.Items[1].Name != "r3" {
+		t.Errorf("items after remove = %+v", cur.Runners.Items)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "r2")); !os.IsNotExist(err) {
+		t.Errorf("r2 dir not removed: %v", err)
+	}
+
+	for _, body := range []string{
+		`{"action":"start","selector":{}}`,
+		`{"action":"reboot","selector":{"names":["r1"]}}`,
+		`{"action":"start","selector":{"status":"busy"}}`,
+		`{"action":"update-labels","selector":{"names":["r1"]}}`,
+	} {
+		if code, _ := bulk(body, ""); code != http.StatusBadRequest {
+			t.Errorf("%s = %d, want 400", body, code)
+		}
+	}
+}
+
+func TestBulkDrain_ReportsJobClaimedBeforeStop(t *testing.T) {
+	cfg := &config.Config{Runners: config.RunnersConfig{BasePath: t.TempDir()}}
+	item := config.RunnerItem{Name: "r1", TargetType: "org", Target: "o1"}
+	fake := &claimOnStopOps{fakeOps: fakeOps{running: map[string]bool{"r1": true}}, busy: map[string]bool{}}
+	savedOps, savedPoll := fleetOps, upgradePollInterval
+	fleetOps, upgradePollInterval = fake, time.Millisecond
+	defer func() { fleetOps, upgradePollInterval = savedOps, savedPoll }()
+
+	res := runBulk(context.Background(), cfg, &BulkRequest{Action: BulkDrain}, time.Second, item)
+	if res.Result != "error" || !strings.Contains(res.Message, "中断") {
+		t.Errorf("drain = %+v, want interrupted job reported", res)
+	}
+}
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/labstack/echo/v4"
+)
+
+// requireFullAccess 配置历史、计划与 GitOps 涉及所有 runner，受 auth.access 限制的调用者无权使用
+func requireFullAccess(c echo.Context, cfg *config.Config) error {
+	if !fullAccess(c, cfg) {
+		return echo.NewHTTPError(http.StatusForbidden, "该操作涉及所有 runner，仅限不受 auth.access 限制的调用者")
+	}
+	return nil
+}
+
+func revParam(s, name string) (int, error) {
+	rev, err := strconv.Atoi(s)
+	if err != nil || rev < 1 {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, name+" 必须为正整数版本号")
+	}
+	return rev, nil
+}
+
+func revisionError(err error) error {
+	if errors.Is(err, config.ErrRevisionNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, "读取配置历史失败: "+err.Error())
+}
+
+// ListConfigHistory 列出配置历史版本（GET /api/config/history），最新的在前
+func ListConfigHistory(c echo.Context) error {
+	cfg, err := getConfig(c)
+	if err != nil {
+		return err
+	}
+	if err := requireFullAccess(c, cfg); err != nil {
+		return err
+	}
+	list, err := config.History(ConfigPath)
+	if err != nil {
+		return revisionError(err)
+	}
+	return c.JSON(http.StatusOK, map[string]any{"revisions": list})
+}
+
+// GetConfigRevision 返回某个历史版本的配置文件内容（GET /api/config/history/:rev）
+func GetConfigRevision(c echo.Context) error {
+	cfg, err := getConfig(c)
+	if err != nil {
+		return err
+	}
+	if err := requireFullAccess(c, cfg); err != nil {
+		return err
+	}
+	rev, err := revParam(c.Param("rev"), "rev")
+	if err != nil {
+		return err
+	}
+	data, err := config.RevisionContent(ConfigPath, rev)
+	if err != nil {
+		return revisionError(err)
+	}
+	return c.Blob(http.StatusOK, "application/yaml; charset=utf-8", data)
+}
+
+// DiffConfig 比较两个版本（GET /api/config/diff?from=&to=），省略 to 时与当前配置文件比较
+func DiffConfig(c echo.Context) error {
+	cfg, err := getConfig(c)
+	if err != nil {
+		return err
+	}
+	if err := requireFullAccess(c, cfg); err != nil {
+		return err
+	}
+	from, err := revParam(c.QueryParam("from"), "from")
+	if err != nil {
+		return err
+	}
+	to := 0
+	if v := c.QueryParam("to"); v != "" {
+		if to, err = revParam(v, "to"); err != nil {
+			return err
+		}
+	}
+	diff, err := config.DiffRevisions(ConfigPath, from, to)
+	if err != nil {
+		return revisionError(err)
+	}
+	return c.JSON(http.StatusOK, map[string]any{"from": from, "to": to, "diff": diff})
+}
+
+// RollbackResult 回滚结果：新版本号与 runner 对齐结果
+type RollbackResult struct {
+	Revision int `json:"revision"` // 回滚产生的新版本
+	ReconcileResult
+}
+
+// RollbackConfig 将配置回滚到历史版本（POST /api/config/rollback/:rev），经 config.Validate 校验后写回，
+// 并按 reconcileRunners 对齐 runner。runner 目录不删除，便于再次回滚
+func RollbackConfig(c echo.Context) error {
+	cfg, err := getConfig(c)
+	if err != nil {
+		return err
+	}
+	if err := requireFullAccess(c, cfg); err != nil {
+		return err
+	}
+	if err := rejectIfGitManaged(cfg); err != nil {
+		return err
+	}
+	rev, err := revParam(c.Param("rev"), "rev")
+	if err != nil {
+		return err
+	}
+	prev, restored, err := config.Rollback(ConfigPath, rev, CurrentPrincipal(c).Name)
+	if err != nil {
+		if errors.Is(err, config.ErrRevisionNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusBadRequest, "回滚失败: "+err.Error())
+	}
+	res := RollbackResult{ReconcileResult: reconcileRunners(prev, restored)}
+	if list, err := config.History(ConfigPath); err == nil && len(list) > 0 {
+		res.Revision = list[0].Rev
+	}
+	log.Printf("[config] %s 将配置回滚到版本 %d", CurrentPrincipal(c).Name, rev)
+	return c.JSON(http.StatusOK, res)
+}
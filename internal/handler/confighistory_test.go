@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/apitoken"
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/labstack/echo/v4"
+)
+
+func TestConfigRollbackReconcilesRunners(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	cfg := &config.Config{Runners: config.RunnersConfig{BasePath: dir, Items: []config.RunnerItem{
+		{Name: "r1", TargetType: "org", Target: "o1"},
+	}}}
+	_ = cfg.Save(cfgPath)
+	ConfigPath = cfgPath
+	defer func() { ConfigPath = filepath.Join(os.TempDir(), "handler-test-config.yaml") }()
+	_ = os.MkdirAll(filepath.Join(dir, "r2"), 0755)
+	_ = os.WriteFile(filepath.Join(dir, "r2", ".runner"), []byte("{}"), 0644)
+	if err := config.LoadAndSaveBy(cfgPath, "alice", "runner.add r2", func(c *config.Config) error {
+		c.Runners.Items = append(c.Runners.Items, config.RunnerItem{Name: "r2", TargetType: "org", Target: "o1"})
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	restricted, _, _ := apitoken.Create(cfg.Runners.StateDir(), "ci", []string{"admin"})
+	_ = config.LoadAndSave(cfgPath, func(c *config.Config) error {
+		c.Auth = &config.AuthConfig{Access: []config.AccessRule{{Principals: []string{"token:ci"}, Targets: []string{"o1"}}}}
+		return nil
+	})
+
+	fake := &fakeOps{running: map[string]bool{"r1": true, "r2": true}}
+	savedOps := fleetOps
+	fleetOps = fake
+	defer func() { fleetOps = savedOps }()
+
+	e := echo.New()
+	e.Use(Auth("admin", "secret"))
+	e.GET("/api/config/history", ListConfigHistory)
+	e.GET("/api/config/diff", DiffConfig)
+	e.POST("/api/config/rollback/:rev", RollbackConfig)
+	do := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		} else {
+			req.SetBasicAuth("admin", "secret")
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodGet, "/api/config/history", restricted); rec.Code != http.StatusForbidden {
+		t.Errorf("restricted token: expected 403, got %d", rec.Code)
+	}
+	rec := do(http.MethodGet, "/api/config/history", "")
+	var hist struct {
+		Revisions []config.Revision `json:"revisions"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &hist); err != nil || len(hist.Revisions) != 3 || hist.Revisions[1].Author != "alice" {
+		t.Fatalf("history: %s", rec.Body.String())
+	}
+	rec = do(http.MethodGet, "/api/config/diff?from=1&to=2", "")
+	if !strings.Contains(rec.Body.String(), `+        - name: r2`) {
+		t.Errorf("diff: %s", rec.Body.String())
+	}
+	if rec := do(http.MethodPost, "/api/config/rollback/99", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown rev: expected 404, got %d", rec.Code)
+	}
+
+	rec = do(http.MethodPost, "/api/config/rollback/1", "")
+	var res RollbackResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("rollback: %d %s", rec.Code, rec.Body.String())
+	}
+	if strings.Join(res.Stopped, ",") != "r2" || len(res.Started) != 0 || res.Revision != 4 {
+		t.Errorf("rollback to 1 = %+v", res)
+	}
+	if cur, _ := config.Load(cfgPath); len(cur.Runners.Items) != 1 || cur.Auth != nil {
+		t.Errorf("config not restored: %+v", cur)
+	}
+
+	rec = do(http.MethodPost, "/api/config/rollback/2", "")
+	res = RollbackResult{}
+	_ = json.Unmarshal(rec.Body.Bytes(), &res)
+	if strings.Join(res.Started, ",") != "r2" || len(res.Stopped) != 0 {
+		t.Errorf("rollback to 2 = %+v", res)
+	}
+	if got := strings.Join(fake.ops, ","); got != "remove r2,start r2" {
+		t.Errorf("ops = %s", got)
+	}
+}
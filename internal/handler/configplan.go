@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/labstack/echo/v4"
+)
+
+// ConfigPlan 提交的配置相对当前 config.yaml 的执行计划，与热加载、回滚或 GitOps 应用时对 runner 的处理一致
+type ConfigPlan struct {
+	Valid    bool           `json:"valid"`
+	Errors   []string       `json:"errors"`   // 校验错误；非空时不生成动作
+	Actions  []RunnerAction `json:"actions"`  // 逐个 runner 的动作，未变化的不列出
+	Warnings []string       `json:"warnings"` // 不影响 runner、但应用前需知道的事项
+
+	Recreate        []string `json:"recreate"`         // 将被重建的 runner（容器模式下删除并重新创建容器）
+	Start           []string `json:"start"`            // 将被启动的 runner
+	Stop            []string `json:"stop"`             // 将被停止的 runner
+	Register        []string `json:"register"`         // 新目录尚未注册、需提交注册令牌的 runner
+	StillRegistered []string `json:"still_registered"` // 被移除但仍在 GitHub 注册的 runner；修改配置不会注销它们
+	KeptDirs        []string `json:"kept_dirs"`        // 被移除或迁移的 runner 留在磁盘上的目录；修改配置不会删除目录
+}
+
+func newConfigPlan() *ConfigPlan {
+	return &ConfigPlan{Errors: []string{}, Actions: []RunnerAction{}, Warnings: []string{},
+		Recreate: []string{}, Start: []string{}, Stop: []string{}, Register: []string{}, StillRegistered: []string{}, KeptDirs: []string{}}
+}
+
+// planConfig 计算从 prev 切换到 next 的计划；next 须已通过校验
+func planConfig(ctx context.Context, prev, next *config.Config) *ConfigPlan {
+	p := newConfigPlan()
+	p.Valid = true
+	p.Actions = planRunners(ctx, prev, next)
+	for _, a := range p.Actions {
+		switch a.Action {
+		case ActionRecreate:
+			p.Recreate = append(p.Recreate, a.Runner)
+		case ActionStart:
+			p.Start = append(p.Start, a.Runner)
+		case ActionStop:
+			p.Stop = append(p.Stop, a.Runner)
+		}
+		switch {
+		case a.Action == ActionStop || a.Action == ActionRemove:
+			if a.Registered {
+				p.StillRegistered = append(p.StillRegistered, a.Runner)
+			}
+			p.KeptDirs = append(p.KeptDirs, a.InstallDir)
+		case a.PrevDir != "":
+			p.KeptDirs = append(p.KeptDirs, a.PrevDir)
+			if !a.Registered {
+				p.Register = append(p.Register, a.Runner)
+			}
+		case a.Action == ActionAdd && !a.Registered:
+			p.Register = append(p.Register, a.Runner)
+		}
+	}
+	if prev.Server != next.Server {
+		p.Warnings = append(p.Warnings, "server 配置变化需重启 Manager 后生效")
+	}
+	if next.GitManaged() && len(p.Actions) > 0 {
+		p.Warnings = append(p.Warnings, "runner 列表由 git 仓库管理，下次同步时将以仓库中的定义为准")
+	}
+	return p
+}
+
+// PlanConfig 预览提交的配置（完整的 config.yaml 内容，YAML 或 JSON）生效后 Manager 将执行的动作，不做任何修改（POST /api/config/plan）
+func PlanConfig(c echo.Context) error {
+	cfg, err := getConfig(c)
+	if err != nil {
+		return err
+	}
+	if err := requireFullAccess(c, cfg); err != nil {
+		return err
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, 1<<20))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "读取请求失败: "+err.Error())
+	}
+	if strings.TrimSpace(string(body)) == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "请提交完整的配置内容")
+	}
+	next, err := config.Decode(body)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "解析配置失败: "+err.Error())
+	}
+	if errs := config.ValidationErrors(next); len(errs) > 0 {
+		p := newConfigPlan()
+		p.Errors = errs
+		return c.JSON(http.StatusOK, p)
+	}
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 30*time.Second)
+	defer cancel()
+	return c.JSON(http.StatusOK, planConfig(ctx, cfg, next))
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestPlanConfig(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	current := "runners:\n  base_path: " + dir + "\n  items:\n" +
+		"    - {name: r1, target_type: org, target: o1}\n" +
+		"    - {name: r2, target_type: org, target: o1}\n" +
+		"    - {name: r3, target_type: org, target: o1}\n"
+	if err := os.WriteFile(cfgPath, []byte(current), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"r1", "r2", "r4"} {
+		_ = os.MkdirAll(filepath.Join(dir, name), 0755)
+		_ = os.WriteFile(filepath.Join(dir, name, ".runner"), []byte("{}"), 0644)
+	}
+	ConfigPath = cfgPath
+	defer func() { ConfigPath = filepath.Join(os.TempDir(), "handler-test-config.yaml") }()
+	fake := &fakeOps{running: map[string]bool{"r1": true, "r2": true}}
+	savedOps := fleetOps
+	fleetOps = fake
+	defer func() { fleetOps = savedOps }()
+
+	e := echo.New()
+	e.Use(Auth("", ""))
+	e.POST("/api/config/plan", PlanConfig)
+	plan := func(body string) (int, ConfigPlan) {
+		req := httptest.NewRequest(http.MethodPost, "/api/config/plan", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, "application/yaml")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		var p ConfigPlan
+		_ = json.Unmarshal(rec.Body.Bytes(), &p)
+		return rec.Code, p
+	}
+
+	// r1 换架构需重建，r2 移除，r3 改 labels，新增已注册的 r4 与未注册的 r5
+	code, p := plan("server:\n  port: 9090\nrunners:\n  base_path: " + dir + "\n  items:\n" +
+		"    - {name: r1, target_type: org, target: o1, arch: arm64}\n" +
+		"    - {name: r3, target_type: org, target: o1, labels: [gpu]}\n" +
+		"    - {name: r4, target_type: org, target: o1}\n" +
+		"    - {name: r5, target_type: org, target: o1}\n")
+	if code != http.StatusOK || !p.Valid {
+		t.Fatalf("plan = %d %+v", code, p)
+	}
+	var got []string
+	for _, a := range p.Actions {
+		got = append(got, a.Runner+":"+a.Action)
+	}
+	if want := "r1:recreate,r3:update,r4:start,r5:add,r2:stop"; strings.Join(got, ",") != want {
+		t.Errorf("actions = %s, want %s", strings.Join(got, ","), want)
+	}
+	if !slices.Equal(p.Actions[0].Runtime, []string{"arch"}) || !slices.Equal(p.Actions[1].Fields, []string{"labels"}) {
+		t.Errorf("changes = %+v / %+v", p.Actions[0], p.Actions[1])
+	}
+	if !slices.Equal(p.Recreate, []string{"r1"}) || !slices.Equal(p.Start, []string{"r4"}) || !slices.Equal(p.Stop, []string{"r2"}) ||
+		!slices.Equal(p.Register, []string{"r5"}) || !slices.Equal(p.StillRegistered, []string{"r2"}) ||
+		!slices.Equal(p.KeptDirs, []string{filepath.Join(dir, "r2")}) || len(p.Warnings) != 1 {
+		t.Errorf("summary = %+v", p)
+	}
+	if len(fake.ops) != 0 {
+		t.Errorf("plan touched runners: %v", fake.ops)
+	}
+	if data, _ := os.ReadFile(cfgPath); string(data) != current {
+		t.Errorf("plan changed config.yaml:\n%s", data)
+	}
+
+	// 列出每个无效 runner 的错误，序号与提交的内容一致
+	code, p = plan("runners:\n  base_path: " + dir + "\n  items:\n" +
+		"    - {name: r1, target_type: org, target: o1}\n" +
+		"    - {name: ../bad, target_type: org, target: o1}\n" +
+		"    - {name: r3, target_type: repo, target: o1}\n")
+	if code != http.StatusOK || p.Valid || len(p.Errors) != 2 || !strings.HasPrefix(p.Errors[0], "runners.items[1]") || len(p.Actions) != 0 {
+		t.Errorf("invalid plan = %d %+v", code, p)
+	}
+	code, p = plan("runners:\n  base_path: " + dir + "\n  items:\n    - {name: r1, target_type: org, target: o1}\n    - {name: r1, target_type: org, target: o2}\n")
+	if code != http.StatusOK || len(p.Errors) != 1 || !strings.Contains(p.Errors[0], "r1") {
+		t.Errorf("duplicate plan = %d %+v", code, p)
+	}
+	if code, _ := plan("runners: [\n"); code != http.StatusBadRequest {
+		t.Errorf("bad yaml = %d", code)
+	}
+}
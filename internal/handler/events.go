@@ -0,0 +1,213 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/lab-dev/github-actions-runner-manager/internal/events"
+	"github.com/lab-dev/github-actions-runner-manager/internal/runner"
+	"github.com/labstack/echo/v4"
+)
+
+// sseHeartbeat 空闲时发送注释行的间隔，避免代理因无数据断开连接
+const sseHeartbeat = 25 * time.Second
+
+// StreamEvents 以 Server-Sent Events 推送 fleet 状态变化（GET /api/events）；
+// 断线重连时浏览器自动携带 Last-Event-ID，补发缓存中其后的事件。受 auth.access 限制的调用者只收到可管理 runner 的事件
+func StreamEvents(c echo.Context) error {
+	cfg, err := getConfig(c)
+	if err != nil {
+		return err
+	}
+	visible := eventFilter(c, cfg)
+	var afterID int64
+	if v := c.Request().Header.Get("Last-Event-ID"); v != "" {
+		afterID, _ = strconv.ParseInt(v, 10, 64)
+	}
+	ch, backlog, cancel := events.Default.Subscribe(afterID)
+	defer cancel()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no") // 关闭 nginx 缓冲
+	res.WriteHeader(http.StatusOK)
+	// 客户端断线后 3 秒重连
+	if _, err := fmt.Fprint(res, "retry: 3000\n\n"); err != nil {
+		return nil
+	}
+	for _, ev := range backlog {
+		if !visible(ev) {
+			continue
+		}
+		if err := writeSSE(res, ev); err != nil {
+			return nil
+		}
+	}
+	res.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	ctx := c.Request().Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-ch:
+			if !ok {
+				// 消费过慢被总线移除：结束响应，EventSource 带 Last-Event-ID 重连后补发
+				return nil
+			}
+			if !visible(ev) {
+				continue
+			}
+			if err := writeSSE(res, ev); err != nil {
+				return nil
+			}
+			res.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(res, ": ping\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+		}
+	}
+}
+
+// eventFilter 返回判断调用者能否收到某事件的函数。受限的调用者只收到 target 可管理的 runner 的事件，
+// 不属于单个 runner 的事件（配置重载、升级汇总）不推送；新增或修改 runner 时重新读取配置，
+// 已移除的 runner 沿用订阅期间最后一次看到的 target
+func eventFilter(c echo.Context, cfg *config.Config) func(events.Event) bool {
+	patterns, restricted := accessPatterns(c, cfg)
+	if !restricted {
+		return func(events.Event) bool { return true }
+	}
+	targets := map[string]string{}
+	learn := func(cfg *config.Config) {
+		for _, item := range cfg.Runners.Items {
+			targets[item.Name] = item.Target
+		}
+	}
+	learn(cfg)
+	return func(ev events.Event) bool {
+		if ev.Runner == "" {
+			return false
+		}
+		target, ok := targets[ev.Runner]
+		if !ok || ev.Type == events.TypeRunnerAdded || ev.Type == events.TypeRunnerUpdated {
+			if latest, err := config.Load(ConfigPath); err == nil {
+				learn(latest)
+			}
+			target, ok = targets[ev.Runner]
+		}
+		return ok && config.TargetAllowed(patterns, target)
+	}
+}
+
+func writeSSE(res *echo.Response, ev events.Event) error {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(res, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, b)
+	return err
+}
+
+// observedState 状态监视记录的上次 runner 状态；同时作为 GET /api/runners?probe=false 的缓存
+type observedState struct {
+	Status    runner.Status
+	Running   bool
+	ProbeType string
+	Probe     *runner.ProbeInfo
+	Usage     *runner.ResourceUsage
+	At        time.Time
+}
+
+var (
+	observedMu sync.Mutex
+	observed   = map[string]observedState{}
+)
+
+// observeRunners 与上次观察到的状态比较，发布 runner.status 与 runner.probe_failed 事件；
+// 首次观察到的 runner 只记录不发布。List/Index/Metrics 与后台监视都会调用。
+func observeRunners(infos ...*runner.RunnerInfo) {
+	observedMu.Lock()
+	defer observedMu.Unlock()
+	for _, info := range infos {
+		prev, seen := observed[info.Name]
+		cur := observedState{Status: info.Status, Running: info.Running, Probe: info.Probe, Usage: info.Usage, At: time.Now()}
+		if info.Probe != nil {
+			cur.ProbeType = info.Probe.Type
+		}
+		// 后台监视不统计资源占用，沿用上次结果
+		if cur.Usage == nil && cur.Status != runner.StatusMissing {
+			cur.Usage = prev.Usage
+		}
+		observed[info.Name] = cur
+		if !seen {
+			continue
+		}
+		if prev.Status != cur.Status || prev.Running != cur.Running {
+			events.Publish(events.TypeRunnerStatus, info.Name, map[string]any{
+				"status":       cur.Status,
+				"running":      cur.Running,
+				"prev_status":  prev.Status,
+				"prev_running": prev.Running,
+			})
+		}
+		if info.Probe != nil && prev.ProbeType != cur.ProbeType {
+			events.Publish(events.TypeProbeFailed, info.Name, info.Probe)
+		}
+	}
+}
+
+// observeList 对 List 结果调用 observeRunners
+func observeList(list []runner.RunnerInfo) {
+	infos := make([]*runner.RunnerInfo, len(list))
+	for i := range list {
+		infos[i] = &list[i]
+	}
+	observeRunners(infos...)
+}
+
+// forgetObserved runner 被移除后清除其状态记录
+func forgetObserved(name string) {
+	observedMu.Lock()
+	defer observedMu.Unlock()
+	delete(observed, name)
+	forgetProbe(name)
+}
+
+// StatusWatchInterval 有 SSE 订阅者时后台探测 runner 状态的间隔
+const StatusWatchInterval = 10 * time.Second
+
+// RunStatusWatcher 在有 SSE 订阅者时定时探测全部 runner 状态并发布变化，应在 main 中以 goroutine 启动一次
+func RunStatusWatcher(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if events.Default.Subscribers() == 0 {
+			continue
+		}
+		cfg, err := config.Load(ConfigPath)
+		if err != nil {
+			log.Printf("[events] 加载配置失败: %v", err)
+			continue
+		}
+		list := runner.List(cfg)
+		if cfg.Runners.ContainerMode {
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			applyContainerStatus(ctx, cfg, list)
+			cancel()
+		}
+		observeList(list)
+	}
+}
@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/lab-dev/github-actions-runner-manager/internal/gitops"
+	"github.com/labstack/echo/v4"
+)
+
+// gitopsMu 串行化同步与应用，避免定时同步与 API 触发的同步交错
+var gitopsMu sync.Mutex
+
+// gitopsSyncTimeout 单次拉取、生成计划与应用的最长时间
+const gitopsSyncTimeout = 2 * time.Minute
+
+var errGitOpsDisabled = errors.New("未配置 gitops.repo")
+
+// rejectIfGitManaged runner 列表由 git 管理时拒绝通过界面或 API 修改，改动须提交到仓库
+func rejectIfGitManaged(cfg *config.Config) error {
+	if !cfg.GitManaged() {
+		return nil
+	}
+	return echo.NewHTTPError(http.StatusConflict, "runner 列表由 git 仓库 "+gitops.Redact(cfg.GitOps.Repo)+" 管理，请修改仓库中的 "+cfg.GitOps.EffectiveFile())
+}
+
+// syncGitOps 拉取仓库、校验定义并生成计划；有变更且开启 auto_apply 时直接应用。
+// 拉取或校验失败记入计划的 error，配置保持不变
+func syncGitOps(ctx context.Context) (*gitops.Plan, error) {
+	gitopsMu.Lock()
+	defer gitopsMu.Unlock()
+	cfg, err := config.Load(ConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.GitManaged() {
+		return nil, errGitOpsDisabled
+	}
+	g := cfg.GitOps
+	sd := cfg.Runners.StateDir()
+	p := &gitops.Plan{
+		Repo:      gitops.Redact(g.Repo),
+		Branch:    g.EffectiveBranch(),
+		File:      g.EffectiveFile(),
+		Changes:   []gitops.Change{},
+		CheckedAt: time.Now().UTC(),
+	}
+	if last, _ := gitops.LoadPlan(sd); last != nil {
+		p.AppliedCommit, p.AppliedAt = last.AppliedCommit, last.AppliedAt
+	}
+	fail := func(err error) (*gitops.Plan, error) {
+		p.State, p.Error = gitops.StateError, err.Error()
+		log.Printf("[gitops] 同步 %s 失败: %v", p.Repo, err)
+		return p, gitops.SavePlan(sd, p)
+	}
+	p.Commit, err = gitops.Checkout(ctx, gitops.CheckoutDir(sd), g.Repo, p.Branch)
+	if err != nil {
+		return fail(err)
+	}
+	def, err := gitops.LoadDefinition(gitops.CheckoutDir(sd), p.File)
+	if err != nil {
+		return fail(err)
+	}
+	items, err := def.Runners()
+	if err != nil {
+		return fail(errors.New("定义校验失败: " + err.Error()))
+	}
+	desired := gitops.Merge(cfg.Runners.Items, items)
+	candidate := *cfg
+	candidate.Runners.Items = desired
+	if err := config.Validate(&candidate); err != nil {
+		return fail(errors.New("定义校验失败: " + err.Error()))
+	}
+	p.Changes = gitops.Diff(cfg.Runners.Items, desired)
+	if len(p.Changes) == 0 {
+		p.State = gitops.StateInSync
+		if p.AppliedCommit != p.Commit {
+			now := time.Now().UTC()
+			p.AppliedCommit, p.AppliedAt = p.Commit, &now
+		}
+		return p, gitops.SavePlan(sd, p)
+	}
+	p.State, p.Desired = gitops.StatePending, desired
+	if err := gitops.SavePlan(sd, p); err != nil {
+		return nil, err
+	}
+	if !g.AutoApply {
+		log.Printf("[gitops] %s@%.12s 有 %d 项变更，等待批准", p.Repo, p.Commit, len(p.Changes))
+		return p, nil
+	}
+	if _, err := applyGitOpsPlan(p, config.AuthorSystem); err != nil {
+		return fail(err)
+	}
+	return p, nil
+}
+
+// applyGitOpsPlan 将计划中的 runner 列表写入配置（调用方需持有 gitopsMu），并按差异对齐 runner
+func applyGitOpsPlan(p *gitops.Plan, author string) (ReconcileResult, error) {
+	var prev, next *config.Config
+	err := config.LoadAndSaveBy(ConfigPath, author, "gitops "+shortCommit(p.Commit), func(c *config.Config) error {
+		cur := *c
+		prev = &cur
+		c.Runners.Items = gitops.Merge(c.Runners.Items, p.Desired)
+		next = c
+		return nil
+	})
+	if err != nil {
+		return ReconcileResult{}, err
+	}
+	res := reconcileRunners(prev, next)
+	now := time.Now().UTC()
+	p.State, p.Desired, p.Error = gitops.StateInSync, nil, ""
+	p.AppliedCommit, p.AppliedAt = p.Commit, &now
+	if err := gitops.SavePlan(next.Runners.StateDir(), p); err != nil {
+		log.Printf("[gitops] 保存计划失败: %v", err)
+	}
+	log.Printf("[gitops] 已应用 %s@%.12s：启动 %v，停止 %v，重建 %v，更新 %v", p.Repo, p.Commit, res.Started, res.Stopped, res.Recreated, res.Updated)
+	return res, nil
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
+
+// RunGitOpsSync 按 gitops.interval_seconds 定时同步；未配置 gitops 时每分钟检查一次配置。应在 main 中以 goroutine 启动一次
+func RunGitOpsSync() {
+	for {
+		interval := time.Minute
+		if cfg, err := config.Load(ConfigPath); err == nil && cfg.GitManaged() {
+			interval = cfg.GitOps.Interval()
+			ctx, cancel := context.WithTimeout(context.Background(), gitopsSyncTimeout)
+			if _, err := syncGitOps(ctx); err != nil {
+				log.Printf("[gitops] 同步失败: %v", err)
+			}
+			cancel()
+		}
+		time.Sleep(interval)
+	}
+}
+
+// GetGitOps 返回仓库同步状态与最近一次计划（GET /api/gitops）
+func GetGitOps(c echo.Context) error {
+	cfg, err := getConfig(c)
+	if err != nil {
+		return err
+	}
+	if err := requireFullAccess(c, cfg); err != nil {
+		return err
+	}
+	p, err := gitops.LoadPlan(cfg.Runners.StateDir())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "读取同步计划失败: "+err.Error())
+	}
+	return c.JSON(http.StatusOK, map[string]any{"enabled": cfg.GitManaged(), "plan": p})
+}
+
+// SyncGitOps 立即拉取仓库并生成计划（POST /api/gitops/sync）；开启 auto_apply 时同时应用
+func SyncGitOps(c echo.Context) error {
+	cfg, err := getConfig(c)
+	if err != nil {
+		return err
+	}
+	if err := requireFullAccess(c, cfg); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(c.Request().Context(), gitopsSyncTimeout)
+	defer cancel()
+	p, err := syncGitOps(ctx)
+	if err != nil {
+		if errors.Is(err, errGitOpsDisabled) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "同步失败: "+err.Error())
+	}
+	return c.JSON(http.StatusOK, map[string]any{"plan": p})
+}
+
+// ApplyGitOpsRequest 批准待应用的计划
+type ApplyGitOpsRequest struct {
+	// Commit 可选，须与待应用计划的提交一致，避免批准的不是审阅过的版本
+	Commit string `json:"commit"`
+}
+
+// ApplyGitOps 批准并应用待应用的计划（POST /api/gitops/apply）
+func ApplyGitOps(c echo.Context) error {
+	cfg, err := getConfig(c)
+	if err != nil {
+		return err
+	}
+	if err := requireFullAccess(c, cfg); err != nil {
+		return err
+	}
+	if !cfg.GitManaged() {
+		return echo.NewHTTPError(http.StatusBadRequest, errGitOpsDisabled.Error())
+	}
+	var req ApplyGitOpsRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "参数错误: "+err.Error())
+	}
+	gitopsMu.Lock()
+	defer gitopsMu.Unlock()
+	p, err := gitops.LoadPlan(cfg.Runners.StateDir())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "读取同步计划失败: "+err.Error())
+	}
+	if p == nil || p.State != gitops.StatePending {
+		return echo.NewHTTPError(http.StatusConflict, "没有待应用的计划")
+	}
+	if req.Commit != "" && req.Commit != p.Commit && req.Commit != shortCommit(p.Commit) {
+		return echo.NewHTTPError(http.StatusConflict, "待应用的计划已更新为 "+shortCommit(p.Commit)+"，请重新审阅")
+	}
+	res, err := applyGitOpsPlan(p, CurrentPrincipal(c).Name)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "应用失败: "+err.Error())
+	}
+	return c.JSON(http.StatusOK, map[string]any{"plan": p, "result": res})
+}
@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/lab-dev/github-actions-runner-manager/internal/gitops"
+	"github.com/labstack/echo/v4"
+)
+
+func TestGitOpsSyncAndApply(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	dir := t.TempDir()
+	repo := filepath.Join(dir, "fleet.git")
+	work := filepath.Join(dir, "work")
+	gitRun := func(dir string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	gitRun(dir, "init", "--quiet", "--bare", "--initial-branch=main", repo)
+	gitRun(dir, "init", "--quiet", "--initial-branch=main", work)
+	push := func(content string) {
+		_ = os.WriteFile(filepath.Join(work, "fleet.yaml"), []byte(content), 0644)
+		gitRun(work, "add", "fleet.yaml")
+		gitRun(work, "commit", "--quiet", "-m", "update fleet")
+		gitRun(work, "push", "--quiet", repo, "HEAD:main")
+	}
+	push("items:\n  - {name: r1, target_type: org, target: o1}\n  - {name: r2, target_type: org, target: o1, labels: [gpu]}\n")
+
+	cfgPath := filepath.Join(dir, "config.yaml")
+	cfg := &config.Config{
+		Runners: config.RunnersConfig{BasePath: filepath.Join(dir, "runners"), Items: []config.RunnerItem{
+			{Name: "r1", TargetType: "org", Target: "o1"},
+		}},
+		GitOps: &config.GitOpsConfig{Repo: repo},
+	}
+	if err := cfg.Save(cfgPath); err != nil {
+		t.Fatal(err)
+	}
+	ConfigPath = cfgPath
+	defer func() { ConfigPath = filepath.Join(os.TempDir(), "handler-test-config.yaml") }()
+	fake := &fakeOps{running: map[string]bool{"r1": true}}
+	savedOps := fleetOps
+	fleetOps = fake
+	defer func() { fleetOps = savedOps }()
+
+	e := echo.New()
+	e.Use(Auth("admin", "secret"))
+	e.POST("/api/runners", AddRunner)
+	e.GET("/api/gitops", GetGitOps)
+	e.POST("/api/gitops/sync", SyncGitOps)
+	e.POST("/api/gitops/apply", ApplyGitOps)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.SetBasicAuth("admin", "secret")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// 首次同步：生成待批准的计划，配置不变
+	if rec := do(http.MethodPost, "/api/gitops/sync", ""); rec.Code != http.StatusOK {
+		t.Fatalf("sync = %d %s", rec.Code, rec.Body)
+	}
+	p, _ := gitops.LoadPlan(cfg.Runners.StateDir())
+	if p == nil || p.State != gitops.StatePending || len(p.Changes) != 1 || p.Changes[0].Action != gitops.ActionAdd || p.Changes[0].Runner != "r2" {
+		t.Fatalf("plan = %+v", p)
+	}
+	if cur, _ := config.Load(cfgPath); len(cur.Runners.Items) != 1 {
+		t.Fatalf("sync without auto_apply changed config: %+v", cur.Runners.Items)
+	}
+
+	// git 管理时拒绝通过 API 修改 runner 列表
+	if rec := do(http.MethodPost, "/api/runners", `{"name":"r9","target_type":"org","target":"o1"}`); rec.Code != http.StatusConflict {
+		t.Errorf("add while git-managed = %d, want 409", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/gitops/apply", `{"commit":"0000000"}`); rec.Code != http.StatusConflict {
+		t.Errorf("apply stale commit = %d, want 409", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/gitops/apply", `{"commit":"`+p.Commit+`"}`); rec.Code != http.StatusOK {
+		t.Fatalf("apply = %d %s", rec.Code, rec.Body)
+	}
+	cur, _ := config.Load(cfgPath)
+	if len(cur.Runners.Items) != 2 || cur.Runners.Items[1].Labels[0] != "gpu" {
+		t.Fatalf("applied items = %+v", cur.Runners.Items)
+	}
+	if hist, _ := config.History(cfgPath); hist[0].Author != "admin" || !strings.HasPrefix(hist[0].Note, "gitops ") {
+		t.Errorf("revision = %+v", hist[0])
+	}
+	if rec := do(http.MethodPost, "/api/gitops/apply", ""); rec.Code != http.StatusConflict {
+		t.Errorf("apply without pending plan = %d, want 409", rec.Code)
+	}
+
+	// 无效定义：状态为 error，配置保持不变
+	push("items:\n  - {name: ../bad, target_type: org, target: o1}\n")
+	p, err := syncGitOps(context.Background())
+	if err != nil || p.State != gitops.StateError || !strings.Contains(p.Error, "校验") {
+		t.Fatalf("invalid definition plan = %+v, %v", p, err)
+	}
+	if cur, _ := config.Load(cfgPath); len(cur.Runners.Items) != 2 {
+		t.Errorf("invalid definition changed config: %+v", cur.Runners.Items)
+	}
+
+	// auto_apply：同步后直接应用并对齐 runner
+	_ = config.LoadAndSave(cfgPath, func(c *config.Config) error {
+		c.GitOps.AutoApply = true
+		return nil
+	})
+	push("items:\n  - {name: r2, target_type: org, target: o1, labels: [gpu]}\n")
+	fake.ops = nil
+	p, err = syncGitOps(context.Background())
+	if err != nil || p.State != gitops.StateInSync || p.AppliedCommit != p.Commit {
+		t.Fatalf("auto_apply plan = %+v, %v", p, err)
+	}
+	if cur, _ := config.Load(cfgPath); len(cur.Runners.Items) != 1 || cur.Runners.Items[0].Name != "r2" {
+		t.Errorf("auto_apply items = %+v", cur.Runners.Items)
+	}
+	if got := strings.Join(fake.ops, ","); got != "remove r1" {
+		t.Errorf("ops = %s", got)
+	}
+
+	// pool：count 增加时新增末尾的 runner，减少时移除
+	changes := func(p *gitops.Plan) string {
+		var got []string
+		for _, ch := range p.Changes {
+			got = append(got, ch.Action+" "+ch.Runner)
+		}
+		return strings.Join(got, ",")
+	}
+	push("pools:\n  - {name: ci, count: 3, target_type: org, target: o1, labels: [linux]}\n")
+	p, err = syncGitOps(context.Background())
+	if err != nil || p.State != gitops.StateInSync {
+		t.Fatalf("pool plan = %+v, %v", p, err)
+	}
+	if got := changes(p); got != "add ci-1,add ci-2,add ci-3,remove r2" {
+		t.Errorf("pool scale-up changes = %s", got)
+	}
+	push("pools:\n  - {name: ci, count: 1, target_type: org, target: o1, labels: [linux]}\n")
+	p, err = syncGitOps(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := changes(p); got != "remove ci-2,remove ci-3" {
+		t.Errorf("pool scale-down changes = %s", got)
+	}
+	if cur, _ := config.Load(cfgPath); len(cur.Runners.Items) != 1 || cur.Runners.Items[0].Name != "ci-1" || cur.Runners.Items[0].Labels[0] != "linux" {
+		t.Errorf("pool items = %+v", cur.Runners.Items)
+	}
+}
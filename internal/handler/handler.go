@@ -3,6 +3,7 @@
 package handler
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"encoding/json"
@@ -17,6 +18,11 @@ import (
 	"time"
 
 	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/lab-dev/github-actions-runner-manager/internal/events"
+	"github.com/lab-dev/github-actions-runner-manager/internal/gitops"
+	"github.com/lab-dev/github-actions-runner-manager/internal/installer"
+	"github.com/lab-dev/github-actions-runner-manager/internal/metrics"
+	"github.com/lab-dev/github-actions-runner-manager/internal/regjob"
 	"github.com/lab-dev/github-actions-runner-manager/internal/runner"
 	"github.com/labstack/echo/v4"
 )
@@ -27,88 +33,12 @@ var supportedLangs = []string{"en", "zh", "fr", "ja", "ko", "de"}
 // I18nLoader loads translations for a language code (e.g. "en", "zh"). Set by main from embed.
 var I18nLoader func(lang string) (map[string]string, error)
 
-// installRunnerScriptPath 容器内自动安装 runner 的脚本路径（Docker 镜像中有）
-const installRunnerScriptPath = "/app/scripts/install-runner.sh"
-
 // ConfigPath 配置文件路径，由 main 注入
 var ConfigPath string
 
 // Version 由 main 注入，供 /version 使用
 var Version string
 
-// registrationJob 后台安装并注册 runner 的任务
-type registrationJob struct {
-	BasePath   string
-	InstallDir string
-	RunnerName string
-	URL        string
-	Token      string
-	Labels     []string
-}
-
-// registrationQueue 后台任务队列，单 worker 顺序执行，避免多任务同时占满资源且 API 不阻塞
-var registrationQueue = make(chan registrationJob, 128)
-
-// StartRegistrationWorker 启动后台 worker，应在 main 中调用一次
-func StartRegistrationWorker() {
-	go func() {
-		for j := range registrationQueue {
-			runRegistrationJob(j)
-		}
-	}()
-}
-
-// runRegistrationJob 执行单次安装+注册+启动（在后台 goroutine 中调用）
-func runRegistrationJob(j registrationJob) {
-	installDir := j.InstallDir
-	configScript := filepath.Join(installDir, runner.ConfigScriptName())
-	if _, err := os.Stat(configScript); err != nil {
-		runnerSegment := filepath.Base(installDir)
-		installOut, installErr := runInstallRunnerScript(j.BasePath, runnerSegment, 3*time.Minute)
-		if installErr != nil {
-			msg := "自动安装 Runner 失败: " + installErr.Error()
-			writeRegistrationResult(installDir, false, msg)
-			log.Printf("[registration] %s 安装失败: %v\noutput: %s", j.RunnerName, installErr, string(installOut))
-			return
-		}
-		if _, err2 := os.Stat(configScript); err2 != nil {
-			writeRegistrationResult(installDir, false, "安装完成但未找到 "+runner.ConfigScriptName())
-			log.Printf("[registration] %s 安装后未找到 config 脚本\noutput: %s", j.RunnerName, string(installOut))
-			return
-		}
-	}
-	out, err := runConfigScript(installDir, j.URL, j.Token, j.Labels, 2*time.Minute)
-	if err != nil {
-		msg := strings.TrimSpace(string(out))
-		if msg == "" {
-			msg = err.Error()
-		}
-		if strings.Contains(string(out), "Must not run with sudo") {
-			msg += "（请以非 root 用户运行容器，或设置环境变量 RUNNER_ALLOW_RUNASROOT=1）"
-		}
-		outLower := strings.ToLower(string(out))
-		if strings.Contains(outLower, "token") &&
-			(strings.Contains(outLower, "invalid") || strings.Contains(outLower, "expired") ||
-				strings.Contains(outLower, "already") || strings.Contains(outLower, "used")) {
-			msg += "。请为每个 Runner 在 GitHub 重新生成新的注册 Token"
-		}
-		writeRegistrationResult(installDir, false, msg)
-		log.Printf("[registration] %s 注册失败: %s", j.RunnerName, msg)
-		return
-	}
-	writeRegistrationResult(installDir, true, "注册成功")
-	cfg, _ := config.Load(ConfigPath)
-	if cfg != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-		defer cancel()
-		if startErr := runner.StartIfInstalled(ctx, cfg, j.RunnerName, installDir); startErr != nil {
-			log.Printf("[registration] %s 注册成功但启动失败: %v", j.RunnerName, startErr)
-		} else {
-			log.Printf("[registration] %s 已注册并启动", j.RunnerName)
-		}
-	}
-}
-
 func getConfig(c echo.Context) (*config.Config, error) {
 	cfg, err := config.Load(ConfigPath)
 	if err != nil {
@@ -134,26 +64,6 @@ func clearProbe(info *runner.RunnerInfo) {
 	info.Probe = nil
 }
 
-// applyContainerStatus 容器模式下用 Agent 状态覆盖 list 中每项的 Running/Status，就地修改
-func applyContainerStatus(ctx context.Context, cfg *config.Config, list []runner.RunnerInfo) {
-	for i := range list {
-		applyContainerStatusOne(ctx, cfg, &list[i])
-	}
-}
-
-// applyContainerStatusOne 容器模式下用 Agent 状态覆盖单条 info 的 Running/Status/Probe
-func applyContainerStatusOne(ctx context.Context, cfg *config.Config, info *runner.RunnerInfo) {
-	running, status, statusErr := runner.ContainerRunnerStatus(ctx, cfg, info.Name, info.InstallDir)
-	if statusErr != nil {
-		log.Printf("[container-status] name=%s: %v", info.Name, statusErr)
-		applyProbeFailure(info, statusErr)
-		return
-	}
-	clearProbe(info)
-	info.Running = running
-	info.Status = status
-}
-
 // shortRandomSuffix 生成 6 位小写字母+数字的随机后缀，用于 runner 名称去重
 func shortRandomSuffix() string {
 	const letters = "abcdefghijklmnopqrstuvwxyz0123456789"
@@ -175,22 +85,44 @@ func runnerNameExists(cfg *config.Config, name string) bool {
 	return false
 }
 
-// runInstallRunnerScript 执行容器内 install-runner.sh，下载并解压 runner 到 basePath/runnerName；超时返回 error
-func runInstallRunnerScript(basePath, runnerName string, timeout time.Duration) ([]byte, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+// runnerCache 返回 base_path/.cache 安装包缓存，校验值取内置条目与 runners.runner_manifest，
+// 开启 runners.runner_checksum_from_release_notes 时再取发布说明
+func runnerCache(cfg *config.Config) (*installer.Cache, error) {
+	manifest, err := installer.LoadManifest(cfg.Runners.RunnerManifest)
+	if err != nil {
+		return nil, err
+	}
+	return &installer.Cache{Dir: cfg.Runners.CacheDir(), Manifest: manifest, ReleaseNotes: cfg.Runners.RunnerChecksumFromReleaseNotes}, nil
+}
+
+// installRunner 从 base_path/.cache 中已校验的安装包将 runner 安装到 installDir，版本取 runner_version、架构取 arch 配置；
+// 超时或 parent 取消时返回 error。返回安装输出与所装版本
+func installRunner(parent context.Context, cfg *config.Config, runnerName, installDir string, timeout time.Duration) ([]byte, string, error) {
+	ctx, cancel := context.WithTimeout(parent, timeout)
 	defer cancel()
-	cmd := exec.CommandContext(ctx, installRunnerScriptPath, runnerName)
-	cmd.Env = append(os.Environ(), "RUNNERS_BASE_PATH="+basePath)
-	out, err := cmd.CombinedOutput()
+	item := config.RunnerItem{Name: runnerName}
+	for _, i := range cfg.Runners.Items {
+		if i.Name == runnerName {
+			item = i
+			break
+		}
+	}
+	version := cfg.Runners.EffectiveRunnerVersion(item)
+	var out bytes.Buffer
+	cache, err := runnerCache(cfg)
+	if err != nil {
+		return out.Bytes(), version, err
+	}
+	err = cache.Install(ctx, version, installer.Platform(cfg.Runners.EffectiveArch(item)), installDir, &out)
 	if ctx.Err() == context.DeadlineExceeded {
-		return out, context.DeadlineExceeded
+		return out.Bytes(), version, context.DeadlineExceeded
 	}
-	return out, err
+	return out.Bytes(), version, err
 }
 
-// runConfigScript 在 installDir 下执行 config 脚本向 GitHub 注册，超时 2 分钟；返回输出与 error
+// runConfigScript 在 installDir 下执行 config 脚本向 GitHub 注册，超时或 parent 取消时中止；返回输出与 error
 // 将 installDir 转为绝对路径，避免相对路径在 exec 时随进程 CWD 解析导致找不到 config 脚本
-func runConfigScript(installDir, url, token string, labels []string, timeout time.Duration) ([]byte, error) {
+func runConfigScript(parent context.Context, installDir, url, token string, labels []string, timeout time.Duration) ([]byte, error) {
 	absDir, err := filepath.Abs(installDir)
 	if err != nil {
 		return nil, fmt.Errorf("解析 runner 路径失败: %w", err)
@@ -201,7 +133,7 @@ func runConfigScript(installDir, url, token string, labels []string, timeout tim
 	if len(labels) > 0 {
 		args = append(args, "--labels", strings.Join(labels, ","))
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ctx, cancel := context.WithTimeout(parent, timeout)
 	defer cancel()
 	cmd := exec.CommandContext(ctx, configScript, args...)
 	cmd.Dir = installDir
@@ -239,19 +171,6 @@ func VersionInfo(c echo.Context) error {
 	return c.JSON(http.StatusOK, map[string]string{"version": v})
 }
 
-// ListRunners 列出所有 runner；容器模式下用容器内 Agent 状态覆盖 Running/Status
-func ListRunners(c echo.Context) error {
-	cfg, err := getConfig(c)
-	if err != nil {
-		return err
-	}
-	list := runner.List(cfg)
-	if cfg.Runners.ContainerMode {
-		applyContainerStatus(c.Request().Context(), cfg, list)
-	}
-	return c.JSON(http.StatusOK, map[string]any{"runners": list})
-}
-
 // resolveLang returns the UI language: Cookie "lang" > Query "lang" > Accept-Language > "en".
 func resolveLang(c echo.Context) string {
 	if v, err := c.Cookie("lang"); err == nil && v != nil && v.Value != "" {
@@ -300,6 +219,8 @@ func Index(c echo.Context) error {
 	if cfg.Runners.ContainerMode {
 		applyContainerStatus(c.Request().Context(), cfg, list)
 	}
+	applyUsageList(c.Request().Context(), cfg, list)
+	observeList(list)
 	lang := resolveLang(c)
 	var T map[string]string
 	if I18nLoader != nil {
@@ -312,12 +233,19 @@ func Index(c echo.Context) error {
 		T = make(map[string]string)
 	}
 	tjson, _ := json.Marshal(T)
+	// runner 列表由 git 管理时页面隐藏添加、编辑与删除
+	gitRepo := ""
+	if cfg.GitManaged() {
+		gitRepo = gitops.Redact(cfg.GitOps.Repo)
+	}
 	return c.Render(http.StatusOK, "index.html", map[string]any{
-		"Runners": list,
-		"Config":  cfg,
-		"T":       T,
-		"Lang":    lang,
-		"TJSON":   template.JS(tjson),
+		"Runners":    filterByAccess(c, cfg, list),
+		"Config":     cfg,
+		"T":          T,
+		"Lang":       lang,
+		"TJSON":      template.JS(tjson),
+		"User":       CurrentPrincipal(c),
+		"GitOpsRepo": gitRepo,
 	})
 }
 
@@ -329,6 +257,32 @@ type AddRunnerRequest struct {
 	Target            string   `json:"target" form:"target"`
 	Labels            []string `json:"labels" form:"labels"`
 	RegistrationToken string   `json:"registration_token" form:"registration_token"`
+	RunnerVersion     string   `json:"runner_version" form:"runner_version"` // 可选，覆盖 runners.runner_version
+	Arch              string   `json:"arch" form:"arch"`                     // 可选，x64/arm64/arm，覆盖 runners.arch
+	// Count 大于 0 时按相同 target 与 labels 创建 name-1..name-N 共 Count 个 runner（最多 50 个），不可与 path 同时使用
+	Count int `json:"count" form:"count"`
+}
+
+// normalizeArchParam 规范请求中的 arch，空字符串表示使用全局配置
+func normalizeArchParam(v string) (string, error) {
+	if strings.TrimSpace(v) == "" {
+		return "", nil
+	}
+	arch, ok := config.NormalizeArch(v)
+	if !ok {
+		return "", echo.NewHTTPError(http.StatusBadRequest, "arch 仅支持 x64、arm64、arm")
+	}
+	return arch, nil
+}
+
+// normalizeRunnerVersionParam 校验请求中的 runner_version，空表示使用 runners.runner_version；
+// 须在创建目录、写入配置之前调用，使格式错误返回 400 而非保存配置时的 500
+func normalizeRunnerVersionParam(v string) (string, error) {
+	v = strings.TrimSpace(v)
+	if v != "" && !config.ValidRunnerVersion(v) {
+		return "", echo.NewHTTPError(http.StatusBadRequest, "runner_version 格式应为 x.y.z")
+	}
+	return v, nil
 }
 
 // AddRunner 添加并可选注册新 runner
@@ -337,6 +291,9 @@ func AddRunner(c echo.Context) error {
 	if err != nil {
 		return err
 	}
+	if err := rejectIfGitManaged(cfg); err != nil {
+		return err
+	}
 	var req AddRunnerRequest
 	if err := c.Bind(&req); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "参数错误: "+err.Error())
@@ -353,10 +310,31 @@ func AddRunner(c echo.Context) error {
 	if err := config.ValidateTarget(targetTypeNorm, req.Target); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
+	if !targetAccess(c, cfg)(req.Target) {
+		return echo.NewHTTPError(http.StatusForbidden, "无权为 "+req.Target+" 添加 runner")
+	}
 	if !config.IsSafeRunnerNameOrPath(req.Name) || (req.Path != "" && !config.IsSafeRunnerNameOrPath(req.Path)) {
 		return echo.NewHTTPError(http.StatusBadRequest, "name、path 不可包含 / \\ .. 等非法字符")
 	}
+	arch, err := normalizeArchParam(req.Arch)
+	if err != nil {
+		return err
+	}
+	version, err := normalizeRunnerVersionParam(req.RunnerVersion)
+	if err != nil {
+		return err
+	}
 	targetNorm := req.Target
+	if req.Count != 0 {
+		return addRunnerReplicas(c, cfg, &req, config.RunnerItem{
+			Name:          req.Name,
+			TargetType:    targetTypeNorm,
+			Target:        targetNorm,
+			Labels:        req.Labels,
+			RunnerVersion: version,
+			Arch:          arch,
+		})
+	}
 	// 若已存在同名 runner，自动添加短随机后缀直至名称唯一
 	name := req.Name
 	for i := 0; i < 20; i++ {
@@ -374,12 +352,16 @@ func AddRunner(c echo.Context) error {
 		TargetType: targetTypeNorm,
 		Target:     targetNorm,
 		Labels:     req.Labels,
+
+		RunnerVersion: version,
+		Arch:          arch,
 	}
+	c.Set(auditRunnerKey, item.Name)
 	installDir, err := runner.EnsureRunnerDir(cfg, item.Name, item.Path)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "创建目录失败: "+err.Error())
 	}
-	if err := config.LoadAndSave(ConfigPath, func(c *config.Config) error {
+	if err := config.LoadAndSaveBy(ConfigPath, CurrentPrincipal(c).Name, "runner.add "+item.Name, func(c *config.Config) error {
 		for _, i := range c.Runners.Items {
 			if i.Name == item.Name {
 				return echo.NewHTTPError(http.StatusConflict, "已存在同名 runner: "+item.Name)
@@ -393,66 +375,41 @@ func AddRunner(c echo.Context) error {
 		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "保存配置失败: "+err.Error())
 	}
-	if req.RegistrationToken != "" {
-		configScript := filepath.Join(installDir, runner.ConfigScriptName())
-		if _, err := os.Stat(configScript); err != nil {
-			// 目录为空时需先安装：若存在自动安装脚本则交给后台 worker 执行，避免阻塞请求
-			if _, scriptErr := os.Stat(installRunnerScriptPath); scriptErr == nil {
-				url := "https://github.com/" + targetNorm
-				select {
-				case registrationQueue <- registrationJob{
-					BasePath:   cfg.Runners.BasePath,
-					InstallDir: installDir,
-					RunnerName: item.Name,
-					URL:        url,
-					Token:      req.RegistrationToken,
-					Labels:     item.Labels,
-				}:
-					return c.JSON(http.StatusOK, map[string]any{
-						"message":     "Runner 已添加，正在后台安装并注册，请稍后刷新页面查看状态",
-						"name":        item.Name,
-						"install_dir": installDir,
-						"queued":      true,
-					})
-				default:
-					return c.JSON(http.StatusServiceUnavailable, map[string]any{
-						"message": "当前注册任务队列已满，请稍后再试",
-						"name":    item.Name,
-					})
-				}
-			}
-			return c.JSON(http.StatusOK, map[string]any{
-				"message":     "配置已保存，Runner 目录已创建。请将 GitHub Actions runner 解压到 " + installDir + " 后，使用注册 token 再次提交或在该目录下手动执行 " + runner.ConfigScriptName(),
-				"name":        item.Name,
-				"install_dir": installDir,
-			})
+	events.Publish(events.TypeRunnerAdded, item.Name, map[string]any{
+		"name":        item.Name,
+		"target_type": item.TargetType,
+		"target":      item.Target,
+		"labels":      item.Labels,
+		"install_dir": installDir,
+	})
+	token, err := registrationTokenFor(c.Request().Context(), req.RegistrationToken, item)
+	if err != nil {
+		return c.JSON(http.StatusOK, map[string]any{
+			"message":     "Runner 已添加，但" + err.Error() + "，请提供注册 token 完成注册",
+			"name":        item.Name,
+			"install_dir": installDir,
+		})
+	}
+	if token != "" {
+		// 目录为空时先从安装包缓存安装再注册，已有 config 脚本时仅需注册；均交给后台 worker 执行，避免阻塞请求
+		msg := "Runner 已添加，正在后台注册，完成后页面会自动更新"
+		if _, err := os.Stat(filepath.Join(installDir, runner.ConfigScriptName())); err != nil {
+			msg = "Runner 已添加，正在后台安装并注册，完成后页面会自动更新"
 		}
-		// 已有 config 脚本（目录非空）：仅需注册，也放入后台执行，避免长时间阻塞
-		url := "https://github.com/" + targetNorm
-		select {
-		case registrationQueue <- registrationJob{
-			BasePath:   cfg.Runners.BasePath,
-			InstallDir: installDir,
-			RunnerName: item.Name,
-			URL:        url,
-			Token:      req.RegistrationToken,
-			Labels:     item.Labels,
-		}:
-			return c.JSON(http.StatusOK, map[string]any{
-				"message":     "Runner 已添加，正在后台注册，请稍后刷新页面查看状态",
-				"name":        item.Name,
-				"install_dir": installDir,
-				"queued":      true,
-			})
-		default:
-			return c.JSON(http.StatusServiceUnavailable, map[string]any{
-				"message": "当前注册任务队列已满，请稍后再试",
-				"name":    item.Name,
-			})
+		job := regjob.New(item.Name, installDir, "https://github.com/"+targetNorm, item.Labels)
+		if err := enqueueRegistration(cfg, job, token); err != nil {
+			return registrationEnqueueError(c, item.Name, err)
 		}
+		return c.JSON(http.StatusOK, map[string]any{
+			"message":     msg,
+			"name":        item.Name,
+			"install_dir": installDir,
+			"queued":      true,
+			"job_id":      job.ID,
+		})
 	}
 	return c.JSON(http.StatusOK, map[string]any{
-		"message":     "Runner 已添加，请将 runner 解压到目录后使用注册 token 完成注册",
+		"message":     "Runner 已添加，未提供注册 token，暂未安装与注册",
 		"name":        item.Name,
 		"install_dir": installDir,
 	})
@@ -472,12 +429,15 @@ func GetRunner(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "name 不可包含 / \\ .. 等非法字符")
 	}
 	info := runner.GetByName(cfg, name)
-	if info == nil {
+	if info == nil || !targetAccess(c, cfg)(info.Target) {
 		return echo.NewHTTPError(http.StatusNotFound, "未找到该 runner")
 	}
 	if cfg.Runners.ContainerMode {
 		applyContainerStatusOne(c.Request().Context(), cfg, info)
 	}
+	applyUsage(c.Request().Context(), cfg, []*runner.RunnerInfo{info})
+	observeRunners(info)
+	c.Response().Header().Set("ETag", info.ETag)
 	return c.JSON(http.StatusOK, info)
 }
 
@@ -495,7 +455,7 @@ func StartRunner(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "name 不可包含 / \\ .. 等非法字符")
 	}
 	info := runner.GetByName(cfg, name)
-	if info == nil {
+	if info == nil || !targetAccess(c, cfg)(info.Target) {
 		return echo.NewHTTPError(http.StatusNotFound, "未找到该 runner")
 	}
 	probeFailed := false
@@ -540,7 +500,7 @@ func StopRunner(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "name 不可包含 / \\ .. 等非法字符")
 	}
 	info := runner.GetByName(cfg, name)
-	if info == nil {
+	if info == nil || !targetAccess(c, cfg)(info.Target) {
 		return echo.NewHTTPError(http.StatusNotFound, "未找到该 runner")
 	}
 	probeFailed := false
@@ -554,10 +514,12 @@ func StopRunner(c echo.Context) error {
 	if !info.Running && !probeFailed {
 		return c.JSON(http.StatusOK, map[string]any{"message": "Runner 未在运行"})
 	}
+	metrics.RunnerStopAttempts.Inc(name)
 	if cfg.Runners.ContainerMode {
 		ctx, cancel := context.WithTimeout(c.Request().Context(), 35*time.Second)
 		defer cancel()
 		if err := runner.StopRunnerContainer(ctx, name); err != nil {
+			metrics.RunnerStopFailures.Inc(name)
 			return echo.NewHTTPError(http.StatusInternalServerError, "停止 Runner 容器失败: "+err.Error())
 		}
 		if probeFailed {
@@ -569,6 +531,7 @@ func StopRunner(c echo.Context) error {
 		return c.JSON(http.StatusOK, map[string]any{"message": "已停止 Runner 容器"})
 	}
 	if err := runner.Stop(info.InstallDir); err != nil {
+		metrics.RunnerStopFailures.Inc(name)
 		return echo.NewHTTPError(http.StatusInternalServerError, "停止失败: "+err.Error())
 	}
 	return c.JSON(http.StatusOK, map[string]any{"message": "已发送停止信号"})
@@ -581,9 +544,14 @@ type UpdateRunnerRequest struct {
 	TargetType string   `json:"target_type" form:"target_type"`
 	Target     string   `json:"target" form:"target"`
 	Labels     []string `json:"labels" form:"labels"`
+	// RunnerVersion 省略时保持不变，空字符串表示改用 runners.runner_version；仅影响之后的安装与升级
+	RunnerVersion *string `json:"runner_version,omitempty" form:"runner_version"`
+	// Arch 省略时保持不变，空字符串表示改用 runners.arch；已安装的 runner 需删除后重新添加才会换架构
+	Arch *string `json:"arch,omitempty" form:"arch"`
 }
 
-// UpdateRunner 更新 runner 配置（PUT /api/runners/:name）；名称不可改，与目录一致
+// UpdateRunner 更新 runner 配置（PUT /api/runners/:name）；名称不可改，与目录一致。
+// 带 If-Match 时须与当前配置的 ETag 一致，否则返回 412，避免覆盖他人的并发修改
 func UpdateRunner(c echo.Context) error {
 	name := c.Param("name")
 	if name == "" {
@@ -615,9 +583,22 @@ func UpdateRunner(c echo.Context) error {
 	if req.Path != "" && !config.IsSafeRunnerNameOrPath(req.Path) {
 		return echo.NewHTTPError(http.StatusBadRequest, "path 不可包含 / \\ .. 等非法字符")
 	}
+	var arch, version string
+	if req.Arch != nil {
+		var err error
+		if arch, err = normalizeArchParam(*req.Arch); err != nil {
+			return err
+		}
+	}
+	if req.RunnerVersion != nil {
+		var err error
+		if version, err = normalizeRunnerVersionParam(*req.RunnerVersion); err != nil {
+			return err
+		}
+	}
 	targetNorm := req.Target
 	var updated *runner.RunnerInfo
-	if err := config.LoadAndSave(ConfigPath, func(cfg *config.Config) error {
+	if err := config.LoadAndSaveBy(ConfigPath, CurrentPrincipal(c).Name, "runner.update "+name, func(cfg *config.Config) error {
 		idx := -1
 		for i, item := range cfg.Runners.Items {
 			if item.Name == name {
@@ -625,15 +606,30 @@ func UpdateRunner(c echo.Context) error {
 				break
 			}
 		}
-		if idx < 0 {
+		if err := rejectIfGitManaged(cfg); err != nil {
+			return err
+		}
+		allowed := targetAccess(c, cfg)
+		if idx < 0 || !allowed(cfg.Runners.Items[idx].Target) {
 			return echo.NewHTTPError(http.StatusNotFound, "未找到该 runner")
 		}
-		cfg.Runners.Items[idx] = config.RunnerItem{
-			Name:       name,
-			Path:       req.Path,
-			TargetType: targetTypeNorm,
-			Target:     targetNorm,
-			Labels:     req.Labels,
+		if ifMatch := c.Request().Header.Get("If-Match"); ifMatch != "" && !etagMatches(ifMatch, cfg.Runners.Items[idx].ETag()) {
+			return echo.NewHTTPError(http.StatusPreconditionFailed, "runner 配置已被其他人修改，请刷新后重试")
+		}
+		if !allowed(targetNorm) {
+			return echo.NewHTTPError(http.StatusForbidden, "无权将 runner 改到 "+targetNorm)
+		}
+		// 在原条目上修改，保留 cleanup 等未在请求中出现的字段
+		item := &cfg.Runners.Items[idx]
+		item.Path = req.Path
+		item.TargetType = targetTypeNorm
+		item.Target = targetNorm
+		item.Labels = req.Labels
+		if req.RunnerVersion != nil {
+			item.RunnerVersion = version
+		}
+		if req.Arch != nil {
+			item.Arch = arch
 		}
 		return nil
 	}); err != nil {
@@ -647,6 +643,10 @@ func UpdateRunner(c echo.Context) error {
 		return err
 	}
 	updated = runner.GetByName(cfg, name)
+	if updated != nil {
+		c.Response().Header().Set("ETag", updated.ETag)
+	}
+	events.Publish(events.TypeRunnerUpdated, name, updated)
 	// 若已注册且未在运行，自动启动（容器/进程模式统一走 StartIfInstalled）
 	msg := "已更新"
 	var started bool
@@ -668,6 +668,17 @@ func UpdateRunner(c echo.Context) error {
 	})
 }
 
+// etagMatches 判断 If-Match 请求头是否匹配当前 ETag：支持 * 与逗号分隔的多个值，弱标签 W/ 按强标签比较
+func etagMatches(ifMatch, etag string) bool {
+	for _, v := range strings.Split(ifMatch, ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+		if v == "*" || v == etag {
+			return true
+		}
+	}
+	return false
+}
+
 // RemoveRunnerByName 从路径参数获取 name 并移除（DELETE /api/runners/:name）
 // 会先停止 runner 进程，再删除其安装目录，最后从配置中移除。
 func RemoveRunnerByName(c echo.Context) error {
@@ -682,8 +693,11 @@ func RemoveRunnerByName(c echo.Context) error {
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "加载配置失败: "+err.Error())
 	}
+	if err := rejectIfGitManaged(cfg); err != nil {
+		return err
+	}
 	info := runner.GetByName(cfg, name)
-	if info == nil {
+	if info == nil || !targetAccess(c, cfg)(info.Target) {
 		return echo.NewHTTPError(http.StatusNotFound, "未找到该 runner")
 	}
 	installDir := info.InstallDir
@@ -699,7 +713,7 @@ func RemoveRunnerByName(c echo.Context) error {
 	if installDir != "" && isUnderBasePath(cfg.Runners.BasePath, installDir) {
 		_ = os.RemoveAll(installDir)
 	}
-	if err := config.LoadAndSave(ConfigPath, func(cfg *config.Config) error {
+	if err := config.LoadAndSaveBy(ConfigPath, CurrentPrincipal(c).Name, "runner.remove "+name, func(cfg *config.Config) error {
 		return removeRunnerFromConfig(cfg, name)
 	}); err != nil {
 		if he, ok := err.(*echo.HTTPError); ok {
@@ -707,6 +721,8 @@ func RemoveRunnerByName(c echo.Context) error {
 		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "保存配置失败: "+err.Error())
 	}
+	forgetObserved(name)
+	events.Publish(events.TypeRunnerRemoved, name, nil)
 	return c.JSON(http.StatusOK, map[string]any{"message": "已从配置中移除"})
 }
 
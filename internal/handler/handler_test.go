@@ -1,15 +1,24 @@
 package handler
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/lab-dev/github-actions-runner-manager/internal/events"
+	"github.com/lab-dev/github-actions-runner-manager/internal/jobhistory"
+	"github.com/lab-dev/github-actions-runner-manager/internal/regjob"
+	"github.com/lab-dev/github-actions-runner-manager/internal/runner"
 	"github.com/labstack/echo/v4"
 )
 
@@ -79,6 +88,7 @@ func TestVersionInfo_EmptyDefaultsToDev(t *testing.T) {
 
 func TestAddRunner_InvalidName(t *testing.T) {
 	e := echo.New()
+	e.Use(Auth("", ""))
 	e.POST("/api/runners", AddRunner)
 	body := map[string]string{
 		"name":        "bad..name",
@@ -95,6 +105,47 @@ func TestAddRunner_InvalidName(t *testing.T) {
 	}
 }
 
+func TestAddRunner_InvalidRunnerVersion(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	cfg := &config.Config{
+		Runners: config.RunnersConfig{
+			BasePath: dir,
+			Items:    []config.RunnerItem{{Name: "r1", TargetType: "org", Target: "o1"}},
+		},
+	}
+	_ = cfg.Save(cfgPath)
+	ConfigPath = cfgPath
+	defer func() { ConfigPath = filepath.Join(os.TempDir(), "handler-test-config.yaml") }()
+
+	e := echo.New()
+	e.Use(Auth("", ""))
+	e.POST("/api/runners", AddRunner)
+	e.PUT("/api/runners/:name", UpdateRunner)
+	for _, tc := range []struct{ method, target, body string }{
+		{http.MethodPost, "/api/runners", `{"name":"web","target_type":"org","target":"o1","runner_version":"latest"}`},
+		{http.MethodPost, "/api/runners", `{"name":"pool","target_type":"org","target":"o1","runner_version":"2.331","count":2}`},
+		{http.MethodPut, "/api/runners/r1", `{"target_type":"org","target":"o1","runner_version":"v2.331.0"}`},
+	} {
+		req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "runner_version") {
+			t.Errorf("%s %s: expected 400 for invalid runner_version, got %d %s", tc.method, tc.body, rec.Code, rec.Body.String())
+		}
+	}
+	// 校验在创建目录之前完成
+	for _, name := range []string{"web", "pool-1", "pool-2"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("directory %s should not be created", name)
+		}
+	}
+	if got, _ := config.Load(cfgPath); len(got.Runners.Items) != 1 || got.Runners.Items[0].RunnerVersion != "" {
+		t.Errorf("config should be unchanged: %+v", got.Runners.Items)
+	}
+}
+
 func TestUpdateRunner_NameImmutable(t *testing.T) {
 	dir := t.TempDir()
 	cfgPath := filepath.Join(dir, "config.yaml")
@@ -153,6 +204,7 @@ func TestUpdateRunner_TrimmedBodyNameAccepted(t *testing.T) {
 	defer func() { ConfigPath = filepath.Join(os.TempDir(), "handler-test-config.yaml") }()
 
 	e := echo.New()
+	e.Use(Auth("", ""))
 	e.PUT("/api/runners/:name", UpdateRunner)
 	body := map[string]any{"name": "  r1  ", "target_type": "org", "target": "o1"}
 	raw, _ := json.Marshal(body)
@@ -164,3 +216,393 @@ func TestUpdateRunner_TrimmedBodyNameAccepted(t *testing.T) {
 		t.Errorf("expected 200 when body name trims to URL name, got %d body=%s", rec.Code, rec.Body.String())
 	}
 }
+
+func TestUpdateRunner_IfMatch(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	cfg := &config.Config{
+		Runners: config.RunnersConfig{
+			BasePath: dir,
+			Items:    []config.RunnerItem{{Name: "r1", TargetType: "org", Target: "o1"}},
+		},
+	}
+	_ = cfg.Save(cfgPath)
+	ConfigPath = cfgPath
+	defer func() { ConfigPath = filepath.Join(os.TempDir(), "handler-test-config.yaml") }()
+
+	e := echo.New()
+	e.Use(Auth("", ""))
+	e.GET("/api/runners/:name", GetRunner)
+	e.PUT("/api/runners/:name", UpdateRunner)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/runners/r1", nil))
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("GET should return ETag")
+	}
+	put := func(target, ifMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/runners/r1", strings.NewReader(`{"target_type":"org","target":"`+target+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", ifMatch)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	// 第一个编辑者成功，ETag 随之变化；持旧 ETag 的第二个编辑者得到 412
+	rec = put("o2", etag)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") == etag {
+		t.Fatalf("first edit: %d etag=%s", rec.Code, rec.Header().Get("ETag"))
+	}
+	if rec := put("o3", etag); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("stale If-Match: expected 412, got %d", rec.Code)
+	}
+	if cur, _ := config.Load(cfgPath); cur.Runners.Items[0].Target != "o2" {
+		t.Errorf("stale edit was applied: %s", cur.Runners.Items[0].Target)
+	}
+	if rec := put("o3", "*"); rec.Code != http.StatusOK {
+		t.Errorf("If-Match *: expected 200, got %d", rec.Code)
+	}
+}
+
+func TestListRunnerJobs(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	cfg := &config.Config{
+		Runners: config.RunnersConfig{
+			BasePath: dir,
+			Items:    []config.RunnerItem{{Name: "r1", TargetType: "org", Target: "o1"}},
+		},
+	}
+	_ = cfg.Save(cfgPath)
+	ConfigPath = cfgPath
+	defer func() { ConfigPath = filepath.Join(os.TempDir(), "handler-test-config.yaml") }()
+	_ = jobhistory.Upsert(cfg.Runners.StateDir(), jobhistory.Record{ID: "gh-1", Runner: "r1", Job: "build", Conclusion: "success"})
+
+	e := echo.New()
+	e.Use(Auth("", ""))
+	e.GET("/api/runners/:name/jobs", ListRunnerJobs)
+	req := httptest.NewRequest(http.MethodGet, "/api/runners/r1/jobs?per_page=10", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	var out struct {
+		Jobs       []jobhistory.Record `json:"jobs"`
+		TotalCount int                 `json:"total_count"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.TotalCount != 1 || len(out.Jobs) != 1 || out.Jobs[0].Job != "build" {
+		t.Errorf("unexpected response: %+v", out)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/runners/missing/jobs", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown runner, got %d", rec.Code)
+	}
+}
+
+func TestGitHubWebhook_RejectsBadSignature(t *testing.T) {
+	WebhookSecret = "s3cret"
+	defer func() { WebhookSecret = "" }()
+	e := echo.New()
+	e.POST("/api/webhooks/github", GitHubWebhook)
+	req := httptest.NewRequest(http.MethodPost, "/api/webhooks/github", bytes.NewReader([]byte(`{}`)))
+	req.Header.Set("X-GitHub-Event", "workflow_job")
+	req.Header.Set("X-Hub-Signature-256", "sha256=00")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", rec.Code)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	cfg := &config.Config{
+		Runners: config.RunnersConfig{
+			BasePath: dir,
+			Items:    []config.RunnerItem{{Name: "r1", TargetType: "org", Target: "o1"}},
+		},
+	}
+	_ = cfg.Save(cfgPath)
+	ConfigPath = cfgPath
+	defer func() { ConfigPath = filepath.Join(os.TempDir(), "handler-test-config.yaml") }()
+	installDir := filepath.Join(dir, "r1")
+	_ = os.MkdirAll(installDir, 0755)
+	writeRegistrationResult(installDir, false, "token expired")
+
+	e := echo.New()
+	e.Use(Auth("", ""))
+	e.GET("/metrics", Metrics)
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`runner_fleet_runner_status{runner="r1",status="new"} 1`,
+		`runner_fleet_runner_status{runner="r1",status="installed"} 0`,
+		`runner_fleet_runner_running{runner="r1"} 0`,
+		`runner_fleet_runner_last_registration_success{runner="r1"} 0`,
+		`runner_fleet_registration_queue_depth 0`,
+		`# TYPE runner_fleet_runner_start_attempts_total counter`,
+		`# TYPE runner_fleet_github_api_request_duration_seconds histogram`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q", want)
+		}
+	}
+	if strings.Contains(body, "runner_fleet_runner_registered_on_github{") {
+		t.Error("unchecked runner should not export registered_on_github")
+	}
+}
+
+func TestMetricsTokenValid(t *testing.T) {
+	e := echo.New()
+	check := func(header string) bool {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if header != "" {
+			req.Header.Set(echo.HeaderAuthorization, header)
+		}
+		return MetricsTokenValid(e.NewContext(req, httptest.NewRecorder()))
+	}
+	if check("Bearer anything") {
+		t.Error("no token configured should never validate")
+	}
+	MetricsToken = "scrape"
+	defer func() { MetricsToken = "" }()
+	if !check("Bearer scrape") {
+		t.Error("expected valid token")
+	}
+	if check("Bearer wrong") || check("Basic c2NyYXBl") || check("") {
+		t.Error("expected invalid token")
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	e := echo.New()
+	e.Use(Auth("", ""))
+	e.GET("/api/events", StreamEvents)
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+
+	id := events.Publish(events.TypeRunnerAdded, "sse-r1", nil)
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/events", nil)
+	req.Header.Set("Last-Event-ID", strconv.FormatInt(id-1, 10))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("content-type = %q", ct)
+	}
+	sc := bufio.NewScanner(resp.Body)
+	var lines []string
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+		if strings.HasPrefix(sc.Text(), "data: ") {
+			break
+		}
+	}
+	got := strings.Join(lines, "\n")
+	if !strings.Contains(got, "event: runner.added") || !strings.Contains(got, `"runner":"sse-r1"`) {
+		t.Errorf("unexpected stream:\n%s", got)
+	}
+}
+
+func TestObserveRunners_PublishesTransitions(t *testing.T) {
+	ch, _, cancel := events.Default.Subscribe(0)
+	defer cancel()
+	defer forgetObserved("obs-r1")
+	info := &runner.RunnerInfo{Name: "obs-r1", Status: runner.StatusInstalled}
+	observeRunners(info)
+	info.Running = true
+	observeRunners(info)
+	select {
+	case ev := <-ch:
+		if ev.Type != events.TypeRunnerStatus || ev.Runner != "obs-r1" {
+			t.Errorf("unexpected event %+v", ev)
+		}
+	default:
+		t.Fatal("expected runner.status event")
+	}
+	observeRunners(info)
+	select {
+	case ev := <-ch:
+		t.Errorf("unchanged state should not publish, got %+v", ev)
+	default:
+	}
+}
+
+func TestRegistrationJobs_CancelAndRetry(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	cfg := &config.Config{
+		Runners: config.RunnersConfig{
+			BasePath: dir,
+			Items:    []config.RunnerItem{{Name: "r1", TargetType: "org", Target: "o1"}},
+		},
+	}
+	_ = cfg.Save(cfgPath)
+	ConfigPath = cfgPath
+	defer func() { ConfigPath = filepath.Join(os.TempDir(), "handler-test-config.yaml") }()
+	// 未启动 worker：任务停留在 queued，测试结束时清空队列
+	defer func() {
+		jobMu.Lock()
+		pendingJobs = nil
+		jobMu.Unlock()
+	}()
+
+	job := regjob.New("r1", filepath.Join(dir, "r1"), "https://github.com/o1", nil)
+	if err := enqueueRegistration(cfg, job, "AAA111"); err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	e.Use(Auth("", ""))
+	e.GET("/api/jobs", ListRegistrationJobs)
+	e.GET("/api/jobs/:id", GetRegistrationJob)
+	e.POST("/api/jobs/:id/cancel", CancelRegistrationJob)
+	e.POST("/api/jobs/:id/retry", RetryRegistrationJob)
+	do := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodGet, "/api/jobs?runner=r1&state=queued")
+	var list struct {
+		Jobs       []regjob.Job `json:"jobs"`
+		TotalCount int          `json:"total_count"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if list.TotalCount != 1 || list.Jobs[0].ID != job.ID {
+		t.Fatalf("unexpected list: %+v", list)
+	}
+	if rec := do(http.MethodGet, "/api/jobs/reg-missing"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/jobs/"+job.ID+"/retry"); rec.Code != http.StatusConflict {
+		t.Errorf("retry of queued job: expected 409, got %d", rec.Code)
+	}
+
+	if rec := do(http.MethodPost, "/api/jobs/"+job.ID+"/cancel"); rec.Code != http.StatusOK {
+		t.Fatalf("cancel: status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	rec = do(http.MethodGet, "/api/jobs/"+job.ID)
+	var got regjob.Job
+	_ = json.NewDecoder(rec.Body).Decode(&got)
+	if got.State != regjob.StateCancelled || got.FinishedAt.IsZero() {
+		t.Errorf("expected cancelled job, got %+v", got)
+	}
+	if rec := do(http.MethodPost, "/api/jobs/"+job.ID+"/cancel"); rec.Code != http.StatusConflict {
+		t.Errorf("second cancel: expected 409, got %d", rec.Code)
+	}
+
+	// 原任务之后 runner 改了 target 与 labels：重试按当前配置注册
+	_ = config.LoadAndSave(cfgPath, func(c *config.Config) error {
+		c.Runners.Items[0].Target, c.Runners.Items[0].Labels = "o2", []string{"gpu"}
+		return nil
+	})
+	rec = do(http.MethodPost, "/api/jobs/"+job.ID+"/retry")
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("retry: status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	var retried struct {
+		Job regjob.Job `json:"job"`
+	}
+	_ = json.NewDecoder(rec.Body).Decode(&retried)
+	sd := cfg.Runners.StateDir()
+	if retried.Job.RetryOf != job.ID || retried.Job.State != regjob.StateQueued {
+		t.Errorf("unexpected retried job: %+v", retried.Job)
+	}
+	if regjob.LoadToken(sd, retried.Job.ID) != "AAA111" || regjob.LoadToken(sd, job.ID) != "" {
+		t.Error("token should move to the retried job")
+	}
+	if strings.Contains(rec.Body.String(), "AAA111") {
+		t.Error("token leaked in response")
+	}
+	if retried.Job.URL != "https://github.com/o2" || !slices.Equal(retried.Job.Labels, []string{"gpu"}) || retried.Job.InstallDir != filepath.Join(dir, "r1") {
+		t.Errorf("retry should use the current runner config: %+v", retried.Job)
+	}
+
+	// runner 已从配置中移除：重试返回 404
+	if rec := do(http.MethodPost, "/api/jobs/"+retried.Job.ID+"/cancel"); rec.Code != http.StatusOK {
+		t.Fatalf("cancel retried job: status = %d", rec.Code)
+	}
+	_ = config.LoadAndSave(cfgPath, func(c *config.Config) error {
+		c.Runners.Items = nil
+		return nil
+	})
+	if rec := do(http.MethodPost, "/api/jobs/"+retried.Job.ID+"/retry"); rec.Code != http.StatusNotFound {
+		t.Errorf("retry for removed runner = %d, want 404", rec.Code)
+	}
+}
+
+func TestRegistrationPool_PerTargetLimit(t *testing.T) {
+	jobMu.Lock()
+	savedPending, savedActive, savedLimit := pendingJobs, activeTargets, perTargetLimit
+	pendingJobs, activeTargets, perTargetLimit = nil, map[string]int{}, 1
+	jobMu.Unlock()
+	defer func() {
+		jobMu.Lock()
+		pendingJobs, activeTargets, perTargetLimit = savedPending, savedActive, savedLimit
+		jobMu.Unlock()
+	}()
+
+	for _, j := range []*regjob.Job{
+		{ID: "a", URL: "https://github.com/o1"},
+		{ID: "b", URL: "https://github.com/o1"},
+		{ID: "c", URL: "https://github.com/o2"},
+	} {
+		if !pushPending(j) {
+			t.Fatalf("enqueue %s failed", j.ID)
+		}
+	}
+	a := nextRegistrationJob()
+	// o1 已达上限，后入队的 o2 任务先执行
+	c := nextRegistrationJob()
+	if a.id != "a" || c.id != "c" {
+		t.Fatalf("took %s, %s; want a, c", a.id, c.id)
+	}
+	next := make(chan pendingJob, 1)
+	go func() { next <- nextRegistrationJob() }()
+	select {
+	case p := <-next:
+		t.Fatalf("took %s while o1 is at its limit", p.id)
+	case <-time.After(50 * time.Millisecond):
+	}
+	finishPending(c)
+	select {
+	case p := <-next:
+		t.Fatalf("took %s after an o2 job finished", p.id)
+	case <-time.After(50 * time.Millisecond):
+	}
+	finishPending(a)
+	select {
+	case p := <-next:
+		if p.id != "b" {
+			t.Errorf("took %s, want b", p.id)
+		}
+		finishPending(p)
+	case <-time.After(2 * time.Second):
+		t.Fatal("b not taken after a finished")
+	}
+	jobMu.Lock()
+	defer jobMu.Unlock()
+	if len(pendingJobs) != 0 || len(activeTargets) != 0 {
+		t.Errorf("pool not drained: pending=%v active=%v", pendingJobs, activeTargets)
+	}
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/lab-dev/github-actions-runner-manager/internal/jobhistory"
+	"github.com/labstack/echo/v4"
+)
+
+// WebhookSecret GitHub webhook 签名密钥，由 main 从 GITHUB_WEBHOOK_SECRET 注入；为空时拒绝 webhook
+var WebhookSecret string
+
+// maxJobsPerPage 单页最多返回的 Job 记录数
+const maxJobsPerPage = 100
+
+// ListRunnerJobs 分页返回某 runner 的 Job 历史（GET /api/runners/:name/jobs?page=1&per_page=30），按开始时间倒序
+func ListRunnerJobs(c echo.Context) error {
+	cfg, err := getConfig(c)
+	if err != nil {
+		return err
+	}
+	name := c.Param("name")
+	if !config.IsSafeRunnerNameOrPath(name) {
+		return echo.NewHTTPError(http.StatusBadRequest, "name 不可包含 / \\ .. 等非法字符")
+	}
+	if !runnerAccessible(c, cfg, name) {
+		return echo.NewHTTPError(http.StatusNotFound, "未找到该 runner")
+	}
+	page, perPage := 1, 30
+	if v := c.QueryParam("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return echo.NewHTTPError(http.StatusBadRequest, "page 必须为正整数")
+		}
+		page = n
+	}
+	if v := c.QueryParam("per_page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxJobsPerPage {
+			return echo.NewHTTPError(http.StatusBadRequest, "per_page 必须为 1-"+strconv.Itoa(maxJobsPerPage)+" 的整数")
+		}
+		perPage = n
+	}
+	jobs, total, err := jobhistory.List(cfg.Runners.StateDir(), name, page, perPage)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "读取 Job 历史失败: "+err.Error())
+	}
+	return c.JSON(http.StatusOK, map[string]any{
+		"jobs":        jobs,
+		"total_count": total,
+		"page":        page,
+		"per_page":    perPage,
+	})
+}
+
+// GitHubWebhook 接收 GitHub workflow_job 事件并写入 Job 历史（POST /api/webhooks/github），以 X-Hub-Signature-256 校验来源
+func GitHubWebhook(c echo.Context) error {
+	if WebhookSecret == "" {
+		return echo.NewHTTPError(http.StatusForbidden, "未配置 GITHUB_WEBHOOK_SECRET，webhook 已禁用")
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, 5<<20))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "读取请求失败: "+err.Error())
+	}
+	if !jobhistory.VerifySignature(WebhookSecret, body, c.Request().Header.Get("X-Hub-Signature-256")) {
+		return echo.NewHTTPError(http.StatusUnauthorized, "签名校验失败")
+	}
+	switch c.Request().Header.Get("X-GitHub-Event") {
+	case "ping":
+		return c.JSON(http.StatusOK, map[string]any{"message": "pong"})
+	case "workflow_job":
+	default:
+		return c.JSON(http.StatusAccepted, map[string]any{"message": "已忽略该事件"})
+	}
+	rec, err := jobhistory.FromWorkflowJobEvent(body)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "解析事件失败: "+err.Error())
+	}
+	if rec == nil {
+		return c.JSON(http.StatusAccepted, map[string]any{"message": "已忽略该事件"})
+	}
+	cfg, err := getConfig(c)
+	if err != nil {
+		return err
+	}
+	// 仅记录本 Manager 管理的 runner（GitHub 上的 runner 名称与配置中的 name 一致）
+	if !runnerNameExists(cfg, rec.Runner) {
+		return c.JSON(http.StatusAccepted, map[string]any{"message": "非本服务管理的 runner，已忽略"})
+	}
+	if err := jobhistory.Upsert(cfg.Runners.StateDir(), *rec); err != nil {
+		log.Printf("[webhook] 写入 Job 历史失败 runner=%s: %v", rec.Runner, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "写入 Job 历史失败: "+err.Error())
+	}
+	return c.JSON(http.StatusOK, map[string]any{"message": "已记录", "id": rec.ID})
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/metrics"
+	"github.com/lab-dev/github-actions-runner-manager/internal/runner"
+	"github.com/labstack/echo/v4"
+)
+
+// MetricsToken /metrics 专用 Bearer Token，由 main 从 METRICS_TOKEN 注入；携带正确 Token 的抓取请求可跳过 Basic Auth
+var MetricsToken string
+
+// MetricsTokenValid 判断请求是否携带正确的 Authorization: Bearer <METRICS_TOKEN>；未配置 Token 时恒为 false
+func MetricsTokenValid(c echo.Context) bool {
+	if MetricsToken == "" {
+		return false
+	}
+	auth := c.Request().Header.Get(echo.HeaderAuthorization)
+	token, ok := strings.CutPrefix(auth, "Bearer ")
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), []byte(MetricsToken)) == 1
+}
+
+// allStatuses runner_fleet_runner_status 按状态逐一输出 0/1，便于 PromQL 按 status 过滤
+var allStatuses = []runner.Status{runner.StatusInstalled, runner.StatusNew, runner.StatusMissing, runner.StatusUnknown}
+
+// Metrics 以 Prometheus 文本格式输出 Manager 指标（GET /metrics）；容器模式下与列表接口一样向各 Agent 探测状态。
+// 携带 METRICS_TOKEN 的抓取请求看到全部 runner，其余调用者只看到 auth.access 允许的 target 下的 runner
+func Metrics(c echo.Context) error {
+	cfg, err := getConfig(c)
+	if err != nil {
+		return err
+	}
+	list := runner.List(cfg)
+	var visible map[string]bool
+	if !MetricsTokenValid(c) && !fullAccess(c, cfg) {
+		list = filterByAccess(c, cfg, list)
+		visible = make(map[string]bool, len(list))
+		for _, info := range list {
+			visible[info.Name] = true
+		}
+	}
+	if cfg.Runners.ContainerMode {
+		applyContainerStatus(c.Request().Context(), cfg, list)
+	}
+	observeList(list)
+	var buf bytes.Buffer
+	w := metrics.NewWriter(&buf)
+	if visible != nil {
+		// 计数器按 runner 标签累计了全部 runner，受限调用者只输出其可见 runner 的样本
+		w.Keep = func(l metrics.Labels) bool {
+			name, ok := l["runner"]
+			return !ok || visible[name]
+		}
+	}
+	writeFleetGauges(w, list)
+	metrics.Fleet.Write(w)
+	if err := w.Err(); err != nil {
+		return err
+	}
+	return c.Blob(http.StatusOK, metrics.ContentType, buf.Bytes())
+}
+
+// writeFleetGauges 按当前 runner 状态输出 Gauge；未检查/无记录的项不输出样本
+func writeFleetGauges(w *metrics.Writer, list []runner.RunnerInfo) {
+	const p = metrics.FleetPrefix
+	w.Header(p+"build_info", "Manager build information.", "gauge")
+	w.Sample(p+"build_info", metrics.Labels{"version": Version}, 1)
+	w.Header(p+"registration_queue_depth", "Install+register jobs waiting in the background queue.", "gauge")
+	w.Sample(p+"registration_queue_depth", nil, float64(registrationQueueDepth()))
+	w.Header(p+"registration_jobs_running", "Install+register jobs currently being executed by the worker pool.", "gauge")
+	w.Sample(p+"registration_jobs_running", nil, float64(registrationRunning()))
+	w.Header(p+"runners", "Runners in config.", "gauge")
+	w.Sample(p+"runners", nil, float64(len(list)))
+
+	w.Header(p+"runner_status", "Runner directory status (1 for the current status).", "gauge")
+	for _, info := range list {
+		for _, st := range allStatuses {
+			w.Sample(p+"runner_status", metrics.Labels{"runner": info.Name, "status": string(st)}, metrics.Bool(info.Status == st))
+		}
+	}
+	w.Header(p+"runner_running", "Whether the runner process/container is running.", "gauge")
+	for _, info := range list {
+		w.Sample(p+"runner_running", metrics.Labels{"runner": info.Name}, metrics.Bool(info.Running))
+	}
+	writeOptionalBool(w, p+"runner_registered_on_github", "Whether the runner is listed on GitHub (only runners with .github_check_token).", list,
+		func(info *runner.RunnerInfo) *bool { return info.RegisteredOnGitHub })
+	writeOptionalBool(w, p+"runner_github_online", "Whether GitHub reports the runner as online.", list,
+		func(info *runner.RunnerInfo) *bool { return info.GitHubOnline })
+	writeOptionalBool(w, p+"runner_github_busy", "Whether GitHub reports the runner as running a job.", list,
+		func(info *runner.RunnerInfo) *bool { return info.GitHubBusy })
+	writeOptionalBool(w, p+"runner_last_registration_success", "Whether the last install+register attempt succeeded.", list,
+		func(info *runner.RunnerInfo) *bool { return info.RegistrationSuccess })
+
+	w.Header(p+"runner_last_registration_timestamp_seconds", "Unix time of the last install+register result.", "gauge")
+	for _, info := range list {
+		if t, err := time.Parse(time.RFC3339, info.RegistrationCheckedAt); err == nil {
+			w.Sample(p+"runner_last_registration_timestamp_seconds", metrics.Labels{"runner": info.Name}, float64(t.Unix()))
+		}
+	}
+	w.Header(p+"runner_probe_error", "Set to 1 when probing the runner container failed, labelled with the error type.", "gauge")
+	for _, info := range list {
+		if info.Probe != nil {
+			w.Sample(p+"runner_probe_error", metrics.Labels{"runner": info.Name, "type": info.Probe.Type}, 1)
+		}
+	}
+}
+
+func writeOptionalBool(w *metrics.Writer, name, help string, list []runner.RunnerInfo, get func(*runner.RunnerInfo) *bool) {
+	w.Header(name, help, "gauge")
+	for i := range list {
+		if v := get(&list[i]); v != nil {
+			w.Sample(name, metrics.Labels{"runner": list[i].Name}, metrics.Bool(*v))
+		}
+	}
+}
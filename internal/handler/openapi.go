@@ -0,0 +1,364 @@
+package handler
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/apitoken"
+	"github.com/lab-dev/github-actions-runner-manager/internal/audit"
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/lab-dev/github-actions-runner-manager/internal/gitops"
+	"github.com/lab-dev/github-actions-runner-manager/internal/jobhistory"
+	"github.com/lab-dev/github-actions-runner-manager/internal/openapi"
+	"github.com/lab-dev/github-actions-runner-manager/internal/regjob"
+	"github.com/lab-dev/github-actions-runner-manager/internal/rollout"
+	"github.com/lab-dev/github-actions-runner-manager/internal/runner"
+	"github.com/lab-dev/github-actions-runner-manager/internal/workspace"
+	"github.com/labstack/echo/v4"
+)
+
+// 以下类型仅用于描述以 map 返回的响应，字段须与对应处理函数一致（见 openapi_test.go）
+
+// ErrorResponse 错误响应，HTTP 状态码非 2xx 时返回
+type ErrorResponse struct {
+	Message string `json:"message"`
+}
+
+// MessageResponse 仅含提示信息的响应
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// HealthResponse GET /health 的响应
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+// VersionResponse GET /version 的响应
+type VersionResponse struct {
+	Version string `json:"version"`
+}
+
+// RunnerList GET /api/runners 的响应
+type RunnerList struct {
+	Probed     bool                `json:"probed"`                // 是否实时探测了容器状态
+	CachedAt   time.Time           `json:"cached_at,omitzero"`    // probe=false 时最早一条缓存状态的时间
+	Runners    []runner.RunnerInfo `json:"runners"`               // 本页 runner
+	TotalCount int                 `json:"total_count"`           // 筛选后的总数
+	NextCursor string              `json:"next_cursor,omitempty"` // 下一页的 cursor，最后一页时省略
+}
+
+// AddRunnerResponse POST /api/runners 的响应；count 大于 0 时返回 names、runners 与 queued_count，否则返回 name 等单个 runner 的字段
+type AddRunnerResponse struct {
+	Message     string          `json:"message"`
+	Name        string          `json:"name,omitempty"`
+	InstallDir  string          `json:"install_dir,omitempty"`
+	Queued      bool            `json:"queued,omitempty"` // 已排队注册，进度见 job_id 对应的任务
+	JobID       string          `json:"job_id,omitempty"`
+	Names       []string        `json:"names,omitempty"`
+	Runners     []ReplicaResult `json:"runners,omitempty"`
+	QueuedCount int             `json:"queued_count,omitempty"` // count 大于 0 时已排队注册的 runner 数
+}
+
+// RunnerActionResponse 启动、停止 runner 的响应；容器状态探测失败但仍已尝试操作时带 probe
+type RunnerActionResponse struct {
+	Message string            `json:"message"`
+	Probe   *runner.ProbeInfo `json:"probe,omitempty"`
+}
+
+// UpdateRunnerResponse PUT /api/runners/:name 的响应
+type UpdateRunnerResponse struct {
+	Message string             `json:"message"`
+	Runner  *runner.RunnerInfo `json:"runner"`
+	Started bool               `json:"started"` // 是否已自动启动
+}
+
+// RunnerJobList GET /api/runners/:name/jobs 的响应
+type RunnerJobList struct {
+	Jobs       []jobhistory.Record `json:"jobs"`
+	TotalCount int                 `json:"total_count"`
+	Page       int                 `json:"page"`
+	PerPage    int                 `json:"per_page"`
+}
+
+// RegistrationJobList GET /api/jobs 的响应
+type RegistrationJobList struct {
+	Jobs       []*regjob.Job `json:"jobs"`
+	TotalCount int           `json:"total_count"`
+}
+
+// RegistrationJobResponse 取消或重试注册任务的响应
+type RegistrationJobResponse struct {
+	Message string      `json:"message"`
+	Job     *regjob.Job `json:"job"`
+}
+
+// QueueFullResponse 注册队列已满时的 503 响应，runner 已写入配置
+type QueueFullResponse struct {
+	Message string `json:"message"`
+	Name    string `json:"name"`
+}
+
+// AuditList GET /api/audit 的响应
+type AuditList struct {
+	Entries []audit.Entry `json:"entries"`
+}
+
+// ConfigHistory GET /api/config/history 的响应
+type ConfigHistory struct {
+	Revisions []config.Revision `json:"revisions"`
+}
+
+// ConfigDiff GET /api/config/diff 的响应；to 为 0 表示当前配置文件
+type ConfigDiff struct {
+	From int    `json:"from"`
+	To   int    `json:"to"`
+	Diff string `json:"diff"`
+}
+
+// GitOpsStatus GET /api/gitops 的响应
+type GitOpsStatus struct {
+	Enabled bool         `json:"enabled"`
+	Plan    *gitops.Plan `json:"plan"`
+}
+
+// GitOpsSyncResponse POST /api/gitops/sync 的响应
+type GitOpsSyncResponse struct {
+	Plan *gitops.Plan `json:"plan"`
+}
+
+// GitOpsApplyResponse POST /api/gitops/apply 的响应
+type GitOpsApplyResponse struct {
+	Plan   *gitops.Plan    `json:"plan"`
+	Result ReconcileResult `json:"result"`
+}
+
+// TokenList GET /api/tokens 的响应
+type TokenList struct {
+	Tokens []apitoken.Token `json:"tokens"`
+}
+
+// CreateTokenResponse POST /api/tokens 的响应，token 明文仅返回这一次
+type CreateTokenResponse struct {
+	Token string          `json:"token"`
+	Info  *apitoken.Token `json:"info"`
+}
+
+// FleetUpgradeStatus GET /api/fleet/upgrade 的响应
+type FleetUpgradeStatus struct {
+	Upgrade *rollout.Rollout `json:"upgrade"`
+	Counts  map[string]int   `json:"counts"` // 各状态的 runner 数
+}
+
+// WebhookResponse POST /api/webhooks/github 的响应
+type WebhookResponse struct {
+	Message string `json:"message"`
+	ID      string `json:"id,omitempty"` // 写入的 Job 记录
+}
+
+// 接口分组
+const (
+	tagRunners      = "runners"
+	tagRegistration = "registration"
+	tagConfig       = "config"
+	tagAuth         = "auth"
+	tagFleet        = "fleet"
+	tagSystem       = "system"
+)
+
+// errorResp 各接口共用的错误响应
+var errorResp = openapi.Resp{Description: "错误，message 为原因", Body: ErrorResponse{}}
+
+func okResp(body any, desc string) openapi.Resp {
+	return openapi.Resp{Status: http.StatusOK, Description: desc, Body: body}
+}
+
+// apiRoutes 对外接口的描述，与 RegisterRoutes 一一对应（页面与 /auth 登录流程除外）；权限范围由 RouteScope 推出
+func apiRoutes() []openapi.Route {
+	return []openapi.Route{
+		{Method: http.MethodGet, Path: "/health", ID: "Health", Summary: "健康检查", Tag: tagSystem,
+			Responses: []openapi.Resp{okResp(HealthResponse{}, "服务正常")}},
+		{Method: http.MethodGet, Path: "/version", ID: "Version", Summary: "版本号", Tag: tagSystem,
+			Responses: []openapi.Resp{okResp(VersionResponse{}, "版本号，未注入时为 dev"), errorResp}},
+		{Method: http.MethodGet, Path: "/metrics", ID: "Metrics", Summary: "Prometheus 指标", Tag: tagSystem,
+			Description: "除常规鉴权外，也可用 Authorization: Bearer <METRICS_TOKEN> 访问",
+			Responses:   []openapi.Resp{{Status: http.StatusOK, Description: "Prometheus 文本格式", Media: "text/plain"}, errorResp}},
+		{Method: http.MethodGet, Path: "/api/openapi.json", ID: "GetOpenAPI", Summary: "本文档", Tag: tagSystem, NoClient: true,
+			Responses: []openapi.Resp{okResp(map[string]any{}, "OpenAPI 3 文档"), errorResp}},
+		{Method: http.MethodGet, Path: "/api/me", ID: "Me", Summary: "当前调用者", Tag: tagAuth,
+			Responses: []openapi.Resp{okResp(Principal{}, "调用者名称、类型与角色"), errorResp}},
+		{Method: http.MethodGet, Path: "/api/events", ID: "StreamEvents", Summary: "事件流（SSE）", Tag: tagSystem, NoClient: true,
+			Description: "text/event-stream，每条事件的 data 为 events.Event 的 JSON；带 Last-Event-ID 时补发之后的事件",
+			Responses:   []openapi.Resp{{Status: http.StatusOK, Description: "事件流", Media: "text/event-stream"}, errorResp}},
+
+		{Method: http.MethodGet, Path: "/api/runners", ID: "ListRunners", Summary: "列出 runner", Tag: tagRunners,
+			Description: "按配置顺序返回调用者可见的 runner，支持筛选、排序与游标分页",
+			Query: []openapi.Param{
+				{Name: "status", Description: "逗号分隔的状态，任一匹配", Type: ""},
+				{Name: "running", Description: "是否在运行", Type: false},
+				{Name: "target", Description: "target 通配，如 myorg/*", Type: ""},
+				{Name: "label", Description: "逗号分隔的 label，须全部包含", Type: ""},
+				{Name: "registered_on_github", Description: "true、false 或 unknown（尚未检查）", Type: ""},
+				{Name: "probe_error", Description: "逗号分隔的探测失败类型，any 为任意失败，none 为无失败", Type: ""},
+				{Name: "sort", Description: "name、status 或 target，前缀 - 倒序；默认配置顺序", Type: ""},
+				{Name: "limit", Description: "每页条数，1-500；省略时不分页", Type: 0},
+				{Name: "cursor", Description: "上一页返回的 next_cursor，须与 sort 一致", Type: ""},
+				{Name: "probe", Description: "false 时不实时探测容器，返回最近一次的缓存状态；默认 true", Type: false},
+			},
+			Responses: []openapi.Resp{okResp(RunnerList{}, "runner 列表"), errorResp}},
+		{Method: http.MethodPost, Path: "/api/runners", ID: "AddRunner", Summary: "添加 runner", Tag: tagRunners,
+			Description: "提供注册 token（或配置了 GITHUB_ADMIN_TOKEN）时在后台安装并注册；count 大于 0 时创建 name-1..name-N",
+			Body:        AddRunnerRequest{},
+			Responses: []openapi.Resp{okResp(AddRunnerResponse{}, "已添加"),
+				{Status: http.StatusServiceUnavailable, Description: "已添加，但注册队列已满", Body: QueueFullResponse{}}, errorResp}},
+		{Method: http.MethodPost, Path: "/api/runners/bulk", ID: "BulkRunners", Summary: "批量操作 runner", Tag: tagRunners,
+			Description: "start、stop、restart、drain 需 operate 权限，remove 与 update-labels 需 admin 权限",
+			Body:        BulkRequest{},
+			Responses:   []openapi.Resp{okResp(BulkResponse{}, "逐个 runner 的结果"), errorResp}},
+		{Method: http.MethodGet, Path: "/api/runners/:name", ID: "GetRunner", Summary: "查看 runner", Tag: tagRunners,
+			Description: "响应头 ETag 为配置条目的实体标签，修改时通过 If-Match 带回",
+			Responses:   []openapi.Resp{okResp(runner.RunnerInfo{}, "runner 配置与状态"), errorResp}},
+		{Method: http.MethodPut, Path: "/api/runners/:name", ID: "UpdateRunner", Summary: "更新 runner 配置", Tag: tagRunners,
+			Headers:   []openapi.Param{{Name: "If-Match", Description: "GET 返回的 ETag，不一致时返回 412", Type: ""}},
+			Body:      UpdateRunnerRequest{},
+			Responses: []openapi.Resp{okResp(UpdateRunnerResponse{}, "已更新"), errorResp}},
+		{Method: http.MethodDelete, Path: "/api/runners/:name", ID: "RemoveRunner", Summary: "移除 runner", Tag: tagRunners,
+			Description: "停止 runner、删除 base_path 下的安装目录并从配置中移除",
+			Responses:   []openapi.Resp{okResp(MessageResponse{}, "已移除"), errorResp}},
+		{Method: http.MethodPost, Path: "/api/runners/:name/start", ID: "StartRunner", Summary: "启动 runner", Tag: tagRunners,
+			Responses: []openapi.Resp{okResp(RunnerActionResponse{}, "已发起启动或已在运行"), errorResp}},
+		{Method: http.MethodPost, Path: "/api/runners/:name/stop", ID: "StopRunner", Summary: "停止 runner", Tag: tagRunners,
+			Responses: []openapi.Resp{okResp(RunnerActionResponse{}, "已停止或未在运行"), errorResp}},
+		{Method: http.MethodGet, Path: "/api/runners/:name/jobs", ID: "ListRunnerJobs", Summary: "runner 的 Job 历史", Tag: tagRunners,
+			Query: []openapi.Param{
+				{Name: "page", Description: "页码，默认 1", Type: 0},
+				{Name: "per_page", Description: "每页条数，1-100，默认 30", Type: 0},
+			},
+			Responses: []openapi.Resp{okResp(RunnerJobList{}, "按开始时间倒序"), errorResp}},
+
+		{Method: http.MethodGet, Path: "/api/jobs", ID: "ListRegistrationJobs", Summary: "列出注册任务", Tag: tagRegistration,
+			Query: []openapi.Param{
+				{Name: "runner", Description: "runner 名称", Type: ""},
+				{Name: "state", Description: "任务状态", Type: ""},
+			},
+			Responses: []openapi.Resp{okResp(RegistrationJobList{}, "按创建时间倒序，不含脚本输出"), errorResp}},
+		{Method: http.MethodGet, Path: "/api/jobs/:id", ID: "GetRegistrationJob", Summary: "查看注册任务", Tag: tagRegistration,
+			Responses: []openapi.Resp{okResp(regjob.Job{}, "任务详情，含脱敏后的脚本输出"), errorResp}},
+		{Method: http.MethodPost, Path: "/api/jobs/:id/cancel", ID: "CancelRegistrationJob", Summary: "取消注册任务", Tag: tagRegistration,
+			Responses: []openapi.Resp{okResp(RegistrationJobResponse{}, "排队中的任务已取消"),
+				{Status: http.StatusAccepted, Description: "已中止执行中的任务", Body: RegistrationJobResponse{}}, errorResp}},
+		{Method: http.MethodPost, Path: "/api/jobs/:id/retry", ID: "RetryRegistrationJob", Summary: "重试注册任务", Tag: tagRegistration,
+			Body: RetryRegistrationRequest{},
+			Responses: []openapi.Resp{{Status: http.StatusAccepted, Description: "已重新排队", Body: RegistrationJobResponse{}},
+				{Status: http.StatusServiceUnavailable, Description: "注册队列已满", Body: QueueFullResponse{}}, errorResp}},
+
+		{Method: http.MethodGet, Path: "/api/audit", ID: "ListAudit", Summary: "查询审计日志", Tag: tagAuth,
+			Query: []openapi.Param{
+				{Name: "actor", Type: ""},
+				{Name: "action", Description: "如 runner.add、token.create", Type: ""},
+				{Name: "runner", Type: ""},
+				{Name: "result", Description: "success 或 failure", Type: ""},
+				{Name: "since", Description: "RFC 3339 时间", Type: ""},
+				{Name: "until", Description: "RFC 3339 时间", Type: ""},
+				{Name: "limit", Description: "1-1000，默认 100", Type: 0},
+			},
+			Responses: []openapi.Resp{okResp(AuditList{}, "最新的在前"), errorResp}},
+		{Method: http.MethodGet, Path: "/api/tokens", ID: "ListTokens", Summary: "列出 API Token", Tag: tagAuth,
+			Responses: []openapi.Resp{okResp(TokenList{}, "不含明文与哈希"), errorResp}},
+		{Method: http.MethodPost, Path: "/api/tokens", ID: "CreateToken", Summary: "签发 API Token", Tag: tagAuth,
+			Body:      CreateTokenRequest{},
+			Responses: []openapi.Resp{{Status: http.StatusCreated, Description: "token 明文仅返回这一次", Body: CreateTokenResponse{}}, errorResp}},
+		{Method: http.MethodDelete, Path: "/api/tokens/:id", ID: "DeleteToken", Summary: "吊销 API Token", Tag: tagAuth,
+			Responses: []openapi.Resp{okResp(MessageResponse{}, "已吊销"), errorResp}},
+
+		{Method: http.MethodGet, Path: "/api/config/history", ID: "ListConfigHistory", Summary: "配置历史版本", Tag: tagConfig,
+			Responses: []openapi.Resp{okResp(ConfigHistory{}, "最新的在前"), errorResp}},
+		{Method: http.MethodGet, Path: "/api/config/history/:rev", ID: "GetConfigRevision", Summary: "历史版本的配置内容", Tag: tagConfig,
+			Responses: []openapi.Resp{{Status: http.StatusOK, Description: "config.yaml 内容", Media: "application/yaml"}, errorResp}},
+		{Method: http.MethodGet, Path: "/api/config/diff", ID: "DiffConfig", Summary: "比较两个配置版本", Tag: tagConfig,
+			Query: []openapi.Param{
+				{Name: "from", Description: "起始版本", Type: 0, Required: true},
+				{Name: "to", Description: "目标版本，省略时为当前配置文件", Type: 0},
+			},
+			Responses: []openapi.Resp{okResp(ConfigDiff{}, "unified diff"), errorResp}},
+		{Method: http.MethodPost, Path: "/api/config/rollback/:rev", ID: "RollbackConfig", Summary: "回滚配置", Tag: tagConfig,
+			Responses: []openapi.Resp{okResp(RollbackResult{}, "新版本号与 runner 对齐结果"), errorResp}},
+		{Method: http.MethodPost, Path: "/api/config/plan", ID: "PlanConfig", Summary: "预览配置变更", Tag: tagConfig,
+			Description: "提交完整的 config.yaml（YAML 或 JSON），返回生效后将执行的动作，不做任何修改",
+			BodyMedia:   "application/yaml",
+			Responses:   []openapi.Resp{okResp(ConfigPlan{}, "执行计划；配置无效时 valid 为 false 并列出 errors"), errorResp}},
+		{Method: http.MethodGet, Path: "/api/gitops", ID: "GetGitOps", Summary: "GitOps 同步状态", Tag: tagConfig,
+			Responses: []openapi.Resp{okResp(GitOpsStatus{}, "是否启用与最近一次计划"), errorResp}},
+		{Method: http.MethodPost, Path: "/api/gitops/sync", ID: "SyncGitOps", Summary: "立即同步仓库", Tag: tagConfig,
+			Responses: []openapi.Resp{okResp(GitOpsSyncResponse{}, "最新计划"), errorResp}},
+		{Method: http.MethodPost, Path: "/api/gitops/apply", ID: "ApplyGitOps", Summary: "应用待应用的计划", Tag: tagConfig,
+			Body:      ApplyGitOpsRequest{},
+			Responses: []openapi.Resp{okResp(GitOpsApplyResponse{}, "已应用"), errorResp}},
+
+		{Method: http.MethodGet, Path: "/api/fleet/upgrade", ID: "GetFleetUpgrade", Summary: "滚动升级进度", Tag: tagFleet,
+			Responses: []openapi.Resp{okResp(FleetUpgradeStatus{}, "最近一次升级"), errorResp}},
+		{Method: http.MethodPost, Path: "/api/fleet/upgrade", ID: "StartFleetUpgrade", Summary: "开始滚动升级", Tag: tagFleet,
+			Body:      FleetUpgradeRequest{},
+			Responses: []openapi.Resp{{Status: http.StatusAccepted, Description: "已开始", Body: rollout.Rollout{}}, errorResp}},
+		{Method: http.MethodPost, Path: "/api/fleet/upgrade/cancel", ID: "CancelFleetUpgrade", Summary: "取消滚动升级", Tag: tagFleet,
+			Responses: []openapi.Resp{{Status: http.StatusAccepted, Description: "已发送取消", Body: MessageResponse{}}, errorResp}},
+
+		{Method: http.MethodPost, Path: "/api/webhooks/github", ID: "GitHubWebhook", Summary: "接收 GitHub workflow_job 事件", Tag: tagSystem, NoClient: true,
+			Description: "配置了 GITHUB_WEBHOOK_SECRET 时以 X-Hub-Signature-256 校验来源",
+			Headers: []openapi.Param{
+				{Name: "X-GitHub-Event", Type: "", Required: true},
+				{Name: "X-Hub-Signature-256", Type: ""},
+			},
+			Body: map[string]any{},
+			Responses: []openapi.Resp{okResp(WebhookResponse{}, "已记录"),
+				{Status: http.StatusAccepted, Description: "已忽略", Body: WebhookResponse{}}, errorResp}},
+	}
+}
+
+var (
+	openapiOnce sync.Once
+	openapiDoc  *openapi.Document
+)
+
+// OpenAPIDocument 返回描述全部接口的 OpenAPI 文档，client 包由它生成
+func OpenAPIDocument() *openapi.Document {
+	openapiOnce.Do(func() {
+		v := Version
+		if v == "" {
+			v = "dev"
+		}
+		b := openapi.NewBuilder(openapi.Info{
+			Title:       "GitHub Actions Runner Manager API",
+			Version:     v,
+			Description: "错误时返回 {\"message\": \"...\"}。x-scope 为 API Token 所需的权限范围（read < operate < admin）",
+		})
+		b.Security("bearerAuth", openapi.SecurityScheme{Type: "http", Scheme: "bearer", Description: "API Token（rfm_...），见 POST /api/tokens"})
+		b.Security("basicAuth", openapi.SecurityScheme{Type: "http", Scheme: "basic", Description: "BASIC_AUTH_USER / BASIC_AUTH_PASSWORD，视为 admin"})
+		b.Security("session", openapi.SecurityScheme{Type: "apiKey", In: "cookie", Name: sessionCookie, Description: "OIDC 登录会话；写操作还须带 " + CSRFHeader + " 请求头"})
+		// 不同包中的同名类型与过于宽泛的类型名
+		b.Name(audit.Change{}, "AuditChange")
+		b.Name(audit.Entry{}, "AuditEntry")
+		b.Name(gitops.Change{}, "GitOpsChange")
+		b.Name(gitops.Plan{}, "GitOpsPlan")
+		b.Name(regjob.Job{}, "RegistrationJob")
+		b.Name(jobhistory.Record{}, "JobRecord")
+		b.Name(apitoken.Token{}, "APIToken")
+		b.Name(config.Revision{}, "ConfigRevision")
+		b.Name(rollout.Step{}, "RolloutStep")
+		b.Name(workspace.Result{}, "CleanupResult")
+		for _, r := range apiRoutes() {
+			if r.Path != "/health" && r.Path != "/api/webhooks/github" {
+				r.Scope = RouteScope(r.Method, r.Path)
+			}
+			b.Add(r)
+		}
+		openapiDoc = b.Document()
+	})
+	return openapiDoc
+}
+
+// OpenAPI 返回 OpenAPI 3 文档（GET /api/openapi.json）
+func OpenAPI(c echo.Context) error {
+	return c.JSON(http.StatusOK, OpenAPIDocument())
+}
@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/lab-dev/github-actions-runner-manager/internal/jobhistory"
+	"github.com/lab-dev/github-actions-runner-manager/internal/regjob"
+	"github.com/labstack/echo/v4"
+)
+
+// 不在文档中的路由：管理界面与 OIDC 登录流程
+func undocumented(path string) bool {
+	return path == "/" || strings.HasPrefix(path, "/auth/")
+}
+
+func TestOpenAPI_RoutesMatchSpec(t *testing.T) {
+	e := echo.New()
+	RegisterRoutes(e)
+	doc := OpenAPIDocument()
+	registered := map[string]bool{}
+	for _, r := range e.Routes() {
+		if undocumented(r.Path) {
+			continue
+		}
+		registered[r.Method+" "+r.Path] = true
+		op, ok := doc.Operation(r.Method, r.Path)
+		if !ok {
+			t.Errorf("%s %s 未写入 OpenAPI 文档", r.Method, r.Path)
+			continue
+		}
+		want := RouteScope(r.Method, r.Path)
+		if r.Path == "/health" || r.Path == "/api/webhooks/github" {
+			want = ""
+		}
+		if op.Scope != want {
+			t.Errorf("%s %s: x-scope = %q, want %q", r.Method, r.Path, op.Scope, want)
+		}
+	}
+	for _, op := range doc.Operations() {
+		path := strings.NewReplacer("{", ":", "}", "").Replace(op.Path())
+		if !registered[op.Method()+" "+path] {
+			t.Errorf("文档中的 %s %s 未注册路由", op.Method(), op.Path())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	var served struct {
+		OpenAPI string                    `json:"openapi"`
+		Paths   map[string]map[string]any `json:"paths"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &served); err != nil || served.OpenAPI != "3.0.3" || served.Paths["/api/runners/{name}"]["put"] == nil {
+		t.Fatalf("served document: %v %s", err, rec.Body.String()[:min(rec.Body.Len(), 200)])
+	}
+	for _, name := range []string{"RunnerInfo", "ProbeInfo", "AddRunnerRequest", "UpdateRunnerRequest", "ErrorResponse"} {
+		if doc.Components.Schemas[name] == nil {
+			t.Errorf("components.schemas 缺少 %s", name)
+		}
+	}
+}
+
+// TestOpenAPI_ResponsesMatchSpec 经完整路由与中间件调用各接口，按文档中对应状态码的 schema 校验响应
+func TestOpenAPI_ResponsesMatchSpec(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	cfg := &config.Config{Runners: config.RunnersConfig{BasePath: dir, Items: []config.RunnerItem{
+		{Name: "r1", TargetType: "org", Target: "o1", Labels: []string{"linux"}},
+		{Name: "r2", TargetType: "repo", Target: "o1/app"},
+	}}}
+	_ = cfg.Save(cfgPath)
+	ConfigPath = cfgPath
+	defer func() { ConfigPath = filepath.Join(os.TempDir(), "handler-test-config.yaml") }()
+	defer func() {
+		jobMu.Lock()
+		pendingJobs = nil
+		jobMu.Unlock()
+	}()
+	WebhookSecret = "s3cret"
+	defer func() { WebhookSecret = "" }()
+	sd := cfg.Runners.StateDir()
+	_ = jobhistory.Upsert(sd, jobhistory.Record{ID: "gh-1", Runner: "r1", Job: "build", Conclusion: "success"})
+	job := regjob.New("r2", filepath.Join(dir, "r2"), "https://github.com/o1/app", nil)
+	if err := enqueueRegistration(cfg, job, "AAA111"); err != nil {
+		t.Fatal(err)
+	}
+
+	doc := OpenAPIDocument()
+	e := echo.New()
+	var route string
+	e.Use(AuditLog(), Auth("", ""), func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			route = c.Path()
+			return next(c)
+		}
+	})
+	RegisterRoutes(e)
+
+	call := func(method, target, body string, header ...string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		route = ""
+		e.ServeHTTP(rec, req)
+		op, ok := doc.Operation(method, route)
+		if !ok {
+			t.Fatalf("%s %s: 路由 %q 不在文档中", method, target, route)
+		}
+		ct := rec.Header().Get("Content-Type")
+		if !strings.HasPrefix(ct, "application/json") {
+			resp := op.Responses[strconv.Itoa(rec.Code)]
+			if resp == nil || resp.Content[strings.TrimSpace(strings.Split(ct, ";")[0])].Schema == nil {
+				t.Errorf("%s %s: %d %s 未在文档中声明", method, target, rec.Code, ct)
+			}
+			return rec
+		}
+		s, ok := op.ResponseSchema(rec.Code)
+		if !ok {
+			t.Errorf("%s %s: 状态码 %d 未在文档中声明", method, target, rec.Code)
+			return rec
+		}
+		if err := doc.Validate(s, rec.Body.Bytes()); err != nil {
+			t.Errorf("%s %s -> %d: %v\n%s", method, target, rec.Code, err, rec.Body.String())
+		}
+		return rec
+	}
+	expect := func(rec *httptest.ResponseRecorder, code int) {
+		t.Helper()
+		if rec.Code != code {
+			t.Errorf("status = %d, want %d: %s", rec.Code, code, rec.Body.String())
+		}
+	}
+
+	expect(call(http.MethodGet, "/health", ""), http.StatusOK)
+	expect(call(http.MethodGet, "/version", ""), http.StatusOK)
+	expect(call(http.MethodGet, "/metrics", ""), http.StatusOK)
+	expect(call(http.MethodGet, "/api/me", ""), http.StatusOK)
+	expect(call(http.MethodGet, "/api/runners", ""), http.StatusOK)
+	expect(call(http.MethodGet, "/api/runners?limit=1&sort=-name&probe=false", ""), http.StatusOK)
+	expect(call(http.MethodGet, "/api/runners?sort=bogus", ""), http.StatusBadRequest)
+	expect(call(http.MethodGet, "/api/runners/r1", ""), http.StatusOK)
+	expect(call(http.MethodGet, "/api/runners/nope", ""), http.StatusNotFound)
+	expect(call(http.MethodPost, "/api/runners", `{"name":"web","target_type":"org","target":"o1"}`), http.StatusOK)
+	expect(call(http.MethodPost, "/api/runners", `{"name":"pool","target_type":"org","target":"o1","count":2}`), http.StatusOK)
+	expect(call(http.MethodPut, "/api/runners/web", `{"target_type":"org","target":"o1","labels":["gpu"]}`), http.StatusOK)
+	expect(call(http.MethodPut, "/api/runners/web", `{"target_type":"org","target":"o1"}`, "If-Match", `"stale"`), http.StatusPreconditionFailed)
+	expect(call(http.MethodPost, "/api/runners/r1/start", ""), http.StatusBadRequest)
+	expect(call(http.MethodPost, "/api/runners/r1/stop", ""), http.StatusOK)
+	expect(call(http.MethodPost, "/api/runners/bulk", `{"action":"update-labels","selector":{"label":"gpu"},"add_labels":["big"]}`), http.StatusOK)
+	expect(call(http.MethodGet, "/api/runners/r1/jobs?per_page=5", ""), http.StatusOK)
+	expect(call(http.MethodDelete, "/api/runners/pool-2", ""), http.StatusOK)
+
+	expect(call(http.MethodGet, "/api/jobs", ""), http.StatusOK)
+	expect(call(http.MethodGet, "/api/jobs/"+job.ID, ""), http.StatusOK)
+	expect(call(http.MethodPost, "/api/jobs/"+job.ID+"/cancel", ""), http.StatusOK)
+	expect(call(http.MethodPost, "/api/jobs/"+job.ID+"/retry", `{}`), http.StatusAccepted)
+
+	rec := call(http.MethodPost, "/api/tokens", `{"name":"ci","scopes":["read"]}`)
+	expect(rec, http.StatusCreated)
+	var created CreateTokenResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+	expect(call(http.MethodGet, "/api/tokens", ""), http.StatusOK)
+	expect(call(http.MethodDelete, "/api/tokens/"+created.Info.ID, ""), http.StatusOK)
+	expect(call(http.MethodGet, "/api/audit?limit=5", ""), http.StatusOK)
+
+	expect(call(http.MethodGet, "/api/config/history", ""), http.StatusOK)
+	expect(call(http.MethodGet, "/api/config/history/1", ""), http.StatusOK)
+	expect(call(http.MethodGet, "/api/config/diff?from=1", ""), http.StatusOK)
+	expect(call(http.MethodPost, "/api/config/plan", "runners:\n  base_path: "+dir+"\n  items: []\n"), http.StatusOK)
+	expect(call(http.MethodPost, "/api/config/rollback/1", ""), http.StatusOK)
+	expect(call(http.MethodGet, "/api/gitops", ""), http.StatusOK)
+	expect(call(http.MethodPost, "/api/gitops/sync", ""), http.StatusBadRequest)
+	expect(call(http.MethodGet, "/api/fleet/upgrade", ""), http.StatusNotFound)
+	expect(call(http.MethodPost, "/api/fleet/upgrade/cancel", ""), http.StatusConflict)
+
+	body := `{"zen":"hi"}`
+	mac := hmac.New(sha256.New, []byte(WebhookSecret))
+	mac.Write([]byte(body))
+	sig := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	expect(call(http.MethodPost, "/api/webhooks/github", body, "X-GitHub-Event", "ping", "X-Hub-Signature-256", sig), http.StatusOK)
+	expect(call(http.MethodPost, "/api/webhooks/github", body, "X-GitHub-Event", "push", "X-Hub-Signature-256", sig), http.StatusAccepted)
+}
@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/lab-dev/github-actions-runner-manager/internal/runner"
+)
+
+// 容器状态探测：每个 runner 需一次 docker inspect 与一次 Agent /status，不可达时各自等到超时。
+// 列表探测并发执行，并共享短时缓存，Index、ListRunners、Metrics 与后台监视同时请求时只探测一次。
+const probeConcurrency = 8 // 全局同时进行的探测数
+
+var (
+	probeTimeout  = 6 * time.Second  // 单个 runner 的探测时限
+	probeDeadline = 10 * time.Second // 一次列表探测的总时限，超过后未完成的 runner 记为超时
+	probeCacheTTL = 2 * time.Second  // 探测结果的共享时长
+	probeFunc     = runner.ContainerRunnerStatus
+)
+
+// probeResult 一次探测的结果
+type probeResult struct {
+	running bool
+	status  runner.Status
+	usage   *runner.ResourceUsage
+	err     error
+}
+
+// probeEntry 进行中或已完成的探测；done 关闭后 res 可读
+type probeEntry struct {
+	installDir string
+	done       chan struct{}
+	at         time.Time // 完成时间，进行中为零值
+	res        probeResult
+}
+
+var (
+	probeMu    sync.Mutex
+	probes     = map[string]*probeEntry{}
+	probeSlots = make(chan struct{}, probeConcurrency)
+)
+
+// sharedProbe 返回 runner 的探测项：缓存未过期或正在探测时复用，否则在后台发起新探测。
+// 探测不随调用方取消，完成后的结果留给后续请求。
+func sharedProbe(cfg *config.Config, name, installDir string) *probeEntry {
+	probeMu.Lock()
+	defer probeMu.Unlock()
+	if e, ok := probes[name]; ok && e.installDir == installDir && (e.at.IsZero() || time.Since(e.at) < probeCacheTTL) {
+		return e
+	}
+	e := &probeEntry{installDir: installDir, done: make(chan struct{})}
+	probes[name] = e
+	probe, timeout := probeFunc, probeTimeout
+	go func() {
+		probeSlots <- struct{}{}
+		defer func() { <-probeSlots }()
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		var r probeResult
+		r.running, r.status, r.usage, r.err = probe(ctx, cfg, name, installDir)
+		if r.err != nil && ctx.Err() == context.DeadlineExceeded {
+			r.err = &runner.ProbeError{Type: runner.ProbeErrorTypeTimeout, Err: fmt.Errorf("探测超过 %s 未完成: %w", timeout, r.err)}
+		}
+		probeMu.Lock()
+		e.res, e.at = r, time.Now()
+		probeMu.Unlock()
+		close(e.done)
+	}()
+	return e
+}
+
+// storeProbe 记录单个 runner 直接探测的结果，供随后的列表请求复用
+func storeProbe(name, installDir string, r probeResult) {
+	e := &probeEntry{installDir: installDir, done: make(chan struct{}), at: time.Now(), res: r}
+	close(e.done)
+	probeMu.Lock()
+	probes[name] = e
+	probeMu.Unlock()
+}
+
+// forgetProbe runner 被移除后清除其探测缓存
+func forgetProbe(name string) {
+	probeMu.Lock()
+	defer probeMu.Unlock()
+	delete(probes, name)
+}
+
+// applyContainerStatus 容器模式下用 Agent 状态覆盖 list 中每项的 Running/Status，就地修改；
+// 各 runner 并发探测，总时长不超过 probeDeadline
+func applyContainerStatus(ctx context.Context, cfg *config.Config, list []runner.RunnerInfo) {
+	ctx, cancel := context.WithTimeout(ctx, probeDeadline)
+	defer cancel()
+	entries := make([]*probeEntry, len(list))
+	for i := range list {
+		entries[i] = sharedProbe(cfg, list[i].Name, list[i].InstallDir)
+	}
+	for i, e := range entries {
+		// 已完成的结果优先，避免总时限到达后把已返回的 runner 记为超时
+		select {
+		case <-e.done:
+			applyProbeResult(&list[i], e.res)
+			continue
+		default:
+		}
+		select {
+		case <-e.done:
+			applyProbeResult(&list[i], e.res)
+		case <-ctx.Done():
+			applyProbeResult(&list[i], probeResult{err: &runner.ProbeError{
+				Type: runner.ProbeErrorTypeTimeout,
+				Err:  fmt.Errorf("列表探测超过总时限 %s，该 runner 尚未返回: %w", probeDeadline, ctx.Err()),
+			}})
+		}
+	}
+}
+
+// applyContainerStatusOne 容器模式下用 Agent 状态覆盖单条 info 的 Running/Status/Probe；
+// 直接探测以取得最新状态，结果写入共享缓存
+func applyContainerStatusOne(ctx context.Context, cfg *config.Config, info *runner.RunnerInfo) {
+	var r probeResult
+	r.running, r.status, r.usage, r.err = probeFunc(ctx, cfg, info.Name, info.InstallDir)
+	storeProbe(info.Name, info.InstallDir, r)
+	applyProbeResult(info, r)
+}
+
+func applyProbeResult(info *runner.RunnerInfo, r probeResult) {
+	if r.err != nil {
+		log.Printf("[container-status] name=%s: %v", info.Name, r.err)
+		applyProbeFailure(info, r.err)
+		return
+	}
+	clearProbe(info)
+	info.Running = r.running
+	info.Status = r.status
+	info.Usage = nil
+	if r.usage != nil {
+		// 缓存结果由多个请求共享，后续 applyUsage 会就地修改，需复制
+		u := *r.usage
+		info.Usage = &u
+	}
+}
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/lab-dev/github-actions-runner-manager/internal/runner"
+)
+
+// fakeProbe 替换 probeFunc：记录调用次数与最大并发，stuck 中的 runner 阻塞到 release 关闭
+type fakeProbe struct {
+	delay   time.Duration
+	stuck   map[string]bool
+	release chan struct{}
+	calls   atomic.Int32
+	active  atomic.Int32
+	mu      sync.Mutex
+	peak    int32
+}
+
+func (f *fakeProbe) probe(ctx context.Context, _ *config.Config, name, _ string) (bool, runner.Status, *runner.ResourceUsage, error) {
+	f.calls.Add(1)
+	n := f.active.Add(1)
+	defer f.active.Add(-1)
+	f.mu.Lock()
+	f.peak = max(f.peak, n)
+	f.mu.Unlock()
+	if f.stuck[name] {
+		select {
+		case <-f.release:
+		case <-ctx.Done():
+			return false, runner.StatusUnknown, nil, ctx.Err()
+		}
+	}
+	time.Sleep(f.delay)
+	return true, runner.StatusInstalled, &runner.ResourceUsage{WorkDirBytes: 1}, nil
+}
+
+func useFakeProbe(t *testing.T, f *fakeProbe) {
+	t.Helper()
+	prev := probeFunc
+	probeFunc = f.probe
+	probeMu.Lock()
+	probes = map[string]*probeEntry{}
+	probeMu.Unlock()
+	t.Cleanup(func() {
+		probeFunc = prev
+		probeMu.Lock()
+		probes = map[string]*probeEntry{}
+		probeMu.Unlock()
+	})
+}
+
+func probeList(n int) []runner.RunnerInfo {
+	list := make([]runner.RunnerInfo, n)
+	for i := range list {
+		list[i] = runner.RunnerInfo{Name: fmt.Sprintf("r%d", i), InstallDir: fmt.Sprintf("/runners/r%d", i)}
+	}
+	return list
+}
+
+func TestApplyContainerStatus_Concurrent(t *testing.T) {
+	f := &fakeProbe{delay: 50 * time.Millisecond}
+	useFakeProbe(t, f)
+	cfg := &config.Config{}
+
+	list := probeList(20)
+	start := time.Now()
+	applyContainerStatus(context.Background(), cfg, list)
+	// 串行需 1s；8 路并发约 150ms
+	if d := time.Since(start); d > 600*time.Millisecond {
+		t.Errorf("probing took %s, want concurrent", d)
+	}
+	if f.peak > probeConcurrency {
+		t.Errorf("peak concurrency = %d, limit %d", f.peak, probeConcurrency)
+	}
+	for _, info := range list {
+		if !info.Running || info.Status != runner.StatusInstalled || info.Usage == nil {
+			t.Fatalf("%s not applied: %+v", info.Name, info)
+		}
+	}
+}
+
+func TestApplyContainerStatus_SharedCache(t *testing.T) {
+	f := &fakeProbe{delay: 30 * time.Millisecond}
+	useFakeProbe(t, f)
+	cfg := &config.Config{}
+
+	// 两个请求同时探测同一批 runner，只探测一次
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			applyContainerStatus(context.Background(), cfg, probeList(5))
+		}()
+	}
+	wg.Wait()
+	if got := f.calls.Load(); got != 5 {
+		t.Fatalf("calls = %d, want 5", got)
+	}
+	// 缓存内不再探测，单个 runner 的直接探测刷新缓存
+	applyContainerStatus(context.Background(), cfg, probeList(5))
+	info := probeList(1)[0]
+	applyContainerStatusOne(context.Background(), cfg, &info)
+	if got := f.calls.Load(); got != 6 {
+		t.Fatalf("calls = %d, want 6", got)
+	}
+
+	prev := probeCacheTTL
+	probeCacheTTL = 0
+	defer func() { probeCacheTTL = prev }()
+	applyContainerStatus(context.Background(), cfg, probeList(5))
+	if got := f.calls.Load(); got != 11 {
+		t.Errorf("calls after expiry = %d, want 11", got)
+	}
+}
+
+func TestApplyContainerStatus_Deadline(t *testing.T) {
+	f := &fakeProbe{stuck: map[string]bool{"r1": true}, release: make(chan struct{})}
+	useFakeProbe(t, f)
+	defer close(f.release)
+	prev := probeDeadline
+	probeDeadline = 50 * time.Millisecond
+	defer func() { probeDeadline = prev }()
+
+	list := probeList(3)
+	start := time.Now()
+	applyContainerStatus(context.Background(), &config.Config{}, list)
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("took %s, want about probeDeadline", d)
+	}
+	if list[1].Probe == nil || list[1].Probe.Type != string(runner.ProbeErrorTypeTimeout) || list[1].Status != runner.StatusUnknown {
+		t.Errorf("stuck runner = %+v", list[1])
+	}
+	if list[0].Probe != nil || list[2].Probe != nil || !list[0].Running {
+		t.Errorf("other runners should be probed: %+v %+v", list[0], list[2])
+	}
+}
@@ -0,0 +1,470 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/lab-dev/github-actions-runner-manager/internal/events"
+	"github.com/lab-dev/github-actions-runner-manager/internal/githubcheck"
+	"github.com/lab-dev/github-actions-runner-manager/internal/metrics"
+	"github.com/lab-dev/github-actions-runner-manager/internal/regjob"
+	"github.com/lab-dev/github-actions-runner-manager/internal/runner"
+	"github.com/labstack/echo/v4"
+)
+
+// GitHubAdminToken 用于自动申请注册 Token 的 PAT（组织需 admin:org，仓库需 repo），由 main 从 GITHUB_ADMIN_TOKEN 注入；
+// 为空时添加 runner 需在请求中提供 registration_token 才会注册
+var GitHubAdminToken string
+
+// registrationTokenFor 返回 runner 的注册 Token：优先使用请求中提供的；未提供且配置了 GitHubAdminToken 时为该 runner 申请一个新的。都没有时返回空
+func registrationTokenFor(ctx context.Context, given string, item config.RunnerItem) (string, error) {
+	if given != "" || GitHubAdminToken == "" {
+		return given, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	token, _, err := githubcheck.RegistrationToken(ctx, GitHubAdminToken, item.TargetType, item.Target)
+	if err != nil {
+		return "", fmt.Errorf("自动申请注册 Token 失败: %w", err)
+	}
+	return token, nil
+}
+
+// maxPendingRegistrations 等待执行的任务数上限，超出时拒绝新任务
+const maxPendingRegistrations = 500
+
+// errQueueFull 注册任务队列已满
+var errQueueFull = errors.New("当前注册任务队列已满，请稍后再试")
+
+// pendingJob 等待执行的任务；target 为注册 URL，用于按 org/repo 限制并发
+type pendingJob struct {
+	id     string
+	target string
+}
+
+var (
+	// jobMu 保护等待队列与执行中任务，并串行化任务状态切换（worker 领取任务与取消），避免取消与开始执行交错
+	jobMu   sync.Mutex
+	jobCond = sync.NewCond(&jobMu)
+	// pendingJobs 按入队顺序等待执行的任务；任务本身持久化在 regjob 中，Manager 重启后由 recoverRegistrationJobs 重建
+	pendingJobs []pendingJob
+	// activeTargets 各 target 正在执行的任务数
+	activeTargets = map[string]int{}
+	// perTargetLimit 同一 target 同时执行的任务数上限，由 StartRegistrationWorker 按配置设置
+	perTargetLimit = config.DefaultRegistrationPerTarget
+	// runningJobs 正在执行的任务 ID 到取消函数
+	runningJobs = map[string]context.CancelFunc{}
+)
+
+// StartRegistrationWorker 按配置启动 worker 池，应在 main 中调用一次；启动前先恢复上次退出时未完成的任务。
+// 多个 worker 并行下载与注册，同一 org/repo 的并发数受 runners.registration.per_target 限制
+func StartRegistrationWorker(cfg *config.Config) {
+	jobMu.Lock()
+	perTargetLimit = cfg.Runners.RegistrationPerTarget()
+	jobMu.Unlock()
+	recoverRegistrationJobs(cfg)
+	for range cfg.Runners.RegistrationWorkers() {
+		go registrationWorker()
+	}
+}
+
+func registrationWorker() {
+	for {
+		p := nextRegistrationJob()
+		runRegistrationJob(p.id)
+		finishPending(p)
+	}
+}
+
+// finishPending 任务执行结束后归还其 target 的并发名额，唤醒等待同一 target 的 worker
+func finishPending(p pendingJob) {
+	jobMu.Lock()
+	defer jobMu.Unlock()
+	if activeTargets[p.target]--; activeTargets[p.target] <= 0 {
+		delete(activeTargets, p.target)
+	}
+	jobCond.Broadcast()
+}
+
+// nextRegistrationJob 阻塞直到有可执行的任务，取出并占用其 target 的并发名额
+func nextRegistrationJob() pendingJob {
+	jobMu.Lock()
+	defer jobMu.Unlock()
+	for {
+		if i := runnablePending(); i >= 0 {
+			p := pendingJobs[i]
+			pendingJobs = slices.Delete(pendingJobs, i, i+1)
+			activeTargets[p.target]++
+			return p
+		}
+		jobCond.Wait()
+	}
+}
+
+// runnablePending 返回第一个所在 target 未达并发上限的等待任务下标，无则返回 -1；调用方持有 jobMu
+func runnablePending() int {
+	for i, p := range pendingJobs {
+		if activeTargets[p.target] < perTargetLimit {
+			return i
+		}
+	}
+	return -1
+}
+
+// pushPending 将任务加入等待队列并唤醒 worker；队列已满返回 false
+func pushPending(j *regjob.Job) bool {
+	jobMu.Lock()
+	defer jobMu.Unlock()
+	if len(pendingJobs) >= maxPendingRegistrations {
+		return false
+	}
+	pendingJobs = append(pendingJobs, pendingJob{id: j.ID, target: j.URL})
+	jobCond.Broadcast()
+	return true
+}
+
+// removePending 从等待队列移除任务，返回是否存在；调用方持有 jobMu
+func removePending(id string) bool {
+	for i, p := range pendingJobs {
+		if p.id == id {
+			pendingJobs = slices.Delete(pendingJobs, i, i+1)
+			return true
+		}
+	}
+	return false
+}
+
+// registrationQueueDepth 等待执行的任务数
+func registrationQueueDepth() int {
+	jobMu.Lock()
+	defer jobMu.Unlock()
+	return len(pendingJobs)
+}
+
+// registrationRunning 正在执行的任务数
+func registrationRunning() int {
+	jobMu.Lock()
+	defer jobMu.Unlock()
+	return len(runningJobs)
+}
+
+// recoverRegistrationJobs Manager 重启后：仍有 Token 的 queued 任务按创建顺序重新入队，执行中断的任务标记为 failed（可重试）
+func recoverRegistrationJobs(cfg *config.Config) {
+	sd := cfg.Runners.StateDir()
+	jobs := regjob.List(sd, "", "")
+	requeued := 0
+	for i := len(jobs) - 1; i >= 0; i-- {
+		j := jobs[i]
+		if j.Terminal() {
+			continue
+		}
+		if j.State == regjob.StateQueued && regjob.LoadToken(sd, j.ID) != "" && pushPending(j) {
+			requeued++
+			continue
+		}
+		j.State = regjob.StateFailed
+		j.Error = "Manager 重启导致任务中断，可通过 retry 重新执行"
+		j.FinishedAt = time.Now().UTC()
+		_ = regjob.Save(sd, j)
+	}
+	if requeued > 0 {
+		log.Printf("[registration] 已恢复 %d 个排队中的注册任务", requeued)
+	}
+}
+
+// enqueueRegistration 保存任务与 Token 后放入等待队列并发布 registration.queued；队列已满时任务记为 failed 并返回 errQueueFull
+func enqueueRegistration(cfg *config.Config, j *regjob.Job, token string) error {
+	sd := cfg.Runners.StateDir()
+	if err := regjob.SaveToken(sd, j.ID, token); err != nil {
+		return err
+	}
+	if err := regjob.Save(sd, j); err != nil {
+		return err
+	}
+	if !pushPending(j) {
+		j.State = regjob.StateFailed
+		j.Error = errQueueFull.Error()
+		j.FinishedAt = time.Now().UTC()
+		_ = regjob.Save(sd, j)
+		return errQueueFull
+	}
+	events.Publish(events.TypeRegistrationQueued, j.Runner, map[string]any{"job_id": j.ID, "queue_depth": registrationQueueDepth()})
+	return nil
+}
+
+// registrationEnqueueError 将入队失败转为 HTTP 响应
+func registrationEnqueueError(c echo.Context, name string, err error) error {
+	if errors.Is(err, errQueueFull) {
+		return c.JSON(http.StatusServiceUnavailable, map[string]any{"message": err.Error(), "name": name})
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, "保存注册任务失败: "+err.Error())
+}
+
+// claimRegistrationJob 领取 queued 任务：登记取消函数并切换到执行状态；任务已取消或不存在时返回 nil
+func claimRegistrationJob(sd, id string, cancel context.CancelFunc) *regjob.Job {
+	jobMu.Lock()
+	defer jobMu.Unlock()
+	j, err := regjob.Get(sd, id)
+	if err != nil || j.State != regjob.StateQueued {
+		return nil
+	}
+	j.StartedAt = time.Now().UTC()
+	runningJobs[id] = cancel
+	return j
+}
+
+func releaseRegistrationJob(id string) {
+	jobMu.Lock()
+	defer jobMu.Unlock()
+	delete(runningJobs, id)
+}
+
+// runRegistrationJob 执行单次安装+注册+启动（在后台 goroutine 中调用），每个阶段写入任务状态
+func runRegistrationJob(id string) {
+	cfg, err := config.Load(ConfigPath)
+	if err != nil {
+		log.Printf("[registration] %s 加载配置失败: %v", id, err)
+		return
+	}
+	sd := cfg.Runners.StateDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	j := claimRegistrationJob(sd, id, cancel)
+	if j == nil {
+		return
+	}
+	defer releaseRegistrationJob(id)
+	metrics.RegistrationAttempts.Inc(j.Runner)
+	events.Publish(events.TypeRegistrationStarted, j.Runner, map[string]any{"job_id": j.ID})
+	setState := func(state string) {
+		j.State = state
+		_ = regjob.Save(sd, j)
+	}
+	token := regjob.LoadToken(sd, j.ID)
+	fail := func(stage, msg string) {
+		j.FinishedAt = time.Now().UTC()
+		if ctx.Err() == context.Canceled {
+			j.State = regjob.StateCancelled
+			j.Error = "任务已取消"
+			_ = regjob.Save(sd, j)
+			writeRegistrationResult(j.InstallDir, false, "注册任务已取消")
+			events.Publish(events.TypeRegistrationFailed, j.Runner, map[string]any{"job_id": j.ID, "stage": stage, "message": j.Error, "cancelled": true})
+			log.Printf("[registration] %s 任务 %s 已取消", j.Runner, j.ID)
+			return
+		}
+		msg = regjob.RedactOutput([]byte(msg), token)
+		j.State = regjob.StateFailed
+		j.Error = msg
+		_ = regjob.Save(sd, j)
+		writeRegistrationResult(j.InstallDir, false, msg)
+		registrationFailed(j.Runner, j.ID, stage, msg)
+		log.Printf("[registration] %s 任务 %s 失败（%s）: %s", j.Runner, j.ID, stage, msg)
+	}
+	if token == "" {
+		fail("config", "未找到注册 Token，请通过 retry 提供新的 registration_token")
+		return
+	}
+
+	installDir := j.InstallDir
+	configScript := filepath.Join(installDir, runner.ConfigScriptName())
+	if _, err := os.Stat(configScript); err != nil {
+		setState(regjob.StateInstalling)
+		installOut, version, installErr := installRunner(ctx, cfg, j.Runner, installDir, 15*time.Minute)
+		j.RunnerVersion = version
+		j.InstallOutput = regjob.RedactOutput(installOut, token)
+		if installErr != nil {
+			fail("install", "自动安装 Runner 失败: "+installErr.Error())
+			return
+		}
+		if _, err2 := os.Stat(configScript); err2 != nil {
+			fail("install", "安装完成但未找到 "+runner.ConfigScriptName())
+			return
+		}
+	}
+
+	setState(regjob.StateConfiguring)
+	out, err := runConfigScript(ctx, installDir, j.URL, token, j.Labels, 2*time.Minute)
+	j.ConfigOutput = regjob.RedactOutput(out, token)
+	if err != nil {
+		msg := strings.TrimSpace(string(out))
+		if msg == "" {
+			msg = err.Error()
+		}
+		if strings.Contains(string(out), "Must not run with sudo") {
+			msg += "（请以非 root 用户运行容器，或设置环境变量 RUNNER_ALLOW_RUNASROOT=1）"
+		}
+		outLower := strings.ToLower(string(out))
+		if strings.Contains(outLower, "token") &&
+			(strings.Contains(outLower, "invalid") || strings.Contains(outLower, "expired") ||
+				strings.Contains(outLower, "already") || strings.Contains(outLower, "used")) {
+			msg += "。请为每个 Runner 在 GitHub 重新生成新的注册 Token"
+		}
+		fail("config", msg)
+		return
+	}
+	// 注册 Token 为一次性，成功后即删除
+	regjob.DeleteToken(sd, j.ID)
+	writeRegistrationResult(installDir, true, "注册成功")
+	events.Publish(events.TypeRegistrationSucceeded, j.Runner, map[string]any{"job_id": j.ID, "message": "注册成功"})
+
+	setState(regjob.StateStarting)
+	startCtx, startCancel := context.WithTimeout(ctx, 60*time.Second)
+	defer startCancel()
+	if startErr := runner.StartIfInstalled(startCtx, cfg, j.Runner, installDir); startErr != nil {
+		// 注册已成功，任务记为 done 并保留启动错误，可在界面手动启动
+		j.Error = "注册成功但启动失败: " + startErr.Error()
+		log.Printf("[registration] %s 注册成功但启动失败: %v", j.Runner, startErr)
+	} else {
+		log.Printf("[registration] %s 已注册并启动", j.Runner)
+	}
+	j.FinishedAt = time.Now().UTC()
+	setState(regjob.StateDone)
+}
+
+// registrationFailed 记录注册失败指标并发布 registration.failed；stage 为 install 或 config
+func registrationFailed(runnerName, jobID, stage, msg string) {
+	metrics.RegistrationFailures.Inc(runnerName, stage)
+	events.Publish(events.TypeRegistrationFailed, runnerName, map[string]any{"job_id": jobID, "stage": stage, "message": msg})
+}
+
+// ListRegistrationJobs 列出注册任务（GET /api/jobs?runner=&state=），按创建时间倒序；列表中不含脚本输出，详见单个任务
+func ListRegistrationJobs(c echo.Context) error {
+	cfg, err := getConfig(c)
+	if err != nil {
+		return err
+	}
+	all := regjob.List(cfg.Runners.StateDir(), strings.TrimSpace(c.QueryParam("runner")), strings.TrimSpace(c.QueryParam("state")))
+	jobs := make([]*regjob.Job, 0, len(all))
+	for _, j := range all {
+		if !jobAccessible(c, cfg, j) {
+			continue
+		}
+		j.InstallOutput, j.ConfigOutput = "", ""
+		jobs = append(jobs, j)
+	}
+	return c.JSON(http.StatusOK, map[string]any{"jobs": jobs, "total_count": len(jobs)})
+}
+
+// GetRegistrationJob 查看单个注册任务（GET /api/jobs/:id），含脱敏后的安装与注册输出
+func GetRegistrationJob(c echo.Context) error {
+	cfg, err := getConfig(c)
+	if err != nil {
+		return err
+	}
+	j, err := regjob.Get(cfg.Runners.StateDir(), c.Param("id"))
+	if err == nil && !jobAccessible(c, cfg, j) {
+		err = regjob.ErrNotFound
+	}
+	if err != nil {
+		return registrationJobError(err)
+	}
+	return c.JSON(http.StatusOK, j)
+}
+
+// CancelRegistrationJob 取消注册任务（POST /api/jobs/:id/cancel）：排队中的任务立即取消，执行中的任务中止当前脚本
+func CancelRegistrationJob(c echo.Context) error {
+	cfg, err := getConfig(c)
+	if err != nil {
+		return err
+	}
+	sd := cfg.Runners.StateDir()
+	jobMu.Lock()
+	defer jobMu.Unlock()
+	j, err := regjob.Get(sd, c.Param("id"))
+	if err == nil && !jobAccessible(c, cfg, j) {
+		err = regjob.ErrNotFound
+	}
+	if err != nil {
+		return registrationJobError(err)
+	}
+	if j.Terminal() {
+		return echo.NewHTTPError(http.StatusConflict, "任务已结束: "+j.State)
+	}
+	if cancel, ok := runningJobs[j.ID]; ok {
+		cancel()
+		return c.JSON(http.StatusAccepted, map[string]any{"message": "已发送取消，当前步骤中止后任务将标记为 cancelled", "job": j})
+	}
+	removePending(j.ID)
+	j.State = regjob.StateCancelled
+	j.Error = "任务已取消"
+	j.FinishedAt = time.Now().UTC()
+	if err := regjob.Save(sd, j); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "保存任务失败: "+err.Error())
+	}
+	events.Publish(events.TypeRegistrationFailed, j.Runner, map[string]any{"job_id": j.ID, "stage": "queued", "message": j.Error, "cancelled": true})
+	return c.JSON(http.StatusOK, map[string]any{"message": "已取消", "job": j})
+}
+
+// RetryRegistrationRequest 重试请求；registration_token 为空时沿用原任务保存的 Token
+type RetryRegistrationRequest struct {
+	RegistrationToken string `json:"registration_token" form:"registration_token"`
+}
+
+// RetryRegistrationJob 按 runner 当前配置新建任务并入队（POST /api/jobs/:id/retry），仅 failed/cancelled 任务可重试；runner 已移除时返回 404
+func RetryRegistrationJob(c echo.Context) error {
+	cfg, err := getConfig(c)
+	if err != nil {
+		return err
+	}
+	sd := cfg.Runners.StateDir()
+	old, err := regjob.Get(sd, c.Param("id"))
+	if err == nil && !jobAccessible(c, cfg, old) {
+		err = regjob.ErrNotFound
+	}
+	if err != nil {
+		return registrationJobError(err)
+	}
+	if old.State != regjob.StateFailed && old.State != regjob.StateCancelled {
+		return echo.NewHTTPError(http.StatusConflict, "仅 failed 或 cancelled 的任务可重试，当前状态: "+old.State)
+	}
+	idx := slices.IndexFunc(cfg.Runners.Items, func(i config.RunnerItem) bool { return i.Name == old.Runner })
+	if idx < 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "runner 已不存在: "+old.Runner)
+	}
+	item := cfg.Runners.Items[idx]
+	var req RetryRegistrationRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "参数错误: "+err.Error())
+	}
+	token := strings.TrimSpace(req.RegistrationToken)
+	if token == "" {
+		token = regjob.LoadToken(sd, old.ID)
+	}
+	if token == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "原任务的注册 Token 已不可用，请提供 registration_token")
+	}
+	// 按 runner 当前的配置重建任务：原任务之后修改过的 target、labels 或 path 以最新值为准
+	installDir, err := runner.EnsureRunnerDir(cfg, item.Name, item.Path)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "创建目录失败: "+err.Error())
+	}
+	j := regjob.New(item.Name, installDir, "https://github.com/"+item.Target, item.Labels)
+	j.RetryOf = old.ID
+	if err := enqueueRegistration(cfg, j, token); err != nil {
+		return registrationEnqueueError(c, old.Runner, err)
+	}
+	regjob.DeleteToken(sd, old.ID)
+	return c.JSON(http.StatusAccepted, map[string]any{"message": "已重新排队", "job": j})
+}
+
+// jobAccessible 调用者能否查看与操作注册任务：受 auth.access 限制时只能看到配置中可管理的 runner 的任务
+func jobAccessible(c echo.Context, cfg *config.Config, j *regjob.Job) bool {
+	return fullAccess(c, cfg) || runnerAccessible(c, cfg, j.Runner)
+}
+
+func registrationJobError(err error) error {
+	if errors.Is(err, regjob.ErrNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "未找到该任务")
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, "读取任务失败: "+err.Error())
+}
@@ -0,0 +1,305 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"log"
+	"maps"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/lab-dev/github-actions-runner-manager/internal/events"
+	"github.com/lab-dev/github-actions-runner-manager/internal/metrics"
+	"github.com/lab-dev/github-actions-runner-manager/internal/runner"
+)
+
+// ConfigWatchInterval 检查 config.yaml 是否被外部修改的间隔
+const ConfigWatchInterval = 5 * time.Second
+
+// ReconcileResult 按新旧配置对齐 runner 的结果
+type ReconcileResult struct {
+	Stopped   []string          `json:"stopped"`   // 不再存在而被停止（容器模式下删除容器）的 runner
+	Started   []string          `json:"started"`   // 新出现且已注册而被启动的 runner
+	Recreated []string          `json:"recreated"` // 路径、架构或容器设置变化，按新配置重建并启动的 runner
+	Updated   []string          `json:"updated"`   // 其他配置变化（如 labels、target），在下次注册时生效
+	Errors    map[string]string `json:"errors,omitempty"`
+}
+
+// 按新配置对齐时对单个 runner 的动作
+const (
+	ActionAdd      = "add"      // 新增，未注册或已在运行，不做操作
+	ActionStart    = "start"    // 新增且已注册，启动
+	ActionRecreate = "recreate" // 正在运行且运行方式变化，删除旧实例后按新配置启动
+	ActionUpdate   = "update"   // 配置变化；运行方式变化但未运行时删除旧实例（容器），其余在下次注册时生效
+	ActionStop     = "stop"     // 已移除且正在运行，停止（容器模式下删除容器）
+	ActionRemove   = "remove"   // 已移除且未运行，容器模式下删除残留容器
+)
+
+// RunnerAction 对单个 runner 的动作
+type RunnerAction struct {
+	Runner     string   `json:"runner"`
+	Action     string   `json:"action"`
+	Fields     []string `json:"fields,omitempty"`  // runner 配置中变化的字段
+	Runtime    []string `json:"runtime,omitempty"` // 导致重建的运行方式变化
+	InstallDir string   `json:"install_dir"`
+	PrevDir    string   `json:"prev_install_dir,omitempty"` // 安装目录变化时的原目录，保留在磁盘上
+	Registered bool     `json:"registered"`                 // 新配置下的安装目录是否已注册（移除的 runner 为原目录）
+	Running    bool     `json:"running"`                    // 当前是否在运行
+	Note       string   `json:"note,omitempty"`
+}
+
+// runtimeChanges 返回新旧配置下 runner 运行方式的变化（非空时需重建进程或容器）
+func runtimeChanges(prev, next *config.Config, a, b config.RunnerItem) []string {
+	var out []string
+	p, n := prev.Runners, next.Runners
+	if p.ContainerMode != n.ContainerMode {
+		out = append(out, "container_mode")
+	}
+	if a.InstallPath(p.BasePath) != b.InstallPath(n.BasePath) {
+		out = append(out, "install_dir")
+	}
+	if p.EffectiveArch(a) != n.EffectiveArch(b) {
+		out = append(out, "arch")
+	}
+	if !n.ContainerMode || !p.ContainerMode {
+		return out
+	}
+	if p.ContainerImageFor(p.EffectiveArch(a)) != n.ContainerImageFor(n.EffectiveArch(b)) {
+		out = append(out, "container_image")
+	}
+	for _, f := range []struct {
+		name       string
+		prev, next any
+	}{
+		{"container_network", p.ContainerNetwork, n.ContainerNetwork},
+		{"job_docker_backend", p.JobDockerBackend, n.JobDockerBackend},
+		{"dind_host", p.DindHost, n.DindHost},
+		{"volume_host_path", p.VolumeHostPath, n.VolumeHostPath},
+		{"agent_port", p.AgentPort, n.AgentPort},
+	} {
+		if f.prev != f.next {
+			out = append(out, f.name)
+		}
+	}
+	return out
+}
+
+// planRunners 计算从 prev 切换到 next 时对各 runner 的动作，按 next 中的顺序列出，移除的在最后；只读取状态，不做修改
+func planRunners(ctx context.Context, prev, next *config.Config) []RunnerAction {
+	actions := []RunnerAction{}
+	old := map[string]config.RunnerItem{}
+	for _, item := range prev.Runners.Items {
+		old[item.Name] = item
+	}
+	for _, item := range next.Runners.Items {
+		a := RunnerAction{Runner: item.Name, InstallDir: item.InstallPath(next.Runners.BasePath)}
+		if info := runner.GetByName(next, item.Name); info != nil {
+			a.Registered = info.Status == runner.StatusInstalled
+		}
+		before, existed := old[item.Name]
+		delete(old, item.Name)
+		if !existed {
+			a.Running = fleetOps.Running(ctx, next, item)
+			switch {
+			case a.Running:
+				a.Action = ActionAdd
+			case a.Registered:
+				a.Action = ActionStart
+			default:
+				a.Action, a.Note = ActionAdd, "未注册，需提交注册令牌"
+			}
+			actions = append(actions, a)
+			continue
+		}
+		a.Fields = config.ChangedFields(before, item)
+		a.Runtime = runtimeChanges(prev, next, before, item)
+		if len(a.Fields) == 0 && len(a.Runtime) == 0 {
+			continue
+		}
+		a.Action = ActionUpdate
+		if len(a.Runtime) > 0 {
+			a.Running = fleetOps.Running(ctx, prev, before)
+			if a.Running {
+				a.Action = ActionRecreate
+			}
+			if oldDir := before.InstallPath(prev.Runners.BasePath); oldDir != a.InstallDir {
+				a.PrevDir = oldDir
+				if !a.Registered {
+					a.Note = "新目录未注册，需重新注册"
+				}
+			}
+		}
+		actions = append(actions, a)
+	}
+	for _, item := range prev.Runners.Items {
+		if _, removed := old[item.Name]; !removed {
+			continue
+		}
+		a := RunnerAction{Runner: item.Name, Action: ActionRemove, InstallDir: item.InstallPath(prev.Runners.BasePath)}
+		if info := runner.GetByName(prev, item.Name); info != nil {
+			a.Registered = info.Status == runner.StatusInstalled
+		}
+		if a.Running = fleetOps.Running(ctx, prev, item); a.Running {
+			a.Action = ActionStop
+		}
+		actions = append(actions, a)
+	}
+	return actions
+}
+
+// reconcileRunners 按新旧配置对齐 runner 的运行状态并发布事件：
+// 停止并清理已移除的，启动新出现且已注册的，运行方式变化的按新配置重建。runner 目录始终保留
+func reconcileRunners(prev, next *config.Config) ReconcileResult {
+	res := ReconcileResult{Stopped: []string{}, Started: []string{}, Recreated: []string{}, Updated: []string{}, Errors: map[string]string{}}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	items := map[string]config.RunnerItem{}
+	for _, item := range prev.Runners.Items {
+		items[item.Name] = item
+	}
+	olds := maps.Clone(items)
+	for _, item := range next.Runners.Items {
+		items[item.Name] = item
+	}
+	for _, a := range planRunners(ctx, prev, next) {
+		item, before := items[a.Runner], olds[a.Runner]
+		switch a.Action {
+		case ActionAdd, ActionStart:
+			events.Publish(events.TypeRunnerAdded, item.Name, map[string]any{
+				"name":        item.Name,
+				"target_type": item.TargetType,
+				"target":      item.Target,
+				"labels":      item.Labels,
+				"install_dir": a.InstallDir,
+			})
+			if a.Action == ActionAdd {
+				continue
+			}
+			if err := fleetOps.Start(ctx, next, item); err != nil {
+				res.Errors[item.Name] = "启动失败: " + err.Error()
+				continue
+			}
+			res.Started = append(res.Started, item.Name)
+		case ActionRecreate, ActionUpdate:
+			if len(a.Runtime) > 0 {
+				if err := fleetOps.Remove(ctx, prev, before); err != nil {
+					res.Errors[item.Name] = "停止旧实例失败: " + err.Error()
+				} else if a.Action == ActionRecreate {
+					if err := fleetOps.Start(ctx, next, item); err != nil {
+						res.Errors[item.Name] = "按新配置启动失败: " + err.Error()
+					} else {
+						res.Recreated = append(res.Recreated, item.Name)
+					}
+				} else {
+					res.Updated = append(res.Updated, item.Name)
+				}
+			} else {
+				res.Updated = append(res.Updated, item.Name)
+			}
+			events.Publish(events.TypeRunnerUpdated, item.Name, runner.GetByName(next, item.Name))
+		case ActionStop, ActionRemove:
+			if err := fleetOps.Remove(ctx, prev, before); err != nil {
+				res.Errors[item.Name] = "停止失败: " + err.Error()
+			} else if a.Action == ActionStop {
+				res.Stopped = append(res.Stopped, item.Name)
+			}
+			forgetObserved(item.Name)
+			events.Publish(events.TypeRunnerRemoved, item.Name, nil)
+		}
+	}
+	return res
+}
+
+// configWatch 热加载的基准：最近一次生效的配置及其文件内容摘要
+var configWatch struct {
+	mu      sync.Mutex
+	applied *config.Config
+	sum     [32]byte
+}
+
+// RunConfigWatcher 定时检查 config.yaml，被外部修改（如 Ansible 下发）时校验并按差异对齐 runner。
+// 新配置无效时记录错误并继续使用上一份配置；应在 main 中以 goroutine 启动一次
+func RunConfigWatcher(interval time.Duration) {
+	reloadConfig() // 首次只记录基准
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		reloadConfig()
+	}
+}
+
+// reloadConfig 文件内容变化时加载并对齐；尚无基准时只记录基准。本进程自身的写入已由对应操作处理 runner，
+// 不重复对齐，但其前后的外部修改（如外部编辑后尚未对齐就被 API 写入）仍按差异对齐，见 externalChanges
+func reloadConfig() {
+	data, err := os.ReadFile(ConfigPath)
+	if err != nil {
+		return
+	}
+	sum := sha256.Sum256(data)
+	configWatch.mu.Lock()
+	defer configWatch.mu.Unlock()
+	if sum == configWatch.sum {
+		return
+	}
+	prevSum := configWatch.sum
+	configWatch.sum = sum
+	next, err := config.Parse(data)
+	if err != nil {
+		log.Printf("[config] config.yaml 已变更但无法加载，继续使用上一份配置: %v", err)
+		metrics.ConfigReloads.Inc("error")
+		events.Publish(events.TypeConfigReloadFailed, "", map[string]any{"message": err.Error()})
+		return
+	}
+	prev := configWatch.applied
+	configWatch.applied = next
+	writes := config.TakeWrites(ConfigPath)
+	if prev == nil {
+		return
+	}
+	steps := externalChanges(prev, prevSum, next, sum, writes)
+	if len(steps) == 0 {
+		return
+	}
+	res := ReconcileResult{Stopped: []string{}, Started: []string{}, Recreated: []string{}, Updated: []string{}, Errors: map[string]string{}}
+	for _, step := range steps {
+		r := reconcileRunners(step[0], step[1])
+		res.Stopped = append(res.Stopped, r.Stopped...)
+		res.Started = append(res.Started, r.Started...)
+		res.Recreated = append(res.Recreated, r.Recreated...)
+		res.Updated = append(res.Updated, r.Updated...)
+		maps.Copy(res.Errors, r.Errors)
+	}
+	metrics.ConfigReloads.Inc("ok")
+	var failed []string
+	for name, msg := range res.Errors {
+		failed = append(failed, name+": "+msg)
+	}
+	log.Printf("[config] 已加载外部修改的 config.yaml：启动 %v，停止 %v，重建 %v，更新 %v；失败 %s",
+		res.Started, res.Stopped, res.Recreated, res.Updated, strings.Join(failed, "; "))
+	events.Publish(events.TypeConfigReloaded, "", res)
+}
+
+// externalChanges 按本进程的写入记录把 prev 到 next 的变化拆成需要对齐的外部修改，每项为 [修改前, 修改后]：
+// 写入者读取到的内容与上一次已知内容不同时，其间为外部修改；写入本身跳过。最后一次写入之后的变化同样是外部修改。
+// 直接调用 Save 的写入不知道读取前的内容，视为已处理
+func externalChanges(prev *config.Config, prevSum [32]byte, next *config.Config, nextSum [32]byte, writes []config.Write) [][2]*config.Config {
+	var steps [][2]*config.Config
+	cur, curSum := prev, prevSum
+	for _, w := range writes {
+		if w.Loaded != nil && sha256.Sum256(w.Loaded) != curSum {
+			if loaded, err := config.Parse(w.Loaded); err == nil {
+				steps = append(steps, [2]*config.Config{cur, loaded})
+			}
+		}
+		if saved, err := config.Parse(w.Saved); err == nil {
+			cur, curSum = saved, sha256.Sum256(w.Saved)
+		}
+	}
+	if curSum != nextSum {
+		steps = append(steps, [2]*config.Config{cur, next})
+	}
+	return steps
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/lab-dev/github-actions-runner-manager/internal/events"
+	"github.com/lab-dev/github-actions-runner-manager/internal/metrics"
+)
+
+func TestReloadConfig(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	write := func(items string) {
+		t.Helper()
+		data := "runners:\n  base_path: " + dir + "\n  items:\n" + items
+		if err := os.WriteFile(cfgPath, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("    - {name: r1, target_type: org, target: o1}\n    - {name: r2, target_type: org, target: o1}\n")
+	for _, name := range []string{"r1", "r2", "r3"} {
+		_ = os.MkdirAll(filepath.Join(dir, name), 0755)
+		_ = os.WriteFile(filepath.Join(dir, name, ".runner"), []byte("{}"), 0644)
+	}
+	ConfigPath = cfgPath
+	defer func() { ConfigPath = filepath.Join(os.TempDir(), "handler-test-config.yaml") }()
+	configWatch.applied = nil
+	defer func() { configWatch.applied = nil }()
+
+	fake := &fakeOps{running: map[string]bool{"r1": true, "r2": true}}
+	savedOps := fleetOps
+	fleetOps = fake
+	defer func() { fleetOps = savedOps }()
+	ch, _, cancel := events.Default.Subscribe(0)
+	defer cancel()
+
+	reloadConfig() // 基准
+	// 外部编辑：移除 r2、新增已注册的 r3、r1 改架构需重建
+	write("    - {name: r1, target_type: org, target: o1, arch: arm64}\n    - {name: r3, target_type: org, target: o1}\n")
+	reloadConfig()
+	if got := strings.Join(fake.ops, ","); got != "remove r1,start r1,start r3,remove r2" {
+		t.Errorf("ops = %s", got)
+	}
+	var reloaded *ReconcileResult
+	for len(ch) > 0 {
+		if ev := <-ch; ev.Type == events.TypeConfigReloaded {
+			res := ev.Data.(ReconcileResult)
+			reloaded = &res
+		}
+	}
+	if reloaded == nil || !slices.Equal(reloaded.Recreated, []string{"r1"}) || !slices.Equal(reloaded.Stopped, []string{"r2"}) {
+		t.Errorf("config.reloaded event = %+v", reloaded)
+	}
+
+	// 本进程自身写入（由对应 API 自行处理 runner）不重复对齐
+	fake.ops = nil
+	if err := config.LoadAndSave(cfgPath, func(c *config.Config) error {
+		c.Runners.Items = c.Runners.Items[:1]
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	reloadConfig()
+	if len(fake.ops) != 0 {
+		t.Errorf("own write reconciled: %v", fake.ops)
+	}
+
+	// 外部编辑尚未对齐时本进程又写入：外部新增的 r4 仍要启动，本进程新增的 r2 不处理
+	fake.ops = nil
+	_ = os.MkdirAll(filepath.Join(dir, "r4"), 0755)
+	_ = os.WriteFile(filepath.Join(dir, "r4", ".runner"), []byte("{}"), 0644)
+	write("    - {name: r1, target_type: org, target: o1, arch: arm64}\n    - {name: r4, target_type: org, target: o1}\n")
+	if err := config.LoadAndSave(cfgPath, func(c *config.Config) error {
+		c.Runners.Items = append(c.Runners.Items, config.RunnerItem{Name: "r2", TargetType: "org", Target: "o1"})
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	reloadConfig()
+	if got := strings.Join(fake.ops, ","); got != "start r4" {
+		t.Errorf("external edit before own write: ops = %s", got)
+	}
+	if names := len(configWatch.applied.Runners.Items); names != 3 {
+		t.Errorf("applied config has %d runners, want 3", names)
+	}
+
+	// 无效配置：报告错误，保留上一份配置
+	fake.ops = nil
+	errorsBefore := metrics.ConfigReloads.Value("error")
+	write("    - {name: ../bad, target_type: org, target: o1}\n")
+	reloadConfig()
+	if metrics.ConfigReloads.Value("error") != errorsBefore+1 || len(fake.ops) != 0 {
+		t.Errorf("invalid config: errors=%v ops=%v", metrics.ConfigReloads.Value("error"), fake.ops)
+	}
+	if configWatch.applied.Runners.Items[0].Arch != config.ArchARM64 {
+		t.Error("last good config should stay applied")
+	}
+}
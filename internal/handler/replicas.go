@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/lab-dev/github-actions-runner-manager/internal/events"
+	"github.com/lab-dev/github-actions-runner-manager/internal/regjob"
+	"github.com/lab-dev/github-actions-runner-manager/internal/runner"
+	"github.com/labstack/echo/v4"
+)
+
+// maxReplicas 单次请求最多创建的 runner 数
+const maxReplicas = 50
+
+// ReplicaResult 批量添加中单个 runner 的结果
+type ReplicaResult struct {
+	Name       string `json:"name"`
+	InstallDir string `json:"install_dir"`
+	Queued     bool   `json:"queued"`
+	JobID      string `json:"job_id,omitempty"`
+	Error      string `json:"error,omitempty"` // 已添加但未能排队注册的原因
+}
+
+// addRunnerReplicas 按 base 创建 name-1..name-N：名称冲突时整体拒绝，一次写入配置；
+// 每个 runner 使用请求中的注册 Token，未提供时各自申请一个，再分别排队注册
+func addRunnerReplicas(c echo.Context, cfg *config.Config, req *AddRunnerRequest, base config.RunnerItem) error {
+	if req.Count < 1 || req.Count > maxReplicas {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("count 须在 1 到 %d 之间", maxReplicas))
+	}
+	if req.Path != "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "count 不可与 path 同时使用，各 runner 的目录与名称相同")
+	}
+	if _, err := normalizeRunnerVersionParam(base.RunnerVersion); err != nil {
+		return err
+	}
+	items := make([]config.RunnerItem, req.Count)
+	names := make([]string, req.Count)
+	for i := range items {
+		items[i] = base
+		items[i].Name = fmt.Sprintf("%s-%d", base.Name, i+1)
+		items[i].Labels = append([]string(nil), base.Labels...)
+		names[i] = items[i].Name
+	}
+	c.Set(auditRunnerKey, strings.Join(names, ","))
+	conflicts := func(cfg *config.Config) error {
+		var taken []string
+		for _, name := range names {
+			if runnerNameExists(cfg, name) {
+				taken = append(taken, name)
+			}
+		}
+		if len(taken) > 0 {
+			return echo.NewHTTPError(http.StatusConflict, "已存在同名 runner: "+strings.Join(taken, ", "))
+		}
+		return nil
+	}
+	if err := conflicts(cfg); err != nil {
+		return err
+	}
+	// 配置写入失败（如并发请求抢先占用了名称）时删除本次新建的目录，已存在的目录保留
+	var created []string
+	removeCreated := func() {
+		for _, dir := range created {
+			_ = os.Remove(dir)
+		}
+	}
+	results := make([]ReplicaResult, len(items))
+	for i, item := range items {
+		_, statErr := os.Stat(item.InstallPath(cfg.Runners.BasePath))
+		installDir, err := runner.EnsureRunnerDir(cfg, item.Name, item.Path)
+		if err != nil {
+			removeCreated()
+			return echo.NewHTTPError(http.StatusInternalServerError, "创建目录失败: "+err.Error())
+		}
+		if os.IsNotExist(statErr) {
+			created = append(created, installDir)
+		}
+		results[i] = ReplicaResult{Name: item.Name, InstallDir: installDir}
+	}
+	note := "runner.add " + names[0]
+	if len(names) > 1 {
+		note += ".." + names[len(names)-1]
+	}
+	if err := config.LoadAndSaveBy(ConfigPath, CurrentPrincipal(c).Name, note, func(cfg *config.Config) error {
+		if err := conflicts(cfg); err != nil {
+			return err
+		}
+		cfg.Runners.Items = append(cfg.Runners.Items, items...)
+		return nil
+	}); err != nil {
+		removeCreated()
+		if he, ok := err.(*echo.HTTPError); ok {
+			return he
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "保存配置失败: "+err.Error())
+	}
+	queued := 0
+	for i, item := range items {
+		r := &results[i]
+		events.Publish(events.TypeRunnerAdded, item.Name, map[string]any{
+			"name":        item.Name,
+			"target_type": item.TargetType,
+			"target":      item.Target,
+			"labels":      item.Labels,
+			"install_dir": r.InstallDir,
+		})
+		token, err := registrationTokenFor(c.Request().Context(), req.RegistrationToken, item)
+		if err != nil {
+			r.Error = err.Error()
+			continue
+		}
+		if token == "" {
+			continue
+		}
+		job := regjob.New(item.Name, r.InstallDir, "https://github.com/"+item.Target, item.Labels)
+		if err := enqueueRegistration(cfg, job, token); err != nil {
+			if !errors.Is(err, errQueueFull) {
+				err = errors.New("保存注册任务失败: " + err.Error())
+			}
+			r.Error = err.Error()
+			continue
+		}
+		r.Queued, r.JobID = true, job.ID
+		queued++
+	}
+	msg := fmt.Sprintf("已添加 %d 个 runner", len(items))
+	if queued > 0 {
+		msg += fmt.Sprintf("，其中 %d 个正在后台安装并注册，完成后页面会自动更新", queued)
+	}
+	return c.JSON(http.StatusOK, map[string]any{
+		"message":      msg,
+		"names":        names,
+		"runners":      results,
+		"queued_count": queued,
+	})
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/lab-dev/github-actions-runner-manager/internal/githubcheck"
+	"github.com/lab-dev/github-actions-runner-manager/internal/regjob"
+	"github.com/labstack/echo/v4"
+)
+
+func TestAddRunner_Replicas(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	cfg := &config.Config{Runners: config.RunnersConfig{BasePath: dir, Items: []config.RunnerItem{
+		{Name: "ci-2", TargetType: "org", Target: "o1"},
+	}}}
+	_ = cfg.Save(cfgPath)
+	ConfigPath = cfgPath
+	defer func() { ConfigPath = filepath.Join(os.TempDir(), "handler-test-config.yaml") }()
+	// 未启动 worker：任务停留在 queued，测试结束时清空队列
+	defer func() {
+		jobMu.Lock()
+		pendingJobs = nil
+		jobMu.Unlock()
+	}()
+
+	var minted atomic.Int32
+	gh := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/orgs/o1/actions/runners/registration-token" || r.Header.Get("Authorization") != "Bearer pat" {
+			http.Error(w, "unexpected", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = fmt.Fprintf(w, `{"token":"REG%d","expires_at":"2030-01-01T00:00:00Z"}`, minted.Add(1))
+	}))
+	defer gh.Close()
+	prevBase := githubcheck.APIBase
+	githubcheck.APIBase = gh.URL
+	defer func() { githubcheck.APIBase = prevBase; GitHubAdminToken = "" }()
+
+	e := echo.New()
+	e.Use(Auth("", ""))
+	e.POST("/api/runners", AddRunner)
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/runners", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := post(`{"name":"ci","target_type":"org","target":"o1","count":3}`); rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), "ci-2") {
+		t.Fatalf("collision: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := post(`{"name":"ci","target_type":"org","target":"o1","count":51}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("count too large: %d", rec.Code)
+	}
+	if rec := post(`{"name":"ci","target_type":"org","target":"o1","count":2,"path":"x"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("count with path: %d", rec.Code)
+	}
+
+	// 未配置管理 Token 且未提供注册 Token：只写入配置
+	rec := post(`{"name":"web","target_type":"org","target":"o1","labels":["gpu"],"count":2}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("add: %d %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Names       []string        `json:"names"`
+		Runners     []ReplicaResult `json:"runners"`
+		QueuedCount int             `json:"queued_count"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+	if !slices.Equal(resp.Names, []string{"web-1", "web-2"}) || resp.QueuedCount != 0 {
+		t.Fatalf("resp = %+v", resp)
+	}
+
+	// 配置管理 Token 后每个 runner 各自申请注册 Token 并排队
+	GitHubAdminToken = "pat"
+	rec = post(`{"name":"job","target_type":"org","target":"o1","count":3}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("add: %d %s", rec.Code, rec.Body.String())
+	}
+	resp.Runners = nil
+	_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.QueuedCount != 3 || minted.Load() != 3 {
+		t.Fatalf("queued = %d, minted = %d", resp.QueuedCount, minted.Load())
+	}
+	sd := cfg.Runners.StateDir()
+	tokens := map[string]bool{}
+	for _, r := range resp.Runners {
+		if !r.Queued || r.JobID == "" {
+			t.Fatalf("runner %+v not queued", r)
+		}
+		tokens[regjob.LoadToken(sd, r.JobID)] = true
+	}
+	if len(tokens) != 3 {
+		t.Errorf("tokens should be distinct per runner: %v", tokens)
+	}
+
+	saved, err := config.Load(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, it := range saved.Runners.Items {
+		names = append(names, it.Name)
+		if it.Name == "web-2" && !slices.Equal(it.Labels, []string{"gpu"}) {
+			t.Errorf("web-2 labels = %v", it.Labels)
+		}
+	}
+	if !slices.Equal(names, []string{"ci-2", "web-1", "web-2", "job-1", "job-2", "job-3"}) {
+		t.Errorf("config runners = %v", names)
+	}
+}
+
+func TestAddRunnerReplicas_RemovesDirsOnConflict(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	stale := &config.Config{Runners: config.RunnersConfig{BasePath: dir}}
+	_ = (&config.Config{Runners: config.RunnersConfig{BasePath: dir, Items: []config.RunnerItem{
+		{Name: "ci-2", TargetType: "org", Target: "o1"},
+	}}}).Save(cfgPath)
+	ConfigPath = cfgPath
+	defer func() { ConfigPath = filepath.Join(os.TempDir(), "handler-test-config.yaml") }()
+	_ = os.MkdirAll(filepath.Join(dir, "ci-2"), 0755)
+
+	// 请求开始时读到的配置中还没有 ci-2，写入前的检查才发现冲突
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/api/runners", nil), httptest.NewRecorder())
+	req := &AddRunnerRequest{Name: "ci", TargetType: "org", Target: "o1", Count: 3}
+	err := addRunnerReplicas(c, stale, req, config.RunnerItem{Name: "ci", TargetType: "org", Target: "o1"})
+	if he, ok := err.(*echo.HTTPError); !ok || he.Code != http.StatusConflict {
+		t.Fatalf("err = %v, want 409", err)
+	}
+	for _, name := range []string{"ci-1", "ci-3"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s should be removed after the conflict: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ci-2")); err != nil {
+		t.Errorf("existing ci-2 dir must be kept: %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package handler
+
+import "github.com/labstack/echo/v4"
+
+// RegisterRoutes 注册全部路由；新增接口时须同时在 apiRoutes 中描述，见 /api/openapi.json
+func RegisterRoutes(e *echo.Echo) {
+	e.GET("/health", Health)
+	e.GET("/version", VersionInfo)
+	e.GET("/metrics", Metrics)
+	e.GET("/", Index)
+	e.GET("/api/openapi.json", OpenAPI)
+	e.GET("/api/runners", ListRunners)
+	e.GET("/api/events", StreamEvents)
+	e.GET("/api/runners/:name", GetRunner)
+	e.POST("/api/runners", AddRunner)
+	e.POST("/api/runners/bulk", BulkRunners)
+	e.PUT("/api/runners/:name", UpdateRunner)
+	e.DELETE("/api/runners/:name", RemoveRunnerByName)
+	e.POST("/api/runners/:name/start", StartRunner)
+	e.POST("/api/runners/:name/stop", StopRunner)
+	e.GET("/api/runners/:name/jobs", ListRunnerJobs)
+	e.GET("/api/jobs", ListRegistrationJobs)
+	e.GET("/api/jobs/:id", GetRegistrationJob)
+	e.POST("/api/jobs/:id/cancel", CancelRegistrationJob)
+	e.POST("/api/jobs/:id/retry", RetryRegistrationJob)
+	e.GET("/auth/login", SSOLogin)
+	e.GET("/auth/callback", SSOCallback)
+	e.POST("/auth/logout", SSOLogout)
+	e.GET("/api/me", Me)
+	e.GET("/api/audit", ListAudit)
+	e.GET("/api/config/history", ListConfigHistory)
+	e.GET("/api/config/history/:rev", GetConfigRevision)
+	e.GET("/api/config/diff", DiffConfig)
+	e.POST("/api/config/rollback/:rev", RollbackConfig)
+	e.POST("/api/config/plan", PlanConfig)
+	e.GET("/api/gitops", GetGitOps)
+	e.POST("/api/gitops/sync", SyncGitOps)
+	e.POST("/api/gitops/apply", ApplyGitOps)
+	e.GET("/api/tokens", ListTokens)
+	e.POST("/api/tokens", CreateToken)
+	e.DELETE("/api/tokens/:id", DeleteToken)
+	e.GET("/api/fleet/upgrade", GetFleetUpgrade)
+	e.POST("/api/fleet/upgrade", StartFleetUpgrade)
+	e.POST("/api/fleet/upgrade/cancel", CancelFleetUpgrade)
+	e.POST("/api/webhooks/github", GitHubWebhook)
+}
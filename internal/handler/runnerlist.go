@@ -0,0 +1,322 @@
+package handler
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/lab-dev/github-actions-runner-manager/internal/runner"
+	"github.com/labstack/echo/v4"
+)
+
+// maxRunnersPerPage GET /api/runners 单页最多返回的 runner 数
+const maxRunnersPerPage = 500
+
+// runnerSortKeys 支持的排序字段；未指定时按配置文件顺序，分页时按名称
+var runnerSortKeys = []string{"name", "status", "target"}
+
+// runnerQuery GET /api/runners 的筛选、排序与分页参数
+type runnerQuery struct {
+	Status     []string // status 任一匹配
+	Running    *bool
+	Target     string   // path.Match 模式，如 myorg/*
+	Labels     []string // 须全部包含
+	Registered string   // registered_on_github：true / false / unknown（尚未检查）
+	ProbeError []string // probe.type 任一匹配；any 为任意探测失败，none 为无探测失败
+	Sort       string
+	Desc       bool
+	Limit      int // 0 表示不分页
+	After      *runnerCursor
+	Probe      bool // false 时不实时探测容器，使用最近一次探测结果
+}
+
+// runnerCursor 上一页最后一条的排序键，编码后作为 next_cursor 返回
+type runnerCursor struct {
+	Sort string `json:"s"`
+	Key  string `json:"k"`
+	Name string `json:"n"`
+}
+
+func (cur runnerCursor) encode() string {
+	b, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeRunnerCursor(s string) (*runnerCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var cur runnerCursor
+	if err := json.Unmarshal(b, &cur); err != nil {
+		return nil, err
+	}
+	return &cur, nil
+}
+
+// splitList 解析逗号分隔的查询参数，忽略空项
+func splitList(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// parseRunnerQuery 解析并校验查询参数，非法时返回 400
+func parseRunnerQuery(c echo.Context) (runnerQuery, error) {
+	q := runnerQuery{
+		Status:     splitList(c.QueryParam("status")),
+		Target:     strings.TrimSpace(c.QueryParam("target")),
+		Labels:     splitList(c.QueryParam("label")),
+		Registered: strings.TrimSpace(c.QueryParam("registered_on_github")),
+		ProbeError: splitList(c.QueryParam("probe_error")),
+		Probe:      true,
+	}
+	if v := c.QueryParam("running"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return q, echo.NewHTTPError(http.StatusBadRequest, "running 须为 true 或 false")
+		}
+		q.Running = &b
+	}
+	if v := c.QueryParam("probe"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return q, echo.NewHTTPError(http.StatusBadRequest, "probe 须为 true 或 false")
+		}
+		q.Probe = b
+	}
+	if q.Target != "" {
+		if _, err := path.Match(q.Target, ""); err != nil {
+			return q, echo.NewHTTPError(http.StatusBadRequest, "target 通配模式无效: "+err.Error())
+		}
+	}
+	switch q.Registered {
+	case "", "true", "false", "unknown":
+	default:
+		return q, echo.NewHTTPError(http.StatusBadRequest, "registered_on_github 须为 true、false 或 unknown")
+	}
+	if v := strings.TrimSpace(c.QueryParam("sort")); v != "" {
+		q.Desc = strings.HasPrefix(v, "-")
+		q.Sort = strings.TrimPrefix(v, "-")
+		if !slices.Contains(runnerSortKeys, q.Sort) {
+			return q, echo.NewHTTPError(http.StatusBadRequest, "sort 须为 "+strings.Join(runnerSortKeys, "、")+" 之一，前缀 - 表示倒序")
+		}
+	}
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxRunnersPerPage {
+			return q, echo.NewHTTPError(http.StatusBadRequest, "limit 必须为 1-"+strconv.Itoa(maxRunnersPerPage)+" 的整数")
+		}
+		q.Limit = n
+	}
+	if v := c.QueryParam("cursor"); v != "" {
+		cur, err := decodeRunnerCursor(v)
+		if err != nil {
+			return q, echo.NewHTTPError(http.StatusBadRequest, "cursor 无效")
+		}
+		if cur.Sort != q.sortID() {
+			return q, echo.NewHTTPError(http.StatusBadRequest, "cursor 与当前 sort 不一致，请从第一页重新查询")
+		}
+		q.After = cur
+	}
+	return q, nil
+}
+
+// sortID 标识排序方式，写入 cursor 防止翻页时换了排序
+func (q runnerQuery) sortID() string {
+	if q.Desc {
+		return "-" + q.Sort
+	}
+	return q.Sort
+}
+
+// matchStatic 按配置中的字段筛选，在探测前执行以减少探测数量
+func (q runnerQuery) matchStatic(info *runner.RunnerInfo) bool {
+	if q.Target != "" {
+		if ok, _ := path.Match(q.Target, info.Target); !ok {
+			return false
+		}
+	}
+	for _, l := range q.Labels {
+		if !slices.Contains(info.Labels, l) {
+			return false
+		}
+	}
+	switch q.Registered {
+	case "unknown":
+		return info.RegisteredOnGitHub == nil
+	case "true", "false":
+		return info.RegisteredOnGitHub != nil && strconv.FormatBool(*info.RegisteredOnGitHub) == q.Registered
+	}
+	return true
+}
+
+// matchDynamic 按探测得到的状态筛选
+func (q runnerQuery) matchDynamic(info *runner.RunnerInfo) bool {
+	if len(q.Status) > 0 && !slices.Contains(q.Status, string(info.Status)) {
+		return false
+	}
+	if q.Running != nil && info.Running != *q.Running {
+		return false
+	}
+	if len(q.ProbeError) > 0 {
+		probeType := "none"
+		if info.Probe != nil {
+			probeType = info.Probe.Type
+		}
+		if !slices.Contains(q.ProbeError, probeType) && (info.Probe == nil || !slices.Contains(q.ProbeError, "any")) {
+			return false
+		}
+	}
+	return true
+}
+
+// sortKey 返回 info 的排序键，键相同时按名称排序。未指定 sort 时不分页按配置顺序；
+// 分页时按名称，配置中的序号会随增删 runner 变化，不能作为游标
+func (q runnerQuery) sortKey(info *runner.RunnerInfo, order map[string]int) string {
+	switch q.Sort {
+	case "name":
+		return info.Name
+	case "status":
+		return string(info.Status)
+	case "target":
+		return info.Target
+	}
+	if q.Limit > 0 || q.After != nil {
+		return ""
+	}
+	return fmt.Sprintf("%08d", order[info.Name])
+}
+
+// needsProbe 筛选或排序是否依赖探测得到的状态；不依赖时先分页，只探测当前页
+func (q runnerQuery) needsProbe() bool {
+	return len(q.Status) > 0 || q.Running != nil || len(q.ProbeError) > 0 || q.Sort == "status"
+}
+
+// paginate 排序后返回 cursor 之后的一页，以及还有下一页时的 next_cursor
+func (q runnerQuery) paginate(list []runner.RunnerInfo, order map[string]int) ([]runner.RunnerInfo, string) {
+	compare := func(ka, na, kb, nb string) int {
+		c := cmp.Or(cmp.Compare(ka, kb), cmp.Compare(na, nb))
+		if q.Desc {
+			return -c
+		}
+		return c
+	}
+	keys := make(map[string]string, len(list))
+	for i := range list {
+		keys[list[i].Name] = q.sortKey(&list[i], order)
+	}
+	slices.SortStableFunc(list, func(a, b runner.RunnerInfo) int {
+		return compare(keys[a.Name], a.Name, keys[b.Name], b.Name)
+	})
+	if q.After != nil {
+		i, _ := slices.BinarySearchFunc(list, q.After, func(info runner.RunnerInfo, cur *runnerCursor) int {
+			if compare(keys[info.Name], info.Name, cur.Key, cur.Name) <= 0 {
+				return -1
+			}
+			return 1
+		})
+		list = list[i:]
+	}
+	if q.Limit == 0 || len(list) <= q.Limit {
+		return list, ""
+	}
+	last := list[q.Limit-1]
+	return list[:q.Limit], runnerCursor{Sort: q.sortID(), Key: keys[last.Name], Name: last.Name}.encode()
+}
+
+// applyObserved 用最近一次探测结果填充 list（probe=false）：容器模式下覆盖状态与探测信息，资源占用均取缓存；
+// 从未探测过的 runner 保持 runner.List 的结果
+func applyObserved(cfg *config.Config, list []runner.RunnerInfo) time.Time {
+	observedMu.Lock()
+	defer observedMu.Unlock()
+	var oldest time.Time
+	for i := range list {
+		info := &list[i]
+		st, ok := observed[info.Name]
+		if !ok {
+			continue
+		}
+		if cfg.Runners.ContainerMode {
+			info.Status, info.Running, info.Probe = st.Status, st.Running, st.Probe
+		}
+		if info.Status != runner.StatusMissing {
+			info.Usage = st.Usage
+		}
+		if oldest.IsZero() || st.At.Before(oldest) {
+			oldest = st.At
+		}
+	}
+	return oldest
+}
+
+// ListRunners 返回 runner 列表（GET /api/runners）。
+// 筛选：status、running、target（通配）、label（逗号分隔，须全部包含）、registered_on_github、probe_error；
+// 排序：sort=name|status|target，前缀 - 倒序，默认配置顺序（分页时为名称顺序）；分页：limit 与上一页返回的 cursor；
+// probe=false 时不实时探测，返回最近一次探测的缓存状态。
+func ListRunners(c echo.Context) error {
+	cfg, err := getConfig(c)
+	if err != nil {
+		return err
+	}
+	q, err := parseRunnerQuery(c)
+	if err != nil {
+		return err
+	}
+	all := filterByAccess(c, cfg, runner.List(cfg))
+	order := make(map[string]int, len(all))
+	list := all[:0]
+	for i := range all {
+		order[all[i].Name] = i
+		if q.matchStatic(&all[i]) {
+			list = append(list, all[i])
+		}
+	}
+	resp := map[string]any{"probed": q.Probe}
+	refresh := func(list []runner.RunnerInfo) {
+		if q.Probe {
+			if cfg.Runners.ContainerMode {
+				applyContainerStatus(c.Request().Context(), cfg, list)
+			}
+			applyUsageList(c.Request().Context(), cfg, list)
+			observeList(list)
+		} else if oldest := applyObserved(cfg, list); !oldest.IsZero() {
+			resp["cached_at"] = oldest.UTC().Format(time.RFC3339)
+		}
+	}
+	var page []runner.RunnerInfo
+	var next string
+	total := len(list)
+	if q.needsProbe() {
+		refresh(list)
+		matched := list[:0]
+		for i := range list {
+			if q.matchDynamic(&list[i]) {
+				matched = append(matched, list[i])
+			}
+		}
+		page, next = q.paginate(matched, order)
+		total = len(matched)
+	} else {
+		page, next = q.paginate(list, order)
+		refresh(page)
+	}
+	resp["runners"] = page
+	resp["total_count"] = total
+	if next != "" {
+		resp["next_cursor"] = next
+	}
+	return c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/lab-dev/github-actions-runner-manager/internal/runner"
+	"github.com/labstack/echo/v4"
+)
+
+func TestListRunners_Query(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	cfg := &config.Config{Runners: config.RunnersConfig{BasePath: dir, Items: []config.RunnerItem{
+		{Name: "c", TargetType: "org", Target: "acme", Labels: []string{"gpu", "linux"}},
+		{Name: "a", TargetType: "repo", Target: "acme/api", Labels: []string{"linux"}},
+		{Name: "d", TargetType: "repo", Target: "other/web"},
+		{Name: "b", TargetType: "repo", Target: "acme/web", Labels: []string{"gpu"}},
+	}}}
+	_ = cfg.Save(cfgPath)
+	ConfigPath = cfgPath
+	defer func() { ConfigPath = filepath.Join(os.TempDir(), "handler-test-config.yaml") }()
+	// a、c 已注册，b 仅有目录，d 目录不存在；c 在 GitHub 上可见，a 检查结果为不可见
+	for _, n := range []string{"a", "b", "c"} {
+		_ = os.MkdirAll(filepath.Join(dir, n), 0755)
+	}
+	_ = os.WriteFile(filepath.Join(dir, "a", ".runner"), []byte("{}"), 0644)
+	_ = os.WriteFile(filepath.Join(dir, "c", ".runner"), []byte("{}"), 0644)
+	_ = os.WriteFile(filepath.Join(dir, "c", runner.GitHubStatusFile), []byte(`{"registered":true}`), 0644)
+	_ = os.WriteFile(filepath.Join(dir, "a", runner.GitHubStatusFile), []byte(`{"registered":false}`), 0644)
+
+	e := echo.New()
+	e.Use(Auth("", ""))
+	e.GET("/api/runners", ListRunners)
+	type listResp struct {
+		Runners []struct {
+			Name   string            `json:"name"`
+			Status string            `json:"status"`
+			Probe  *runner.ProbeInfo `json:"probe"`
+		} `json:"runners"`
+		TotalCount int    `json:"total_count"`
+		NextCursor string `json:"next_cursor"`
+		Probed     bool   `json:"probed"`
+		CachedAt   string `json:"cached_at"`
+	}
+	get := func(query string, wantCode int) listResp {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/runners"+query, nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != wantCode {
+			t.Fatalf("GET %s: %d %s", query, rec.Code, rec.Body.String())
+		}
+		var resp listResp
+		_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+		return resp
+	}
+	names := func(resp listResp) []string {
+		var out []string
+		for _, r := range resp.Runners {
+			out = append(out, r.Name)
+		}
+		return out
+	}
+
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"c", "a", "d", "b"}},
+		{"?status=installed", []string{"c", "a"}},
+		{"?status=new,missing", []string{"d", "b"}},
+		{"?running=false&target=acme/*", []string{"a", "b"}},
+		{"?label=gpu,linux", []string{"c"}},
+		{"?registered_on_github=true", []string{"c"}},
+		{"?registered_on_github=false", []string{"a"}},
+		{"?registered_on_github=unknown", []string{"d", "b"}},
+		{"?probe_error=none", []string{"c", "a", "d", "b"}},
+		{"?probe_error=any", nil},
+		{"?sort=name", []string{"a", "b", "c", "d"}},
+		{"?sort=-target", []string{"d", "b", "a", "c"}},
+		{"?sort=status", []string{"a", "c", "d", "b"}},
+	}
+	for _, tc := range cases {
+		if got := names(get(tc.query, http.StatusOK)); !slices.Equal(got, tc.want) {
+			t.Errorf("%q: got %v, want %v", tc.query, got, tc.want)
+		}
+	}
+	for _, q := range []string{"?running=maybe", "?sort=age", "?limit=0", "?registered_on_github=yes", "?cursor=!!", "?target=["} {
+		get(q, http.StatusBadRequest)
+	}
+
+	// 按名称倒序分页，逐页跟随 next_cursor
+	var all []string
+	query := "?sort=-name&limit=3"
+	for range 3 {
+		resp := get(query, http.StatusOK)
+		if resp.TotalCount != 4 {
+			t.Fatalf("total_count = %d", resp.TotalCount)
+		}
+		all = append(all, names(resp)...)
+		if resp.NextCursor == "" {
+			break
+		}
+		query = "?sort=-name&limit=3&cursor=" + resp.NextCursor
+	}
+	if !slices.Equal(all, []string{"d", "c", "b", "a"}) {
+		t.Errorf("paged = %v", all)
+	}
+	// 未指定 sort 时分页按名称，翻页期间删除或调整 runner 顺序不影响游标
+	first := get("?limit=2", http.StatusOK)
+	if !slices.Equal(names(first), []string{"a", "b"}) || first.NextCursor == "" {
+		t.Fatalf("first page = %+v", first)
+	}
+	if err := config.LoadAndSave(cfgPath, func(c *config.Config) error {
+		c.Runners.Items = slices.DeleteFunc(c.Runners.Items, func(i config.RunnerItem) bool { return i.Name == "c" })
+		c.Runners.Items = append(c.Runners.Items, config.RunnerItem{Name: "c", TargetType: "org", Target: "acme", Labels: []string{"gpu", "linux"}})
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got := names(get("?limit=2&cursor="+first.NextCursor, http.StatusOK)); !slices.Equal(got, []string{"c", "d"}) {
+		t.Errorf("second page = %v", got)
+	}
+	get("?sort=name&cursor="+first.NextCursor, http.StatusBadRequest)
+	// 不按状态筛选或排序时只探测当前页
+	observedMu.Lock()
+	for _, n := range []string{"a", "b", "c", "d"} {
+		delete(observed, n)
+	}
+	observedMu.Unlock()
+	get("?limit=1", http.StatusOK)
+	observedMu.Lock()
+	_, probedA := observed["a"]
+	_, probedB := observed["b"]
+	observedMu.Unlock()
+	if !probedA || probedB {
+		t.Errorf("probed a=%v b=%v, want only the page", probedA, probedB)
+	}
+
+	// probe=false：容器模式下不探测，直接使用缓存的探测结果
+	cfg.Runners.ContainerMode = true
+	_ = cfg.Save(cfgPath)
+	observedMu.Lock()
+	observed["a"] = observedState{Status: runner.StatusUnknown, Probe: &runner.ProbeInfo{Type: "agent_unreachable"}, ProbeType: "agent_unreachable", At: time.Now()}
+	observedMu.Unlock()
+	defer func() {
+		observedMu.Lock()
+		for _, n := range []string{"a", "b", "c", "d"} {
+			delete(observed, n)
+		}
+		observedMu.Unlock()
+	}()
+	resp := get("?probe=false&probe_error=agent_unreachable", http.StatusOK)
+	if resp.Probed || resp.CachedAt == "" || !slices.Equal(names(resp), []string{"a"}) || resp.Runners[0].Status != "unknown" {
+		t.Errorf("cached = %+v", resp)
+	}
+}
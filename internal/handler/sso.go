@@ -0,0 +1,249 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/lab-dev/github-actions-runner-manager/internal/oidc"
+	"github.com/labstack/echo/v4"
+)
+
+// OIDC 启用 auth.oidc 时由 main 设置，为 nil 表示未启用单点登录
+var OIDC *oidc.Provider
+
+const (
+	sessionCookie    = "rfm_session"
+	csrfCookie       = "rfm_csrf" // 页面脚本读取后放入 X-CSRF-Token 请求头（双重提交）
+	loginStateCookie = "rfm_login_state"
+	// CSRFHeader 会话发起写操作时须携带的请求头
+	CSRFHeader = "X-CSRF-Token"
+	// loginTimeout 跳转 IdP 到回调之间允许的最长时间
+	loginTimeout = 10 * time.Minute
+	// maxPendingLogins 同时等待回调的登录上限，超出时丢弃最早发起的，避免匿名请求占满内存
+	maxPendingLogins = 1000
+)
+
+// session 登录会话，仅保存在内存中，Manager 重启后需重新登录
+type session struct {
+	principal Principal
+	csrf      string
+	expires   time.Time
+}
+
+// pendingLogin 已跳转 IdP、等待回调的登录
+type pendingLogin struct {
+	nonce    string
+	verifier string
+	returnTo string
+	expires  time.Time
+}
+
+var (
+	sessionMu     sync.Mutex
+	sessions      = map[string]*session{}
+	pendingLogins = map[string]*pendingLogin{}
+)
+
+func randomString() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// pruneSessionsLocked 清理过期的会话与登录，调用方须持有 sessionMu
+func pruneSessionsLocked(now time.Time) {
+	for id, s := range sessions {
+		if now.After(s.expires) {
+			delete(sessions, id)
+		}
+	}
+	for state, p := range pendingLogins {
+		if now.After(p.expires) {
+			delete(pendingLogins, state)
+		}
+	}
+}
+
+// evictPendingLoginsLocked 等待回调的登录达到上限时按过期时间从早到晚丢弃，调用方须持有 sessionMu
+func evictPendingLoginsLocked() {
+	for len(pendingLogins) >= maxPendingLogins {
+		oldest := ""
+		for state, p := range pendingLogins {
+			if oldest == "" || p.expires.Before(pendingLogins[oldest].expires) {
+				oldest = state
+			}
+		}
+		delete(pendingLogins, oldest)
+	}
+}
+
+// lookupSession 按 Cookie 查找未过期的会话
+func lookupSession(c echo.Context) (*session, bool) {
+	ck, err := c.Cookie(sessionCookie)
+	if err != nil || ck.Value == "" {
+		return nil, false
+	}
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	s, ok := sessions[ck.Value]
+	if !ok || time.Now().After(s.expires) {
+		return nil, false
+	}
+	return s, true
+}
+
+// secureCookies 回调地址为 https 时 Cookie 只经 https 发送
+func secureCookies(c echo.Context) bool {
+	return c.Request().TLS != nil || (OIDC != nil && strings.HasPrefix(OIDC.RedirectURL, "https://"))
+}
+
+func setCookie(c echo.Context, name, value string, maxAge int, httpOnly bool) {
+	c.SetCookie(&http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: httpOnly,
+		Secure:   secureCookies(c),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// safeReturnPath 仅允许站内相对路径，避免登录后被重定向到外部站点
+func safeReturnPath(p string) string {
+	if !strings.HasPrefix(p, "/") || strings.HasPrefix(p, "//") || strings.HasPrefix(p, "/\\") {
+		return "/"
+	}
+	return p
+}
+
+// SSOLogin 跳转到 IdP 登录（GET /auth/login?return=/path）
+func SSOLogin(c echo.Context) error {
+	if OIDC == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "未启用 OIDC 登录")
+	}
+	state, nonce, verifier := randomString(), randomString(), randomString()
+	u, err := OIDC.AuthCodeURL(c.Request().Context(), state, nonce, verifier)
+	if err != nil {
+		log.Printf("[oidc] %v", err)
+		return echo.NewHTTPError(http.StatusBadGateway, "无法连接身份提供方: "+err.Error())
+	}
+	now := time.Now()
+	sessionMu.Lock()
+	pruneSessionsLocked(now)
+	evictPendingLoginsLocked()
+	pendingLogins[state] = &pendingLogin{nonce: nonce, verifier: verifier, returnTo: safeReturnPath(c.QueryParam("return")), expires: now.Add(loginTimeout)}
+	sessionMu.Unlock()
+	// state 同时写入 Cookie，回调时比对，防止他人发起的登录回调落在当前浏览器
+	setCookie(c, loginStateCookie, state, int(loginTimeout.Seconds()), true)
+	return c.Redirect(http.StatusFound, u)
+}
+
+// SSOCallback IdP 回调（GET /auth/callback）：校验 state 与 ID Token，按组映射角色后建立会话
+func SSOCallback(c echo.Context) error {
+	if OIDC == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "未启用 OIDC 登录")
+	}
+	if e := c.QueryParam("error"); e != "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "登录失败: "+e+" "+c.QueryParam("error_description"))
+	}
+	state := c.QueryParam("state")
+	ck, err := c.Cookie(loginStateCookie)
+	if state == "" || err != nil || ck.Value != state {
+		return echo.NewHTTPError(http.StatusBadRequest, "登录状态无效，请重新登录")
+	}
+	setCookie(c, loginStateCookie, "", -1, true)
+	sessionMu.Lock()
+	pending, ok := pendingLogins[state]
+	delete(pendingLogins, state)
+	sessionMu.Unlock()
+	if !ok || time.Now().After(pending.expires) {
+		return echo.NewHTTPError(http.StatusBadRequest, "登录已过期，请重新登录")
+	}
+	ctx := c.Request().Context()
+	raw, err := OIDC.Exchange(ctx, c.QueryParam("code"), pending.verifier)
+	if err != nil {
+		log.Printf("[oidc] %v", err)
+		return echo.NewHTTPError(http.StatusBadGateway, "换取 ID Token 失败: "+err.Error())
+	}
+	idt, err := OIDC.Verify(ctx, raw, pending.nonce)
+	if err != nil {
+		log.Printf("[oidc] %v", err)
+		return echo.NewHTTPError(http.StatusUnauthorized, "ID Token 校验失败: "+err.Error())
+	}
+	cfg, err := getConfig(c)
+	if err != nil {
+		return err
+	}
+	if cfg.Auth == nil || cfg.Auth.OIDC == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "未启用 OIDC 登录")
+	}
+	oc := cfg.Auth.OIDC
+	groups := idt.Groups(oc.EffectiveGroupsClaim())
+	name := idt.Subject
+	for _, claim := range []string{"preferred_username", "email", "name"} {
+		if v := idt.StringClaim(claim); v != "" {
+			name = v
+			break
+		}
+	}
+	role, ok := oc.RoleFor(groups)
+	if !ok {
+		log.Printf("[oidc] 拒绝 %s 登录：不在允许的组中（%v）", name, groups)
+		return echo.NewHTTPError(http.StatusForbidden, "账号 "+name+" 无权访问")
+	}
+	now := time.Now()
+	s := &session{
+		principal: Principal{Name: name, Kind: PrincipalSession, Role: role, Groups: groups},
+		csrf:      randomString(),
+		expires:   now.Add(oc.SessionTTL()),
+	}
+	id := randomString()
+	sessionMu.Lock()
+	pruneSessionsLocked(now)
+	sessions[id] = s
+	sessionMu.Unlock()
+	maxAge := int(oc.SessionTTL().Seconds())
+	setCookie(c, sessionCookie, id, maxAge, true)
+	setCookie(c, csrfCookie, s.csrf, maxAge, false)
+	log.Printf("[oidc] %s 已登录（角色 %s）", name, role)
+	return c.Redirect(http.StatusFound, pending.returnTo)
+}
+
+// SSOLogout 退出登录（POST /auth/logout），删除会话与 Cookie
+func SSOLogout(c echo.Context) error {
+	if ck, err := c.Cookie(sessionCookie); err == nil {
+		sessionMu.Lock()
+		delete(sessions, ck.Value)
+		sessionMu.Unlock()
+	}
+	setCookie(c, sessionCookie, "", -1, true)
+	setCookie(c, csrfCookie, "", -1, false)
+	return c.JSON(http.StatusOK, map[string]any{"message": "已退出登录"})
+}
+
+// Me 返回当前调用者（GET /api/me）
+func Me(c echo.Context) error {
+	return c.JSON(http.StatusOK, CurrentPrincipal(c))
+}
+
+// NewOIDCProvider 按配置创建 OIDC 客户端，未配置 auth.oidc 时返回 nil
+func NewOIDCProvider(cfg *config.Config, clientSecret string) *oidc.Provider {
+	if cfg.Auth == nil || cfg.Auth.OIDC == nil {
+		return nil
+	}
+	oc := cfg.Auth.OIDC
+	return &oidc.Provider{
+		Issuer:       oc.Issuer,
+		ClientID:     oc.ClientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  oc.RedirectURL,
+		Scopes:       oc.Scopes,
+	}
+}
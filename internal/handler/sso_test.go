@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/lab-dev/github-actions-runner-manager/internal/oidc/oidctest"
+	"github.com/labstack/echo/v4"
+)
+
+func TestSSO_LoginRolesAndCSRF(t *testing.T) {
+	is := oidctest.NewIssuer(t, "fleet", "s3cret")
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	cfg := &config.Config{
+		Runners: config.RunnersConfig{BasePath: dir},
+		Auth: &config.AuthConfig{OIDC: &config.OIDCConfig{
+			Issuer:        is.URL,
+			ClientID:      "fleet",
+			RedirectURL:   "http://fleet.test/auth/callback",
+			AllowedGroups: []string{"platform", "dev"},
+			GroupRoles:    map[string]string{"platform": "admin"},
+		}},
+	}
+	_ = cfg.Save(cfgPath)
+	ConfigPath = cfgPath
+	OIDC = NewOIDCProvider(cfg, "s3cret")
+	defer func() {
+		ConfigPath = filepath.Join(os.TempDir(), "handler-test-config.yaml")
+		OIDC = nil
+	}()
+
+	ok := func(c echo.Context) error { return c.String(http.StatusOK, "ok") }
+	e := echo.New()
+	e.Use(Auth("", ""))
+	e.GET("/auth/login", SSOLogin)
+	e.GET("/auth/callback", SSOCallback)
+	e.POST("/auth/logout", SSOLogout)
+	e.GET("/api/me", Me)
+	e.GET("/api/runners", ok)
+	e.POST("/api/runners/:name/start", ok)
+	do := func(method, target string, cookies []*http.Cookie, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		for _, ck := range cookies {
+			req.AddCookie(ck)
+		}
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	// login 走完整授权码流程，返回回调后设置的 Cookie
+	login := func(groups []string) (*httptest.ResponseRecorder, []*http.Cookie) {
+		rec := do(http.MethodGet, "/auth/login?return=/api/me", nil, nil)
+		if rec.Code != http.StatusFound {
+			t.Fatalf("login: expected 302, got %d %s", rec.Code, rec.Body.String())
+		}
+		cb := is.Authorize(t, rec.Header().Get("Location"), map[string]any{"sub": "u-" + groups[0], "preferred_username": groups[0] + "-user", "groups": groups})
+		rec = do(http.MethodGet, "/auth/callback?"+cb.Encode(), rec.Result().Cookies(), nil)
+		return rec, rec.Result().Cookies()
+	}
+	cookie := func(cookies []*http.Cookie, name string) string {
+		for _, ck := range cookies {
+			if ck.Name == name {
+				return ck.Value
+			}
+		}
+		return ""
+	}
+
+	if rec := do(http.MethodGet, "/api/runners", nil, map[string]string{echo.HeaderAccept: "text/html"}); rec.Code != http.StatusFound || !strings.HasPrefix(rec.Header().Get("Location"), "/auth/login") {
+		t.Errorf("browser without session: expected redirect to login, got %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+	if rec := do(http.MethodGet, "/api/runners", nil, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("API without session: expected 401, got %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/auth/callback?code=x&state=forged", nil, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("forged state: expected 400, got %d", rec.Code)
+	}
+
+	rec, admin := login([]string{"platform"})
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/api/me" {
+		t.Fatalf("callback: expected redirect to /api/me, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := do(http.MethodGet, "/api/me", admin, nil); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"role":"admin"`) {
+		t.Errorf("me: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := do(http.MethodPost, "/api/runners/r1/start", admin, nil); rec.Code != http.StatusForbidden {
+		t.Errorf("POST without CSRF header: expected 403, got %d", rec.Code)
+	}
+	csrf := map[string]string{CSRFHeader: cookie(admin, csrfCookie)}
+	if rec := do(http.MethodPost, "/api/runners/r1/start", admin, csrf); rec.Code != http.StatusOK {
+		t.Errorf("POST with CSRF header: expected 200, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	_, reader := login([]string{"dev"})
+	if rec := do(http.MethodGet, "/api/runners", reader, nil); rec.Code != http.StatusOK {
+		t.Errorf("read role GET: expected 200, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/runners/r1/start", reader, map[string]string{CSRFHeader: cookie(reader, csrfCookie)}); rec.Code != http.StatusForbidden {
+		t.Errorf("read role start: expected 403, got %d", rec.Code)
+	}
+
+	if rec, _ := login([]string{"contractors"}); rec.Code != http.StatusForbidden {
+		t.Errorf("group not allowed: expected 403, got %d", rec.Code)
+	}
+
+	if rec := do(http.MethodPost, "/auth/logout", admin, csrf); rec.Code != http.StatusOK {
+		t.Errorf("logout: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := do(http.MethodGet, "/api/runners", admin, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("after logout: expected 401, got %d", rec.Code)
+	}
+}
+
+func TestEvictPendingLogins(t *testing.T) {
+	sessionMu.Lock()
+	defer func() {
+		pendingLogins = map[string]*pendingLogin{}
+		sessionMu.Unlock()
+	}()
+	now := time.Now()
+	pendingLogins = map[string]*pendingLogin{}
+	for i := range maxPendingLogins {
+		pendingLogins[strconv.Itoa(i)] = &pendingLogin{expires: now.Add(time.Duration(i) * time.Second)}
+	}
+	evictPendingLoginsLocked()
+	if len(pendingLogins) != maxPendingLogins-1 {
+		t.Fatalf("pending logins = %d, want %d", len(pendingLogins), maxPendingLogins-1)
+	}
+	if _, ok := pendingLogins["0"]; ok {
+		t.Error("oldest pending login should be evicted first")
+	}
+}
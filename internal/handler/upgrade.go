@@ -0,0 +1,567 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/lab-dev/github-actions-runner-manager/internal/events"
+	"github.com/lab-dev/github-actions-runner-manager/internal/githubcheck"
+	"github.com/lab-dev/github-actions-runner-manager/internal/installer"
+	"github.com/lab-dev/github-actions-runner-manager/internal/jobhistory"
+	"github.com/lab-dev/github-actions-runner-manager/internal/rollout"
+	"github.com/lab-dev/github-actions-runner-manager/internal/runner"
+	"github.com/labstack/echo/v4"
+)
+
+// 滚动升级默认值
+const (
+	defaultDrainTimeout  = 30 * time.Minute
+	defaultOnlineTimeout = 5 * time.Minute
+	upgradeStopTimeout   = 60 * time.Second
+)
+
+// upgradePollInterval 等待 Job 结束、停止与上线时的轮询间隔，测试中缩短
+var upgradePollInterval = 5 * time.Second
+
+// errUpgradeCancelled 等待 Job 结束时升级被取消（runner 未改动）
+var errUpgradeCancelled = errors.New("升级已取消")
+
+// binarySwap 已换入新版本的 runner 二进制，验证后提交或回滚
+type binarySwap interface {
+	Commit() error
+	Rollback() error
+}
+
+// runnerOps 滚动升级与配置对齐对单个 runner 的操作；liveRunnerOps 为实际实现，测试中替换
+type runnerOps interface {
+	Prepare(ctx context.Context, cfg *config.Config, version string, platforms []string) error
+	Busy(cfg *config.Config, item config.RunnerItem) bool
+	Running(ctx context.Context, cfg *config.Config, item config.RunnerItem) bool
+	Stop(ctx context.Context, cfg *config.Config, item config.RunnerItem) error
+	Start(ctx context.Context, cfg *config.Config, item config.RunnerItem) error
+	Remove(ctx context.Context, cfg *config.Config, item config.RunnerItem) error
+	Online(ctx context.Context, cfg *config.Config, item config.RunnerItem) bool
+	Swap(ctx context.Context, cfg *config.Config, version string, item config.RunnerItem, log io.Writer) (binarySwap, error)
+}
+
+var fleetOps runnerOps = liveRunnerOps{}
+
+type liveRunnerOps struct{}
+
+// Prepare 下载并校验目标版本各平台的安装包，失败时不改动任何 runner
+func (liveRunnerOps) Prepare(ctx context.Context, cfg *config.Config, version string, platforms []string) error {
+	cache, err := runnerCache(cfg)
+	if err != nil {
+		return err
+	}
+	for _, platform := range platforms {
+		if _, err := cache.Ensure(ctx, version, platform, io.Discard); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (liveRunnerOps) Busy(cfg *config.Config, item config.RunnerItem) bool {
+	return jobhistory.Busy(item.InstallPath(cfg.Runners.BasePath))
+}
+
+func (liveRunnerOps) Running(ctx context.Context, cfg *config.Config, item config.RunnerItem) bool {
+	installDir := item.InstallPath(cfg.Runners.BasePath)
+	if cfg.Runners.ContainerMode {
+		running, _, _, err := runner.ContainerRunnerStatus(ctx, cfg, item.Name, installDir)
+		return running && err == nil
+	}
+	info := runner.GetByName(cfg, item.Name)
+	return info != nil && info.Running
+}
+
+func (liveRunnerOps) Stop(ctx context.Context, cfg *config.Config, item config.RunnerItem) error {
+	if cfg.Runners.ContainerMode {
+		return runner.StopRunnerContainer(ctx, item.Name)
+	}
+	return runner.Stop(item.InstallPath(cfg.Runners.BasePath))
+}
+
+func (liveRunnerOps) Start(ctx context.Context, cfg *config.Config, item config.RunnerItem) error {
+	return runner.StartIfInstalled(ctx, cfg, item.Name, item.InstallPath(cfg.Runners.BasePath))
+}
+
+// Remove 停止并清理运行实例：容器模式下删除容器（下次启动按新配置重建），进程模式下停止在跑的进程
+func (o liveRunnerOps) Remove(ctx context.Context, cfg *config.Config, item config.RunnerItem) error {
+	if cfg.Runners.ContainerMode {
+		return runner.RemoveRunnerContainer(ctx, item.Name)
+	}
+	if !o.Running(ctx, cfg, item) {
+		return nil
+	}
+	return runner.Stop(item.InstallPath(cfg.Runners.BasePath))
+}
+
+// Online 进程/容器在运行；配置了 .github_check_token 时还须 GitHub 报告 online
+func (o liveRunnerOps) Online(ctx context.Context, cfg *config.Config, item config.RunnerItem) bool {
+	if !o.Running(ctx, cfg, item) {
+		return false
+	}
+	if st, ok := githubcheck.Check(cfg, item); ok {
+		return st.Online
+	}
+	return true
+}
+
+func (liveRunnerOps) Swap(ctx context.Context, cfg *config.Config, version string, item config.RunnerItem, log io.Writer) (binarySwap, error) {
+	cache, err := runnerCache(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return cache.Upgrade(ctx, version, installer.Platform(runner.Arch(cfg, item)), item.InstallPath(cfg.Runners.BasePath), log)
+}
+
+var (
+	upgradeMu sync.Mutex
+	// upgradeCancel 进行中升级的取消函数，无进行中的升级时为 nil
+	upgradeCancel context.CancelFunc
+)
+
+// FleetUpgradeRequest 滚动升级请求
+type FleetUpgradeRequest struct {
+	Version              string   `json:"version"`
+	Runners              []string `json:"runners"`                // 为空时升级全部 runner，按配置顺序；重复的名称只升级一次
+	FailureBudget        int      `json:"failure_budget"`         // 允许失败的 runner 数，默认 0（首个失败即停止）
+	DrainTimeoutSeconds  int      `json:"drain_timeout_seconds"`  // 等待当前 Job 结束的上限，默认 1800
+	OnlineTimeoutSeconds int      `json:"online_timeout_seconds"` // 重启后等待上线的上限，默认 300
+}
+
+// StartFleetUpgrade 开始滚动升级（POST /api/fleet/upgrade）：逐个等待 runner 空闲后停止、替换二进制（保留 .runner 与 .credentials）、
+// 重启并确认重新上线；未能上线的 runner 回滚到原版本。失败数超过 failure_budget 时停止后续 runner
+func StartFleetUpgrade(c echo.Context) error {
+	cfg, err := getConfig(c)
+	if err != nil {
+		return err
+	}
+	var req FleetUpgradeRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "参数错误: "+err.Error())
+	}
+	req.Version = strings.TrimPrefix(strings.TrimSpace(req.Version), "v")
+	if !config.ValidRunnerVersion(req.Version) {
+		return echo.NewHTTPError(http.StatusBadRequest, "version 格式应为 x.y.z")
+	}
+	if req.FailureBudget < 0 || req.DrainTimeoutSeconds < 0 || req.OnlineTimeoutSeconds < 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "failure_budget 与超时时间不能为负数")
+	}
+	cache, err := runnerCache(cfg)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	items := cfg.Runners.Items
+	if len(req.Runners) == 0 && !fullAccess(c, cfg) {
+		return echo.NewHTTPError(http.StatusForbidden, "仅可管理部分 target，请在 runners 中指定要升级的 runner")
+	}
+	if len(req.Runners) > 0 {
+		items = nil
+		for _, name := range req.Runners {
+			idx := slices.IndexFunc(cfg.Runners.Items, func(i config.RunnerItem) bool { return i.Name == name })
+			if idx < 0 || !targetAccess(c, cfg)(cfg.Runners.Items[idx].Target) {
+				return echo.NewHTTPError(http.StatusNotFound, "未找到 runner: "+name)
+			}
+			// 重复的名称只升级一次
+			if !slices.ContainsFunc(items, func(i config.RunnerItem) bool { return i.Name == name }) {
+				items = append(items, cfg.Runners.Items[idx])
+			}
+		}
+	}
+	now := time.Now().UTC()
+	r := &rollout.Rollout{
+		ID:                   "upg-" + now.Format("20060102-150405") + "-" + shortRandomSuffix(),
+		Version:              req.Version,
+		State:                rollout.StateRunning,
+		FailureBudget:        req.FailureBudget,
+		DrainTimeoutSeconds:  int(defaultDrainTimeout.Seconds()),
+		OnlineTimeoutSeconds: int(defaultOnlineTimeout.Seconds()),
+		CreatedAt:            now,
+	}
+	if req.DrainTimeoutSeconds > 0 {
+		r.DrainTimeoutSeconds = req.DrainTimeoutSeconds
+	}
+	if req.OnlineTimeoutSeconds > 0 {
+		r.OnlineTimeoutSeconds = req.OnlineTimeoutSeconds
+	}
+	for _, item := range items {
+		installDir := item.InstallPath(cfg.Runners.BasePath)
+		step := rollout.Step{Runner: item.Name, State: rollout.StepPending, FromVersion: installer.InstalledVersion(installDir), Arch: runner.Arch(cfg, item)}
+		if step.FromVersion == req.Version {
+			step.State, step.Message = rollout.StepSkipped, "已是目标版本"
+		} else if info := runner.GetByName(cfg, item.Name); info == nil || info.Status != runner.StatusInstalled {
+			step.State, step.Message = rollout.StepSkipped, "未注册，注册时将按 runner_version 安装"
+		} else if _, err := cache.Checksum(c.Request().Context(), req.Version, installer.Platform(step.Arch)); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		r.Steps = append(r.Steps, step)
+	}
+
+	upgradeMu.Lock()
+	defer upgradeMu.Unlock()
+	if upgradeCancel != nil {
+		return echo.NewHTTPError(http.StatusConflict, "已有进行中的升级，请等待结束或先取消")
+	}
+	sd := cfg.Runners.StateDir()
+	if err := rollout.Save(sd, r); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "保存升级进度失败: "+err.Error())
+	}
+	resp := *r
+	resp.Steps = slices.Clone(r.Steps)
+	ctx, cancel := context.WithCancel(context.Background())
+	upgradeCancel = cancel
+	go runFleetUpgrade(ctx, cfg, r)
+	return c.JSON(http.StatusAccepted, resp)
+}
+
+// GetFleetUpgrade 查看最近一次升级的进度（GET /api/fleet/upgrade）
+func GetFleetUpgrade(c echo.Context) error {
+	cfg, err := getConfig(c)
+	if err != nil {
+		return err
+	}
+	r, err := rollout.Load(cfg.Runners.StateDir())
+	if errors.Is(err, rollout.ErrNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "读取升级进度失败: "+err.Error())
+	}
+	return c.JSON(http.StatusOK, map[string]any{"upgrade": r, "counts": r.Counts()})
+}
+
+// RecoverFleetUpgrade 在 Manager 启动时调用一次：将各 runner 目录中未提交的升级恢复为原版本（见 installer.RecoverUpgrade），
+// 恢复前停止、恢复后重新启动在运行的 runner；并把上次退出时仍在进行的升级记为失败，处理中的 runner 记为失败、未开始的记为跳过
+func RecoverFleetUpgrade(cfg *config.Config) {
+	var restored []string
+	ctx := context.Background()
+	for _, item := range cfg.Runners.Items {
+		dir := item.InstallPath(cfg.Runners.BasePath)
+		if !installer.PendingUpgrade(dir) {
+			// 仍清理已提交升级残留的临时目录
+			_, _ = installer.RecoverUpgrade(dir)
+			continue
+		}
+		wasRunning := fleetOps.Running(ctx, cfg, item)
+		if wasRunning {
+			if err := stopAndWait(ctx, cfg, item); err != nil {
+				log.Printf("[upgrade] %s 上次升级未完成，停止 runner 失败，未恢复: %v", item.Name, err)
+				continue
+			}
+		}
+		if _, err := installer.RecoverUpgrade(dir); err != nil {
+			log.Printf("[upgrade] 恢复 %s 中断的升级失败: %v", item.Name, err)
+			continue
+		}
+		restored = append(restored, item.Name)
+		log.Printf("[upgrade] %s 上次升级未完成，已恢复原版本文件", item.Name)
+		if wasRunning {
+			if err := fleetOps.Start(ctx, cfg, item); err != nil {
+				log.Printf("[upgrade] %s 恢复后启动失败: %v", item.Name, err)
+			}
+		}
+	}
+	sd := cfg.Runners.StateDir()
+	r, err := rollout.Load(sd)
+	if err != nil || r.State != rollout.StateRunning {
+		return
+	}
+	now := time.Now().UTC()
+	const interrupted = "Manager 重启导致升级中断"
+	for i := range r.Steps {
+		step := &r.Steps[i]
+		switch step.State {
+		case rollout.StepDone, rollout.StepFailed, rollout.StepSkipped:
+		case rollout.StepPending:
+			step.State, step.Message = rollout.StepSkipped, interrupted
+		default:
+			step.State, step.Message, step.FinishedAt = rollout.StepFailed, interrupted, now
+			if slices.Contains(restored, step.Runner) {
+				step.RolledBack = true
+				step.Message += "，已恢复原版本"
+			}
+			r.Failures++
+		}
+	}
+	r.State, r.Error, r.FinishedAt = rollout.StateFailed, interrupted+"，请检查失败的 runner 是否在运行", now
+	if err := rollout.Save(sd, r); err != nil {
+		log.Printf("[upgrade] 保存升级进度失败: %v", err)
+	}
+}
+
+// CancelFleetUpgrade 取消进行中的升级（POST /api/fleet/upgrade/cancel）：正在等待 Job 结束的 runner 不再升级，
+// 已开始替换的 runner 完成当前步骤后停止。受 auth.access 限制的调用者须可管理升级中的全部 runner
+func CancelFleetUpgrade(c echo.Context) error {
+	cfg, err := getConfig(c)
+	if err != nil {
+		return err
+	}
+	upgradeMu.Lock()
+	defer upgradeMu.Unlock()
+	if upgradeCancel == nil {
+		return echo.NewHTTPError(http.StatusConflict, "没有进行中的升级")
+	}
+	if !fullAccess(c, cfg) {
+		r, err := rollout.Load(cfg.Runners.StateDir())
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "读取升级进度失败: "+err.Error())
+		}
+		for _, step := range r.Steps {
+			if !runnerAccessible(c, cfg, step.Runner) {
+				return echo.NewHTTPError(http.StatusForbidden, "升级包含无权管理的 runner: "+step.Runner)
+			}
+		}
+	}
+	upgradeCancel()
+	return c.JSON(http.StatusAccepted, map[string]any{"message": "已发送取消，当前 runner 处理完成后停止"})
+}
+
+// runFleetUpgrade 逐个升级 runner（在后台 goroutine 中执行），每步写入进度并发布 upgrade.progress
+func runFleetUpgrade(ctx context.Context, cfg *config.Config, r *rollout.Rollout) {
+	defer func() {
+		upgradeMu.Lock()
+		upgradeCancel = nil
+		upgradeMu.Unlock()
+	}()
+	sd := cfg.Runners.StateDir()
+	finish := func(state, msg string) {
+		r.State, r.Error = state, msg
+		r.FinishedAt = time.Now().UTC()
+		_ = rollout.Save(sd, r)
+		events.Publish(events.TypeUpgradeProgress, "", map[string]any{"rollout_id": r.ID, "version": r.Version, "state": r.State, "failures": r.Failures})
+		log.Printf("[upgrade] %s 结束: %s %s", r.ID, state, msg)
+	}
+	skipRest := func(from int, msg string) {
+		for i := from; i < len(r.Steps); i++ {
+			if r.Steps[i].State == rollout.StepPending {
+				r.Steps[i].State, r.Steps[i].Message = rollout.StepSkipped, msg
+			}
+		}
+	}
+	var platforms []string
+	for _, step := range r.Steps {
+		if p := installer.Platform(step.Arch); step.State == rollout.StepPending && !slices.Contains(platforms, p) {
+			platforms = append(platforms, p)
+		}
+	}
+	if err := fleetOps.Prepare(ctx, cfg, r.Version, platforms); err != nil {
+		skipRest(0, "准备安装包失败")
+		finish(rollout.StateFailed, "准备安装包失败: "+err.Error())
+		return
+	}
+	for i := range r.Steps {
+		step := &r.Steps[i]
+		if step.State != rollout.StepPending {
+			continue
+		}
+		if ctx.Err() != nil {
+			skipRest(i, errUpgradeCancelled.Error())
+			finish(rollout.StateCancelled, "")
+			return
+		}
+		// 每个 runner 开始前重新加载配置，期间被移除的 runner 跳过
+		if latest, err := config.Load(ConfigPath); err == nil {
+			cfg = latest
+		}
+		idx := slices.IndexFunc(cfg.Runners.Items, func(i config.RunnerItem) bool { return i.Name == step.Runner })
+		if idx < 0 {
+			step.State, step.Message = rollout.StepSkipped, "runner 已从配置中移除"
+			_ = rollout.Save(sd, r)
+			continue
+		}
+		step.StartedAt = time.Now().UTC()
+		err := upgradeRunner(ctx, cfg, cfg.Runners.Items[idx], r, step)
+		step.FinishedAt = time.Now().UTC()
+		switch {
+		case err == nil:
+			step.State = rollout.StepDone
+			recordRunnerVersion(step.Runner, r.Version)
+		case errors.Is(err, errUpgradeCancelled):
+			step.State, step.Message = rollout.StepSkipped, err.Error()
+		default:
+			step.State, step.Message = rollout.StepFailed, err.Error()
+			r.Failures++
+		}
+		_ = rollout.Save(sd, r)
+		publishStep(r, step)
+		if r.Failures > r.FailureBudget {
+			skipRest(i+1, "失败数超过预算，升级已停止")
+			finish(rollout.StateHalted, fmt.Sprintf("%d 个 runner 升级失败，超过 failure_budget=%d", r.Failures, r.FailureBudget))
+			return
+		}
+	}
+	if ctx.Err() != nil {
+		finish(rollout.StateCancelled, "")
+		return
+	}
+	finish(rollout.StateSucceeded, "")
+}
+
+func publishStep(r *rollout.Rollout, step *rollout.Step) {
+	events.Publish(events.TypeUpgradeProgress, step.Runner, map[string]any{
+		"rollout_id": r.ID,
+		"version":    r.Version,
+		"step":       step.State,
+		"message":    step.Message,
+	})
+}
+
+// jobInterruptedMsg 排空后、停止前 runner 又领取了 Job 时的提示
+const jobInterruptedMsg = "停止前 runner 刚领取了新的 Job，该 Job 已被中断"
+
+// upgradeRunner 升级单个 runner；返回 nil 表示新版本已上线并提交
+func upgradeRunner(ctx context.Context, cfg *config.Config, item config.RunnerItem, r *rollout.Rollout, step *rollout.Step) error {
+	sd := cfg.Runners.StateDir()
+	setStep := func(state string) {
+		step.State = state
+		_ = rollout.Save(sd, r)
+		publishStep(r, step)
+	}
+
+	setStep(rollout.StepDraining)
+	drainDeadline := time.Now().Add(time.Duration(r.DrainTimeoutSeconds) * time.Second)
+	for fleetOps.Busy(cfg, item) {
+		if time.Now().After(drainDeadline) {
+			return fmt.Errorf("等待当前 Job 结束超时（%d 秒），未改动该 runner", r.DrainTimeoutSeconds)
+		}
+		if sleepCtx(ctx, upgradePollInterval) != nil {
+			return errUpgradeCancelled
+		}
+	}
+	if ctx.Err() != nil {
+		return errUpgradeCancelled
+	}
+
+	wasRunning := fleetOps.Running(ctx, cfg, item)
+	// 开始替换二进制后不再响应取消，保证每个 runner 以完整的新版本或原版本结束
+	opCtx := context.Background()
+	if wasRunning {
+		setStep(rollout.StepStopping)
+		if err := stopAndWait(ctx, cfg, item); err != nil {
+			if ctx.Err() != nil {
+				// 等待停止时取消：二进制尚未改动，重新启动后结束
+				_ = fleetOps.Start(opCtx, cfg, item)
+				return errUpgradeCancelled
+			}
+			return err
+		}
+		// 排空检查与停止之间 runner 可能又领取了 Job：停止后 Worker 日志仍无结论即说明该 Job 被中断
+		if fleetOps.Busy(cfg, item) {
+			step.JobInterrupted, step.Message = true, jobInterruptedMsg
+		}
+	}
+
+	setStep(rollout.StepInstalling)
+	var out strings.Builder
+	sw, err := fleetOps.Swap(opCtx, cfg, r.Version, item, &out)
+	if err != nil {
+		if wasRunning {
+			_ = fleetOps.Start(opCtx, cfg, item)
+		}
+		return fmt.Errorf("替换二进制失败，已保留原版本: %w", err)
+	}
+	if !wasRunning {
+		// 原本未运行：只替换二进制，不启动
+		return sw.Commit()
+	}
+
+	rollback := func(reason string) error {
+		_ = stopAndWait(opCtx, cfg, item)
+		step.RolledBack = true
+		if err := sw.Rollback(); err != nil {
+			return fmt.Errorf("%s，且回滚失败: %v", reason, err)
+		}
+		if err := fleetOps.Start(opCtx, cfg, item); err != nil {
+			return fmt.Errorf("%s，已回滚到 %s 但启动失败: %v", reason, step.FromVersion, err)
+		}
+		return fmt.Errorf("%s，已回滚到 %s", reason, step.FromVersion)
+	}
+	setStep(rollout.StepStarting)
+	startCtx, cancel := context.WithTimeout(opCtx, 60*time.Second)
+	err = fleetOps.Start(startCtx, cfg, item)
+	cancel()
+	if err != nil {
+		return rollback("启动新版本失败: " + err.Error())
+	}
+
+	setStep(rollout.StepVerifying)
+	if !waitOnline(opCtx, cfg, item, time.Duration(r.OnlineTimeoutSeconds)*time.Second) {
+		return rollback(fmt.Sprintf("新版本未在 %d 秒内上线", r.OnlineTimeoutSeconds))
+	}
+	return sw.Commit()
+}
+
+// sleepCtx 等待 d，ctx 先结束时提前返回其错误
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// stopAndWait 停止 runner 并等待其退出；ctx 结束时停止等待并返回错误
+func stopAndWait(ctx context.Context, cfg *config.Config, item config.RunnerItem) error {
+	if err := fleetOps.Stop(ctx, cfg, item); err != nil {
+		return fmt.Errorf("停止 runner 失败: %w", err)
+	}
+	deadline := time.Now().Add(upgradeStopTimeout)
+	for fleetOps.Running(ctx, cfg, item) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("runner 未在 %s 内停止", upgradeStopTimeout)
+		}
+		if err := sleepCtx(ctx, upgradePollInterval); err != nil {
+			return fmt.Errorf("等待 runner 停止时中断: %w", err)
+		}
+	}
+	return nil
+}
+
+// waitOnline 等待 runner 连续两次检查均为上线，避免启动后立即退出被误判为成功；ctx 结束时返回 false
+func waitOnline(ctx context.Context, cfg *config.Config, item config.RunnerItem, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	ok := 0
+	for {
+		if fleetOps.Online(ctx, cfg, item) {
+			ok++
+			if ok >= 2 {
+				return true
+			}
+		} else {
+			ok = 0
+		}
+		if time.Now().After(deadline) || sleepCtx(ctx, upgradePollInterval) != nil {
+			return false
+		}
+	}
+}
+
+// recordRunnerVersion 升级成功后将版本写入该 runner 的 runner_version，之后重装时保持一致
+func recordRunnerVersion(name, version string) {
+	err := config.LoadAndSaveBy(ConfigPath, config.AuthorSystem, "fleet.upgrade "+name+" → "+version, func(c *config.Config) error {
+		for i := range c.Runners.Items {
+			if c.Runners.Items[i].Name == name {
+				c.Runners.Items[i].RunnerVersion = version
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[upgrade] %s 写入 runner_version 失败: %v", name, err)
+	}
+}
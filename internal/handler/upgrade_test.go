@@ -0,0 +1,341 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/lab-dev/github-actions-runner-manager/internal/installer"
+	"github.com/lab-dev/github-actions-runner-manager/internal/rollout"
+	"github.com/labstack/echo/v4"
+)
+
+// fakeOps 记录操作序列的 runnerOps；offline 中的 runner 启动后不会上线
+type fakeOps struct {
+	mu      sync.Mutex
+	running map[string]bool
+	offline map[string]bool
+	ops     []string
+}
+
+type fakeSwap struct {
+	ops  *fakeOps
+	name string
+}
+
+func (s fakeSwap) Commit() error   { s.ops.record("commit " + s.name); return nil }
+func (s fakeSwap) Rollback() error { s.ops.record("rollback " + s.name); return nil }
+
+func (f *fakeOps) record(op string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ops = append(f.ops, op)
+}
+
+func (f *fakeOps) Prepare(context.Context, *config.Config, string, []string) error { return nil }
+func (f *fakeOps) Busy(*config.Config, config.RunnerItem) bool                     { return false }
+func (f *fakeOps) Running(_ context.Context, _ *config.Config, item config.RunnerItem) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.running[item.Name]
+}
+func (f *fakeOps) Stop(_ context.Context, _ *config.Config, item config.RunnerItem) error {
+	f.record("stop " + item.Name)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.running[item.Name] = false
+	return nil
+}
+func (f *fakeOps) Start(_ context.Context, _ *config.Config, item config.RunnerItem) error {
+	f.record("start " + item.Name)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.running[item.Name] = true
+	return nil
+}
+func (f *fakeOps) Remove(_ context.Context, _ *config.Config, item config.RunnerItem) error {
+	f.record("remove " + item.Name)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.running[item.Name] = false
+	return nil
+}
+func (f *fakeOps) Online(ctx context.Context, cfg *config.Config, item config.RunnerItem) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.running[item.Name] && !f.offline[item.Name]
+}
+func (f *fakeOps) Swap(_ context.Context, _ *config.Config, _ string, item config.RunnerItem, _ io.Writer) (binarySwap, error) {
+	f.record("swap " + item.Name)
+	return fakeSwap{ops: f, name: item.Name}, nil
+}
+
+func TestFleetUpgrade_HaltsWhenBudgetExceeded(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	cfg := &config.Config{Runners: config.RunnersConfig{BasePath: dir, Arch: config.ArchX64}}
+	for _, name := range []string{"r1", "r2", "r3", "r4"} {
+		cfg.Runners.Items = append(cfg.Runners.Items, config.RunnerItem{Name: name, TargetType: "org", Target: "o1"})
+		if name != "r4" {
+			// r4 未注册，应被跳过
+			_ = os.MkdirAll(filepath.Join(dir, name), 0755)
+			_ = os.WriteFile(filepath.Join(dir, name, ".runner"), []byte("{}"), 0644)
+		}
+	}
+	_ = cfg.Save(cfgPath)
+	ConfigPath = cfgPath
+	defer func() { ConfigPath = filepath.Join(os.TempDir(), "handler-test-config.yaml") }()
+
+	fake := &fakeOps{running: map[string]bool{"r1": true, "r2": true, "r3": true}, offline: map[string]bool{"r2": true}}
+	savedOps, savedPoll := fleetOps, upgradePollInterval
+	fleetOps, upgradePollInterval = fake, time.Millisecond
+	defer func() { fleetOps, upgradePollInterval = savedOps, savedPoll }()
+
+	e := echo.New()
+	e.Use(Auth("", ""))
+	e.POST("/api/fleet/upgrade", StartFleetUpgrade)
+	e.GET("/api/fleet/upgrade", GetFleetUpgrade)
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/fleet/upgrade", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := post(`{"version":"latest"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid version: expected 400, got %d", rec.Code)
+	}
+	if rec := post(`{"version":"9.9.9"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("version without checksum: expected 400, got %d", rec.Code)
+	}
+	if rec := post(`{"version":"2.331.0","runners":["missing"]}`); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown runner: expected 404, got %d", rec.Code)
+	}
+	rec := post(`{"version":"2.331.0","online_timeout_seconds":1}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("start: status = %d body=%s", rec.Code, rec.Body.String())
+	}
+
+	var r *rollout.Rollout
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		req := httptest.NewRequest(http.MethodGet, "/api/fleet/upgrade", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		var out struct {
+			Upgrade rollout.Rollout `json:"upgrade"`
+		}
+		_ = json.NewDecoder(rec.Body).Decode(&out)
+		if out.Upgrade.State != rollout.StateRunning {
+			r = &out.Upgrade
+			break
+		}
+	}
+	if r == nil {
+		t.Fatal("upgrade did not finish")
+	}
+	if r.State != rollout.StateHalted || r.Failures != 1 {
+		t.Fatalf("expected halted with 1 failure, got %s/%d: %s", r.State, r.Failures, r.Error)
+	}
+	want := []string{rollout.StepDone, rollout.StepFailed, rollout.StepSkipped, rollout.StepSkipped}
+	for i, s := range r.Steps {
+		if s.State != want[i] {
+			t.Errorf("step %s = %s (%s), want %s", s.Runner, s.State, s.Message, want[i])
+		}
+	}
+	if !r.Steps[1].RolledBack {
+		t.Error("r2 should be rolled back")
+	}
+	got := strings.Join(fake.ops, ",")
+	wantOps := "stop r1,swap r1,start r1,commit r1,stop r2,swap r2,start r2,stop r2,rollback r2,start r2"
+	if got != wantOps {
+		t.Errorf("ops = %s\nwant  %s", got, wantOps)
+	}
+	latest, _ := config.Load(cfgPath)
+	if latest.Runners.Items[0].RunnerVersion != "2.331.0" || latest.Runners.Items[1].RunnerVersion != "" {
+		t.Errorf("runner_version not recorded only for upgraded runner: %+v", latest.Runners.Items)
+	}
+}
+
+// stuckOps 停止后进程仍在运行
+type stuckOps struct{ fakeOps }
+
+func (f *stuckOps) Stop(_ context.Context, _ *config.Config, item config.RunnerItem) error {
+	f.record("stop " + item.Name)
+	return nil
+}
+
+func TestStopAndWait_HonorsContext(t *testing.T) {
+	fake := &stuckOps{fakeOps{running: map[string]bool{"r1": true}}}
+	savedOps, savedPoll := fleetOps, upgradePollInterval
+	fleetOps, upgradePollInterval = fake, time.Hour
+	defer func() { fleetOps, upgradePollInterval = savedOps, savedPoll }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	start := time.Now()
+	err := stopAndWait(ctx, &config.Config{}, config.RunnerItem{Name: "r1"})
+	if err == nil || !errors.Is(err, context.Canceled) {
+		t.Errorf("stopAndWait = %v, want context.Canceled", err)
+	}
+	if waitOnline(ctx, &config.Config{}, config.RunnerItem{Name: "r1"}, time.Hour) {
+		t.Error("waitOnline should give up once ctx is done")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("waits ignored cancellation for %s", d)
+	}
+}
+
+// claimOnStopOps 排空检查时空闲，停止前恰好领取了 Job：停止后 Worker 日志仍无结论
+type claimOnStopOps struct {
+	fakeOps
+	busy map[string]bool
+}
+
+func (f *claimOnStopOps) Busy(_ *config.Config, item config.RunnerItem) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.busy[item.Name]
+}
+
+func (f *claimOnStopOps) Stop(ctx context.Context, cfg *config.Config, item config.RunnerItem) error {
+	f.mu.Lock()
+	f.busy[item.Name] = true
+	f.mu.Unlock()
+	return f.fakeOps.Stop(ctx, cfg, item)
+}
+
+func TestUpgradeRunner_ReportsJobClaimedBeforeStop(t *testing.T) {
+	cfg := &config.Config{Runners: config.RunnersConfig{BasePath: t.TempDir()}}
+	item := config.RunnerItem{Name: "r1", TargetType: "org", Target: "o1"}
+	fake := &claimOnStopOps{fakeOps: fakeOps{running: map[string]bool{"r1": true}}, busy: map[string]bool{}}
+	savedOps, savedPoll := fleetOps, upgradePollInterval
+	fleetOps, upgradePollInterval = fake, time.Millisecond
+	defer func() { fleetOps, upgradePollInterval = savedOps, savedPoll }()
+
+	r := &rollout.Rollout{ID: "u1", Version: "2.331.0", DrainTimeoutSeconds: 1, OnlineTimeoutSeconds: 1, Steps: []rollout.Step{{Runner: "r1"}}}
+	if err := upgradeRunner(context.Background(), cfg, item, r, &r.Steps[0]); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Steps[0].JobInterrupted || !strings.Contains(r.Steps[0].Message, "中断") {
+		t.Errorf("upgrade step = %+v, want job_interrupted", r.Steps[0])
+	}
+}
+
+func TestRecoverFleetUpgrade(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{Runners: config.RunnersConfig{BasePath: dir, Items: []config.RunnerItem{
+		{Name: "r1", TargetType: "org", Target: "o1"},
+		{Name: "r2", TargetType: "org", Target: "o1"},
+		{Name: "r3", TargetType: "org", Target: "o1"},
+	}}}
+	// r2 在换入新版本后、提交前 Manager 退出
+	r2 := filepath.Join(dir, "r2")
+	_ = os.MkdirAll(filepath.Join(r2, ".upgrade-backup", "bin"), 0755)
+	_ = os.WriteFile(filepath.Join(r2, ".upgrade-backup", "bin", "Runner.Listener"), []byte("old"), 0755)
+	_ = os.WriteFile(filepath.Join(r2, ".upgrade-backup", installer.VersionFileName), []byte("2.330.0\n"), 0644)
+	_ = os.MkdirAll(filepath.Join(r2, "bin"), 0755)
+	_ = os.WriteFile(filepath.Join(r2, "bin", "Runner.Listener"), []byte("new"), 0755)
+	_ = os.WriteFile(filepath.Join(r2, installer.VersionFileName), []byte("2.331.0\n"), 0644)
+	sd := cfg.Runners.StateDir()
+	_ = rollout.Save(sd, &rollout.Rollout{ID: "upg-1", Version: "2.331.0", State: rollout.StateRunning, Steps: []rollout.Step{
+		{Runner: "r1", State: rollout.StepDone},
+		{Runner: "r2", State: rollout.StepVerifying},
+		{Runner: "r3", State: rollout.StepPending},
+	}})
+
+	fake := &fakeOps{running: map[string]bool{"r2": true}}
+	savedOps, savedPoll := fleetOps, upgradePollInterval
+	fleetOps, upgradePollInterval = fake, time.Millisecond
+	defer func() { fleetOps, upgradePollInterval = savedOps, savedPoll }()
+
+	RecoverFleetUpgrade(cfg)
+	if got := strings.Join(fake.ops, ","); got != "stop r2,start r2" {
+		t.Errorf("ops = %s, want the running r2 stopped before restoring and started after", got)
+	}
+	r, err := rollout.Load(sd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.State != rollout.StateFailed || r.Failures != 1 || r.FinishedAt.IsZero() {
+		t.Errorf("rollout = %s failures=%d", r.State, r.Failures)
+	}
+	want := []string{rollout.StepDone, rollout.StepFailed, rollout.StepSkipped}
+	for i, s := range r.Steps {
+		if s.State != want[i] {
+			t.Errorf("step %s = %s, want %s", s.Runner, s.State, want[i])
+		}
+	}
+	if !r.Steps[1].RolledBack {
+		t.Error("r2 should be marked rolled back")
+	}
+	if b, _ := os.ReadFile(filepath.Join(r2, "bin", "Runner.Listener")); string(b) != "old" || installer.InstalledVersion(r2) != "2.330.0" {
+		t.Errorf("r2 not restored: %q %q", b, installer.InstalledVersion(r2))
+	}
+	if _, err := os.Stat(filepath.Join(r2, ".upgrade-backup")); !os.IsNotExist(err) {
+		t.Error(".upgrade-backup should be removed")
+	}
+
+	// GET 只读取进度，不再修改
+	before, _ := os.ReadFile(filepath.Join(sd, "upgrade.json"))
+	e := echo.New()
+	ConfigPath = filepath.Join(dir, "config.yaml")
+	defer func() { ConfigPath = filepath.Join(os.TempDir(), "handler-test-config.yaml") }()
+	_ = cfg.Save(ConfigPath)
+	e.GET("/api/fleet/upgrade", GetFleetUpgrade)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/fleet/upgrade", nil))
+	after, _ := os.ReadFile(filepath.Join(sd, "upgrade.json"))
+	if rec.Code != http.StatusOK || string(before) != string(after) {
+		t.Errorf("GET changed state: %d", rec.Code)
+	}
+}
+
+func TestStartFleetUpgrade_DeduplicatesRunners(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	cfg := &config.Config{Runners: config.RunnersConfig{BasePath: dir, Arch: config.ArchX64, Items: []config.RunnerItem{
+		{Name: "r1", TargetType: "org", Target: "o1"},
+	}}}
+	_ = os.MkdirAll(filepath.Join(dir, "r1"), 0755)
+	_ = os.WriteFile(filepath.Join(dir, "r1", ".runner"), []byte("{}"), 0644)
+	_ = cfg.Save(cfgPath)
+	ConfigPath = cfgPath
+	defer func() { ConfigPath = filepath.Join(os.TempDir(), "handler-test-config.yaml") }()
+
+	fake := &fakeOps{running: map[string]bool{}}
+	savedOps, savedPoll := fleetOps, upgradePollInterval
+	fleetOps, upgradePollInterval = fake, time.Millisecond
+	defer func() { fleetOps, upgradePollInterval = savedOps, savedPoll }()
+
+	e := echo.New()
+	e.Use(Auth("", ""))
+	e.POST("/api/fleet/upgrade", StartFleetUpgrade)
+	req := httptest.NewRequest(http.MethodPost, "/api/fleet/upgrade", strings.NewReader(`{"version":"2.331.0","runners":["r1","r1"]}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	var r rollout.Rollout
+	_ = json.NewDecoder(rec.Body).Decode(&r)
+	if rec.Code != http.StatusAccepted || len(r.Steps) != 1 {
+		t.Fatalf("status = %d, steps = %+v", rec.Code, r.Steps)
+	}
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		upgradeMu.Lock()
+		done := upgradeCancel == nil
+		upgradeMu.Unlock()
+		if done {
+			return
+		}
+	}
+	t.Error("upgrade did not finish")
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/lab-dev/github-actions-runner-manager/internal/runner"
+)
+
+// applyUsage 填充资源占用并按 usage_thresholds 计算告警，就地修改：
+// 容器模式下目录大小来自 Agent（不可达时由 Manager 直接统计挂载目录），CPU/内存来自一次批量 docker stats；
+// 进程模式下由 Manager 读取 /proc 与磁盘统计。
+func applyUsage(ctx context.Context, cfg *config.Config, infos []*runner.RunnerInfo) {
+	var running []string
+	for _, info := range infos {
+		if info.Status == runner.StatusMissing {
+			info.Usage = nil
+			continue
+		}
+		if !cfg.Runners.ContainerMode {
+			info.Usage = runner.ProcessUsage(info.InstallDir)
+			continue
+		}
+		if info.Usage == nil {
+			info.Usage = runner.DirUsage(info.InstallDir)
+		}
+		if info.Running {
+			running = append(running, runner.ContainerName(info.Name))
+		}
+	}
+	if len(running) > 0 {
+		statsCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		stats, err := runner.ContainerStats(statsCtx, running)
+		if err != nil {
+			log.Printf("[usage] docker stats 失败: %v", err)
+		}
+		for _, info := range infos {
+			if st, ok := stats[runner.ContainerName(info.Name)]; ok && info.Usage != nil {
+				info.Usage.CPUPercent = st.CPUPercent
+				info.Usage.MemoryRSSBytes = st.MemoryBytes
+			}
+		}
+	}
+	for _, info := range infos {
+		info.Usage.ApplyThresholds(cfg.Runners.UsageThresholds)
+	}
+}
+
+// applyUsageList 对 List 结果调用 applyUsage
+func applyUsageList(ctx context.Context, cfg *config.Config, list []runner.RunnerInfo) {
+	infos := make([]*runner.RunnerInfo, len(list))
+	for i := range list {
+		infos[i] = &list[i]
+	}
+	applyUsage(ctx, cfg, infos)
+}
@@ -0,0 +1,563 @@
+// Package installer 由 Manager 原生安装 GitHub Actions runner：安装包下载到 base_path/.cache 并按 manifest 中的 SHA-256 校验，
+// 同一版本只下载一次，各 runner 目录从缓存解压。安装完成后在目录写入 .runner_version 记录已安装版本。
+package installer
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPlatform 默认安装包平台
+const DefaultPlatform = "linux-x64"
+
+// VersionFileName runner 目录下记录已安装版本的文件
+const VersionFileName = ".runner_version"
+
+// ArchFileName runner 目录下记录安装包架构的文件
+const ArchFileName = ".runner_arch"
+
+// Platform 返回架构（x64/arm64/arm）对应的安装包平台，如 linux-arm64
+func Platform(arch string) string {
+	return "linux-" + arch
+}
+
+// DownloadBaseURL actions/runner 发布下载地址，测试中可替换
+var DownloadBaseURL = "https://github.com/actions/runner/releases/download"
+
+// ReleaseAPIBase actions/runner 按 tag 查询发布信息的 API 地址，测试中可替换
+var ReleaseAPIBase = "https://api.github.com/repos/actions/runner/releases/tags"
+
+// Manifest 各版本各平台安装包的 SHA-256：version -> platform -> sha256
+type Manifest map[string]map[string]string
+
+// builtinManifest 内置校验值，runners.runner_manifest 中的条目会覆盖或补充；
+// 未收录的版本或平台（如 linux-arm64、linux-arm）须在 manifest 中补充，或开启 Cache.ReleaseNotes
+var builtinManifest = Manifest{
+	"2.331.0": {
+		"linux-x64": "5fcc01bd546ba5c3f1291c2803658ebd3cedb3836489eda3be357d41bfcf28a7",
+	},
+}
+
+// LoadManifest 返回内置 manifest 与 path 指向的 YAML 文件合并后的结果；path 为空时只返回内置条目
+func LoadManifest(path string) (Manifest, error) {
+	m := Manifest{}
+	for v, platforms := range builtinManifest {
+		m[v] = map[string]string{}
+		for p, sum := range platforms {
+			m[v][p] = sum
+		}
+	}
+	if strings.TrimSpace(path) == "" {
+		return m, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取 runner manifest 失败: %w", err)
+	}
+	var extra Manifest
+	if err := yaml.Unmarshal(data, &extra); err != nil {
+		return nil, fmt.Errorf("解析 runner manifest 失败: %w", err)
+	}
+	for v, platforms := range extra {
+		if m[v] == nil {
+			m[v] = map[string]string{}
+		}
+		for p, sum := range platforms {
+			m[v][p] = strings.ToLower(strings.TrimSpace(sum))
+		}
+	}
+	return m, nil
+}
+
+// Checksum 返回指定版本与平台的 SHA-256
+func (m Manifest) Checksum(version, platform string) (string, bool) {
+	sum, ok := m[version][platform]
+	return sum, ok && sum != ""
+}
+
+// TarballName 返回安装包文件名，如 actions-runner-linux-x64-2.331.0.tar.gz
+func TarballName(version, platform string) string {
+	return "actions-runner-" + platform + "-" + version + ".tar.gz"
+}
+
+// TarballURL 返回安装包下载地址
+func TarballURL(version, platform string) string {
+	return DownloadBaseURL + "/v" + version + "/" + TarballName(version, platform)
+}
+
+// Cache 安装包缓存
+type Cache struct {
+	Dir      string
+	Manifest Manifest
+	Client   *http.Client // nil 时使用 30 分钟超时的默认客户端
+	// ReleaseNotes manifest 未收录时读取发布说明中的校验值（runners.runner_checksum_from_release_notes）
+	ReleaseNotes bool
+}
+
+// fileLocks 按缓存文件路径加锁，并行安装同一版本时只下载一次
+var fileLocks sync.Map
+
+func lockFile(p string) func() {
+	v, _ := fileLocks.LoadOrStore(p, &sync.Mutex{})
+	m := v.(*sync.Mutex)
+	m.Lock()
+	return m.Unlock
+}
+
+// releaseSHARe 发布说明中各安装包的校验值，如 <!-- BEGIN SHA linux-arm64 -->…<!-- END SHA linux-arm64 -->
+var releaseSHARe = regexp.MustCompile(`<!-- BEGIN SHA ([a-z0-9-]+) -->\s*([0-9a-fA-F]{64})\s*<!-- END SHA`)
+
+// releaseSums 已从发布说明读取的校验值：version -> platform -> sha256
+var (
+	releaseSumsMu sync.Mutex
+	releaseSums   = Manifest{}
+)
+
+// Checksum 返回安装包的 SHA-256：优先取 manifest；未收录且开启 ReleaseNotes 时读取 actions/runner 该版本发布说明中公布的值（按版本缓存）。
+// 发布说明与安装包同源，只能发现下载损坏，不能发现发布本身被替换，因此默认不使用
+func (c *Cache) Checksum(ctx context.Context, version, platform string) (string, error) {
+	if sum, ok := c.Manifest.Checksum(version, platform); ok {
+		return sum, nil
+	}
+	if !c.ReleaseNotes {
+		return "", fmt.Errorf("runner %s（%s）未在 manifest 中找到校验值，请在 runners.runner_manifest 中补充该版本与平台的 sha256，"+
+			"或设置 runners.runner_checksum_from_release_notes: true 使用发布说明中的值", version, platform)
+	}
+	releaseSumsMu.Lock()
+	sum, ok := releaseSums.Checksum(version, platform)
+	releaseSumsMu.Unlock()
+	if ok {
+		return sum, nil
+	}
+	sums, err := c.fetchReleaseChecksums(ctx, version)
+	if err != nil {
+		return "", fmt.Errorf("runner %s（%s）未在 manifest 中找到校验值，且读取发布说明失败: %w；请在 runners.runner_manifest 中补充", version, platform, err)
+	}
+	releaseSumsMu.Lock()
+	releaseSums[version] = sums
+	releaseSumsMu.Unlock()
+	if sum, ok := sums[platform]; ok {
+		return sum, nil
+	}
+	return "", fmt.Errorf("runner %s 的发布说明中没有 %s 的校验值，请在 runners.runner_manifest 中补充", version, platform)
+}
+
+// fetchReleaseChecksums 读取 actions/runner v<version> 发布说明中的全部校验值：platform -> sha256
+func (c *Cache) fetchReleaseChecksums(ctx context.Context, version string) (map[string]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ReleaseAPIBase+"/v"+version, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	client := c.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s 返回 HTTP %d", req.URL, resp.StatusCode)
+	}
+	var release struct {
+		Body string `json:"body"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&release); err != nil {
+		return nil, fmt.Errorf("解析发布信息失败: %w", err)
+	}
+	sums := map[string]string{}
+	for _, m := range releaseSHARe.FindAllStringSubmatch(release.Body, -1) {
+		sums[m[1]] = strings.ToLower(m[2])
+	}
+	if len(sums) == 0 {
+		return nil, errors.New("发布说明中没有校验值")
+	}
+	return sums, nil
+}
+
+// Ensure 确保缓存中有已校验的安装包并返回其路径：缓存命中时重新校验，不一致则删除后重新下载；log 接收进度输出
+func (c *Cache) Ensure(ctx context.Context, version, platform string, log io.Writer) (string, error) {
+	want, err := c.Checksum(ctx, version, platform)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return "", err
+	}
+	p := filepath.Join(c.Dir, TarballName(version, platform))
+	unlock := lockFile(p)
+	defer unlock()
+
+	if _, err := os.Stat(p); err == nil {
+		got, err := fileSHA256(p)
+		if err == nil && got == want {
+			fmt.Fprintf(log, "使用缓存 %s\n", p)
+			return p, nil
+		}
+		fmt.Fprintf(log, "缓存 %s 校验失败，重新下载\n", p)
+		_ = os.Remove(p)
+	}
+	if err := c.download(ctx, version, platform, p, want, log); err != nil {
+		return "", err
+	}
+	return p, nil
+}
+
+func (c *Cache) download(ctx context.Context, version, platform, dest, want string, log io.Writer) error {
+	url := TarballURL(version, platform)
+	fmt.Fprintf(log, "下载 %s ...\n", url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	client := c.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Minute}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("下载 runner 失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("下载 runner 失败: %s 返回 HTTP %d", url, resp.StatusCode)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), filepath.Base(dest)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h), resp.Body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("下载 runner 失败: %w", err)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != want {
+		return fmt.Errorf("runner 安装包校验失败: 期望 sha256 %s，实际 %s", want, got)
+	}
+	fmt.Fprintf(log, "下载完成（%d 字节），sha256 校验通过\n", n)
+	return os.Rename(tmp.Name(), dest)
+}
+
+func fileSHA256(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Install 从缓存安装指定版本到 dest：必要时下载，解压后写入 .runner_version。
+// 解压覆盖同名文件，不删除 dest 中其他文件（.runner、.credentials、_work 等保持不变）
+func (c *Cache) Install(ctx context.Context, version, platform, dest string, log io.Writer) error {
+	tarball, err := c.Ensure(ctx, version, platform, log)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(log, "解压到 %s ...\n", dest)
+	if err := Extract(ctx, tarball, dest); err != nil {
+		return fmt.Errorf("解压 runner 失败: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dest, VersionFileName), []byte(version+"\n"), 0644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dest, ArchFileName), []byte(strings.TrimPrefix(platform, "linux-")+"\n"), 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(log, "完成。已安装 runner %s（%s）\n", version, platform)
+	return nil
+}
+
+// InstalledVersion 读取 dest 中记录的已安装版本，无记录返回空
+func InstalledVersion(dir string) string {
+	b, err := os.ReadFile(filepath.Join(dir, VersionFileName))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// InstalledArch 读取 dest 中记录的安装包架构，无记录（如手动安装）返回空
+func InstalledArch(dir string) string {
+	b, err := os.ReadFile(filepath.Join(dir, ArchFileName))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// errUnsafePath 压缩包内路径逃逸出目标目录
+var errUnsafePath = errors.New("压缩包包含非法路径")
+
+// Extract 将 tar.gz 解压到 dest，拒绝绝对路径与 .. 逃逸的条目及指向目录外的符号链接
+func Extract(ctx context.Context, tarball, dest string) error {
+	f, err := os.Open(tarball)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		target, ok := safeJoin(dest, hdr.Name)
+		if !ok {
+			return fmt.Errorf("%w: %s", errUnsafePath, hdr.Name)
+		}
+		mode := os.FileMode(hdr.Mode).Perm()
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, mode|0700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeFile(target, tr, mode); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			linkTarget := hdr.Linkname
+			if filepath.IsAbs(linkTarget) {
+				return fmt.Errorf("%w: %s -> %s", errUnsafePath, hdr.Name, linkTarget)
+			}
+			if _, ok := safeJoin(dest, filepath.Join(filepath.Dir(hdr.Name), linkTarget)); !ok {
+				return fmt.Errorf("%w: %s -> %s", errUnsafePath, hdr.Name, linkTarget)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			_ = os.Remove(target)
+			if err := os.Symlink(linkTarget, target); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// safeJoin 拼接 dest 与压缩包内路径，结果须位于 dest 内
+func safeJoin(dest, name string) (string, bool) {
+	if filepath.IsAbs(name) {
+		return "", false
+	}
+	target := filepath.Join(dest, name)
+	rel, err := filepath.Rel(dest, target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return target, true
+}
+
+// writeFile 先写临时文件再 rename，覆盖正在运行的二进制时不会出现 text file busy
+func writeFile(target string, r io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	tmp := target + ".installing"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, target)
+}
+
+// 升级时的临时目录，位于 runner 目录内，与 runner 同盘以便 rename
+const (
+	stagingDirName = ".upgrade-staging"
+	backupDirName  = ".upgrade-backup"
+	// trashDirName Commit 时备份先原子地改名为此再删除，残留时说明升级已提交，可直接删除
+	trashDirName = ".upgrade-trash"
+)
+
+// Swap 一次已换入新版本的升级，验证后 Commit 删除备份，失败时 Rollback 恢复原文件
+type Swap struct {
+	dir        string
+	backup     string
+	swapped    []string // 已换入的安装包顶层条目
+	backedUp   []string // 被移入备份目录的原条目
+	oldVersion string
+}
+
+// Upgrade 将 dir 中的 runner 升级为 version：先解压到 dir/.upgrade-staging，再逐个换入安装包的顶层条目（bin、externals、*.sh 等），
+// 原条目移到 dir/.upgrade-backup。.runner、.credentials、_work 等不在安装包中的文件保持不变。调用前 runner 须已停止
+func (c *Cache) Upgrade(ctx context.Context, version, platform, dir string, log io.Writer) (*Swap, error) {
+	tarball, err := c.Ensure(ctx, version, platform, log)
+	if err != nil {
+		return nil, err
+	}
+	staging := filepath.Join(dir, stagingDirName)
+	_ = os.RemoveAll(staging)
+	defer os.RemoveAll(staging)
+	fmt.Fprintf(log, "解压到 %s ...\n", staging)
+	if err := Extract(ctx, tarball, staging); err != nil {
+		return nil, fmt.Errorf("解压 runner 失败: %w", err)
+	}
+	entries, err := os.ReadDir(staging)
+	if err != nil {
+		return nil, err
+	}
+	s := &Swap{dir: dir, backup: filepath.Join(dir, backupDirName), oldVersion: InstalledVersion(dir)}
+	_ = os.RemoveAll(s.backup)
+	if err := os.MkdirAll(s.backup, 0755); err != nil {
+		return nil, err
+	}
+	// 备份中同时保存原版本记录，Manager 中途退出后 RecoverUpgrade 据此恢复
+	if s.oldVersion != "" {
+		if err := os.WriteFile(filepath.Join(s.backup, VersionFileName), []byte(s.oldVersion+"\n"), 0644); err != nil {
+			return nil, err
+		}
+	}
+	for _, e := range entries {
+		name := e.Name()
+		cur := filepath.Join(dir, name)
+		if _, err := os.Lstat(cur); err == nil {
+			if err := os.Rename(cur, filepath.Join(s.backup, name)); err != nil {
+				_ = s.Rollback()
+				return nil, fmt.Errorf("备份 %s 失败: %w", name, err)
+			}
+			s.backedUp = append(s.backedUp, name)
+		}
+		if err := os.Rename(filepath.Join(staging, name), cur); err != nil {
+			_ = s.Rollback()
+			return nil, fmt.Errorf("替换 %s 失败: %w", name, err)
+		}
+		s.swapped = append(s.swapped, name)
+	}
+	if err := os.WriteFile(filepath.Join(dir, VersionFileName), []byte(version+"\n"), 0644); err != nil {
+		_ = s.Rollback()
+		return nil, err
+	}
+	fmt.Fprintf(log, "已替换 %d 个条目为 runner %s（原版本 %s）\n", len(s.swapped), version, s.oldVersion)
+	return s, nil
+}
+
+// Commit 确认升级，删除备份
+func (s *Swap) Commit() error {
+	trash := filepath.Join(s.dir, trashDirName)
+	_ = os.RemoveAll(trash)
+	if err := os.Rename(s.backup, trash); err != nil {
+		return err
+	}
+	return os.RemoveAll(trash)
+}
+
+// Rollback 删除换入的条目并从备份恢复原文件与版本记录
+func (s *Swap) Rollback() error {
+	var firstErr error
+	keep := func(err error) {
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, name := range s.swapped {
+		keep(os.RemoveAll(filepath.Join(s.dir, name)))
+	}
+	for _, name := range s.backedUp {
+		keep(os.Rename(filepath.Join(s.backup, name), filepath.Join(s.dir, name)))
+	}
+	versionFile := filepath.Join(s.dir, VersionFileName)
+	if s.oldVersion != "" {
+		keep(os.WriteFile(versionFile, []byte(s.oldVersion+"\n"), 0644))
+	} else {
+		_ = os.Remove(versionFile)
+	}
+	if firstErr == nil {
+		keep(os.RemoveAll(s.backup))
+	}
+	return firstErr
+}
+
+// PendingUpgrade 判断 dir 中是否有未提交的升级（残留 .upgrade-backup），需在 runner 停止后调用 RecoverUpgrade
+func PendingUpgrade(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, backupDirName))
+	return err == nil
+}
+
+// RecoverUpgrade 处理 Manager 在升级中途退出后 dir 中残留的临时目录：删除 staging 与已提交的备份；
+// 存在未提交的 .upgrade-backup 时将其中的原条目与版本记录换回 dir，返回 true。调用前 runner 须已停止。
+// 新版本安装包中原本没有的顶层条目会保留，不影响原版本运行
+func RecoverUpgrade(dir string) (bool, error) {
+	_ = os.RemoveAll(filepath.Join(dir, stagingDirName))
+	_ = os.RemoveAll(filepath.Join(dir, trashDirName))
+	backup := filepath.Join(dir, backupDirName)
+	entries, err := os.ReadDir(backup)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	var errs []error
+	hasVersion := false
+	for _, e := range entries {
+		name := e.Name()
+		hasVersion = hasVersion || name == VersionFileName
+		cur := filepath.Join(dir, name)
+		if err := os.RemoveAll(cur); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := os.Rename(filepath.Join(backup, name), cur); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if !hasVersion {
+		// 原本没有版本记录
+		if err := os.Remove(filepath.Join(dir, VersionFileName)); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		errs = append(errs, os.RemoveAll(backup))
+	}
+	return true, errors.Join(errs...)
+}
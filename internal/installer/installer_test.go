@@ -0,0 +1,369 @@
+package installer
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+type tarEntry struct {
+	name, body, link string
+	mode             int64
+	typ              byte
+}
+
+func makeTarball(t *testing.T, entries []tarEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: e.mode, Typeflag: e.typ, Linkname: e.link, Size: int64(len(e.body))}
+		if e.typ != tar.TypeReg {
+			hdr.Size = 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if e.typ == tar.TypeReg {
+			_, _ = tw.Write([]byte(e.body))
+		}
+	}
+	_ = tw.Close()
+	_ = gz.Close()
+	return buf.Bytes()
+}
+
+func sha(b []byte) string {
+	s := sha256.Sum256(b)
+	return hex.EncodeToString(s[:])
+}
+
+// serve 启动返回 tarball 的测试服务器并替换 DownloadBaseURL，返回下载次数
+func serve(t *testing.T, tarball []byte) *atomic.Int32 {
+	t.Helper()
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/v9.9.9/"+TarballName("9.9.9", DefaultPlatform)) {
+			http.NotFound(w, r)
+			return
+		}
+		hits.Add(1)
+		_, _ = w.Write(tarball)
+	}))
+	t.Cleanup(srv.Close)
+	old := DownloadBaseURL
+	DownloadBaseURL = srv.URL
+	t.Cleanup(func() { DownloadBaseURL = old })
+	return &hits
+}
+
+func TestInstall_DownloadsOnceAndExtracts(t *testing.T) {
+	tarball := makeTarball(t, []tarEntry{
+		{name: "bin/", typ: tar.TypeDir, mode: 0755},
+		{name: "config.sh", body: "#!/bin/sh\n", typ: tar.TypeReg, mode: 0755},
+		{name: "bin/Runner.Listener", body: "listener", typ: tar.TypeReg, mode: 0755},
+		{name: "run.sh", link: "config.sh", typ: tar.TypeSymlink},
+	})
+	hits := serve(t, tarball)
+	base := t.TempDir()
+	cache := &Cache{Dir: filepath.Join(base, ".cache"), Manifest: Manifest{"9.9.9": {DefaultPlatform: sha(tarball)}}}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i := range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = cache.Install(context.Background(), "9.9.9", DefaultPlatform, filepath.Join(base, "r"+string(rune('0'+i))), io.Discard)
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("install %d: %v", i, err)
+		}
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("expected 1 download, got %d", n)
+	}
+	dir := filepath.Join(base, "r0")
+	st, err := os.Stat(filepath.Join(dir, "config.sh"))
+	if err != nil || st.Mode().Perm()&0100 == 0 {
+		t.Errorf("config.sh not extracted as executable: %v %v", st, err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, "bin", "Runner.Listener")); string(b) != "listener" {
+		t.Errorf("unexpected listener content %q", b)
+	}
+	if l, _ := os.Readlink(filepath.Join(dir, "run.sh")); l != "config.sh" {
+		t.Errorf("symlink = %q", l)
+	}
+	if v := InstalledVersion(dir); v != "9.9.9" {
+		t.Errorf("InstalledVersion = %q", v)
+	}
+	if a := InstalledArch(dir); a != "x64" {
+		t.Errorf("InstalledArch = %q", a)
+	}
+}
+
+func TestInstall_PreservesRegistrationFiles(t *testing.T) {
+	tarball := makeTarball(t, []tarEntry{{name: "config.sh", body: "new", typ: tar.TypeReg, mode: 0755}})
+	serve(t, tarball)
+	base := t.TempDir()
+	dir := filepath.Join(base, "r1")
+	_ = os.MkdirAll(dir, 0755)
+	_ = os.WriteFile(filepath.Join(dir, ".runner"), []byte("{}"), 0644)
+	_ = os.WriteFile(filepath.Join(dir, "config.sh"), []byte("old"), 0755)
+	cache := &Cache{Dir: filepath.Join(base, ".cache"), Manifest: Manifest{"9.9.9": {DefaultPlatform: sha(tarball)}}}
+	if err := cache.Install(context.Background(), "9.9.9", DefaultPlatform, dir, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, "config.sh")); string(b) != "new" {
+		t.Errorf("config.sh not replaced: %q", b)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".runner")); err != nil {
+		t.Errorf(".runner removed: %v", err)
+	}
+}
+
+func TestEnsure_ChecksumMismatch(t *testing.T) {
+	tarball := makeTarball(t, []tarEntry{{name: "config.sh", body: "x", typ: tar.TypeReg, mode: 0755}})
+	serve(t, tarball)
+	dir := t.TempDir()
+	cache := &Cache{Dir: dir, Manifest: Manifest{"9.9.9": {DefaultPlatform: strings.Repeat("0", 64)}}}
+	if _, err := cache.Ensure(context.Background(), "9.9.9", DefaultPlatform, io.Discard); err == nil || !strings.Contains(err.Error(), "校验失败") {
+		t.Fatalf("expected checksum error, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("cache should be empty after failed download, got %d entries", len(entries))
+	}
+	if _, err := cache.Ensure(context.Background(), "1.0.0", DefaultPlatform, io.Discard); err == nil || !strings.Contains(err.Error(), "manifest") {
+		t.Errorf("expected missing manifest entry error, got %v", err)
+	}
+}
+
+func TestEnsure_RedownloadsCorruptCache(t *testing.T) {
+	tarball := makeTarball(t, []tarEntry{{name: "config.sh", body: "x", typ: tar.TypeReg, mode: 0755}})
+	hits := serve(t, tarball)
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, TarballName("9.9.9", DefaultPlatform)), []byte("corrupt"), 0644)
+	cache := &Cache{Dir: dir, Manifest: Manifest{"9.9.9": {DefaultPlatform: sha(tarball)}}}
+	if _, err := cache.Ensure(context.Background(), "9.9.9", DefaultPlatform, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if hits.Load() != 1 {
+		t.Errorf("expected corrupt cache to be re-downloaded")
+	}
+}
+
+func TestExtract_RejectsUnsafePaths(t *testing.T) {
+	for _, e := range []tarEntry{
+		{name: "../evil", body: "x", typ: tar.TypeReg, mode: 0644},
+		{name: "/abs", body: "x", typ: tar.TypeReg, mode: 0644},
+		{name: "link", link: "../../etc/passwd", typ: tar.TypeSymlink},
+	} {
+		dir := t.TempDir()
+		p := filepath.Join(dir, "t.tar.gz")
+		_ = os.WriteFile(p, makeTarball(t, []tarEntry{e}), 0644)
+		if err := Extract(context.Background(), p, filepath.Join(dir, "out")); !errors.Is(err, errUnsafePath) {
+			t.Errorf("%s: expected unsafe path error, got %v", e.name, err)
+		}
+	}
+}
+
+func TestPlatform(t *testing.T) {
+	if p := Platform("arm64"); p != "linux-arm64" {
+		t.Errorf("Platform(arm64) = %q", p)
+	}
+	if TarballName("2.331.0", Platform("arm64")) != "actions-runner-linux-arm64-2.331.0.tar.gz" {
+		t.Errorf("unexpected tarball name %q", TarballName("2.331.0", Platform("arm64")))
+	}
+}
+
+func TestLoadManifest(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "manifest.yaml")
+	_ = os.WriteFile(p, []byte("\"2.400.0\":\n  linux-x64: ABCDEF\n"), 0644)
+	m, err := LoadManifest(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum, ok := m.Checksum("2.400.0", DefaultPlatform); !ok || sum != "abcdef" {
+		t.Errorf("custom entry = %q %v", sum, ok)
+	}
+	if _, ok := m.Checksum("2.331.0", DefaultPlatform); !ok {
+		t.Error("builtin entry missing")
+	}
+	if _, err := LoadManifest(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected error for missing manifest")
+	}
+}
+
+func TestChecksum_ReleaseNotesFallbackIsOptIn(t *testing.T) {
+	armSum := strings.Repeat("a", 64)
+	arm64Sum := strings.Repeat("B", 64)
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.URL.Path != "/v2.331.0" {
+			http.NotFound(w, r)
+			return
+		}
+		body := "## Linux arm64\n- actions-runner-linux-arm64-2.331.0.tar.gz <!-- BEGIN SHA linux-arm64 -->" + arm64Sum + "<!-- END SHA linux-arm64 -->\n" +
+			"## Linux arm\n- actions-runner-linux-arm-2.331.0.tar.gz <!-- BEGIN SHA linux-arm -->" + armSum + "<!-- END SHA linux-arm -->\n"
+		_ = json.NewEncoder(w).Encode(map[string]string{"body": body})
+	}))
+	defer srv.Close()
+	old := ReleaseAPIBase
+	ReleaseAPIBase = srv.URL
+	defer func() { ReleaseAPIBase = old }()
+	releaseSumsMu.Lock()
+	releaseSums = Manifest{}
+	releaseSumsMu.Unlock()
+
+	m, _ := LoadManifest("")
+	cache := &Cache{Dir: t.TempDir(), Manifest: m}
+	ctx := context.Background()
+	if sum, err := cache.Checksum(ctx, "2.331.0", DefaultPlatform); err != nil || sum != builtinManifest["2.331.0"][DefaultPlatform] || hits.Load() != 0 {
+		t.Errorf("x64 should come from the builtin manifest: %q %v (hits %d)", sum, err, hits.Load())
+	}
+	// 未开启 ReleaseNotes：manifest 未收录的平台直接报错，不读取发布说明
+	if _, err := cache.Checksum(ctx, "2.331.0", Platform("arm64")); err == nil || !strings.Contains(err.Error(), "runner_manifest") || hits.Load() != 0 {
+		t.Errorf("without opt-in: err = %v (hits %d), want runner_manifest error", err, hits.Load())
+	}
+
+	cache.ReleaseNotes = true
+	for platform, want := range map[string]string{Platform("arm64"): strings.ToLower(arm64Sum), Platform("arm"): armSum} {
+		if sum, err := cache.Checksum(ctx, "2.331.0", platform); err != nil || sum != want {
+			t.Errorf("Checksum(%s) = %q, %v", platform, sum, err)
+		}
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("release notes fetched %d times, want 1", n)
+	}
+	if _, err := cache.Checksum(ctx, "2.331.0", "linux-s390x"); err == nil {
+		t.Error("expected error for a platform without a published checksum")
+	}
+	if _, err := cache.Checksum(ctx, "9.9.9", Platform("arm64")); err == nil {
+		t.Error("expected error for an unknown release")
+	}
+}
+
+func TestUpgrade_CommitAndRollback(t *testing.T) {
+	tarball := makeTarball(t, []tarEntry{
+		{name: "bin/", typ: tar.TypeDir, mode: 0755},
+		{name: "bin/Runner.Listener", body: "new", typ: tar.TypeReg, mode: 0755},
+		{name: "run.sh", body: "new", typ: tar.TypeReg, mode: 0755},
+	})
+	serve(t, tarball)
+	base := t.TempDir()
+	cache := &Cache{Dir: filepath.Join(base, ".cache"), Manifest: Manifest{"9.9.9": {DefaultPlatform: sha(tarball)}}}
+	setup := func(dir string) {
+		_ = os.MkdirAll(filepath.Join(dir, "bin"), 0755)
+		_ = os.WriteFile(filepath.Join(dir, "bin", "Runner.Listener"), []byte("old"), 0755)
+		_ = os.WriteFile(filepath.Join(dir, "bin", "old-only.dll"), []byte("old"), 0644)
+		_ = os.WriteFile(filepath.Join(dir, "run.sh"), []byte("old"), 0755)
+		_ = os.WriteFile(filepath.Join(dir, ".credentials"), []byte("secret"), 0600)
+		_ = os.WriteFile(filepath.Join(dir, VersionFileName), []byte("9.9.8\n"), 0644)
+	}
+	read := func(p string) string { b, _ := os.ReadFile(p); return string(b) }
+
+	dir := filepath.Join(base, "r1")
+	setup(dir)
+	s, err := cache.Upgrade(context.Background(), "9.9.9", DefaultPlatform, dir, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read(filepath.Join(dir, "bin", "Runner.Listener")) != "new" || InstalledVersion(dir) != "9.9.9" {
+		t.Error("new binaries not swapped in")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "bin", "old-only.dll")); err == nil {
+		t.Error("stale file from old bin should be gone")
+	}
+	if err := s.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, backupDirName)); !os.IsNotExist(err) {
+		t.Error("backup should be removed after commit")
+	}
+	if read(filepath.Join(dir, ".credentials")) != "secret" {
+		t.Error(".credentials changed")
+	}
+
+	dir = filepath.Join(base, "r2")
+	setup(dir)
+	s, err = cache.Upgrade(context.Background(), "9.9.9", DefaultPlatform, dir, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if read(filepath.Join(dir, "bin", "Runner.Listener")) != "old" || read(filepath.Join(dir, "run.sh")) != "old" || InstalledVersion(dir) != "9.9.8" {
+		t.Error("rollback did not restore old files")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "bin", "old-only.dll")); err != nil {
+		t.Error("rollback lost old-only file")
+	}
+}
+
+func TestRecoverUpgrade(t *testing.T) {
+	tarball := makeTarball(t, []tarEntry{
+		{name: "bin/", typ: tar.TypeDir, mode: 0755},
+		{name: "bin/Runner.Listener", body: "new", typ: tar.TypeReg, mode: 0755},
+	})
+	serve(t, tarball)
+	base := t.TempDir()
+	cache := &Cache{Dir: filepath.Join(base, ".cache"), Manifest: Manifest{"9.9.9": {DefaultPlatform: sha(tarball)}}}
+	read := func(p string) string { b, _ := os.ReadFile(p); return string(b) }
+	for _, oldVersion := range []string{"9.9.8", ""} {
+		dir := filepath.Join(base, "r"+oldVersion)
+		_ = os.MkdirAll(filepath.Join(dir, "bin"), 0755)
+		_ = os.WriteFile(filepath.Join(dir, "bin", "Runner.Listener"), []byte("old"), 0755)
+		if oldVersion != "" {
+			_ = os.WriteFile(filepath.Join(dir, VersionFileName), []byte(oldVersion+"\n"), 0644)
+		}
+		// 换入新版本后既未 Commit 也未 Rollback，模拟 Manager 中途退出
+		if _, err := cache.Upgrade(context.Background(), "9.9.9", DefaultPlatform, dir, io.Discard); err != nil {
+			t.Fatal(err)
+		}
+		_ = os.MkdirAll(filepath.Join(dir, stagingDirName), 0755)
+		restored, err := RecoverUpgrade(dir)
+		if err != nil || !restored {
+			t.Fatalf("RecoverUpgrade = %v, %v", restored, err)
+		}
+		if read(filepath.Join(dir, "bin", "Runner.Listener")) != "old" || InstalledVersion(dir) != oldVersion {
+			t.Errorf("old version %q not restored: version=%q", oldVersion, InstalledVersion(dir))
+		}
+		for _, name := range []string{backupDirName, stagingDirName} {
+			if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+				t.Errorf("%s should be removed", name)
+			}
+		}
+	}
+
+	// 已提交的升级：删除残留的 trash，不恢复
+	dir := filepath.Join(base, "committed")
+	_ = os.MkdirAll(filepath.Join(dir, trashDirName, "bin"), 0755)
+	_ = os.MkdirAll(filepath.Join(dir, "bin"), 0755)
+	_ = os.WriteFile(filepath.Join(dir, "bin", "Runner.Listener"), []byte("new"), 0755)
+	if restored, err := RecoverUpgrade(dir); err != nil || restored {
+		t.Errorf("RecoverUpgrade on committed dir = %v, %v", restored, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, trashDirName)); !os.IsNotExist(err) || read(filepath.Join(dir, "bin", "Runner.Listener")) != "new" {
+		t.Error("committed upgrade should keep new files and drop the trash dir")
+	}
+}
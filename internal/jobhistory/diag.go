@@ -0,0 +1,212 @@
+package jobhistory
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+)
+
+// DiagDirName runner 诊断日志目录名；每次 Job 对应一个 Worker_<时间>-utc.log
+const DiagDirName = "_diag"
+
+const diagTimeLayout = "2006-01-02 15:04:05"
+
+var (
+	diagLineTimeRe   = regexp.MustCompile(`^\[(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2})Z`)
+	diagFileTimeRe   = regexp.MustCompile(`^Worker_(\d{8}-\d{6})-utc\.log$`)
+	diagJobNameRe    = regexp.MustCompile(`Running job: (.+)$`)
+	diagJobDisplayRe = regexp.MustCompile(`"jobDisplayName":\s*"([^"]+)"`)
+	diagRepoRe       = regexp.MustCompile(`"repository":\s*"([^"\s/]+/[^"\s]+)"`)
+	diagWorkflowRe   = regexp.MustCompile(`"workflow":\s*"([^"]+)"`)
+	diagResultRe     = regexp.MustCompile(`(?:Job result after all job steps finish|completed with result): (\w+)`)
+)
+
+// NormalizeConclusion 将 runner 日志中的结果（Succeeded/Failed/Canceled 等）统一为 GitHub API 的 conclusion 取值
+func NormalizeConclusion(s string) string {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "":
+		return ""
+	case "succeeded", "success":
+		return "success"
+	case "failed", "failure":
+		return "failure"
+	case "canceled", "cancelled", "abandoned":
+		return "cancelled"
+	case "skipped":
+		return "skipped"
+	default:
+		return strings.ToLower(strings.TrimSpace(s))
+	}
+}
+
+// ParseWorkerLog 解析单个 Worker 日志，提取 Job 信息；无法识别任何 Job 字段时返回 nil
+func ParseWorkerLog(runnerName, path string) (*Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	base := filepath.Base(path)
+	rec := &Record{
+		ID:     "diag-" + strings.TrimSuffix(base, filepath.Ext(base)),
+		Runner: runnerName,
+		Source: SourceDiag,
+	}
+	if m := diagFileTimeRe.FindStringSubmatch(base); m != nil {
+		if t, err := time.Parse("20060102-150405", m[1]); err == nil {
+			rec.StartedAt = t.UTC()
+		}
+	}
+	var lastTime time.Time
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		if m := diagLineTimeRe.FindStringSubmatch(line); m != nil {
+			if t, err := time.Parse(diagTimeLayout, m[1]); err == nil {
+				lastTime = t.UTC()
+				if rec.StartedAt.IsZero() {
+					rec.StartedAt = lastTime
+				}
+			}
+		}
+		if rec.Job == "" {
+			if m := diagJobNameRe.FindStringSubmatch(line); m != nil {
+				rec.Job = strings.TrimSpace(m[1])
+			} else if m := diagJobDisplayRe.FindStringSubmatch(line); m != nil {
+				rec.Job = m[1]
+			}
+		}
+		if rec.Repository == "" {
+			if m := diagRepoRe.FindStringSubmatch(line); m != nil {
+				rec.Repository = m[1]
+			}
+		}
+		if rec.Workflow == "" {
+			if m := diagWorkflowRe.FindStringSubmatch(line); m != nil {
+				rec.Workflow = m[1]
+			}
+		}
+		if m := diagResultRe.FindStringSubmatch(line); m != nil {
+			rec.Conclusion = NormalizeConclusion(m[1])
+			rec.CompletedAt = lastTime
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if rec.Job == "" && rec.Repository == "" && rec.Conclusion == "" {
+		return nil, nil
+	}
+	rec.fillDuration()
+	return rec, nil
+}
+
+// WorkerLogs 返回 installDir/_diag 下的 Worker 日志路径，按文件名（即开始时间）升序
+func WorkerLogs(installDir string) []string {
+	matches, _ := filepath.Glob(filepath.Join(installDir, DiagDirName, "Worker_*.log"))
+	sort.Strings(matches)
+	return matches
+}
+
+// busyStaleAfter 最新 Worker 日志超过该时长未更新且无结论时，视为 Worker 异常退出而非仍在执行
+const busyStaleAfter = 24 * time.Hour
+
+// Busy 判断 runner 是否正在执行 Job：最新的 Worker 日志尚无结论且近期仍有写入
+func Busy(installDir string) bool {
+	return Current(installDir) != nil
+}
+
+// Current 返回正在执行的 Job（最新 Worker 日志尚无结论且近期仍有写入），空闲时返回 nil
+func Current(installDir string) *Record {
+	logs := WorkerLogs(installDir)
+	if len(logs) == 0 {
+		return nil
+	}
+	latest := logs[len(logs)-1]
+	fi, err := os.Stat(latest)
+	if err != nil || time.Since(fi.ModTime()) > busyStaleAfter {
+		return nil
+	}
+	rec, err := ParseWorkerLog("", latest)
+	if err != nil || rec == nil || rec.Completed() {
+		return nil
+	}
+	return rec
+}
+
+// LastCompletedAt 返回最近一次已完成 Job 的结束时间，无则返回零值
+func LastCompletedAt(installDir string) time.Time {
+	logs := WorkerLogs(installDir)
+	for i := len(logs) - 1; i >= 0; i-- {
+		rec, err := ParseWorkerLog("", logs[i])
+		if err != nil || rec == nil || !rec.Completed() {
+			continue
+		}
+		return rec.CompletedAt
+	}
+	return time.Time{}
+}
+
+// parseLog 解析 Worker 日志，测试中替换以统计解析次数
+var parseLog = ParseWorkerLog
+
+// SyncRunner 解析某 runner 的 _diag 日志并写入历史；已有完成记录的日志（包括已合并进 webhook 记录的）不重复解析
+func SyncRunner(stateDir, runnerName, installDir string) error {
+	done, err := completedDiagIDs(stateDir, runnerName)
+	if err != nil {
+		return err
+	}
+	for _, p := range WorkerLogs(installDir) {
+		base := filepath.Base(p)
+		if done["diag-"+strings.TrimSuffix(base, filepath.Ext(base))] {
+			continue
+		}
+		rec, err := parseLog(runnerName, p)
+		if err != nil || rec == nil {
+			continue
+		}
+		if err := Upsert(stateDir, *rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// completedDiagIDs 返回某 runner 已完成记录对应的 diag 记录 ID：记录自身的 ID 或合并进来的 DiagID
+func completedDiagIDs(stateDir, runner string) (map[string]bool, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	list, err := readRecords(stateDir, runner)
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[string]bool, len(list))
+	for _, r := range list {
+		if !r.Completed() {
+			continue
+		}
+		done[r.ID] = true
+		if r.DiagID != "" {
+			done[r.DiagID] = true
+		}
+	}
+	return done, nil
+}
+
+// Sync 对配置中所有 runner 执行 SyncRunner，由 main 定时调用
+func Sync(cfg *config.Config) {
+	if cfg == nil {
+		return
+	}
+	stateDir := cfg.Runners.StateDir()
+	for _, item := range cfg.Runners.Items {
+		_ = SyncRunner(stateDir, item.Name, item.InstallPath(cfg.Runners.BasePath))
+	}
+}
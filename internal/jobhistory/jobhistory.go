@@ -0,0 +1,217 @@
+// Package jobhistory 持久化每个 runner 执行过的 Job（仓库、workflow、job、结论、耗时与起止时间）。
+// 记录来源为 runner 目录下 _diag 日志解析或 GitHub workflow_job webhook，存放于 base_path/.fleet/jobs/<runner>.json。
+package jobhistory
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// maxRecordsPerRunner 单个 runner 最多保留的记录数，超出后丢弃最旧记录
+	maxRecordsPerRunner = 500
+	// mergeWindow 不同来源的同一 Job（同 runner、同 job 名）开始时间相差在此范围内视为同一条
+	mergeWindow = 2 * time.Minute
+)
+
+// 记录来源
+const (
+	SourceDiag    = "diag"
+	SourceWebhook = "webhook"
+)
+
+// mu 保护历史文件读写，避免 webhook 与定时解析并发写入导致覆盖
+var mu sync.Mutex
+
+// Record 单次 Job 执行记录
+type Record struct {
+	ID              string    `json:"id"`
+	Runner          string    `json:"runner"`
+	Repository      string    `json:"repository"`
+	Workflow        string    `json:"workflow"`
+	Job             string    `json:"job"`
+	Conclusion      string    `json:"conclusion"` // success / failure / cancelled / skipped 等；空表示仍在运行
+	StartedAt       time.Time `json:"started_at,omitzero"`
+	CompletedAt     time.Time `json:"completed_at,omitzero"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Source          string    `json:"source"` // diag | webhook
+	// DiagID 合并进本记录的 _diag 日志记录 ID（diag-<文件名>）；webhook 记录先写入时据此识别已解析过的日志
+	DiagID string `json:"diag_id,omitempty"`
+}
+
+// Completed 是否已有结论
+func (r Record) Completed() bool {
+	return r.Conclusion != ""
+}
+
+func (r *Record) fillDuration() {
+	if !r.StartedAt.IsZero() && !r.CompletedAt.IsZero() && r.CompletedAt.After(r.StartedAt) {
+		r.DurationSeconds = r.CompletedAt.Sub(r.StartedAt).Seconds()
+	}
+}
+
+// Dir 返回历史记录目录（stateDir/jobs）
+func Dir(stateDir string) string {
+	return filepath.Join(stateDir, "jobs")
+}
+
+func recordsFile(stateDir, runner string) string {
+	return filepath.Join(Dir(stateDir), runner+".json")
+}
+
+func readRecords(stateDir, runner string) ([]Record, error) {
+	b, err := os.ReadFile(recordsFile(stateDir, runner))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var list []Record
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func writeRecords(stateDir, runner string, list []Record) error {
+	if err := os.MkdirAll(Dir(stateDir), 0755); err != nil {
+		return err
+	}
+	b, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	p := recordsFile(stateDir, runner)
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+// sameJob 判断两条记录是否为同一次 Job（ID 相同，或 job 名相同且开始时间接近）
+func sameJob(a, b Record) bool {
+	if a.ID == b.ID {
+		return true
+	}
+	if a.Job == "" || a.Job != b.Job || a.StartedAt.IsZero() || b.StartedAt.IsZero() {
+		return false
+	}
+	d := a.StartedAt.Sub(b.StartedAt)
+	if d < 0 {
+		d = -d
+	}
+	return d <= mergeWindow
+}
+
+// merge 用 in 中非空字段补全 existing，保留 existing 的 ID 与来源
+func merge(existing, in Record) Record {
+	out := existing
+	if in.Repository != "" {
+		out.Repository = in.Repository
+	}
+	if in.Workflow != "" {
+		out.Workflow = in.Workflow
+	}
+	if in.Job != "" {
+		out.Job = in.Job
+	}
+	if in.Conclusion != "" {
+		out.Conclusion = in.Conclusion
+	}
+	if out.StartedAt.IsZero() {
+		out.StartedAt = in.StartedAt
+	}
+	if !in.CompletedAt.IsZero() {
+		out.CompletedAt = in.CompletedAt
+	}
+	if in.Source == SourceDiag && in.ID != out.ID {
+		out.DiagID = in.ID
+	}
+	out.fillDuration()
+	return out
+}
+
+// Upsert 写入或合并一条记录
+func Upsert(stateDir string, rec Record) error {
+	if rec.Runner == "" || rec.ID == "" {
+		return nil
+	}
+	rec.fillDuration()
+	mu.Lock()
+	defer mu.Unlock()
+	list, err := readRecords(stateDir, rec.Runner)
+	if err != nil {
+		return err
+	}
+	found := false
+	for i := range list {
+		if sameJob(list[i], rec) {
+			list[i] = merge(list[i], rec)
+			found = true
+			break
+		}
+	}
+	if !found {
+		list = append(list, rec)
+	}
+	sortNewestFirst(list)
+	if len(list) > maxRecordsPerRunner {
+		list = list[:maxRecordsPerRunner]
+	}
+	return writeRecords(stateDir, rec.Runner, list)
+}
+
+// Get 返回某 runner 的单条记录，不存在返回 nil
+func Get(stateDir, runner, id string) (*Record, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	list, err := readRecords(stateDir, runner)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		if list[i].ID == id {
+			return &list[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// List 按开始时间倒序分页返回某 runner 的记录；page 从 1 开始，返回当前页与总数
+func List(stateDir, runner string, page, perPage int) ([]Record, int, error) {
+	mu.Lock()
+	list, err := readRecords(stateDir, runner)
+	mu.Unlock()
+	if err != nil {
+		return nil, 0, err
+	}
+	sortNewestFirst(list)
+	total := len(list)
+	if page < 1 {
+		page = 1
+	}
+	if perPage <= 0 {
+		perPage = 30
+	}
+	start := (page - 1) * perPage
+	if start >= total {
+		return []Record{}, total, nil
+	}
+	end := start + perPage
+	if end > total {
+		end = total
+	}
+	return list[start:end], total, nil
+}
+
+func sortNewestFirst(list []Record) {
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].StartedAt.After(list[j].StartedAt)
+	})
+}
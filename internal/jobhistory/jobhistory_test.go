@@ -0,0 +1,197 @@
+package jobhistory
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeWorkerLog(t *testing.T, installDir, name, content string) string {
+	t.Helper()
+	dir := filepath.Join(installDir, DiagDirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	p := filepath.Join(dir, name)
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+const completedWorkerLog = `[2026-03-03 10:00:00Z INFO Worker] Version: 2.331.0
+[2026-03-03 10:00:01Z INFO JobRunner] Job message:
+  "repository": "acme/payments",
+  "workflow": "CI",
+[2026-03-03 10:00:02Z INFO Terminal] WRITE LINE: Running job: build
+[2026-03-03 10:04:02Z INFO JobRunner] Job result after all job steps finish: Failed
+`
+
+func TestParseWorkerLog_Completed(t *testing.T) {
+	dir := t.TempDir()
+	p := writeWorkerLog(t, dir, "Worker_20260303-100000-utc.log", completedWorkerLog)
+	rec, err := ParseWorkerLog("r1", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec == nil {
+		t.Fatal("expected record")
+	}
+	if rec.ID != "diag-Worker_20260303-100000-utc" || rec.Runner != "r1" || rec.Source != SourceDiag {
+		t.Errorf("unexpected identity: %+v", rec)
+	}
+	if rec.Repository != "acme/payments" || rec.Workflow != "CI" || rec.Job != "build" {
+		t.Errorf("unexpected fields: %+v", rec)
+	}
+	if rec.Conclusion != "failure" {
+		t.Errorf("conclusion = %q, want failure", rec.Conclusion)
+	}
+	if rec.DurationSeconds != 242 {
+		t.Errorf("duration = %v, want 242", rec.DurationSeconds)
+	}
+}
+
+func TestParseWorkerLog_Running(t *testing.T) {
+	dir := t.TempDir()
+	p := writeWorkerLog(t, dir, "Worker_20260303-110000-utc.log", "[2026-03-03 11:00:00Z INFO Terminal] WRITE LINE: Running job: test\n")
+	rec, err := ParseWorkerLog("r1", p)
+	if err != nil || rec == nil {
+		t.Fatalf("rec=%v err=%v", rec, err)
+	}
+	if rec.Completed() || !rec.CompletedAt.IsZero() {
+		t.Errorf("expected running record, got %+v", rec)
+	}
+}
+
+func TestCurrent(t *testing.T) {
+	dir := t.TempDir()
+	writeWorkerLog(t, dir, "Worker_20260303-100000-utc.log", completedWorkerLog)
+	if cur := Current(dir); cur != nil {
+		t.Fatalf("expected idle, got %+v", cur)
+	}
+	writeWorkerLog(t, dir, "Worker_20260303-110000-utc.log", "[2026-03-03 11:00:00Z INFO Terminal] WRITE LINE: Running job: test\n")
+	cur := Current(dir)
+	if cur == nil || cur.Job != "test" || !Busy(dir) {
+		t.Fatalf("expected running job, got %+v", cur)
+	}
+}
+
+func TestSyncRunner_AndList(t *testing.T) {
+	state := t.TempDir()
+	install := t.TempDir()
+	writeWorkerLog(t, install, "Worker_20260303-100000-utc.log", completedWorkerLog)
+	writeWorkerLog(t, install, "Worker_20260303-110000-utc.log", "[2026-03-03 11:00:00Z INFO Terminal] WRITE LINE: Running job: test\n")
+	if err := SyncRunner(state, "r1", install); err != nil {
+		t.Fatal(err)
+	}
+	// 重复同步不应产生重复记录
+	if err := SyncRunner(state, "r1", install); err != nil {
+		t.Fatal(err)
+	}
+	list, total, err := List(state, "r1", 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(list) != 2 {
+		t.Fatalf("expected 2 records, got total=%d len=%d", total, len(list))
+	}
+	if list[0].Job != "test" || list[1].Job != "build" {
+		t.Errorf("expected newest first, got %q then %q", list[0].Job, list[1].Job)
+	}
+	page2, _, _ := List(state, "r1", 2, 1)
+	if len(page2) != 1 || page2[0].Job != "build" {
+		t.Errorf("unexpected page 2: %+v", page2)
+	}
+	empty, _, _ := List(state, "r1", 3, 1)
+	if len(empty) != 0 {
+		t.Errorf("expected empty page, got %+v", empty)
+	}
+}
+
+func TestUpsert_MergesWebhookIntoDiagRecord(t *testing.T) {
+	state := t.TempDir()
+	start := time.Date(2026, 3, 3, 10, 0, 0, 0, time.UTC)
+	if err := Upsert(state, Record{ID: "diag-w1", Runner: "r1", Job: "build", StartedAt: start, Source: SourceDiag}); err != nil {
+		t.Fatal(err)
+	}
+	err := Upsert(state, Record{
+		ID: "gh-1", Runner: "r1", Job: "build", Repository: "acme/api", Conclusion: "success",
+		StartedAt: start.Add(30 * time.Second), CompletedAt: start.Add(90 * time.Second), Source: SourceWebhook,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, total, _ := List(state, "r1", 1, 10)
+	if total != 1 {
+		t.Fatalf("expected merged single record, got %d", total)
+	}
+	if list[0].ID != "diag-w1" || list[0].Repository != "acme/api" || list[0].Conclusion != "success" || list[0].DurationSeconds != 90 {
+		t.Errorf("unexpected merged record: %+v", list[0])
+	}
+}
+
+func TestSyncRunner_SkipsLogMergedIntoWebhookRecord(t *testing.T) {
+	state := t.TempDir()
+	install := t.TempDir()
+	writeWorkerLog(t, install, "Worker_20260303-100000-utc.log", completedWorkerLog)
+	// webhook 先到：记录 ID 为 gh-<id>，diag 解析结果合并进该记录
+	err := Upsert(state, Record{
+		ID: "gh-7", Runner: "r1", Job: "build", Conclusion: "failure",
+		StartedAt: time.Date(2026, 3, 3, 10, 0, 30, 0, time.UTC), Source: SourceWebhook,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed := 0
+	parseLog = func(runnerName, path string) (*Record, error) {
+		parsed++
+		return ParseWorkerLog(runnerName, path)
+	}
+	defer func() { parseLog = ParseWorkerLog }()
+
+	if err := SyncRunner(state, "r1", install); err != nil {
+		t.Fatal(err)
+	}
+	list, total, _ := List(state, "r1", 1, 10)
+	if parsed != 1 || total != 1 || list[0].ID != "gh-7" || list[0].DiagID != "diag-Worker_20260303-100000-utc" {
+		t.Fatalf("first sync: parsed=%d records=%+v", parsed, list)
+	}
+	if err := SyncRunner(state, "r1", install); err != nil {
+		t.Fatal(err)
+	}
+	if parsed != 1 {
+		t.Errorf("second sync parsed %d logs, want none", parsed-1)
+	}
+}
+
+func TestFromWorkflowJobEvent(t *testing.T) {
+	body := []byte(`{"action":"completed","workflow_job":{"id":42,"name":"lint","workflow_name":"CI","conclusion":"success","started_at":"2026-03-03T10:00:00Z","completed_at":"2026-03-03T10:02:00Z","runner_name":"r1"},"repository":{"full_name":"acme/api"}}`)
+	rec, err := FromWorkflowJobEvent(body)
+	if err != nil || rec == nil {
+		t.Fatalf("rec=%v err=%v", rec, err)
+	}
+	if rec.ID != "gh-42" || rec.Runner != "r1" || rec.Repository != "acme/api" || rec.DurationSeconds != 120 {
+		t.Errorf("unexpected record: %+v", rec)
+	}
+	queued, err := FromWorkflowJobEvent([]byte(`{"action":"queued","workflow_job":{"id":43}}`))
+	if err != nil || queued != nil {
+		t.Errorf("expected queued event ignored, got %+v err=%v", queued, err)
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"a":1}`)
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	sig := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if !VerifySignature("s3cret", body, sig) {
+		t.Error("expected valid signature")
+	}
+	if VerifySignature("other", body, sig) || VerifySignature("", body, sig) || VerifySignature("s3cret", body, "sha1=abc") {
+		t.Error("expected invalid signature")
+	}
+}
@@ -0,0 +1,79 @@
+package jobhistory
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// workflowJobEvent GitHub workflow_job webhook 负载中用到的字段
+type workflowJobEvent struct {
+	Action      string `json:"action"`
+	WorkflowJob struct {
+		ID           int64  `json:"id"`
+		Name         string `json:"name"`
+		WorkflowName string `json:"workflow_name"`
+		Conclusion   string `json:"conclusion"`
+		StartedAt    string `json:"started_at"`
+		CompletedAt  string `json:"completed_at"`
+		RunnerName   string `json:"runner_name"`
+	} `json:"workflow_job"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// VerifySignature 校验 X-Hub-Signature-256（sha256=<hex>）
+func VerifySignature(secret string, body []byte, header string) bool {
+	if secret == "" {
+		return false
+	}
+	sig, ok := strings.CutPrefix(strings.TrimSpace(header), "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// FromWorkflowJobEvent 将 workflow_job 事件转为记录；queued 或未分配 runner 的事件返回 nil
+func FromWorkflowJobEvent(body []byte) (*Record, error) {
+	var ev workflowJobEvent
+	if err := json.Unmarshal(body, &ev); err != nil {
+		return nil, err
+	}
+	if ev.Action != "in_progress" && ev.Action != "completed" {
+		return nil, nil
+	}
+	if ev.WorkflowJob.ID == 0 || ev.WorkflowJob.RunnerName == "" {
+		return nil, nil
+	}
+	rec := &Record{
+		ID:         "gh-" + strconv.FormatInt(ev.WorkflowJob.ID, 10),
+		Runner:     ev.WorkflowJob.RunnerName,
+		Repository: ev.Repository.FullName,
+		Workflow:   ev.WorkflowJob.WorkflowName,
+		Job:        ev.WorkflowJob.Name,
+		Source:     SourceWebhook,
+	}
+	if t, err := time.Parse(time.RFC3339, ev.WorkflowJob.StartedAt); err == nil {
+		rec.StartedAt = t.UTC()
+	}
+	if ev.Action == "completed" {
+		rec.Conclusion = NormalizeConclusion(ev.WorkflowJob.Conclusion)
+		if t, err := time.Parse(time.RFC3339, ev.WorkflowJob.CompletedAt); err == nil {
+			rec.CompletedAt = t.UTC()
+		}
+	}
+	rec.fillDuration()
+	return rec, nil
+}
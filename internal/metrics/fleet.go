@@ -0,0 +1,23 @@
+package metrics
+
+// Fleet Manager 进程的计数器与直方图，由 runner、handler、githubcheck 在操作时递增，/metrics 输出
+var Fleet = NewRegistry()
+
+// Manager 侧指标名前缀
+const FleetPrefix = "runner_fleet_"
+
+var (
+	RunnerStartAttempts = Fleet.NewCounterVec(FleetPrefix+"runner_start_attempts_total", "Runner start attempts (UI/API, auto-start and after registration).", "runner")
+	RunnerStartFailures = Fleet.NewCounterVec(FleetPrefix+"runner_start_failures_total", "Runner start attempts that returned an error.", "runner")
+	RunnerStopAttempts  = Fleet.NewCounterVec(FleetPrefix+"runner_stop_attempts_total", "Runner stop attempts.", "runner")
+	RunnerStopFailures  = Fleet.NewCounterVec(FleetPrefix+"runner_stop_failures_total", "Runner stop attempts that returned an error.", "runner")
+
+	RegistrationAttempts = Fleet.NewCounterVec(FleetPrefix+"registration_attempts_total", "Background install+register jobs executed.", "runner")
+	RegistrationFailures = Fleet.NewCounterVec(FleetPrefix+"registration_failures_total", "Background install+register jobs that failed.", "runner", "stage")
+
+	ConfigReloads = Fleet.NewCounterVec(FleetPrefix+"config_reloads_total", "Reloads of config.yaml after an external edit, by result (ok or error).", "result")
+
+	GitHubAPIRequests = Fleet.NewCounterVec(FleetPrefix+"github_api_requests_total", "GitHub API requests by HTTP status code (\"error\" for transport failures).", "code")
+	GitHubAPIErrors   = Fleet.NewCounterVec(FleetPrefix+"github_api_errors_total", "GitHub API requests that failed or returned a non-2xx status.")
+	GitHubAPILatency  = Fleet.NewHistogram(FleetPrefix+"github_api_request_duration_seconds", "GitHub API request latency in seconds.", DefaultBuckets)
+)
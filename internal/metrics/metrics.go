@@ -0,0 +1,241 @@
+// Package metrics 以 Prometheus 文本格式（0.0.4）输出指标，不依赖 client_golang。
+// 计数器与直方图注册在 Registry 中、进程内累计，由各包在操作时递增；Gauge 由 /metrics 处理函数在抓取时按当前状态计算后直接写出。
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType Prometheus 文本格式的 Content-Type
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Labels 标签键值，输出时按键名排序
+type Labels map[string]string
+
+func (l Labels) String() string {
+	if len(l) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(l))
+	for k := range l {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabelValue(l[k]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeLabelValue(v string) string { return labelValueEscaper.Replace(v) }
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Writer 依次写出指标族；首个写错误后忽略后续写入，由 Err 返回
+type Writer struct {
+	w   io.Writer
+	err error
+	// Keep 非 nil 时只写出其返回 true 的样本，用于按调用者权限隐藏部分标签值
+	Keep func(Labels) bool
+}
+
+// NewWriter 包装输出流
+func NewWriter(w io.Writer) *Writer { return &Writer{w: w} }
+
+// Err 返回首个写错误
+func (w *Writer) Err() error { return w.err }
+
+func (w *Writer) printf(format string, args ...any) {
+	if w.err != nil {
+		return
+	}
+	_, w.err = fmt.Fprintf(w.w, format, args...)
+}
+
+// Header 写出 # HELP 与 # TYPE；typ 为 counter、gauge 或 histogram
+func (w *Writer) Header(name, help, typ string) {
+	w.printf("# HELP %s %s\n# TYPE %s %s\n", name, strings.ReplaceAll(help, "\n", " "), name, typ)
+}
+
+// Sample 写出一条样本
+func (w *Writer) Sample(name string, labels Labels, v float64) {
+	if w.Keep != nil && !w.Keep(labels) {
+		return
+	}
+	w.printf("%s%s %s\n", name, labels.String(), formatValue(v))
+}
+
+// Bool 将布尔值转为 0/1 样本值
+func Bool(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// collector 注册到 Registry 的计数器/直方图
+type collector interface {
+	name() string
+	write(w *Writer)
+}
+
+// Registry 一组计数器与直方图；Manager 与 Agent 各用各自的 Registry
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+// NewRegistry 创建空 Registry
+func NewRegistry() *Registry { return &Registry{} }
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// Write 按名称顺序写出所有已注册的计数器与直方图
+func (r *Registry) Write(w *Writer) {
+	r.mu.Lock()
+	cs := append([]collector(nil), r.collectors...)
+	r.mu.Unlock()
+	sort.Slice(cs, func(i, j int) bool { return cs[i].name() < cs[j].name() })
+	for _, c := range cs {
+		c.write(w)
+	}
+}
+
+// CounterVec 带标签的计数器，标签名在创建时固定
+type CounterVec struct {
+	metricName string
+	help       string
+	labelNames []string
+	mu         sync.Mutex
+	values     map[string]float64
+	labels     map[string]Labels
+}
+
+// NewCounterVec 创建计数器并注册到 r
+func (r *Registry) NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	c := &CounterVec{metricName: name, help: help, labelNames: labelNames, values: map[string]float64{}, labels: map[string]Labels{}}
+	r.register(c)
+	return c
+}
+
+// Inc 按标签值（与创建时标签名顺序一致）加 1
+func (c *CounterVec) Inc(labelValues ...string) { c.Add(1, labelValues...) }
+
+// Add 按标签值累加 v（v 不应为负）
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.labels[key]; !ok {
+		l := Labels{}
+		for i, n := range c.labelNames {
+			if i < len(labelValues) {
+				l[n] = labelValues[i]
+			}
+		}
+		c.labels[key] = l
+	}
+	c.values[key] += v
+}
+
+// Value 返回某组标签的当前值，供测试使用
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[strings.Join(labelValues, "\xff")]
+}
+
+func (c *CounterVec) name() string { return c.metricName }
+
+func (c *CounterVec) write(w *Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w.Header(c.metricName, c.help, "counter")
+	keys := make([]string, 0, len(c.values))
+	for k := range c.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		w.Sample(c.metricName, c.labels[k], c.values[k])
+	}
+}
+
+// DefaultBuckets 适用于外部 HTTP 调用耗时（秒）的桶边界
+var DefaultBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Histogram 无标签直方图
+type Histogram struct {
+	metricName string
+	help       string
+	buckets    []float64
+	mu         sync.Mutex
+	counts     []uint64 // 与 buckets 对应，非累计
+	count      uint64
+	sum        float64
+}
+
+// NewHistogram 创建直方图并注册到 r；buckets 须升序
+func (r *Registry) NewHistogram(name, help string, buckets []float64) *Histogram {
+	h := &Histogram{metricName: name, help: help, buckets: buckets, counts: make([]uint64, len(buckets))}
+	r.register(h)
+	return h
+}
+
+// Observe 记录一次观测值
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.count++
+	h.sum += v
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		h.counts[i]++
+	}
+}
+
+func (h *Histogram) name() string { return h.metricName }
+
+func (h *Histogram) write(w *Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	w.Header(h.metricName, h.help, "histogram")
+	var cum uint64
+	for i, b := range h.buckets {
+		cum += h.counts[i]
+		w.Sample(h.metricName+"_bucket", Labels{"le": formatValue(b)}, float64(cum))
+	}
+	w.Sample(h.metricName+"_bucket", Labels{"le": "+Inf"}, float64(h.count))
+	w.Sample(h.metricName+"_sum", nil, h.sum)
+	w.Sample(h.metricName+"_count", nil, float64(h.count))
+}
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("t_requests_total", "Requests.", "code")
+	h := r.NewHistogram("t_duration_seconds", "Duration.", []float64{0.1, 1})
+	c.Inc("200")
+	c.Inc("200")
+	c.Inc(`a"b`)
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(5)
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	r.Write(w)
+	if w.Err() != nil {
+		t.Fatal(w.Err())
+	}
+	want := `# HELP t_duration_seconds Duration.
+# TYPE t_duration_seconds histogram
+t_duration_seconds_bucket{le="0.1"} 1
+t_duration_seconds_bucket{le="1"} 2
+t_duration_seconds_bucket{le="+Inf"} 3
+t_duration_seconds_sum 5.55
+t_duration_seconds_count 3
+# HELP t_requests_total Requests.
+# TYPE t_requests_total counter
+t_requests_total{code="200"} 2
+t_requests_total{code="a\"b"} 1
+`
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected exposition:\n%s\nwant:\n%s", got, want)
+	}
+	if c.Value("200") != 2 {
+		t.Errorf("Value = %v, want 2", c.Value("200"))
+	}
+}
+
+func TestLabelsSorted(t *testing.T) {
+	got := Labels{"status": "new", "runner": "r1"}.String()
+	if got != `{runner="r1",status="new"}` {
+		t.Errorf("got %s", got)
+	}
+	if !strings.HasPrefix(Labels{"x": "a\nb"}.String(), `{x="a\nb"`) {
+		t.Error("newline should be escaped")
+	}
+}
@@ -0,0 +1,383 @@
+// Package oidc 实现 OIDC 授权码流程（含 PKCE）所需的最小客户端：读取 issuer 的 discovery 文档、
+// 生成授权地址、用 code 换取 ID Token，并按 issuer 的 JWKS 校验 ID Token 签名（RS256/ES256）与声明。
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwksRefreshInterval 遇到未知 kid 时重新拉取 JWKS 的最短间隔，避免被伪造 Token 放大请求
+const jwksRefreshInterval = time.Minute
+
+// clockSkew 校验 exp/iat 时允许的时钟偏差
+const clockSkew = time.Minute
+
+// Provider 单个 OIDC issuer 的客户端，discovery 文档与 JWKS 在首次使用时获取并缓存
+type Provider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string // 默认 openid profile email
+	Client       *http.Client
+
+	mu          sync.Mutex
+	meta        *metadata
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+}
+
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// IDToken 校验通过的 ID Token
+type IDToken struct {
+	Subject string
+	Expiry  time.Time
+	Claims  map[string]any
+}
+
+// StringClaim 返回字符串声明，不存在时为空
+func (t *IDToken) StringClaim(name string) string {
+	s, _ := t.Claims[name].(string)
+	return s
+}
+
+// Groups 返回 claim 中的组列表，兼容数组与以逗号/空格分隔的字符串
+func (t *IDToken) Groups(claim string) []string {
+	switch v := t.Claims[claim].(type) {
+	case []any:
+		var out []string
+		for _, g := range v {
+			if s, ok := g.(string); ok && s != "" {
+				out = append(out, s)
+			}
+		}
+		return out
+	case string:
+		return strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' })
+	}
+	return nil
+}
+
+func (p *Provider) client() *http.Client {
+	if p.Client != nil {
+		return p.Client
+	}
+	return &http.Client{Timeout: 15 * time.Second}
+}
+
+func (p *Provider) getJSON(ctx context.Context, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: HTTP %d", u, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// discover 获取并缓存 discovery 文档，失败时不缓存，下次调用重试
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.meta != nil {
+		return p.meta, nil
+	}
+	var m metadata
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.Issuer, "/")+"/.well-known/openid-configuration", &m); err != nil {
+		return nil, fmt.Errorf("读取 OIDC discovery 失败: %w", err)
+	}
+	if m.Issuer != p.Issuer {
+		return nil, fmt.Errorf("discovery 中的 issuer %q 与配置 %q 不一致", m.Issuer, p.Issuer)
+	}
+	if m.AuthorizationEndpoint == "" || m.TokenEndpoint == "" || m.JWKSURI == "" {
+		return nil, errors.New("discovery 文档缺少 authorization_endpoint、token_endpoint 或 jwks_uri")
+	}
+	p.meta = &m
+	return p.meta, nil
+}
+
+// PKCEChallenge 返回 code_verifier 对应的 S256 code_challenge
+func PKCEChallenge(verifier string) string {
+	s := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(s[:])
+}
+
+// AuthCodeURL 返回跳转到 IdP 登录的地址
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	m, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	scopes := p.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "profile", "email"}
+	}
+	if !slices.Contains(scopes, "openid") {
+		scopes = append([]string{"openid"}, scopes...)
+	}
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {p.RedirectURL},
+		"scope":                 {strings.Join(scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {PKCEChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(m.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return m.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Exchange 用授权码换取 ID Token（client_secret_basic），返回原始 JWT
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (string, error) {
+	m, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	if p.ClientSecret == "" {
+		form.Set("client_id", p.ClientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+	resp, err := p.client().Do(req)
+	if err != nil {
+		return "", fmt.Errorf("请求 token endpoint 失败: %w", err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return "", fmt.Errorf("解析 token 响应失败（HTTP %d）: %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		return "", fmt.Errorf("token endpoint 返回 HTTP %d: %s %s", resp.StatusCode, body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", errors.New("token 响应中没有 id_token")
+	}
+	return body.IDToken, nil
+}
+
+// Verify 校验 ID Token 的签名、iss、aud、exp 与 nonce
+func (p *Provider) Verify(ctx context.Context, raw, nonce string) (*IDToken, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("ID Token 格式错误")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("解析 ID Token 头失败: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("ID Token 签名编码错误")
+	}
+	key, err := p.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if header.Alg != "RS256" || rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) != nil {
+			return nil, errors.New("ID Token 签名无效")
+		}
+	case *ecdsa.PublicKey:
+		if header.Alg != "ES256" || len(sig) != 64 ||
+			!ecdsa.Verify(k, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+			return nil, errors.New("ID Token 签名无效")
+		}
+	default:
+		return nil, fmt.Errorf("不支持的签名算法 %q", header.Alg)
+	}
+
+	claims := map[string]any{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("解析 ID Token 声明失败: %w", err)
+	}
+	t := &IDToken{Claims: claims}
+	t.Subject = t.StringClaim("sub")
+	if iss := t.StringClaim("iss"); iss != p.Issuer {
+		return nil, fmt.Errorf("ID Token issuer %q 不匹配", iss)
+	}
+	switch aud := claims["aud"].(type) {
+	case string:
+		if aud != p.ClientID {
+			return nil, errors.New("ID Token audience 不匹配")
+		}
+	case []any:
+		if !slices.Contains(aud, any(p.ClientID)) {
+			return nil, errors.New("ID Token audience 不匹配")
+		}
+	default:
+		return nil, errors.New("ID Token 缺少 audience")
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, errors.New("ID Token 缺少 exp")
+	}
+	t.Expiry = time.Unix(int64(exp), 0)
+	if time.Now().After(t.Expiry.Add(clockSkew)) {
+		return nil, errors.New("ID Token 已过期")
+	}
+	if t.StringClaim("nonce") != nonce {
+		return nil, errors.New("ID Token nonce 不匹配")
+	}
+	if t.Subject == "" {
+		return nil, errors.New("ID Token 缺少 sub")
+	}
+	return t, nil
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// key 返回 kid 对应的公钥；缓存中没有时按 jwksRefreshInterval 限速重新拉取 JWKS
+func (p *Provider) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	m, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if k, ok := p.lookupKey(kid); ok {
+		return k, nil
+	}
+	if time.Since(p.keysFetched) < jwksRefreshInterval {
+		return nil, fmt.Errorf("未知的签名密钥 %q", kid)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := p.getJSON(ctx, m.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("读取 JWKS 失败: %w", err)
+	}
+	p.keys = map[string]crypto.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if pub, err := k.publicKey(); err == nil {
+			p.keys[k.Kid] = pub
+		}
+	}
+	p.keysFetched = time.Now()
+	if k, ok := p.lookupKey(kid); ok {
+		return k, nil
+	}
+	return nil, fmt.Errorf("未知的签名密钥 %q", kid)
+}
+
+// lookupKey 按 kid 查找；ID Token 未带 kid 且 JWKS 只有一个密钥时使用该密钥
+func (p *Provider) lookupKey(kid string) (crypto.PublicKey, bool) {
+	if k, ok := p.keys[kid]; ok {
+		return k, true
+	}
+	if kid == "" && len(p.keys) == 1 {
+		for _, k := range p.keys {
+			return k, true
+		}
+	}
+	return nil, false
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	dec := base64.RawURLEncoding.DecodeString
+	switch k.Kty {
+	case "RSA":
+		n, err := dec(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := dec(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("不支持的曲线 %q", k.Crv)
+		}
+		x, err := dec(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := dec(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("EC 密钥长度错误")
+		}
+		return ecdsa.ParseUncompressedPublicKey(elliptic.P256(), append(append([]byte{4}, x...), y...))
+	}
+	return nil, fmt.Errorf("不支持的密钥类型 %q", k.Kty)
+}
@@ -0,0 +1,71 @@
+package oidc
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/oidc/oidctest"
+)
+
+func TestProvider_CodeFlow(t *testing.T) {
+	is := oidctest.NewIssuer(t, "fleet", "s3cret")
+	p := &Provider{Issuer: is.URL, ClientID: "fleet", ClientSecret: "s3cret", RedirectURL: "https://fleet.example.com/auth/callback"}
+	ctx := context.Background()
+	u, err := p.AuthCodeURL(ctx, "st", "n1", "verifier-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(u, "scope=openid") || !strings.Contains(u, "code_challenge="+PKCEChallenge("verifier-1")) {
+		t.Errorf("unexpected auth url %s", u)
+	}
+	cb := is.Authorize(t, u, map[string]any{"sub": "u1", "groups": []string{"platform"}})
+	if cb.Get("state") != "st" {
+		t.Errorf("state = %q", cb.Get("state"))
+	}
+	if _, err := p.Exchange(ctx, cb.Get("code"), "wrong-verifier"); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("expected invalid_grant for wrong verifier, got %v", err)
+	}
+	cb = is.Authorize(t, u, map[string]any{"sub": "u1", "groups": []string{"platform"}})
+	raw, err := p.Exchange(ctx, cb.Get("code"), "verifier-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, err := p.Verify(ctx, raw, "n1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.Subject != "u1" || len(tok.Groups("groups")) != 1 {
+		t.Errorf("unexpected token %+v", tok)
+	}
+}
+
+func TestProvider_VerifyRejects(t *testing.T) {
+	is := oidctest.NewIssuer(t, "fleet", "")
+	p := &Provider{Issuer: is.URL, ClientID: "fleet"}
+	ctx := context.Background()
+	valid := func() map[string]any {
+		return map[string]any{"iss": is.URL, "aud": []string{"fleet", "other"}, "sub": "u1", "nonce": "n1", "exp": time.Now().Add(time.Hour).Unix()}
+	}
+	if _, err := p.Verify(ctx, is.Sign(valid()), "n1"); err != nil {
+		t.Fatalf("valid token rejected: %v", err)
+	}
+	for name, mutate := range map[string]func(map[string]any){
+		"wrong nonce":    func(c map[string]any) { c["nonce"] = "n2" },
+		"wrong audience": func(c map[string]any) { c["aud"] = "someone-else" },
+		"wrong issuer":   func(c map[string]any) { c["iss"] = "https://evil.example.com" },
+		"expired":        func(c map[string]any) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+	} {
+		c := valid()
+		mutate(c)
+		if _, err := p.Verify(ctx, is.Sign(c), "n1"); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	raw := is.Sign(valid())
+	tampered := raw[:strings.LastIndex(raw, ".")] + ".AAAA"
+	if _, err := p.Verify(ctx, tampered, "n1"); err == nil {
+		t.Error("tampered signature accepted")
+	}
+}
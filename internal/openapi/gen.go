@@ -0,0 +1,317 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// initialisms 生成 Go 字段名时整体大写的词
+var initialisms = map[string]string{
+	"id": "ID", "url": "URL", "api": "API", "ip": "IP", "cpu": "CPU", "rss": "RSS",
+	"etag": "ETag", "sha256": "SHA256", "github": "GitHub", "http": "HTTP", "json": "JSON", "mb": "MB",
+}
+
+// GoName 将 snake_case 或 lowerCamel 名称转为导出的 Go 标识符，如 job_id -> JobID
+func GoName(s string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool { return r == '_' || r == '-' }) {
+		if v, ok := initialisms[strings.ToLower(part)]; ok {
+			b.WriteString(v)
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+// GenerateClient 按文档生成 Go 客户端：components 中的类型、各接口的参数结构与 Client 方法。
+// 生成的代码依赖同包中手写的 Client 与 do 方法（见 client 包）
+func GenerateClient(d *Document, pkg string) ([]byte, error) {
+	g := &generator{doc: d}
+	g.printf("// Code generated by openapi-gen; DO NOT EDIT.\n\n")
+	g.printf("package %s\n\n", pkg)
+	body := &generator{doc: d}
+	for _, name := range slices.Sorted(maps.Keys(d.Components.Schemas)) {
+		body.writeType(name, d.Components.Schemas[name])
+	}
+	for _, op := range d.ops {
+		if !op.NoClient {
+			body.writeOperation(op)
+		}
+	}
+	imports := []string{"context", "net/http", "net/url"}
+	if body.strconv {
+		imports = append(imports, "strconv")
+	}
+	if body.time {
+		imports = append(imports, "time")
+	}
+	g.printf("import (\n")
+	for _, imp := range imports {
+		g.printf("\t%q\n", imp)
+	}
+	g.printf(")\n\n")
+	g.buf.Write(body.buf.Bytes())
+	src, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		return g.buf.Bytes(), fmt.Errorf("格式化生成的代码失败: %w", err)
+	}
+	return src, nil
+}
+
+type generator struct {
+	doc     *Document
+	buf     bytes.Buffer
+	strconv bool // 是否用到 strconv
+	time    bool // 是否用到 time
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// goType 返回 schema 对应的 Go 类型
+func (g *generator) goType(s *Schema) string {
+	if s.Ref != "" {
+		name, _ := strings.CutPrefix(s.Ref, refPrefix)
+		return name
+	}
+	if len(s.AllOf) == 1 {
+		t := g.goType(s.AllOf[0])
+		if s.Nullable {
+			return "*" + t
+		}
+		return t
+	}
+	var t string
+	switch s.Type {
+	case "string":
+		t = "string"
+		if s.Format == "date-time" {
+			g.time = true
+			t = "time.Time"
+		}
+	case "integer":
+		t = "int"
+		if s.Format == "int64" {
+			t = "int64"
+		}
+	case "number":
+		t = "float64"
+	case "boolean":
+		t = "bool"
+	case "array":
+		return "[]" + g.goType(s.Items)
+	case "object":
+		if ap, ok := s.AdditionalProperties.(*Schema); ok {
+			return "map[string]" + g.goType(ap)
+		}
+		return "map[string]any"
+	default:
+		return "any"
+	}
+	if s.Nullable {
+		return "*" + t
+	}
+	return t
+}
+
+func (g *generator) writeType(name string, s *Schema) {
+	g.printf("// %s 对应 components.schemas.%s\n", name, name)
+	g.printf("type %s struct {\n", name)
+	for _, prop := range s.order {
+		ps := s.Properties[prop]
+		opts := ""
+		if !slices.Contains(s.Required, prop) {
+			switch {
+			case ps.Type == "array":
+				// 请求中 null 与 [] 含义不同（如 labels: [] 表示清空），数组不省略
+			case ps.Format == "date-time" && !ps.Nullable:
+				opts = ",omitzero"
+			default:
+				opts = ",omitempty"
+			}
+		}
+		g.printf("\t%s %s `json:\"%s%s\"`\n", GoName(prop), g.goType(ps), prop, opts)
+	}
+	g.printf("}\n\n")
+}
+
+func (g *generator) writeOperation(op *Operation) {
+	method := GoName(op.OperationID)
+	// 路径参数与必填参数作为方法参数，其余放入 <方法名>Params
+	var args []Parameter
+	var params []Parameter
+	for _, p := range op.Parameters {
+		if p.Required {
+			args = append(args, p)
+		} else {
+			params = append(params, p)
+		}
+	}
+	if len(params) > 0 {
+		g.printf("// %sParams %s 的可选参数，零值表示不传\n", method, method)
+		g.printf("type %sParams struct {\n", method)
+		for _, p := range params {
+			if p.Description != "" {
+				g.printf("\t// %s\n", p.Description)
+			}
+			g.printf("\t%s %s\n", GoName(p.Name), g.paramType(p.Schema))
+		}
+		g.printf("}\n\n")
+	}
+
+	sig := []string{"ctx context.Context"}
+	for _, p := range args {
+		t := "string"
+		if p.In != "path" {
+			t = strings.TrimPrefix(g.paramType(p.Schema), "*")
+		}
+		sig = append(sig, argName(p.Name)+" "+t)
+	}
+	bodyArg, bodyMedia := "nil", ""
+	if op.RequestBody != nil {
+		for _, media := range slices.Sorted(maps.Keys(op.RequestBody.Content)) {
+			if media == "application/json" {
+				sig = append(sig, "body "+g.goType(op.RequestBody.Content[media].Schema))
+			} else {
+				sig = append(sig, "body []byte")
+				bodyMedia = media
+			}
+			bodyArg = "body"
+			break
+		}
+	}
+	if len(params) > 0 {
+		sig = append(sig, "params *"+method+"Params")
+	}
+
+	result, raw := g.result(op)
+	returns := "error"
+	if result != "" {
+		returns = "(" + result + ", error)"
+	}
+	g.printf("// %s 调用 %s %s", method, strings.ToUpper(op.method), op.path)
+	if op.Summary != "" {
+		g.printf("：%s", op.Summary)
+	}
+	g.printf("\n")
+	g.printf("func (c *Client) %s(%s) %s {\n", method, strings.Join(sig, ", "), returns)
+	g.printf("\tquery, header := url.Values{}, http.Header{}\n")
+	for _, p := range args {
+		if p.In != "path" {
+			g.printf("\t%s(%q, %s)\n", setter(p), p.Name, g.format(p.Schema, argName(p.Name)))
+		}
+	}
+	if len(params) > 0 {
+		g.printf("\tif params != nil {\n")
+		for _, p := range params {
+			g.writeParam(p)
+		}
+		g.printf("\t}\n")
+	}
+	path := g.pathExpr(op.path)
+	switch {
+	case result == "":
+		g.printf("\treturn c.do(ctx, %q, %s, query, header, %s, %q, nil)\n", strings.ToUpper(op.method), path, bodyArg, bodyMedia)
+	case raw:
+		g.printf("\tvar out []byte\n")
+		g.printf("\terr := c.do(ctx, %q, %s, query, header, %s, %q, &out)\n", strings.ToUpper(op.method), path, bodyArg, bodyMedia)
+		g.printf("\treturn out, err\n")
+	default:
+		g.printf("\tvar out %s\n", strings.TrimPrefix(result, "*"))
+		g.printf("\tif err := c.do(ctx, %q, %s, query, header, %s, %q, &out); err != nil {\n", strings.ToUpper(op.method), path, bodyArg, bodyMedia)
+		g.printf("\t\treturn nil, err\n\t}\n")
+		g.printf("\treturn &out, nil\n")
+	}
+	g.printf("}\n\n")
+}
+
+// result 返回首个 2xx 响应的 Go 类型；文本响应为 []byte（raw 为 true），无内容时为空
+func (g *generator) result(op *Operation) (string, bool) {
+	for _, code := range slices.Sorted(maps.Keys(op.Responses)) {
+		if !strings.HasPrefix(code, "2") {
+			continue
+		}
+		for media, mt := range op.Responses[code].Content {
+			if media == "application/json" {
+				return "*" + strings.TrimPrefix(g.goType(mt.Schema), "*"), false
+			}
+			return "[]byte", true
+		}
+		return "", false
+	}
+	return "", false
+}
+
+// paramType 参数字段类型：布尔用指针以区分 false 与不传
+func (g *generator) paramType(s *Schema) string {
+	switch s.Type {
+	case "boolean":
+		return "*bool"
+	case "integer":
+		return "int"
+	}
+	return "string"
+}
+
+func (g *generator) writeParam(p Parameter) {
+	field := "params." + GoName(p.Name)
+	switch p.Schema.Type {
+	case "boolean":
+		g.printf("\t\tif %s != nil {\n\t\t\t%s(%q, %s)\n\t\t}\n", field, setter(p), p.Name, g.format(p.Schema, "*"+field))
+	case "integer":
+		g.printf("\t\tif %s != 0 {\n\t\t\t%s(%q, %s)\n\t\t}\n", field, setter(p), p.Name, g.format(p.Schema, field))
+	default:
+		g.printf("\t\tif %s != \"\" {\n\t\t\t%s(%q, %s)\n\t\t}\n", field, setter(p), p.Name, field)
+	}
+}
+
+// format 返回将参数值转为字符串的表达式
+func (g *generator) format(s *Schema, v string) string {
+	switch s.Type {
+	case "boolean":
+		g.strconv = true
+		return "strconv.FormatBool(" + v + ")"
+	case "integer":
+		g.strconv = true
+		return "strconv.Itoa(" + v + ")"
+	}
+	return v
+}
+
+func setter(p Parameter) string {
+	if p.In == "header" {
+		return "header.Set"
+	}
+	return "query.Set"
+}
+
+// argName 参数名转为 Go 方法参数名，如 per_page -> perPage
+func argName(s string) string {
+	first, rest, _ := strings.Cut(strings.ReplaceAll(s, "-", "_"), "_")
+	return strings.ToLower(first) + GoName(rest)
+}
+
+// pathExpr 将 /api/runners/{name}/start 转为 "/api/runners/" + url.PathEscape(name) + "/start"
+func (g *generator) pathExpr(path string) string {
+	var parts []string
+	for path != "" {
+		i := strings.Index(path, "{")
+		if i < 0 {
+			parts = append(parts, fmt.Sprintf("%q", path))
+			break
+		}
+		j := strings.Index(path, "}")
+		if i > 0 {
+			parts = append(parts, fmt.Sprintf("%q", path[:i]))
+		}
+		parts = append(parts, "url.PathEscape("+argName(path[i+1:j])+")")
+		path = path[j+1:]
+	}
+	return strings.Join(parts, " + ")
+}
@@ -0,0 +1,414 @@
+// Package openapi 由 Go 类型生成 OpenAPI 3 文档，按文档校验 JSON 响应，并生成 Go 客户端代码。
+// 文档由 handler 中的接口表构建（见 handler.OpenAPIDocument），/api/openapi.json 与 client 包均以它为准。
+package openapi
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version 生成的 OpenAPI 版本
+const Version = "3.0.3"
+
+// Document OpenAPI 文档
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+	Security   []map[string][]string            `json:"security"`
+
+	ops []*Operation // 按添加顺序，供生成客户端
+}
+
+// Info 文档信息
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Components 可复用的 schema 与鉴权方式
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme 鉴权方式
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	In          string `json:"in,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Operation 一个接口
+type Operation struct {
+	OperationID string                 `json:"operationId"`
+	Summary     string                 `json:"summary"`
+	Description string                 `json:"description,omitempty"`
+	Tags        []string               `json:"tags,omitempty"`
+	Parameters  []Parameter            `json:"parameters,omitempty"`
+	RequestBody *RequestBody           `json:"requestBody,omitempty"`
+	Responses   map[string]*Response   `json:"responses"`
+	Security    *[]map[string][]string `json:"security,omitempty"` // 指向空切片表示无需鉴权
+	Scope       string                 `json:"x-scope,omitempty"`  // 所需权限：read、operate、admin
+	NoClient    bool                   `json:"x-no-client,omitempty"`
+
+	method, path string
+}
+
+// Parameter 路径、查询或请求头参数
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"` // path、query、header
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody 请求体
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response 响应
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType 某种媒体类型的内容
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema OpenAPI 3.0 schema 的子集；空 Schema 表示任意值
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"` // 仅用于可为 null 的引用
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties any                `json:"additionalProperties,omitempty"` // *Schema，或 false 表示不允许未声明的字段
+
+	order []string // 属性按 Go 字段声明顺序，生成客户端时保持
+}
+
+// Route 描述一个接口，Path 为 echo 路由格式（如 /api/runners/:name），:name 段生成路径参数
+type Route struct {
+	Method      string
+	Path        string
+	ID          string // operationId，生成客户端时作为方法名
+	Summary     string
+	Description string
+	Tag         string
+	Scope       string // 空表示无需鉴权
+	Query       []Param
+	Headers     []Param
+	Body        any    // JSON 请求体类型的零值
+	BodyMedia   string // 非 JSON 请求体的媒体类型（内容为字符串），如 application/yaml
+	Responses   []Resp
+	NoClient    bool // 不生成客户端方法（如 SSE、webhook）
+}
+
+// Param 查询或请求头参数；Type 为 ""、0、false 之一，决定参数类型
+type Param struct {
+	Name        string
+	Description string
+	Type        any
+	Required    bool
+}
+
+// Resp 一种响应，Status 为 0 时为 default；Body 为 JSON 响应类型的零值，Media 非空时为该媒体类型的文本
+type Resp struct {
+	Status      int
+	Description string
+	Body        any
+	Media       string
+}
+
+// Builder 逐个添加接口并生成文档
+type Builder struct {
+	doc    *Document
+	names  map[reflect.Type]string // 已生成的 schema 名称
+	types  map[string]reflect.Type
+	rename map[reflect.Type]string
+	fields map[string][]string // schema 名称 -> 非 omitempty 字段，只对出现在响应中的 schema 设为 required
+}
+
+// NewBuilder 创建文档
+func NewBuilder(info Info) *Builder {
+	return &Builder{
+		doc: &Document{
+			OpenAPI: Version,
+			Info:    info,
+			Paths:   map[string]map[string]*Operation{},
+			Components: Components{
+				Schemas:         map[string]*Schema{},
+				SecuritySchemes: map[string]SecurityScheme{},
+			},
+			Security: []map[string][]string{},
+		},
+		names:  map[reflect.Type]string{},
+		types:  map[string]reflect.Type{},
+		rename: map[reflect.Type]string{},
+		fields: map[string][]string{},
+	}
+}
+
+// Name 为 v 的类型指定 schema 名称，用于不同包中的同名类型
+func (b *Builder) Name(v any, name string) {
+	b.rename[reflect.TypeOf(v)] = name
+}
+
+// Security 添加一种鉴权方式，各方式任一满足即可
+func (b *Builder) Security(name string, s SecurityScheme) {
+	b.doc.Components.SecuritySchemes[name] = s
+	b.doc.Security = append(b.doc.Security, map[string][]string{name: {}})
+}
+
+var pathParam = regexp.MustCompile(`:([A-Za-z_]+)`)
+
+// Add 添加接口
+func (b *Builder) Add(r Route) {
+	p := pathParam.ReplaceAllString(r.Path, "{$1}")
+	op := &Operation{
+		OperationID: r.ID,
+		Summary:     r.Summary,
+		Description: r.Description,
+		Responses:   map[string]*Response{},
+		Scope:       r.Scope,
+		NoClient:    r.NoClient,
+		method:      r.Method,
+		path:        p,
+	}
+	if r.Tag != "" {
+		op.Tags = []string{r.Tag}
+	}
+	if r.Scope == "" {
+		op.Security = &[]map[string][]string{}
+	}
+	for _, m := range pathParam.FindAllStringSubmatch(r.Path, -1) {
+		op.Parameters = append(op.Parameters, Parameter{Name: m[1], In: "path", Required: true, Schema: &Schema{Type: "string"}})
+	}
+	for _, q := range r.Query {
+		op.Parameters = append(op.Parameters, b.param(q, "query"))
+	}
+	for _, h := range r.Headers {
+		op.Parameters = append(op.Parameters, b.param(h, "header"))
+	}
+	switch {
+	case r.BodyMedia != "":
+		op.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{r.BodyMedia: {Schema: &Schema{Type: "string"}}}}
+	case r.Body != nil:
+		op.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{"application/json": {Schema: b.schema(reflect.TypeOf(r.Body))}}}
+	}
+	for _, resp := range r.Responses {
+		out := &Response{Description: resp.Description}
+		switch {
+		case resp.Media != "":
+			out.Content = map[string]MediaType{resp.Media: {Schema: &Schema{Type: "string"}}}
+		case resp.Body != nil:
+			out.Content = map[string]MediaType{"application/json": {Schema: b.schema(reflect.TypeOf(resp.Body))}}
+		}
+		code := "default"
+		if resp.Status != 0 {
+			code = strconv.Itoa(resp.Status)
+		}
+		op.Responses[code] = out
+	}
+	if b.doc.Paths[p] == nil {
+		b.doc.Paths[p] = map[string]*Operation{}
+	}
+	b.doc.Paths[p][strings.ToLower(r.Method)] = op
+	b.doc.ops = append(b.doc.ops, op)
+}
+
+func (b *Builder) param(p Param, in string) Parameter {
+	return Parameter{Name: p.Name, In: in, Description: p.Description, Required: p.Required, Schema: b.schema(reflect.TypeOf(p.Type))}
+}
+
+// Document 返回文档：出现在响应中的 schema 以非 omitempty 字段为 required，仅用于请求的不设 required
+func (b *Builder) Document() *Document {
+	marked := map[string]bool{}
+	for _, op := range b.doc.ops {
+		for _, r := range op.Responses {
+			for _, mt := range r.Content {
+				b.markOutput(mt.Schema, marked)
+			}
+		}
+	}
+	for name, s := range b.doc.Components.Schemas {
+		if marked[name] {
+			s.Required = b.fields[name]
+		}
+	}
+	return b.doc
+}
+
+func (b *Builder) markOutput(s *Schema, marked map[string]bool) {
+	if s == nil {
+		return
+	}
+	if name, ok := strings.CutPrefix(s.Ref, refPrefix); ok {
+		if marked[name] {
+			return
+		}
+		marked[name] = true
+		s = b.doc.Components.Schemas[name]
+	}
+	for _, sub := range s.AllOf {
+		b.markOutput(sub, marked)
+	}
+	b.markOutput(s.Items, marked)
+	for _, p := range s.Properties {
+		b.markOutput(p, marked)
+	}
+	if ap, ok := s.AdditionalProperties.(*Schema); ok {
+		b.markOutput(ap, marked)
+	}
+}
+
+const refPrefix = "#/components/schemas/"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema 返回类型 t 的 schema；具名结构体放入 components 并返回引用
+func (b *Builder) schema(t reflect.Type) *Schema {
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := b.schema(t.Elem())
+		if s.Ref != "" {
+			return &Schema{AllOf: []*Schema{s}, Nullable: true}
+		}
+		s.Nullable = true
+		return s
+	case reflect.Interface:
+		return &Schema{}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: b.schema(t.Elem()), Nullable: t.Kind() == reflect.Slice}
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			break
+		}
+		return &Schema{Type: "object", AdditionalProperties: b.schema(t.Elem()), Nullable: true}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.object(t, "")
+		}
+		return &Schema{Ref: refPrefix + b.component(t)}
+	}
+	panic(fmt.Sprintf("openapi: 不支持的类型 %s", t))
+}
+
+// component 生成具名结构体的 schema 并返回其名称；不同包中的同名类型须先用 Name 指定名称
+func (b *Builder) component(t reflect.Type) string {
+	if name, ok := b.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if n, ok := b.rename[t]; ok {
+		name = n
+	}
+	if other, ok := b.types[name]; ok && other != t {
+		panic(fmt.Sprintf("openapi: %s 与 %s 同名，请用 Builder.Name 指定名称", t, other))
+	}
+	b.names[t], b.types[name] = name, t
+	b.doc.Components.Schemas[name] = &Schema{} // 先占位，支持递归类型
+	*b.doc.Components.Schemas[name] = *b.object(t, name)
+	return name
+}
+
+// object 按 json 标签生成对象 schema，内嵌结构体的字段并入外层；name 非空时记录非 omitempty 字段
+func (b *Builder) object(t reflect.Type, name string) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}, AdditionalProperties: false}
+	var required []string
+	b.addFields(s, t, &required)
+	if name != "" {
+		b.fields[name] = required
+	} else {
+		s.Required = required
+	}
+	return s
+}
+
+func (b *Builder) addFields(s *Schema, t reflect.Type, required *[]string) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if tag == "-" && opts == "" {
+			continue
+		}
+		if f.Anonymous && tag == "" && f.Type.Kind() == reflect.Struct {
+			b.addFields(s, f.Type, required)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if tag == "" {
+			tag = f.Name
+		}
+		s.Properties[tag] = b.schema(f.Type)
+		s.order = append(s.order, tag)
+		optional := slices.ContainsFunc(strings.Split(opts, ","), func(o string) bool { return o == "omitempty" || o == "omitzero" })
+		if !optional {
+			*required = append(*required, tag)
+		}
+	}
+}
+
+// Operation 按方法与路径查找接口，path 可为 echo 或 OpenAPI 格式
+func (d *Document) Operation(method, path string) (*Operation, bool) {
+	op, ok := d.Paths[pathParam.ReplaceAllString(path, "{$1}")][strings.ToLower(method)]
+	return op, ok
+}
+
+// Operations 按添加顺序返回全部接口
+func (d *Document) Operations() []*Operation {
+	return d.ops
+}
+
+// Method 接口的 HTTP 方法
+func (op *Operation) Method() string { return op.method }
+
+// Path 接口的 OpenAPI 路径
+func (op *Operation) Path() string { return op.path }
+
+// ResponseSchema 返回接口在 status 下的 JSON 响应 schema；未声明该状态码时使用 default
+func (op *Operation) ResponseSchema(status int) (*Schema, bool) {
+	r, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		r, ok = op.Responses["default"]
+	}
+	if !ok {
+		return nil, false
+	}
+	mt, ok := r.Content["application/json"]
+	return mt.Schema, ok
+}
@@ -0,0 +1,145 @@
+package openapi
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type base struct {
+	ID string `json:"id"`
+}
+
+type item struct {
+	base
+	Name    string            `json:"name"`
+	Note    string            `json:"note,omitempty"`
+	At      time.Time         `json:"at"`
+	Done    *time.Time        `json:"done,omitempty"`
+	Parent  *item             `json:"parent"`
+	Tags    []string          `json:"tags"`
+	Extra   map[string]int    `json:"extra,omitempty"`
+	Any     any               `json:"any,omitempty"`
+	Ignored string            `json:"-"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	skipped string
+}
+
+type itemRequest struct {
+	Name string `json:"name"`
+}
+
+func testDocument() *Document {
+	b := NewBuilder(Info{Title: "t", Version: "1"})
+	b.Add(Route{Method: "GET", Path: "/items/:id", ID: "GetItem", Scope: "read",
+		Responses: []Resp{{Status: 200, Body: item{}}}})
+	b.Add(Route{Method: "POST", Path: "/items", ID: "AddItem", Scope: "admin", Body: itemRequest{},
+		Query:     []Param{{Name: "dry_run", Type: false}, {Name: "limit", Type: 0, Required: true}},
+		Responses: []Resp{{Status: 201, Body: item{}}, {Description: "错误", Body: map[string]string{}}}})
+	return b.Document()
+}
+
+func TestBuilder_Schemas(t *testing.T) {
+	d := testDocument()
+	s := d.Components.Schemas["item"]
+	if s == nil {
+		t.Fatal("item schema missing")
+	}
+	if !slices.Equal(s.order, []string{"id", "name", "note", "at", "done", "parent", "tags", "extra", "any", "labels"}) {
+		t.Errorf("order = %v", s.order)
+	}
+	if !slices.Equal(s.Required, []string{"id", "name", "at", "parent", "tags"}) {
+		t.Errorf("required = %v", s.Required)
+	}
+	if p := s.Properties["parent"]; len(p.AllOf) != 1 || !p.Nullable || p.AllOf[0].Ref != refPrefix+"item" {
+		t.Errorf("parent = %+v", p)
+	}
+	if p := s.Properties["at"]; p.Type != "string" || p.Format != "date-time" || p.Nullable {
+		t.Errorf("at = %+v", p)
+	}
+	// 仅用于请求的 schema 不设 required
+	if r := d.Components.Schemas["itemRequest"]; r == nil || len(r.Required) != 0 {
+		t.Errorf("itemRequest = %+v", r)
+	}
+	op, ok := d.Operation("GET", "/items/:id")
+	if !ok || op.Path() != "/items/{id}" || op.Parameters[0].In != "path" || op.Security != nil {
+		t.Fatalf("GetItem = %+v", op)
+	}
+	if _, ok := op.ResponseSchema(500); ok {
+		t.Error("GetItem declares no default response")
+	}
+	op, _ = d.Operation("POST", "/items")
+	if s, ok := op.ResponseSchema(404); !ok || s.Type != "object" {
+		t.Errorf("default response = %+v", s)
+	}
+}
+
+// otherItemRequest 为包级的 itemRequest，与测试函数内的同名类型冲突
+var otherItemRequest = itemRequest{}
+
+func TestBuilder_NameCollision(t *testing.T) {
+	type itemRequest struct{}
+	b := NewBuilder(Info{})
+	b.Add(Route{Method: "POST", Path: "/a", Body: itemRequest{}})
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic on duplicate schema name")
+		}
+	}()
+	b.Add(Route{Method: "POST", Path: "/b", Body: otherItemRequest})
+}
+
+func TestValidate(t *testing.T) {
+	d := testDocument()
+	op, _ := d.Operation("GET", "/items/{id}")
+	s, _ := op.ResponseSchema(200)
+	valid := `{"id":"1","name":"a","at":"2026-01-02T03:04:05Z","parent":{"id":"0","name":"root","at":"2026-01-01T00:00:00Z","parent":null,"tags":null},"tags":["x"],"extra":{"n":1},"any":[1,"a"]}`
+	if err := d.Validate(s, []byte(valid)); err != nil {
+		t.Fatalf("valid document: %v", err)
+	}
+	for body, want := range map[string]string{
+		`{"id":"1","name":"a","at":"2026-01-02T03:04:05Z","parent":null}`:                             "缺少字段 tags",
+		`{"id":"1","name":"a","at":"yesterday","parent":null,"tags":[]}`:                              "date-time",
+		`{"id":"1","name":"a","at":"2026-01-02T03:04:05Z","parent":null,"tags":[],"extra":{"n":"1"}}`: "$.extra.n",
+		`{"id":"1","name":"a","at":"2026-01-02T03:04:05Z","parent":null,"tags":[],"color":"red"}`:     "未声明字段 color",
+		`{"id":1,"name":"a","at":"2026-01-02T03:04:05Z","parent":null,"tags":[]}`:                     "$.id",
+		`{"id":"1","name":null,"at":"2026-01-02T03:04:05Z","parent":null,"tags":[]}`:                  "不可为 null",
+	} {
+		err := d.Validate(s, []byte(body))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate(%s) = %v, want %q", body, err, want)
+		}
+	}
+}
+
+func TestGenerateClient(t *testing.T) {
+	src, err := GenerateClient(testDocument(), "x")
+	if err != nil {
+		t.Fatalf("%v\n%s", err, src)
+	}
+	code := strings.Join(strings.Fields(string(src)), " ")
+	for _, want := range []string{
+		"type item struct",
+		"Parent *item `json:\"parent\"`",
+		"Done *time.Time `json:\"done,omitempty\"`",
+		"Note string `json:\"note,omitempty\"`",
+		"Tags []string `json:\"tags\"`",
+		"func (c *Client) AddItem(ctx context.Context, limit int, body itemRequest, params *AddItemParams) (*item, error)",
+		"DryRun *bool",
+		`query.Set("limit", strconv.Itoa(limit))`,
+		`"/items/"+url.PathEscape(id)`,
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q\n%s", want, src)
+		}
+	}
+}
+
+func TestGoName(t *testing.T) {
+	for in, want := range map[string]string{"job_id": "JobID", "registered_on_github": "RegisteredOnGitHub", "If-Match": "IfMatch", "etag": "ETag", "max_work_size_mb": "MaxWorkSizeMB"} {
+		if got := GoName(in); got != want {
+			t.Errorf("GoName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Validate 按 schema 校验 JSON 文本：类型、必填字段、未声明的字段（additionalProperties: false）与 date-time 格式
+func (d *Document) Validate(s *Schema, data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("解析 JSON 失败: %w", err)
+	}
+	return d.validate(s, v, "$")
+}
+
+func (d *Document) resolve(s *Schema) (*Schema, error) {
+	for s.Ref != "" {
+		name, _ := strings.CutPrefix(s.Ref, refPrefix)
+		next, ok := d.Components.Schemas[name]
+		if !ok {
+			return nil, fmt.Errorf("未定义的 schema %s", s.Ref)
+		}
+		s = next
+	}
+	return s, nil
+}
+
+func (d *Document) validate(s *Schema, v any, at string) error {
+	s, err := d.resolve(s)
+	if err != nil {
+		return fmt.Errorf("%s: %w", at, err)
+	}
+	if v == nil {
+		if s.Nullable || (s.Type == "" && len(s.AllOf) == 0) {
+			return nil
+		}
+		return fmt.Errorf("%s: 不可为 null", at)
+	}
+	for _, sub := range s.AllOf {
+		if err := d.validate(sub, v, at); err != nil {
+			return err
+		}
+	}
+	switch s.Type {
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s: 应为 string，实际为 %T", at, v)
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+				return fmt.Errorf("%s: 不是 date-time: %q", at, str)
+			}
+		}
+	case "integer", "number":
+		f, ok := v.(float64)
+		if !ok {
+			return fmt.Errorf("%s: 应为 %s，实际为 %T", at, s.Type, v)
+		}
+		if s.Type == "integer" && f != math.Trunc(f) {
+			return fmt.Errorf("%s: 应为 integer，实际为 %v", at, f)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: 应为 boolean，实际为 %T", at, v)
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("%s: 应为 array，实际为 %T", at, v)
+		}
+		for i, item := range arr {
+			if err := d.validate(s.Items, item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: 应为 object，实际为 %T", at, v)
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s: 缺少字段 %s", at, name)
+			}
+		}
+		for _, k := range slices.Sorted(maps.Keys(obj)) {
+			sub, ok := s.Properties[k]
+			if !ok {
+				switch ap := s.AdditionalProperties.(type) {
+				case *Schema:
+					sub = ap
+				case bool:
+					if !ap {
+						return fmt.Errorf("%s: 文档中未声明字段 %s（已声明: %s）", at, k, strings.Join(slices.Sorted(maps.Keys(s.Properties)), ", "))
+					}
+				}
+			}
+			if sub == nil {
+				continue
+			}
+			if err := d.validate(sub, obj[k], at+"."+k); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
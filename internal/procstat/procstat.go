@@ -0,0 +1,161 @@
+// Package procstat 从 /proc 读取进程树的 CPU 与内存占用（仅 Linux），供 Agent 与进程模式下的 Manager 上报 runner 资源使用。
+package procstat
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clockTicks /proc 中 CPU 时间的单位（USER_HZ），Linux 上固定为 100
+const clockTicks = 100
+
+// ErrUnsupported 非 Linux 系统无 /proc
+var ErrUnsupported = errors.New("仅 Linux 支持读取 /proc 进程信息")
+
+var procRoot = "/proc"
+
+type procStat struct {
+	pid        int
+	ppid       int
+	cpuTicks   uint64 // utime + stime
+	startTicks uint64 // 进程启动时间（开机后 tick 数）
+	rssPages   int64
+}
+
+// readStat 解析 /proc/<pid>/stat；comm 字段可能含空格与括号，以最后一个 ')' 为界
+func readStat(pid int) (*procStat, error) {
+	b, err := os.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return nil, err
+	}
+	s := string(b)
+	i := strings.LastIndexByte(s, ')')
+	if i < 0 {
+		return nil, errors.New("stat 格式无法识别")
+	}
+	// 从 state（第 3 个字段）开始
+	f := strings.Fields(s[i+1:])
+	if len(f) < 22 {
+		return nil, errors.New("stat 字段不足")
+	}
+	ppid, _ := strconv.Atoi(f[1])
+	utime, _ := strconv.ParseUint(f[11], 10, 64)
+	stime, _ := strconv.ParseUint(f[12], 10, 64)
+	start, _ := strconv.ParseUint(f[19], 10, 64)
+	rss, _ := strconv.ParseInt(f[21], 10, 64)
+	return &procStat{pid: pid, ppid: ppid, cpuTicks: utime + stime, startTicks: start, rssPages: rss}, nil
+}
+
+func listStats() []*procStat {
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return nil
+	}
+	out := make([]*procStat, 0, len(entries))
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		if st, err := readStat(pid); err == nil {
+			out = append(out, st)
+		}
+	}
+	return out
+}
+
+func uptimeSeconds() (float64, error) {
+	b, err := os.ReadFile(filepath.Join(procRoot, "uptime"))
+	if err != nil {
+		return 0, err
+	}
+	f := strings.Fields(string(b))
+	if len(f) == 0 {
+		return 0, errors.New("uptime 格式无法识别")
+	}
+	return strconv.ParseFloat(f[0], 64)
+}
+
+// TreeUsage 进程树累计用量
+type TreeUsage struct {
+	CPUSeconds     float64 // 进程树累计 CPU 时间
+	RSSBytes       int64   // 进程树常驻内存合计
+	ElapsedSeconds float64 // 根进程已运行时长
+	Processes      int
+}
+
+// Tree 统计以 root 为根的进程树（含所有子孙进程）的 CPU 时间与 RSS
+func Tree(root int) (*TreeUsage, error) {
+	if runtime.GOOS != "linux" {
+		return nil, ErrUnsupported
+	}
+	rootStat, err := readStat(root)
+	if err != nil {
+		return nil, err
+	}
+	children := make(map[int][]*procStat)
+	for _, st := range listStats() {
+		children[st.ppid] = append(children[st.ppid], st)
+	}
+	u := &TreeUsage{}
+	pageSize := int64(os.Getpagesize())
+	queue := []*procStat{rootStat}
+	seen := map[int]bool{}
+	for len(queue) > 0 {
+		st := queue[0]
+		queue = queue[1:]
+		if seen[st.pid] {
+			continue
+		}
+		seen[st.pid] = true
+		u.Processes++
+		u.CPUSeconds += float64(st.cpuTicks) / clockTicks
+		u.RSSBytes += st.rssPages * pageSize
+		queue = append(queue, children[st.pid]...)
+	}
+	if up, err := uptimeSeconds(); err == nil {
+		u.ElapsedSeconds = up - float64(rootStat.startTicks)/clockTicks
+	}
+	return u, nil
+}
+
+// Sampler 记录每个根进程上一次的 CPU 时间，用两次采样差计算 CPU 使用率
+type Sampler struct {
+	mu   sync.Mutex
+	last map[int]sample
+}
+
+type sample struct {
+	at  time.Time
+	cpu float64
+}
+
+// NewSampler 创建采样器
+func NewSampler() *Sampler {
+	return &Sampler{last: make(map[int]sample)}
+}
+
+// Sample 返回进程树的 CPU 使用率（100 表示占满一个核）与 RSS；首次采样返回进程生命周期内的平均使用率
+func (s *Sampler) Sample(root int) (cpuPercent float64, rssBytes int64, err error) {
+	u, err := Tree(root)
+	if err != nil {
+		return 0, 0, err
+	}
+	now := time.Now()
+	s.mu.Lock()
+	prev, ok := s.last[root]
+	s.last[root] = sample{at: now, cpu: u.CPUSeconds}
+	s.mu.Unlock()
+	if ok && now.After(prev.at) && u.CPUSeconds >= prev.cpu {
+		cpuPercent = (u.CPUSeconds - prev.cpu) / now.Sub(prev.at).Seconds() * 100
+	} else if u.ElapsedSeconds > 0 {
+		cpuPercent = u.CPUSeconds / u.ElapsedSeconds * 100
+	}
+	return cpuPercent, u.RSSBytes, nil
+}
@@ -0,0 +1,64 @@
+package procstat
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+// writeFakeProc 在临时目录构造 /proc/<pid>/stat；utime/stime 单位为 tick，rss 单位为页
+func writeFakeProc(t *testing.T, root string, pid, ppid int, comm string, utime, stime, start, rss int) {
+	t.Helper()
+	dir := filepath.Join(root, strconv.Itoa(pid))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	stat := strconv.Itoa(pid) + " (" + comm + ") S " + strconv.Itoa(ppid) +
+		" 0 0 0 -1 0 0 0 0 0 " + strconv.Itoa(utime) + " " + strconv.Itoa(stime) +
+		" 0 0 20 0 1 0 " + strconv.Itoa(start) + " 0 " + strconv.Itoa(rss) + " 0\n"
+	if err := os.WriteFile(filepath.Join(dir, "stat"), []byte(stat), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTree(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("仅 Linux")
+	}
+	root := t.TempDir()
+	old := procRoot
+	procRoot = root
+	defer func() { procRoot = old }()
+	writeFakeProc(t, root, 10, 1, "run.sh", 100, 50, 1000, 10)
+	writeFakeProc(t, root, 11, 10, "Runner.Listener (x)", 200, 0, 1100, 20)
+	writeFakeProc(t, root, 12, 11, "node", 50, 50, 1200, 30)
+	writeFakeProc(t, root, 20, 1, "other", 999, 999, 500, 999)
+	if err := os.WriteFile(filepath.Join(root, "uptime"), []byte("40.00 10.00\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	u, err := Tree(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Processes != 3 {
+		t.Errorf("processes = %d, want 3", u.Processes)
+	}
+	if u.CPUSeconds != 4.5 {
+		t.Errorf("cpu seconds = %v, want 4.5", u.CPUSeconds)
+	}
+	if want := int64(60 * os.Getpagesize()); u.RSSBytes != want {
+		t.Errorf("rss = %d, want %d", u.RSSBytes, want)
+	}
+	if u.ElapsedSeconds != 30 {
+		t.Errorf("elapsed = %v, want 30", u.ElapsedSeconds)
+	}
+	cpu, _, err := NewSampler().Sample(10)
+	if err != nil || cpu != 15 {
+		t.Errorf("first sample cpu = %v, %v; want lifetime average 15", cpu, err)
+	}
+	if _, err := Tree(99); err == nil {
+		t.Error("expected error for missing pid")
+	}
+}
@@ -0,0 +1,249 @@
+// Package regjob 持久化后台安装+注册任务：每个任务有 ID、状态（queued/installing/configuring/starting/done/failed/cancelled）、
+// 安装与注册脚本输出（已脱敏注册 Token）及各阶段时间，存放于 base_path/.fleet/registrations/<id>.json。
+// 注册 Token 单独存为同目录下 <id>.token（0600），仅供 worker 与重试读取，不出现在 API 与任务 JSON 中；任务成功后删除。
+package regjob
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 任务状态
+const (
+	StateQueued      = "queued"
+	StateInstalling  = "installing"  // 下载并解压 runner
+	StateConfiguring = "configuring" // 执行 config 脚本向 GitHub 注册
+	StateStarting    = "starting"    // 注册成功后启动 runner
+	StateDone        = "done"
+	StateFailed      = "failed"
+	StateCancelled   = "cancelled"
+)
+
+const (
+	// maxJobs 最多保留的任务数，超出后删除最旧的已结束任务
+	maxJobs = 200
+	// maxOutputBytes 每段脚本输出最多保留的字节数（保留末尾）
+	maxOutputBytes = 32 * 1024
+	// redactedToken 输出中注册 Token 的替换文本
+	redactedToken = "***"
+)
+
+// ErrNotFound 任务不存在
+var ErrNotFound = errors.New("任务不存在")
+
+// mu 保护任务文件读写
+var mu sync.Mutex
+
+// Job 单个安装+注册任务
+type Job struct {
+	ID            string    `json:"id"`
+	Runner        string    `json:"runner"`
+	InstallDir    string    `json:"install_dir"`
+	URL           string    `json:"url"`
+	Labels        []string  `json:"labels,omitempty"`
+	RunnerVersion string    `json:"runner_version,omitempty"` // 本任务安装的 actions runner 版本（仅需安装时）
+	State         string    `json:"state"`
+	Error         string    `json:"error,omitempty"`
+	InstallOutput string    `json:"install_output,omitempty"`
+	ConfigOutput  string    `json:"config_output,omitempty"`
+	RetryOf       string    `json:"retry_of,omitempty"` // 由哪个任务重试而来
+	CreatedAt     time.Time `json:"created_at"`
+	StartedAt     time.Time `json:"started_at,omitzero"`
+	FinishedAt    time.Time `json:"finished_at,omitzero"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Terminal 是否已结束（done/failed/cancelled）
+func (j *Job) Terminal() bool {
+	switch j.State {
+	case StateDone, StateFailed, StateCancelled:
+		return true
+	}
+	return false
+}
+
+// Dir 返回任务目录（stateDir/registrations）
+func Dir(stateDir string) string {
+	return filepath.Join(stateDir, "registrations")
+}
+
+func jobFile(stateDir, id string) string   { return filepath.Join(Dir(stateDir), id+".json") }
+func tokenFile(stateDir, id string) string { return filepath.Join(Dir(stateDir), id+".token") }
+
+// validID 任务 ID 仅含字母、数字与 -，防止路径穿越
+func validID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// NewID 生成按时间排序的任务 ID，如 reg-20261018-200153-3f9a1c
+func NewID() string {
+	b := make([]byte, 3)
+	_, _ = rand.Read(b)
+	return "reg-" + time.Now().UTC().Format("20060102-150405") + "-" + hex.EncodeToString(b)
+}
+
+// New 创建处于 queued 状态的任务（未保存）
+func New(runnerName, installDir, url string, labels []string) *Job {
+	now := time.Now().UTC()
+	return &Job{
+		ID:         NewID(),
+		Runner:     runnerName,
+		InstallDir: installDir,
+		URL:        url,
+		Labels:     append([]string(nil), labels...),
+		State:      StateQueued,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+}
+
+// Save 写入任务（tmp+rename），并在任务数超过上限时清理最旧的已结束任务
+func Save(stateDir string, j *Job) error {
+	if !validID(j.ID) {
+		return ErrNotFound
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if err := os.MkdirAll(Dir(stateDir), 0755); err != nil {
+		return err
+	}
+	j.UpdatedAt = time.Now().UTC()
+	b, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	p := jobFile(stateDir, j.ID)
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		return err
+	}
+	prune(stateDir)
+	return nil
+}
+
+// Get 读取任务，不存在返回 ErrNotFound
+func Get(stateDir, id string) (*Job, error) {
+	if !validID(id) {
+		return nil, ErrNotFound
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return readJob(jobFile(stateDir, id))
+}
+
+func readJob(p string) (*Job, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	var j Job
+	if err := json.Unmarshal(b, &j); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+func readAll(stateDir string) []*Job {
+	matches, _ := filepath.Glob(filepath.Join(Dir(stateDir), "reg-*.json"))
+	out := make([]*Job, 0, len(matches))
+	for _, p := range matches {
+		if j, err := readJob(p); err == nil {
+			out = append(out, j)
+		}
+	}
+	sort.Slice(out, func(i, k int) bool { return out[i].CreatedAt.After(out[k].CreatedAt) })
+	return out
+}
+
+// List 返回任务，按创建时间倒序；runnerName、state 非空时按其过滤
+func List(stateDir, runnerName, state string) []*Job {
+	mu.Lock()
+	defer mu.Unlock()
+	all := readAll(stateDir)
+	out := all[:0]
+	for _, j := range all {
+		if (runnerName == "" || j.Runner == runnerName) && (state == "" || j.State == state) {
+			out = append(out, j)
+		}
+	}
+	return out
+}
+
+// prune 调用方持有 mu
+func prune(stateDir string) {
+	all := readAll(stateDir)
+	if len(all) <= maxJobs {
+		return
+	}
+	for _, j := range all[maxJobs:] {
+		if !j.Terminal() {
+			continue
+		}
+		_ = os.Remove(jobFile(stateDir, j.ID))
+		_ = os.Remove(tokenFile(stateDir, j.ID))
+	}
+}
+
+// SaveToken 保存任务使用的注册 Token（0600）
+func SaveToken(stateDir, id, token string) error {
+	if !validID(id) {
+		return ErrNotFound
+	}
+	if err := os.MkdirAll(Dir(stateDir), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(tokenFile(stateDir, id), []byte(token), 0600)
+}
+
+// LoadToken 读取任务的注册 Token，不存在返回空
+func LoadToken(stateDir, id string) string {
+	if !validID(id) {
+		return ""
+	}
+	b, err := os.ReadFile(tokenFile(stateDir, id))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// DeleteToken 删除任务的注册 Token
+func DeleteToken(stateDir, id string) {
+	if validID(id) {
+		_ = os.Remove(tokenFile(stateDir, id))
+	}
+}
+
+// RedactOutput 将输出中的 token 替换为 ***，并只保留末尾 maxOutputBytes 字节
+func RedactOutput(out []byte, token string) string {
+	s := string(out)
+	if token != "" {
+		s = strings.ReplaceAll(s, token, redactedToken)
+	}
+	if len(s) > maxOutputBytes {
+		s = "…" + strings.ToValidUTF8(s[len(s)-maxOutputBytes:], "")
+	}
+	return s
+}
@@ -0,0 +1,92 @@
+package regjob
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSaveGetList(t *testing.T) {
+	dir := t.TempDir()
+	a := New("r1", "/runners/r1", "https://github.com/o1", []string{"linux"})
+	if err := Save(dir, a); err != nil {
+		t.Fatal(err)
+	}
+	b := New("r2", "/runners/r2", "https://github.com/o1", nil)
+	b.CreatedAt = a.CreatedAt.Add(time.Second)
+	b.State = StateFailed
+	if err := Save(dir, b); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Get(dir, a.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Runner != "r1" || got.State != StateQueued || len(got.Labels) != 1 {
+		t.Errorf("unexpected job: %+v", got)
+	}
+	if _, err := Get(dir, "reg-missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := Get(dir, "../etc/passwd"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for invalid id, got %v", err)
+	}
+
+	all := List(dir, "", "")
+	if len(all) != 2 || all[0].ID != b.ID {
+		t.Fatalf("expected newest first, got %+v", all)
+	}
+	if l := List(dir, "r1", ""); len(l) != 1 || l[0].ID != a.ID {
+		t.Errorf("filter by runner: %+v", l)
+	}
+	if l := List(dir, "", StateFailed); len(l) != 1 || l[0].ID != b.ID {
+		t.Errorf("filter by state: %+v", l)
+	}
+}
+
+func TestToken(t *testing.T) {
+	dir := t.TempDir()
+	j := New("r1", "/runners/r1", "https://github.com/o1", nil)
+	if err := SaveToken(dir, j.ID, "AAA111"); err != nil {
+		t.Fatal(err)
+	}
+	st, err := os.Stat(tokenFile(dir, j.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Mode().Perm() != 0600 {
+		t.Errorf("token file mode = %v", st.Mode().Perm())
+	}
+	if got := LoadToken(dir, j.ID); got != "AAA111" {
+		t.Errorf("LoadToken = %q", got)
+	}
+	DeleteToken(dir, j.ID)
+	if got := LoadToken(dir, j.ID); got != "" {
+		t.Errorf("token not deleted: %q", got)
+	}
+}
+
+func TestRedactOutput(t *testing.T) {
+	out := RedactOutput([]byte("./config.sh --token AAA111 --url x\nAAA111"), "AAA111")
+	if strings.Contains(out, "AAA111") || strings.Count(out, redactedToken) != 2 {
+		t.Errorf("token not redacted: %q", out)
+	}
+	long := strings.Repeat("x", maxOutputBytes+100)
+	if got := RedactOutput([]byte(long), ""); len(got) > maxOutputBytes+len("…") || !strings.HasPrefix(got, "…") {
+		t.Errorf("output not truncated: len=%d", len(got))
+	}
+}
+
+func TestValidID(t *testing.T) {
+	if !validID(NewID()) {
+		t.Error("NewID should be valid")
+	}
+	for _, id := range []string{"", "a/b", "..", "a.json", strings.Repeat("a", 65)} {
+		if validID(id) {
+			t.Errorf("validID(%q) = true", id)
+		}
+	}
+}
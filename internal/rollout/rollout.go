@@ -0,0 +1,125 @@
+// Package rollout 持久化 fleet 滚动升级的进度：目标版本、失败预算及每个 runner 的升级步骤，
+// 存放于 base_path/.fleet/upgrade.json，仅保留最近一次升级。
+package rollout
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 升级状态
+const (
+	StateRunning   = "running"
+	StateSucceeded = "succeeded"
+	StateHalted    = "halted" // 失败数超过预算后停止
+	StateCancelled = "cancelled"
+	StateFailed    = "failed" // 准备阶段失败（如下载或校验安装包，未改动任何 runner），或 Manager 重启导致中断
+)
+
+// 单个 runner 的步骤状态
+const (
+	StepPending    = "pending"
+	StepDraining   = "draining" // 等待当前 Job 结束
+	StepStopping   = "stopping"
+	StepInstalling = "installing"
+	StepStarting   = "starting"
+	StepVerifying  = "verifying" // 等待重新上线
+	StepDone       = "done"
+	StepFailed     = "failed"
+	StepSkipped    = "skipped"
+)
+
+// ErrNotFound 尚无升级记录
+var ErrNotFound = errors.New("尚无升级记录")
+
+var mu sync.Mutex
+
+// Step 单个 runner 的升级进度
+type Step struct {
+	Runner      string `json:"runner"`
+	State       string `json:"state"`
+	FromVersion string `json:"from_version,omitempty"`
+	Arch        string `json:"arch,omitempty"`
+	Message     string `json:"message,omitempty"` // 跳过原因或失败原因
+	RolledBack  bool   `json:"rolled_back,omitempty"`
+	// JobInterrupted 排空后、停止前 runner 又领取了 Job，停止时该 Job 被中断
+	JobInterrupted bool      `json:"job_interrupted,omitempty"`
+	StartedAt      time.Time `json:"started_at,omitzero"`
+	FinishedAt     time.Time `json:"finished_at,omitzero"`
+}
+
+// Rollout 一次滚动升级
+type Rollout struct {
+	ID                   string    `json:"id"`
+	Version              string    `json:"version"`
+	State                string    `json:"state"`
+	FailureBudget        int       `json:"failure_budget"` // 允许失败的 runner 数，超过后停止
+	Failures             int       `json:"failures"`
+	DrainTimeoutSeconds  int       `json:"drain_timeout_seconds"`
+	OnlineTimeoutSeconds int       `json:"online_timeout_seconds"`
+	Error                string    `json:"error,omitempty"`
+	Steps                []Step    `json:"steps"`
+	CreatedAt            time.Time `json:"created_at"`
+	UpdatedAt            time.Time `json:"updated_at"`
+	FinishedAt           time.Time `json:"finished_at,omitzero"`
+}
+
+// Terminal 升级是否已结束
+func (r *Rollout) Terminal() bool {
+	return r.State != StateRunning
+}
+
+// Counts 按步骤状态计数
+func (r *Rollout) Counts() map[string]int {
+	out := map[string]int{}
+	for _, s := range r.Steps {
+		out[s.State]++
+	}
+	return out
+}
+
+func file(stateDir string) string {
+	return filepath.Join(stateDir, "upgrade.json")
+}
+
+// Load 读取最近一次升级，不存在返回 ErrNotFound
+func Load(stateDir string) (*Rollout, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	b, err := os.ReadFile(file(stateDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	var r Rollout
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Save 写入升级进度（tmp+rename）
+func Save(stateDir string, r *Rollout) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return err
+	}
+	r.UpdatedAt = time.Now().UTC()
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	p := file(stateDir)
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
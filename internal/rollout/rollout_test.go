@@ -0,0 +1,30 @@
+package rollout
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Load(dir); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	r := &Rollout{ID: "upg-1", Version: "2.331.0", State: StateRunning, Steps: []Step{
+		{Runner: "r1", State: StepDone},
+		{Runner: "r2", State: StepPending},
+	}}
+	if err := Save(dir, r); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != "upg-1" || len(got.Steps) != 2 || got.Terminal() || got.UpdatedAt.IsZero() {
+		t.Errorf("unexpected rollout: %+v", got)
+	}
+	if c := got.Counts(); c[StepDone] != 1 || c[StepPending] != 1 {
+		t.Errorf("counts = %v", c)
+	}
+}
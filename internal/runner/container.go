@@ -11,6 +11,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -22,10 +23,14 @@ func ContainerName(name string) string {
 	return config.NormalizedContainerName(name)
 }
 
-// AgentStatus 容器内 Agent /status 返回结构
+// AgentStatus 容器内 Agent /status 返回结构；资源字段由 Agent 在容器内统计（目录大小、Runner 进程树 CPU/RSS）
 type AgentStatus struct {
-	Status  string `json:"status"`
-	Running bool   `json:"running"`
+	Status          string  `json:"status"`
+	Running         bool    `json:"running"`
+	InstallDirBytes int64   `json:"install_dir_bytes"`
+	WorkDirBytes    int64   `json:"work_dir_bytes"`
+	CPUPercent      float64 `json:"cpu_percent"`
+	MemoryRSSBytes  int64   `json:"memory_rss_bytes"`
 }
 
 // GetAgentStatus 请求 Runner 容器内 Agent 的 /status，超时 5 秒
@@ -217,10 +222,13 @@ func StartRunnerContainer(ctx context.Context, cfg *config.Config, runnerName, i
 			return fmt.Errorf("容器模式下 Manager 若在容器内运行，必须在 config/config.yaml 中设置 runners.volume_host_path 为宿主机上 runners 根目录的绝对路径（当前 base_path 为 %s）", cfg.Runners.BasePath)
 		}
 	}
-	img := cfg.Runners.ContainerImage
-	if img == "" {
-		img = config.DefaultRunnerContainerImage()
+	// 镜像与 --platform 按 runner 架构选择，须与挂载进容器的 runner 安装包一致
+	item := config.RunnerItem{Name: runnerName}
+	if idx := slices.IndexFunc(cfg.Runners.Items, func(i config.RunnerItem) bool { return i.Name == runnerName }); idx >= 0 {
+		item = cfg.Runners.Items[idx]
 	}
+	arch := Arch(cfg, item)
+	img := cfg.Runners.ContainerImageFor(arch)
 	network := cfg.Runners.ContainerNetwork
 	if network == "" {
 		network = "runner-net"
@@ -250,6 +258,7 @@ func StartRunnerContainer(ctx context.Context, cfg *config.Config, runnerName, i
 	createArgs := []string{
 		"create",
 		"--name", cn,
+		"--platform", config.DockerPlatform(arch),
 		"-v", mountSrc + ":/runner",
 		"--network", network,
 	}
@@ -307,14 +316,15 @@ func RemoveRunnerContainer(ctx context.Context, runnerName string) error {
 
 // ContainerRunnerStatus 在容器模式下获取某 runner 的状态：先看容器是否运行，再问 Agent
 // 容器未运行时仍返回 StatusInstalled（与磁盘一致），仅 Running=false，便于界面显示「已注册未运行」
-func ContainerRunnerStatus(ctx context.Context, cfg *config.Config, runnerName, installDir string) (running bool, status Status, err error) {
+// usage 为 Agent 上报的资源占用，容器未运行或 Agent 不可达时为 nil
+func ContainerRunnerStatus(ctx context.Context, cfg *config.Config, runnerName, installDir string) (running bool, status Status, usage *ResourceUsage, err error) {
 	cn := ContainerName(runnerName)
 	ok, err := ContainerRunning(ctx, cn)
 	if err != nil {
-		return false, StatusUnknown, newProbeError(ProbeErrorTypeDockerAccess, err)
+		return false, StatusUnknown, nil, newProbeError(ProbeErrorTypeDockerAccess, err)
 	}
 	if !ok {
-		return false, StatusInstalled, nil // 容器未跑时保留「已注册」状态，不覆盖为 unknown
+		return false, StatusInstalled, nil, nil // 容器未跑时保留「已注册」状态，不覆盖为 unknown
 	}
 	agent, err := GetAgentStatus(ctx, cn, cfg.Runners.AgentPort)
 	if err != nil {
@@ -322,14 +332,20 @@ func ContainerRunnerStatus(ctx context.Context, cfg *config.Config, runnerName,
 		if strings.Contains(err.Error(), "agent 返回") {
 			agentErrType = ProbeErrorTypeAgentHTTP
 		}
-		return true, StatusUnknown, newProbeError(agentErrType, err)
+		return true, StatusUnknown, nil, newProbeError(agentErrType, err)
+	}
+	usage = &ResourceUsage{
+		InstallDirBytes: agent.InstallDirBytes,
+		WorkDirBytes:    agent.WorkDirBytes,
+		CPUPercent:      agent.CPUPercent,
+		MemoryRSSBytes:  agent.MemoryRSSBytes,
 	}
 	switch agent.Status {
 	case "installed":
-		return agent.Running, StatusInstalled, nil
+		return agent.Running, StatusInstalled, usage, nil
 	case "new":
-		return false, StatusNew, nil
+		return false, StatusNew, usage, nil
 	default:
-		return false, StatusMissing, nil
+		return false, StatusMissing, usage, nil
 	}
 }
@@ -13,6 +13,7 @@ const (
 	ProbeErrorTypeDockerAccess ProbeErrorType = "docker-access"
 	ProbeErrorTypeAgentHTTP    ProbeErrorType = "agent-http"
 	ProbeErrorTypeAgentConnect ProbeErrorType = "agent-connect"
+	ProbeErrorTypeTimeout      ProbeErrorType = "timeout" // 探测未在时限内完成
 )
 
 // ProbeError 包装底层错误并携带可机器识别的失败类型。
@@ -74,6 +75,8 @@ func ProbeSuggestion(t ProbeErrorType) string {
 		return "检查 runner 容器网络、DNS 与 Agent 端口连通性"
 	case ProbeErrorTypeAgentHTTP:
 		return "查看 runner 容器日志，确认 Agent 与 /runner 下脚本进程状态"
+	case ProbeErrorTypeTimeout:
+		return "Docker 或 Agent 响应过慢，检查宿主机负载与 runner 容器是否卡住"
 	default:
 		return "先尝试停止/启动自愈，再查看 manager 与 runner 容器日志"
 	}
@@ -88,6 +91,8 @@ func ProbeCheckCommand(t ProbeErrorType) string {
 		return "docker network inspect runner-net && docker ps --format \"table {{.Names}}\\t{{.Status}}\\t{{.Networks}}\""
 	case ProbeErrorTypeAgentHTTP:
 		return "docker ps -a | rg \"github-runner-\" && docker logs --tail=200 <runner_container_name>"
+	case ProbeErrorTypeTimeout:
+		return "time docker inspect <runner_container_name> && docker stats --no-stream"
 	default:
 		return "docker compose ps && docker logs --tail=200 runner-manager"
 	}
@@ -102,6 +107,8 @@ func ProbeFixCommand(t ProbeErrorType) string {
 		return "docker compose up -d && docker restart runner-manager"
 	case ProbeErrorTypeAgentHTTP:
 		return "docker restart <runner_container_name>"
+	case ProbeErrorTypeTimeout:
+		return "docker restart <runner_container_name>"
 	default:
 		return "docker compose up -d --force-recreate"
 	}
@@ -14,6 +14,9 @@ import (
 	"time"
 
 	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/lab-dev/github-actions-runner-manager/internal/installer"
+	"github.com/lab-dev/github-actions-runner-manager/internal/metrics"
+	"github.com/lab-dev/github-actions-runner-manager/internal/workspace"
 )
 
 // 与 handler 写入的文件名一致，供 cron 与 API 读取
@@ -34,20 +37,28 @@ const (
 
 // RunnerInfo 供前端展示的 runner 信息
 type RunnerInfo struct {
-	Name                  string     `json:"name"`
-	Path                  string     `json:"path"`
-	TargetType            string     `json:"target_type"`
-	Target                string     `json:"target"`
-	Labels                []string   `json:"labels"`
-	Status                Status     `json:"status"`
-	InstallDir            string     `json:"install_dir"`
-	Running               bool       `json:"running"`                 // 进程是否在跑
-	Probe                 *ProbeInfo `json:"probe,omitempty"`         // 结构化探测信息（error/type/suggestion/check_command/fix_command）
-	JobDockerBackend      string     `json:"job_docker_backend"`      // 容器模式下 Job 内 Docker 后端：dind / host-socket / none
-	RegistrationMessage   string     `json:"registration_message"`    // 最近一次注册结果信息（成功或失败原因）
-	RegistrationCheckedAt string     `json:"registration_checked_at"` // 注册结果时间
-	RegisteredOnGitHub    *bool      `json:"registered_on_github"`    // cron 通过 GitHub API 检查是否在 GitHub 显示，nil 表示未检查
-	GitHubCheckAt         string     `json:"github_check_at"`         // 最近一次 GitHub 检查时间
+	Name                  string            `json:"name"`
+	Path                  string            `json:"path"`
+	TargetType            string            `json:"target_type"`
+	Target                string            `json:"target"`
+	Labels                []string          `json:"labels"`
+	Status                Status            `json:"status"`
+	InstallDir            string            `json:"install_dir"`
+	Running               bool              `json:"running"`                  // 进程是否在跑
+	Probe                 *ProbeInfo        `json:"probe,omitempty"`          // 结构化探测信息（error/type/suggestion/check_command/fix_command）
+	JobDockerBackend      string            `json:"job_docker_backend"`       // 容器模式下 Job 内 Docker 后端：dind / host-socket / none
+	RegistrationSuccess   *bool             `json:"registration_success"`     // 最近一次注册是否成功，nil 表示无注册记录
+	RegistrationMessage   string            `json:"registration_message"`     // 最近一次注册结果信息（成功或失败原因）
+	RegistrationCheckedAt string            `json:"registration_checked_at"`  // 注册结果时间
+	RegisteredOnGitHub    *bool             `json:"registered_on_github"`     // cron 通过 GitHub API 检查是否在 GitHub 显示，nil 表示未检查
+	GitHubOnline          *bool             `json:"github_online"`            // GitHub 上是否 online，nil 表示未检查
+	GitHubBusy            *bool             `json:"github_busy"`              // GitHub 上是否正在执行 Job，nil 表示未检查
+	GitHubCheckAt         string            `json:"github_check_at"`          // 最近一次 GitHub 检查时间
+	Cleanup               *workspace.Result `json:"cleanup,omitempty"`        // 最近一次 _work 清理结果（含本次与累计释放字节数）
+	Usage                 *ResourceUsage    `json:"usage,omitempty"`          // 资源占用（目录大小、CPU、内存）及超阈值告警
+	RunnerVersion         string            `json:"runner_version,omitempty"` // 由 Manager 安装时记录的 actions runner 版本
+	Arch                  string            `json:"arch,omitempty"`           // runner 架构 x64/arm64/arm：安装时记录的为准，否则取配置
+	ETag                  string            `json:"etag"`                     // 配置条目的实体标签，修改时通过 If-Match 带回
 }
 
 // ProbeInfo 为容器探测失败的结构化信息。
@@ -84,13 +95,25 @@ func GetByName(cfg *config.Config, name string) *RunnerInfo {
 			info.Path = item.Name
 		}
 		info.Status, info.Running = getStatus(installDir)
-		info.RegistrationMessage, info.RegistrationCheckedAt = readRegistrationResult(installDir)
-		info.RegisteredOnGitHub, info.GitHubCheckAt = readGitHubStatus(installDir)
+		info.RegistrationSuccess, info.RegistrationMessage, info.RegistrationCheckedAt = readRegistrationResult(installDir)
+		info.applyGitHubStatus(readGitHubStatus(installDir))
+		info.Cleanup = workspace.ReadResult(installDir)
+		info.RunnerVersion = installer.InstalledVersion(installDir)
+		info.Arch = Arch(cfg, item)
+		info.ETag = item.ETag()
 		return info
 	}
 	return nil
 }
 
+// Arch 返回 runner 的架构：以安装时记录的 .runner_arch 为准，无记录（未安装或手动安装）时取配置
+func Arch(cfg *config.Config, item config.RunnerItem) string {
+	if a, ok := config.NormalizeArch(installer.InstalledArch(item.InstallPath(cfg.Runners.BasePath))); ok {
+		return a
+	}
+	return cfg.Runners.EffectiveArch(item)
+}
+
 // List 根据配置与磁盘状态列出所有 runner
 func List(cfg *config.Config) []RunnerInfo {
 	base := cfg.Runners.BasePath
@@ -112,8 +135,12 @@ func List(cfg *config.Config) []RunnerInfo {
 			info.Path = item.Name
 		}
 		info.Status, info.Running = getStatus(installDir)
-		info.RegistrationMessage, info.RegistrationCheckedAt = readRegistrationResult(installDir)
-		info.RegisteredOnGitHub, info.GitHubCheckAt = readGitHubStatus(installDir)
+		info.RegistrationSuccess, info.RegistrationMessage, info.RegistrationCheckedAt = readRegistrationResult(installDir)
+		info.applyGitHubStatus(readGitHubStatus(installDir))
+		info.Cleanup = workspace.ReadResult(installDir)
+		info.RunnerVersion = installer.InstalledVersion(installDir)
+		info.Arch = Arch(cfg, item)
+		info.ETag = item.ETag()
 		list = append(list, info)
 	}
 	return list
@@ -136,11 +163,11 @@ func getStatus(installDir string) (Status, bool) {
 	return StatusNew, false
 }
 
-// readRegistrationResult 读取 handler 写入的注册结果，返回 message 与 at
-func readRegistrationResult(installDir string) (message, at string) {
+// readRegistrationResult 读取 handler 写入的注册结果，返回是否成功（无结果时为 nil）、message 与 at
+func readRegistrationResult(installDir string) (success *bool, message, at string) {
 	b, err := os.ReadFile(filepath.Join(installDir, RegistrationResultFile))
 	if err != nil {
-		return "", ""
+		return nil, "", ""
 	}
 	var v struct {
 		Success bool   `json:"success"`
@@ -148,36 +175,49 @@ func readRegistrationResult(installDir string) (message, at string) {
 		At      string `json:"at"`
 	}
 	if json.Unmarshal(b, &v) != nil {
-		return "", ""
+		return nil, "", ""
 	}
-	return v.Message, v.At
+	return &v.Success, v.Message, v.At
 }
 
-// readGitHubStatus 读取 cron 写入的 GitHub 检查结果
-func readGitHubStatus(installDir string) (registered *bool, checkAt string) {
+// GitHubStatus cron 通过 GitHub API 得到的 runner 状态，写入 .github_status.json
+type GitHubStatus struct {
+	Registered bool   `json:"registered"`
+	Online     bool   `json:"online"` // GitHub 显示为 online
+	Busy       bool   `json:"busy"`   // GitHub 显示正在执行 Job
+	LastCheck  string `json:"last_check"`
+}
+
+// readGitHubStatus 读取 cron 写入的 GitHub 检查结果，不存在或无效返回 nil
+func readGitHubStatus(installDir string) *GitHubStatus {
 	b, err := os.ReadFile(filepath.Join(installDir, GitHubStatusFile))
 	if err != nil {
-		return nil, ""
-	}
-	var v struct {
-		Registered bool   `json:"registered"`
-		LastCheck  string `json:"last_check"`
+		return nil
 	}
+	var v GitHubStatus
 	if json.Unmarshal(b, &v) != nil {
-		return nil, ""
+		return nil
 	}
-	reg := v.Registered
-	return &reg, v.LastCheck
+	return &v
+}
+
+// applyGitHubStatus 将 GitHub 检查结果填入 info；未检查时各字段保持 nil
+func (info *RunnerInfo) applyGitHubStatus(st *GitHubStatus) {
+	if st == nil {
+		return
+	}
+	reg, online, busy := st.Registered, st.Online, st.Busy
+	info.RegisteredOnGitHub = &reg
+	info.GitHubOnline = &online
+	info.GitHubBusy = &busy
+	info.GitHubCheckAt = st.LastCheck
 }
 
-// WriteGitHubStatus 由 cron 调用，写入 GitHub 检查结果到 runner 目录
-func WriteGitHubStatus(installDir string, registered bool) error {
+// WriteGitHubStatus 由 cron 调用，写入 GitHub 检查结果到 runner 目录；LastCheck 取当前时间
+func WriteGitHubStatus(installDir string, st GitHubStatus) error {
 	p := filepath.Join(installDir, GitHubStatusFile)
-	body := struct {
-		Registered bool   `json:"registered"`
-		LastCheck  string `json:"last_check"`
-	}{Registered: registered, LastCheck: time.Now().Format(time.RFC3339)}
-	b, err := json.Marshal(body)
+	st.LastCheck = time.Now().Format(time.RFC3339)
+	b, err := json.Marshal(st)
 	if err != nil {
 		return err
 	}
@@ -306,10 +346,17 @@ func Start(installDir string) error {
 }
 
 // StartIfInstalled 若已注册则启动：容器模式调 StartRunnerContainer，否则调 Start。供 main 与 handler 统一“已注册未运行则启动”逻辑
-func StartIfInstalled(ctx context.Context, cfg *config.Config, name, installDir string) error {
+// 每次调用计入 runner_fleet_runner_start_attempts_total，失败计入 runner_fleet_runner_start_failures_total
+func StartIfInstalled(ctx context.Context, cfg *config.Config, name, installDir string) (err error) {
 	if cfg == nil {
 		return fmt.Errorf("配置为空")
 	}
+	metrics.RunnerStartAttempts.Inc(name)
+	defer func() {
+		if err != nil {
+			metrics.RunnerStartFailures.Inc(name)
+		}
+	}()
 	if cfg.Runners.ContainerMode {
 		return StartRunnerContainer(ctx, cfg, name, installDir)
 	}
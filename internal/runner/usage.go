@@ -0,0 +1,166 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/lab-dev/github-actions-runner-manager/internal/procstat"
+	"github.com/lab-dev/github-actions-runner-manager/internal/workspace"
+)
+
+// 资源占用告警项，与 config.UsageThresholds 各字段对应
+const (
+	UsageWarningInstallDir = "install_dir"
+	UsageWarningWorkDir    = "work_dir"
+	UsageWarningCPU        = "cpu"
+	UsageWarningMemory     = "memory"
+)
+
+const bytesPerMB = 1024 * 1024
+
+// ResourceUsage runner 资源占用：目录大小来自磁盘统计，CPU/内存来自进程树（进程模式/Agent）或 docker stats（容器模式）
+type ResourceUsage struct {
+	InstallDirBytes int64    `json:"install_dir_bytes"`
+	WorkDirBytes    int64    `json:"work_dir_bytes"`
+	CPUPercent      float64  `json:"cpu_percent"`      // 100 表示占满一个核
+	MemoryRSSBytes  int64    `json:"memory_rss_bytes"` // 进程树 RSS 或容器内存用量
+	Warnings        []string `json:"warnings,omitempty"`
+}
+
+// ApplyThresholds 按阈值重新计算 Warnings；t 为 nil 时清空
+func (u *ResourceUsage) ApplyThresholds(t *config.UsageThresholds) {
+	if u == nil {
+		return
+	}
+	u.Warnings = nil
+	if t == nil {
+		return
+	}
+	if t.InstallDirMB > 0 && u.InstallDirBytes > t.InstallDirMB*bytesPerMB {
+		u.Warnings = append(u.Warnings, UsageWarningInstallDir)
+	}
+	if t.WorkDirMB > 0 && u.WorkDirBytes > t.WorkDirMB*bytesPerMB {
+		u.Warnings = append(u.Warnings, UsageWarningWorkDir)
+	}
+	if t.CPUPercent > 0 && u.CPUPercent > t.CPUPercent {
+		u.Warnings = append(u.Warnings, UsageWarningCPU)
+	}
+	if t.MemoryMB > 0 && u.MemoryRSSBytes > t.MemoryMB*bytesPerMB {
+		u.Warnings = append(u.Warnings, UsageWarningMemory)
+	}
+}
+
+// cpuSampler 进程模式下 Manager 对各 runner 进程树的 CPU 采样
+var cpuSampler = procstat.NewSampler()
+
+// DirUsage 统计安装目录与 _work 大小（带短期缓存）
+func DirUsage(installDir string) *ResourceUsage {
+	u := &ResourceUsage{}
+	u.InstallDirBytes, _ = workspace.CachedDirSize(installDir)
+	u.WorkDirBytes, _ = workspace.CachedDirSize(filepath.Join(installDir, workspace.WorkDirName))
+	return u
+}
+
+// ProcessUsage 进程模式下统计 runner 的目录大小与进程树 CPU/RSS；runner 未运行时 CPU/内存为 0
+func ProcessUsage(installDir string) *ResourceUsage {
+	u := DirUsage(installDir)
+	if pid, err := readRunnerPid(installDir); err == nil && processExists(pid) {
+		u.CPUPercent, u.MemoryRSSBytes, _ = cpuSampler.Sample(pid)
+	}
+	return u
+}
+
+// ContainerStat docker stats 中单个容器的 CPU 与内存用量
+type ContainerStat struct {
+	CPUPercent  float64
+	MemoryBytes int64
+}
+
+// ContainerStats 对运行中的 Runner 容器执行一次 docker stats --no-stream，返回容器名到用量的映射
+func ContainerStats(ctx context.Context, containerNames []string) (map[string]ContainerStat, error) {
+	out := make(map[string]ContainerStat)
+	if len(containerNames) == 0 {
+		return out, nil
+	}
+	args := append([]string{"stats", "--no-stream", "--format", "{{.Name}}\t{{.CPUPerc}}\t{{.MemUsage}}"}, containerNames...)
+	raw, err := dockerCmd(ctx, args...)
+	if err != nil {
+		return nil, dockerCmdError("docker stats", raw, err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(raw)), "\n") {
+		f := strings.Split(line, "\t")
+		if len(f) != 3 {
+			continue
+		}
+		var st ContainerStat
+		st.CPUPercent, _ = strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(f[1]), "%"), 64)
+		// MemUsage 形如 "12.5MiB / 7.6GiB"，取已用部分
+		used, _, _ := strings.Cut(f[2], "/")
+		st.MemoryBytes, _ = parseDockerSize(used)
+		out[strings.TrimSpace(f[0])] = st
+	}
+	return out, nil
+}
+
+// parseDockerSize 解析 docker 输出的容量（如 12.5MiB、1.2GB、512kB、0B）
+func parseDockerSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	units := []struct {
+		suffix string
+		mult   float64
+	}{
+		{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+		{"kB", 1e3}, {"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+		{"B", 1},
+	}
+	for _, u := range units {
+		if num, ok := strings.CutSuffix(s, u.suffix); ok {
+			v, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+			if err != nil {
+				return 0, err
+			}
+			return int64(v * u.mult), nil
+		}
+	}
+	return 0, fmt.Errorf("无法解析容量: %q", s)
+}
+
+// HasWarning 供模板判断某项是否超过阈值
+func (u *ResourceUsage) HasWarning(kind string) bool {
+	if u == nil {
+		return false
+	}
+	for _, w := range u.Warnings {
+		if w == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatBytes 以 1024 进制格式化字节数（如 1.5 GiB），供界面展示
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// InstallDirSize 格式化后的安装目录大小
+func (u *ResourceUsage) InstallDirSize() string { return FormatBytes(u.InstallDirBytes) }
+
+// WorkDirSize 格式化后的 _work 大小
+func (u *ResourceUsage) WorkDirSize() string { return FormatBytes(u.WorkDirBytes) }
+
+// MemorySize 格式化后的内存用量
+func (u *ResourceUsage) MemorySize() string { return FormatBytes(u.MemoryRSSBytes) }
@@ -0,0 +1,47 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+)
+
+func TestParseDockerSize(t *testing.T) {
+	cases := map[string]int64{
+		"0B":       0,
+		"512kB":    512000,
+		"12.5MiB ": 13107200,
+		"1GiB":     1 << 30,
+		"1.5GB":    1500000000,
+	}
+	for in, want := range cases {
+		got, err := parseDockerSize(in)
+		if err != nil || got != want {
+			t.Errorf("parseDockerSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	if _, err := parseDockerSize("lots"); err == nil {
+		t.Error("expected error for unparseable size")
+	}
+}
+
+func TestResourceUsage_ApplyThresholds(t *testing.T) {
+	u := &ResourceUsage{InstallDirBytes: 300 * bytesPerMB, WorkDirBytes: 50 * bytesPerMB, CPUPercent: 120, MemoryRSSBytes: 2048 * bytesPerMB}
+	u.ApplyThresholds(&config.UsageThresholds{InstallDirMB: 200, WorkDirMB: 100, CPUPercent: 100})
+	if !u.HasWarning(UsageWarningInstallDir) || u.HasWarning(UsageWarningWorkDir) || !u.HasWarning(UsageWarningCPU) || u.HasWarning(UsageWarningMemory) {
+		t.Errorf("unexpected warnings: %v", u.Warnings)
+	}
+	u.ApplyThresholds(nil)
+	if len(u.Warnings) != 0 {
+		t.Errorf("nil thresholds should clear warnings, got %v", u.Warnings)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	cases := map[int64]string{0: "0 B", 1023: "1023 B", 1536: "1.5 KiB", 3 << 30: "3.0 GiB"}
+	for in, want := range cases {
+		if got := FormatBytes(in); got != want {
+			t.Errorf("FormatBytes(%d) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+
+package workspace
+
+import "syscall"
+
+// DiskUsagePercent 返回 path 所在文件系统的已用百分比
+func DiskUsagePercent(path string) (float64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	total := float64(st.Blocks) * float64(st.Bsize)
+	if total <= 0 {
+		return 0, nil
+	}
+	avail := float64(st.Bavail) * float64(st.Bsize)
+	return (total - avail) / total * 100, nil
+}
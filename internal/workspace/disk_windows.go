@@ -0,0 +1,10 @@
+//go:build windows
+
+package workspace
+
+import "errors"
+
+// DiskUsagePercent Windows 上暂不支持磁盘使用率检测，max_disk_usage_percent 不生效
+func DiskUsagePercent(path string) (float64, error) {
+	return 0, errors.New("windows 暂不支持磁盘使用率检测")
+}
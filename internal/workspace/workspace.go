@@ -0,0 +1,242 @@
+// Package workspace 负责 runner _work 目录的清理：按策略（Job 结束后、超过大小/磁盘阈值、cron 定时）在 runner 空闲时清空 _work。
+// 容器模式下由 Runner 容器内 Agent 执行（策略由 Manager 写入 runner 目录下 .cleanup_policy.json），进程模式下由 Manager 执行；
+// 每次清理结果写入 .cleanup_result.json，供 API 展示释放的字节数。
+package workspace
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/lab-dev/github-actions-runner-manager/internal/cron"
+	"github.com/lab-dev/github-actions-runner-manager/internal/jobhistory"
+)
+
+// 与 runner 目录下其它状态文件同级
+const (
+	WorkDirName       = "_work"
+	PolicyFile        = ".cleanup_policy.json"
+	ResultFile        = ".cleanup_result.json"
+	bytesPerMegabyte  = 1024 * 1024
+	scheduleMatchSkew = time.Minute
+	// diskUsageBackoff 距上次清理不足该时长时不按磁盘占用触发：清空 _work 后占用仍超阈值说明空间被其它文件占用，
+	// 每分钟重复清理无济于事
+	diskUsageBackoff = 30 * time.Minute
+)
+
+// 触发原因
+const (
+	ReasonAfterJob  = "after_job"
+	ReasonWorkSize  = "work_size"
+	ReasonDiskUsage = "disk_usage"
+	ReasonSchedule  = "schedule"
+)
+
+// Result 清理结果，累计字段跨多次清理保留
+type Result struct {
+	LastAt          string `json:"last_at"`
+	LastReason      string `json:"last_reason"`
+	LastBytesFreed  int64  `json:"last_bytes_freed"`
+	TotalBytesFreed int64  `json:"total_bytes_freed"`
+	Runs            int    `json:"runs"`
+	LastError       string `json:"last_error,omitempty"`
+}
+
+// DirSize 递归统计目录大小（字节），目录不存在时返回 0
+func DirSize(dir string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.Type().IsRegular() {
+			if fi, err := d.Info(); err == nil {
+				total += fi.Size()
+			}
+		}
+		return nil
+	})
+	return total, err
+}
+
+// sizeCacheTTL 目录大小缓存时长：大型 _work 遍历耗时，状态接口在此期间复用结果
+const sizeCacheTTL = time.Minute
+
+var (
+	sizeCacheMu sync.Mutex
+	sizeCache   = map[string]cachedSize{}
+)
+
+type cachedSize struct {
+	at   time.Time
+	size int64
+}
+
+// CachedDirSize 同 DirSize，但在 sizeCacheTTL 内复用上次结果
+func CachedDirSize(dir string) (int64, error) {
+	sizeCacheMu.Lock()
+	c, ok := sizeCache[dir]
+	sizeCacheMu.Unlock()
+	if ok && time.Since(c.at) < sizeCacheTTL {
+		return c.size, nil
+	}
+	size, err := DirSize(dir)
+	if err != nil {
+		return 0, err
+	}
+	sizeCacheMu.Lock()
+	sizeCache[dir] = cachedSize{at: time.Now(), size: size}
+	sizeCacheMu.Unlock()
+	return size, nil
+}
+
+// Clean 删除 installDir/_work 下的全部内容（保留 _work 目录本身），返回释放的字节数
+func Clean(installDir string) (int64, error) {
+	work := filepath.Join(installDir, WorkDirName)
+	entries, err := os.ReadDir(work)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	var freed int64
+	var errs []error
+	for _, e := range entries {
+		p := filepath.Join(work, e.Name())
+		size, _ := DirSize(p)
+		if err := os.RemoveAll(p); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		freed += size
+	}
+	return freed, errors.Join(errs...)
+}
+
+// ReadResult 读取 runner 目录下的清理结果，不存在返回 nil
+func ReadResult(installDir string) *Result {
+	b, err := os.ReadFile(filepath.Join(installDir, ResultFile))
+	if err != nil {
+		return nil
+	}
+	var r Result
+	if json.Unmarshal(b, &r) != nil {
+		return nil
+	}
+	return &r
+}
+
+func writeResult(installDir string, r *Result) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(installDir, ResultFile), b, 0644)
+}
+
+// WritePolicy 将策略写入 runner 目录供 Agent 读取；policy 未启用时删除文件
+func WritePolicy(installDir string, policy *config.CleanupPolicy) error {
+	p := filepath.Join(installDir, PolicyFile)
+	if !policy.Enabled() {
+		err := os.Remove(p)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	b, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+	if old, err := os.ReadFile(p); err == nil && string(old) == string(b) {
+		return nil
+	}
+	return os.WriteFile(p, b, 0644)
+}
+
+// ReadPolicy 读取 Manager 写入的策略，不存在或无效返回 nil
+func ReadPolicy(installDir string) *config.CleanupPolicy {
+	b, err := os.ReadFile(filepath.Join(installDir, PolicyFile))
+	if err != nil {
+		return nil
+	}
+	var p config.CleanupPolicy
+	if json.Unmarshal(b, &p) != nil {
+		return nil
+	}
+	return &p
+}
+
+// reason 判断当前是否需要清理，返回触发原因；无需清理返回空
+func reason(installDir string, policy *config.CleanupPolicy, last *Result, now time.Time) string {
+	var lastAt time.Time
+	if last != nil {
+		lastAt, _ = time.Parse(time.RFC3339, last.LastAt)
+	}
+	if policy.AfterJob {
+		if done := jobhistory.LastCompletedAt(installDir); !done.IsZero() && done.After(lastAt) {
+			return ReasonAfterJob
+		}
+	}
+	if policy.MaxWorkSizeMB > 0 {
+		if size, err := DirSize(filepath.Join(installDir, WorkDirName)); err == nil && size > policy.MaxWorkSizeMB*bytesPerMegabyte {
+			return ReasonWorkSize
+		}
+	}
+	if policy.MaxDiskUsagePercent > 0 && now.Sub(lastAt) >= diskUsageBackoff {
+		if pct, err := DiskUsagePercent(installDir); err == nil && pct > float64(policy.MaxDiskUsagePercent) {
+			return ReasonDiskUsage
+		}
+	}
+	if s := strings.TrimSpace(policy.Schedule); s != "" {
+		// 同一分钟内只执行一次
+		if sched, err := cron.Parse(s); err == nil && sched.Match(now) && now.Sub(lastAt) >= scheduleMatchSkew {
+			return ReasonSchedule
+		}
+	}
+	return ""
+}
+
+// Tick 按策略检查并在需要时清理，应每分钟调用一次；runner 正在执行 Job 时跳过。
+// 发生清理时返回最新结果，否则返回 nil。
+func Tick(installDir string, policy *config.CleanupPolicy, now time.Time) (*Result, error) {
+	if !policy.Enabled() {
+		return nil, nil
+	}
+	if jobhistory.Busy(installDir) {
+		return nil, nil
+	}
+	last := ReadResult(installDir)
+	why := reason(installDir, policy, last, now)
+	if why == "" {
+		return nil, nil
+	}
+	freed, cleanErr := Clean(installDir)
+	r := &Result{}
+	if last != nil {
+		*r = *last
+	}
+	r.LastAt = now.Format(time.RFC3339)
+	r.LastReason = why
+	r.LastBytesFreed = freed
+	r.TotalBytesFreed += freed
+	r.Runs++
+	r.LastError = ""
+	if cleanErr != nil {
+		r.LastError = cleanErr.Error()
+	}
+	if err := writeResult(installDir, r); err != nil {
+		return r, err
+	}
+	return r, cleanErr
+}
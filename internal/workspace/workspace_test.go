@@ -0,0 +1,143 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lab-dev/github-actions-runner-manager/internal/config"
+	"github.com/lab-dev/github-actions-runner-manager/internal/jobhistory"
+)
+
+func writeFile(t *testing.T, p string, size int) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, make([]byte, size), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClean(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, WorkDirName, "repo", "repo", "a.bin"), 1000)
+	writeFile(t, filepath.Join(dir, WorkDirName, "_temp", "b.bin"), 24)
+	writeFile(t, filepath.Join(dir, ".runner"), 10)
+	freed, err := Clean(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if freed != 1024 {
+		t.Errorf("freed = %d, want 1024", freed)
+	}
+	entries, _ := os.ReadDir(filepath.Join(dir, WorkDirName))
+	if len(entries) != 0 {
+		t.Errorf("expected empty _work, got %d entries", len(entries))
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".runner")); err != nil {
+		t.Error(".runner outside _work must be kept")
+	}
+}
+
+func TestTick_WorkSizeThreshold(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, WorkDirName, "big.bin"), 2*bytesPerMegabyte)
+	res, err := Tick(dir, &config.CleanupPolicy{MaxWorkSizeMB: 1}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res == nil || res.LastReason != ReasonWorkSize || res.LastBytesFreed != 2*bytesPerMegabyte || res.Runs != 1 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	// 已低于阈值，不应再次清理
+	res, _ = Tick(dir, &config.CleanupPolicy{MaxWorkSizeMB: 1}, time.Now())
+	if res != nil {
+		t.Errorf("expected no cleanup below threshold, got %+v", res)
+	}
+	if got := ReadResult(dir); got == nil || got.TotalBytesFreed != 2*bytesPerMegabyte {
+		t.Errorf("persisted result mismatch: %+v", got)
+	}
+}
+
+func TestTick_SkipsWhileBusy(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, WorkDirName, "big.bin"), 2*bytesPerMegabyte)
+	logPath := filepath.Join(dir, jobhistory.DiagDirName, "Worker_20260303-100000-utc.log")
+	if err := os.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(logPath, []byte("[2026-03-03 10:00:00Z INFO Terminal] WRITE LINE: Running job: build\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Tick(dir, &config.CleanupPolicy{MaxWorkSizeMB: 1}, time.Now())
+	if err != nil || res != nil {
+		t.Fatalf("expected skip while job running, got res=%+v err=%v", res, err)
+	}
+	// Job 结束后 after_job 与大小阈值均可触发
+	if err := os.WriteFile(logPath, []byte("[2026-03-03 10:00:00Z INFO Terminal] WRITE LINE: Running job: build\n[2026-03-03 10:01:00Z INFO JobRunner] Job result after all job steps finish: Succeeded\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	res, err = Tick(dir, &config.CleanupPolicy{AfterJob: true}, time.Now())
+	if err != nil || res == nil || res.LastReason != ReasonAfterJob {
+		t.Fatalf("expected after_job cleanup, got res=%+v err=%v", res, err)
+	}
+	res, _ = Tick(dir, &config.CleanupPolicy{AfterJob: true}, time.Now())
+	if res != nil {
+		t.Errorf("after_job must run once per finished job, got %+v", res)
+	}
+}
+
+func TestTick_Schedule(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 3, 1, 3, 0, 0, 0, time.Local)
+	policy := &config.CleanupPolicy{Schedule: "0 3 * * *"}
+	res, err := Tick(dir, policy, now)
+	if err != nil || res == nil || res.LastReason != ReasonSchedule {
+		t.Fatalf("expected scheduled cleanup, got res=%+v err=%v", res, err)
+	}
+	if res, _ := Tick(dir, policy, now.Add(20*time.Second)); res != nil {
+		t.Errorf("expected one run per matching minute, got %+v", res)
+	}
+	if res, _ := Tick(dir, policy, now.Add(time.Hour)); res != nil {
+		t.Errorf("expected no run outside schedule, got %+v", res)
+	}
+}
+
+func TestTick_DiskUsageBackoff(t *testing.T) {
+	dir := t.TempDir()
+	pct, err := DiskUsagePercent(dir)
+	if err != nil || pct < 1 {
+		t.Skipf("disk usage unavailable: %v %v", pct, err)
+	}
+	policy := &config.CleanupPolicy{MaxDiskUsagePercent: 1}
+	now := time.Now()
+	res, err := Tick(dir, policy, now)
+	if err != nil || res == nil || res.LastReason != ReasonDiskUsage {
+		t.Fatalf("expected disk_usage cleanup, got res=%+v err=%v", res, err)
+	}
+	// 清理后占用仍超阈值，退避期内不再重复清理
+	if res, _ := Tick(dir, policy, now.Add(time.Minute)); res != nil {
+		t.Errorf("expected backoff after disk_usage cleanup, got %+v", res)
+	}
+	if res, _ := Tick(dir, policy, now.Add(diskUsageBackoff)); res == nil || res.Runs != 2 {
+		t.Errorf("expected cleanup after backoff, got %+v", res)
+	}
+}
+
+func TestWriteAndReadPolicy(t *testing.T) {
+	dir := t.TempDir()
+	if err := WritePolicy(dir, &config.CleanupPolicy{AfterJob: true}); err != nil {
+		t.Fatal(err)
+	}
+	if p := ReadPolicy(dir); p == nil || !p.AfterJob {
+		t.Fatalf("unexpected policy: %+v", p)
+	}
+	if err := WritePolicy(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	if p := ReadPolicy(dir); p != nil {
+		t.Errorf("expected policy file removed, got %+v", p)
+	}
+}
@@ -1,33 +1,75 @@
 #!/bin/sh
 # 在 RUNNERS_BASE_PATH 下创建指定名称的目录，下载并解压 GitHub Actions runner。
 # 用法: install-runner.sh <runner_name> [version]
-# 默认版本 2.331.0，与官方文档一致；可选校验默认版本的哈希。
+# 默认版本 2.331.0，与官方文档一致。下载后总是校验 SHA-256：默认版本 linux-x64 使用内置值，
+# 其他版本与架构用 RUNNER_SHA256 指定；设置 RUNNER_CHECKSUM_FROM_RELEASE_NOTES=1 时改为读取 actions/runner 发布说明中公布的值。
+# 安装包缓存在 RUNNER_CACHE_DIR（默认 RUNNERS_BASE_PATH/.cache），多次手动安装同一版本时只下载一次。
+# 该脚本仅供手动安装使用：Manager 不调用它，而是由内置安装器（internal/installer）下载并校验，
+# 二者共用 .cache 目录与文件名，flock 只在脚本的并行调用之间生效，Manager 读取缓存时会重新校验哈希。
+# 架构取 ARCH（x64/arm64/arm，兼容 amd64、aarch64），未设置时按 uname -m 检测。
 
 set -e
 
 RUNNER_NAME="${1:?用法: install-runner.sh <runner_name> [version]}"
 VERSION="${2:-2.331.0}"
 BASE="${RUNNERS_BASE_PATH:-/app/runners}"
-TARBALL="actions-runner-linux-x64-${VERSION}.tar.gz"
+ARCH="${ARCH:-$(uname -m)}"
+case "$ARCH" in
+  x64|amd64|x86_64) ARCH=x64 ;;
+  arm64|aarch64) ARCH=arm64 ;;
+  arm|armv7|armv7l|armhf) ARCH=arm ;;
+  *) echo "不支持的架构: ${ARCH}（可用 ARCH=x64|arm64|arm 指定）" >&2; exit 1 ;;
+esac
+TARBALL="actions-runner-linux-${ARCH}-${VERSION}.tar.gz"
 URL="https://github.com/actions/runner/releases/download/v${VERSION}/${TARBALL}"
+CACHE_DIR="${RUNNER_CACHE_DIR:-${BASE}/.cache}"
+CACHED="${CACHE_DIR}/${TARBALL}"
 
-# 默认版本 2.331.0 的官方校验哈希（可选校验）
+# 默认版本 2.331.0 linux-x64 的官方校验哈希
 HASH_2_331="5fcc01bd546ba5c3f1291c2803658ebd3cedb3836489eda3be357d41bfcf28a7"
 
+# expected_hash 输出安装包应有的 SHA-256：RUNNER_SHA256 > 内置值 > 发布说明中的 <!-- BEGIN SHA linux-<arch> --> 标记（需显式开启）
+expected_hash() {
+  if [ -n "$RUNNER_SHA256" ]; then
+    echo "$RUNNER_SHA256"
+  elif [ "$VERSION" = "2.331.0" ] && [ "$ARCH" = "x64" ]; then
+    echo "$HASH_2_331"
+  elif [ "$RUNNER_CHECKSUM_FROM_RELEASE_NOTES" = "1" ]; then
+    curl -fsSL -H "Accept: application/vnd.github+json" "https://api.github.com/repos/actions/runner/releases/tags/v${VERSION}" \
+      | sed -n "s/.*<!-- BEGIN SHA linux-${ARCH} -->\([0-9a-fA-F]\{64\}\)<!-- END SHA.*/\1/p" | head -n 1
+  fi
+}
+
 INSTALL_DIR="${BASE}/${RUNNER_NAME}"
-mkdir -p "$INSTALL_DIR"
-cd "$INSTALL_DIR"
+mkdir -p "$INSTALL_DIR" "$CACHE_DIR"
 
-echo "下载 ${TARBALL} ..."
-curl -o "$TARBALL" -L "$URL"
+# 同一版本的下载加锁（有 flock 时），并行安装时只有一个进程下载，其余等待后直接使用缓存
+exec 9>"${CACHED}.lock"
+if command -v flock >/dev/null 2>&1; then
+  flock 9
+fi
 
-if [ "$VERSION" = "2.331.0" ]; then
+if [ -f "$CACHED" ]; then
+  echo "使用缓存 ${CACHED}"
+else
+  # 先下载到临时文件再 rename，并行安装时其他进程不会读到不完整的安装包
+  TMP="${CACHED}.$$.tmp"
+  trap 'rm -f "$TMP"' EXIT
+  echo "下载 ${TARBALL} ..."
+  HASH="$(expected_hash || true)"
+  if [ -z "$HASH" ]; then
+    echo "未能取得 ${TARBALL} 的 SHA-256，请用 RUNNER_SHA256=<sha256> 指定（见发布页），或设置 RUNNER_CHECKSUM_FROM_RELEASE_NOTES=1 读取发布说明" >&2
+    exit 1
+  fi
+  curl -fsSL -o "$TMP" "$URL"
   echo "校验哈希..."
-  echo "${HASH_2_331}  ${TARBALL}" | sha256sum -c
+  echo "${HASH}  ${TMP}" | sha256sum -c
+  mv -f "$TMP" "$CACHED"
 fi
+exec 9>&-
 
 echo "解压..."
-tar xzf "$TARBALL"
-rm -f "$TARBALL"
+tar xzf "$CACHED" -C "$INSTALL_DIR"
+echo "$ARCH" > "${INSTALL_DIR}/.runner_arch"
 echo "完成。安装目录: ${INSTALL_DIR}"
 echo "请在管理界面「快速添加 Runner」中填写名称: ${RUNNER_NAME}、目标与 Token 完成注册。"